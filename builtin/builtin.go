@@ -24,6 +24,7 @@ var (
 			"image":                 codegen.Image{},
 			"http":                  codegen.HTTP{},
 			"git":                   codegen.Git{},
+			"oci":                   codegen.OCI{},
 			"local":                 codegen.Local{},
 			"frontend":              codegen.Frontend{},
 			"run":                   codegen.Run{},
@@ -57,12 +58,18 @@ var (
 			"localEnv":  codegen.LocalEnv{},
 			"localRun":  codegen.LocalRun{},
 		},
+		parser.Bool: map[string]parser.Callable{
+			"equals":   codegen.Equals{},
+			"notEmpty": codegen.NotEmpty{},
+			"not":      codegen.Not{},
+		},
 		parser.Pipeline: map[string]parser.Callable{
 			"stage":    codegen.Stage{},
 			"parallel": codegen.Stage{},
 		},
 		"option::image": map[string]parser.Callable{
-			"resolve": codegen.Resolve{},
+			"resolve":   codegen.Resolve{},
+			"cacheFrom": codegen.CacheFrom{},
 		},
 		"option::http": map[string]parser.Callable{
 			"checksum": codegen.Checksum{},
@@ -140,6 +147,15 @@ var (
 			"chmod":              codegen.UtilChmod{},
 			"createdTime":        codegen.UtilCreatedTime{},
 		},
+		"option::dockerPush": map[string]parser.Callable{
+			"cacheTo": codegen.CacheTo{},
+		},
+		"option::dockerLoad": map[string]parser.Callable{
+			"cacheTo": codegen.CacheTo{},
+		},
+		"option::download": map[string]parser.Callable{
+			"cacheTo": codegen.CacheTo{},
+		},
 		"option::localRun": map[string]parser.Callable{
 			"ignoreError":   codegen.IgnoreError{},
 			"onlyStderr":    codegen.OnlyStderr{},
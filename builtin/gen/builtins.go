@@ -22,7 +22,7 @@ import (
 type BuiltinData struct {
 	Command     string
 	FuncsByKind map[parser.Kind][]ParsedFunc
-	Reference   string
+	Reference   template.HTML
 }
 
 type ParsedFunc struct {
@@ -63,7 +63,7 @@ func GenerateBuiltins(ctx context.Context, r io.Reader) ([]byte, error) {
 	data := BuiltinData{
 		Command:     fmt.Sprintf("builtingen %s", strings.Join(os.Args[1:], " ")),
 		FuncsByKind: funcsByKind,
-		Reference:   fmt.Sprintf("`%s`", string(fb.Bytes())),
+		Reference:   template.HTML(fmt.Sprintf("`%s`", string(fb.Bytes()))),
 	}
 
 	var buf bytes.Buffer
@@ -0,0 +1,37 @@
+package gen
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateBuiltins(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open("../../language/builtin.hlb")
+	require.NoError(t, err)
+	defer f.Close()
+
+	actual, err := GenerateBuiltins(context.Background(), f)
+	require.NoError(t, err)
+
+	// The reference is embedded as a raw string, so the doc comments of the
+	// builtins are not HTML escaped.
+	require.NotContains(t, string(actual), "&#39;")
+	require.Contains(t, string(actual), "image's filesystem")
+
+	// The generated lookup is up to date, apart from the command that
+	// generated it.
+	expected, err := ioutil.ReadFile("../lookup.go")
+	require.NoError(t, err)
+
+	skipCommand := func(dt []byte) string {
+		return string(dt[bytes.IndexByte(dt, '\n'):])
+	}
+	require.Equal(t, skipCommand(expected), skipCommand(actual))
+}
@@ -20,6 +20,29 @@ type FuncLookup struct {
 var (
 	Lookup = BuiltinLookup{
 		ByKind: map[parser.Kind]LookupByKind{
+			parser.Bool: LookupByKind{
+				Func: map[string]FuncLookup{
+					"equals": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.String, "a", false),
+							parser.NewField(parser.String, "b", false),
+						},
+						Effects: []*parser.Field{},
+					},
+					"notEmpty": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.String, "value", false),
+						},
+						Effects: []*parser.Field{},
+					},
+					"not": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Bool, "value", false),
+						},
+						Effects: []*parser.Field{},
+					},
+				},
+			},
 			parser.Filesystem: LookupByKind{
 				Func: map[string]FuncLookup{
 					"scratch": FuncLookup{
@@ -38,6 +61,12 @@ var (
 						},
 						Effects: []*parser.Field{},
 					},
+					"oci": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.String, "ref", false),
+						},
+						Effects: []*parser.Field{},
+					},
 					"git": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.String, "remote", false),
@@ -241,6 +270,39 @@ var (
 					},
 				},
 			},
+			"option::dockerLoad": LookupByKind{
+				Func: map[string]FuncLookup{
+					"cacheTo": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.String, "type", false),
+							parser.NewField(parser.String, "ref", false),
+						},
+						Effects: []*parser.Field{},
+					},
+				},
+			},
+			"option::dockerPush": LookupByKind{
+				Func: map[string]FuncLookup{
+					"cacheTo": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.String, "type", false),
+							parser.NewField(parser.String, "ref", false),
+						},
+						Effects: []*parser.Field{},
+					},
+				},
+			},
+			"option::download": LookupByKind{
+				Func: map[string]FuncLookup{
+					"cacheTo": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.String, "type", false),
+							parser.NewField(parser.String, "ref", false),
+						},
+						Effects: []*parser.Field{},
+					},
+				},
+			},
 			"option::frontend": LookupByKind{
 				Func: map[string]FuncLookup{
 					"input": FuncLookup{
@@ -295,6 +357,13 @@ var (
 						Params:  []*parser.Field{},
 						Effects: []*parser.Field{},
 					},
+					"cacheFrom": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.String, "type", false),
+							parser.NewField(parser.String, "ref", false),
+						},
+						Effects: []*parser.Field{},
+					},
 				},
 			},
 			"option::local": LookupByKind{
@@ -653,7 +722,7 @@ var (
 # @return a scratch filesystem.
 fs scratch()
 
-# An OCI image&#39;s filesystem.
+# An OCI image's filesystem.
 #
 # @param ref a docker registry reference. if not fully qualified, it will be 
 # expanded the same as the docker CLI.
@@ -663,9 +732,18 @@ fs image(string ref)
 # Resolves the OCI Image Config and inherit its environment, working directory,
 # and entrypoint.
 #
-# @return an option to resolve the image&#39;s OCI image config.
+# @return an option to resolve the image's OCI image config.
 option::image resolve()
 
+# Imports a build cache exported by cacheTo, so that steps already built
+# elsewhere are reused.
+#
+# @param type the type of cache, one of "local", "inline" or "registry".
+# @param ref a local directory for local caches, or a distribution reference
+# for inline and registry caches.
+# @return an option to import the build cache.
+option::image cacheFrom(string type, string ref)
+
 # A filesystem with a file retrieved from a HTTP URL.
 #
 # @param url a fully-qualified URL to send a HTTP GET request.
@@ -691,17 +769,25 @@ option::http chmod(int filemode)
 # @return an option to provide a name for the file.
 option::http filename(string name)
 
+# A filesystem with the files of an HLB module published as an OCI artifact by
+# "hlb module publish". It can also be used to import the module.
+#
+# @param ref a docker registry reference, or the path to an OCI image layout
+# prefixed with "oci-layout://" and optionally followed by a tag.
+# @return a filesystem with the files of the module.
+fs oci(string ref)
+
 # A filesystem with the files from a git repository checked out from
-# a git reference. Note that by default, the &#34;.git&#34; directory is not included.
+# a git reference. Note that by default, the ".git" directory is not included.
 #
 # @param remote the fully qualified git remote.
 # @param ref the git reference to check out.
 # @return a filesystem containing files from a git repository.
 fs git(string remote, string ref)
 
-# Keeps the &#34;.git&#34; directory of the git repository.
+# Keeps the ".git" directory of the git repository.
 #
-# @return the option to keep the &#34;.git&#34; directory.
+# @return the option to keep the ".git" directory.
 option::git keepGitDir()
 
 # A filesystem with the files synced up from a file or directory on the local
@@ -722,7 +808,7 @@ option::local includePatterns(variadic string pattern)
 # path is for a file, then exclude patterns are ignored.
 #
 # @param pattern a list of patterns for files that should not be synced.
-# @return an option to sync files that don&#39;t match any pattern.
+# @return an option to sync files that don't match any pattern.
 option::local excludePatterns(variadic string pattern)
 
 # Sync the targets of symlinks if path is to a symlink.
@@ -754,10 +840,10 @@ option::frontend input(string key, fs value)
 # @return an option to provide a key value pair to the external frontend.
 option::frontend opt(string key, string value)
 
-# Sets the current shell command to use when executing subsequent &#34;run&#34;
-# methods. By default, this is [&#34;sh&#34;, &#34;-c&#34;].
+# Sets the current shell command to use when executing subsequent "run"
+# methods. By default, this is ["sh", "-c"].
 #
-# @param arg the list of args used to prefix &#34;run&#34; statements.
+# @param arg the list of args used to prefix "run" statements.
 # @return the filesystem with a new default shell.
 fs shell(variadic string arg)
 
@@ -765,7 +851,7 @@ fs shell(variadic string arg)
 #
 # If no arguments are given, it will execute the current args set on the
 # filesystem.
-# If exactly one arg is given it will be wrapped with /bin/sh -c &#39;arg&#39;.
+# If exactly one arg is given it will be wrapped with /bin/sh -c 'arg'.
 # If more than one arg is given, it will be executed directly, without a shell.
 #
 # @param arg are optional arguments to execute.
@@ -802,18 +888,18 @@ option::run user(string name)
 option::run ignoreCache()
 
 # Sets the networking mode for the duration of the run command. By default, the
-# value is &#34;unset&#34; (using BuildKit&#39;s CNI provider, otherwise its host
+# value is "unset" (using BuildKit's CNI provider, otherwise its host
 # namespace).
 #
 # @param networkmode the network mode of the container, must be one of the
 # following:
 # - unset: use the default network provider.
-# - host: use the host&#39;s network namespace.
+# - host: use the host's network namespace.
 # - none: disable networking.
 option::run network(string networkmode)
 
 # Sets the security mode for the duration of the run command. By default, the
-# value is &#34;sandbox&#34;.
+# value is "sandbox".
 #
 # @param securitymode the security mode of the container, must be one of the
 # following:
@@ -821,10 +907,10 @@ option::run network(string networkmode)
 # - insecure: enables all capabilities.
 option::run security(string securitymode)
 
-# Attempt to lex the single-argument shell command provided to &#34;run&#34;
-# to determine if a &#34;/bin/sh -c &#39;...&#39;&#34; wrapper needs to be added.
+# Attempt to lex the single-argument shell command provided to "run"
+# to determine if a "/bin/sh -c '...'" wrapper needs to be added.
 #
-# @return an option to attempt to optimize the command execution remoiving the /bin/sh -c &#34;...&#34; wrapper when possible.
+# @return an option to attempt to optimize the command execution remoiving the /bin/sh -c "..." wrapper when possible.
 option::run shlex()
 
 # Adds a host entry to /etc/hosts for the duration of the run command.
@@ -836,7 +922,7 @@ option::run host(string hostname, string address)
 
 # Mounts a SSH socket for the duration of the run command. By default, it will
 # try to use the SSH socket found from $SSH_AUTH_SOCK. Otherwise, an option
-# &#34;localPath&#34; can be provided to specify a filepath to a SSH auth socket or
+# "localPath" can be provided to specify a filepath to a SSH auth socket or
 # *.pem file.
 #
 # @return an option to mount a SSH socket.
@@ -844,7 +930,7 @@ option::run ssh()
 
 # Forwards traffic to/from a local source to a unix domain socket mounted for
 # the duration of the run command. The source must be a fully qualified URI
-# where the scheme must be either &#34;unix://&#34; or &#34;tcp://&#34;.
+# where the scheme must be either "unix://" or "tcp://".
 #
 # @param src a fully qualified URI to forward traffic to/from.
 # @param dest a mountpoint for a unix domain socket that is forwarded to/from.
@@ -861,7 +947,7 @@ option::run secret(string localPath, string mountPoint)
 
 # Attaches an additional filesystem for the duration of the run command.
 #
-# @param input the additional filesystem to mount. the input&#39;s root filesystem
+# @param input the additional filesystem to mount. the input's root filesystem
 # becomes available from the mountPoint directory.
 # @param mountPoint the directory where the mount is attached.
 # @param target the output filesystem after run executes
@@ -869,7 +955,7 @@ option::run secret(string localPath, string mountPoint)
 option::run mount(fs input, string mountPoint) binds (fs target)
 
 # Sets the target directory to mount the SSH agent socket. By default, it is
-# mounted to &#34;/run/buildkit/ssh_agent.${N}&#34;, where N is the index of the 
+# mounted to "/run/buildkit/ssh_agent.${N}", where N is the index of the 
 # socket. If $SSH_AUTH_SOCK is not set, it will set SSH_AUTH_SOCK to the
 # mountPoint.
 #
@@ -934,7 +1020,7 @@ option::secret includePatterns(variadic string pattern)
 # Attach secrets only for files that do not match any of the excluded patterns.
 #
 # @param pattern a list of patterns for files that should not be attached as secrets
-# @return an option to attach files that don&#39;t match any pattern.
+# @return an option to attach files that don't match any pattern.
 option::secret excludePatterns(variadic string pattern)
 
 # Sets the mount to be attached as a read-only filesystem.
@@ -959,11 +1045,11 @@ option::mount sourcePath(string path)
 #
 # Compilers and package managers commonly have an option to specify cache
 # directories. Depending on their implementation, it may be safe to share the
-# cache with concurrent processes. This is adjusted via the &#34;sharingmode&#34;
+# cache with concurrent processes. This is adjusted via the "sharingmode"
 # argument.
 #
 # The cache is modified every time the parent run command is executed. A cache
-# could also be managed by not using the &#34;cache&#34; option. Instead, the mount can
+# could also be managed by not using the "cache" option. Instead, the mount can
 # be aliased, and then pushed as an image, so that there it can be a stable
 # snapshot, or updated externally.
 #
@@ -1003,7 +1089,7 @@ fs user(string name)
 # @return a filesystem with a new directory.
 fs mkdir(string path, int filemode)
 
-# Create the parent directories if they don&#39;t exist already.
+# Create the parent directories if they don't exist already.
 #
 # @return an option to create parent directories.
 option::mkdir createParents()
@@ -1069,19 +1155,19 @@ fs copy(fs input, string src, string dst)
 # @return an option to follow symlinks and copy their targets.
 option::copy followSymlinks()
 
-# If the &#34;src&#34; path is a directory, only the contents of the directory is
+# If the "src" path is a directory, only the contents of the directory is
 # copied to the destination.
 #
 # @return an option to copy only the contents of the input directory.
 option::copy contentsOnly()
 
-# If the &#34;src&#34; path is an archive, attempt to unpack its contents into the
+# If the "src" path is an archive, attempt to unpack its contents into the
 # destination.
 #
 # @return an option to unpack an archive to the destination.
 option::copy unpack()
 
-# Create the parent directories of the destination if they don&#39;t already exist.
+# Create the parent directories of the destination if they don't already exist.
 #
 # @return an option to create the parent directories of the destination.
 option::copy createDestPath()
@@ -1122,6 +1208,16 @@ option::copy createdTime(string created)
 # @return an option to push the filesystem to a registry.
 fs dockerPush(string ref) binds (string digest)
 
+# Exports the build cache of the filesystem, so that it can be imported by
+# builds on other machines.
+#
+# @param type the type of cache, one of "local", "inline" or "registry".
+# @param ref a local directory for local caches, or a distribution reference
+# for registry caches. inline caches are exported with the image, so the ref
+# is ignored.
+# @return an option to export the build cache.
+option::dockerPush cacheTo(string type, string ref)
+
 # Loads the filesystem as a Docker image to the docker client found in your
 # environment.
 #
@@ -1130,12 +1226,32 @@ fs dockerPush(string ref) binds (string digest)
 # environment.
 fs dockerLoad(string ref)
 
+# Exports the build cache of the filesystem, so that it can be imported by
+# builds on other machines.
+#
+# @param type the type of cache, one of "local", "inline" or "registry".
+# @param ref a local directory for local caches, or a distribution reference
+# for registry caches. inline caches are exported with the image, so the ref
+# is ignored.
+# @return an option to export the build cache.
+option::dockerLoad cacheTo(string type, string ref)
+
 # Downloads the filesystem to a local path.
 #
 # @param localPath the destination filepath for the filesystem contents.
 # @return an option to download a filesystem to the local system.
 fs download(string localPath)
 
+# Exports the build cache of the filesystem, so that it can be imported by
+# builds on other machines.
+#
+# @param type the type of cache, one of "local", "inline" or "registry".
+# @param ref a local directory for local caches, or a distribution reference
+# for registry caches. inline caches are exported with the image, so the ref
+# is ignored.
+# @return an option to export the build cache.
+option::download cacheTo(string type, string ref)
+
 # Downloads the filesystem as a tarball to a local path.
 #
 # @param localPath the destination filepath for the tarball.
@@ -1151,7 +1267,7 @@ fs downloadTarball(string localPath)
 fs downloadOCITarball(string localPath)
 
 # Downloads the filesystem as a Docker image tarball to a local path.
-# The tarball is able to be loaded into a docker engine via &#34;docker load&#34;.
+# The tarball is able to be loaded into a docker engine via "docker load".
 # See: https://docs.docker.com/engine/reference/commandline/save/
 # and https://docs.docker.com/engine/reference/commandline/load/
 #
@@ -1202,7 +1318,7 @@ fs volumes(variadic string mountpoints)
 # Sets the system call signal that will be sent to the container to exit.
 #
 # This signal can be a valid unsigned number that matches a position in the
-# kernel&#39;s syscall table, for instance 9, or a signal in the format SIGNAME,
+# kernel's syscall table, for instance 9, or a signal in the format SIGNAME,
 # for instance SIGKILL.
 #
 # This metadata is only useful when exporting as a Docker image.
@@ -1221,7 +1337,7 @@ string format(string formatString, variadic string values)
 
 # The architecture for the clients local environment.
 #
-# @return the client&#39;s architecture.
+# @return the client's architecture.
 string localArch()
 
 # The current working directory from the clients local environment.
@@ -1229,10 +1345,10 @@ string localArch()
 # @return the current working directory.
 string localCwd()
 
-# An environment variable from the client&#39;s local environment.
+# An environment variable from the client's local environment.
 #
-# @param key the environment variable&#39;s key.
-# @return the environment variable&#39;s value.
+# @param key the environment variable's key.
+# @return the environment variable's value.
 string localEnv(string key)
 
 # The OS from the clients local environment.
@@ -1242,7 +1358,7 @@ string localOs()
 
 # Executes an command in the local environment.
 #
-# If exactly one arg is given it will be wrapped with /bin/sh -c &#39;arg&#39;.
+# If exactly one arg is given it will be wrapped with /bin/sh -c 'arg'.
 # If more than one arg is given, it will be executed directly, without a shell.
 #
 # @param command a command to execute.
@@ -1266,14 +1382,14 @@ option::localRun includeStderr()
 # @return an option to ignore stdout on the command
 option::localRun onlyStderr()
 
-# Attempt to lex the single-argument shell command provided to &#34;localRun&#34;
-# to determine if a &#34;/bin/sh -c &#39;...&#39;&#34; wrapper needs to be added.
+# Attempt to lex the single-argument shell command provided to "localRun"
+# to determine if a "/bin/sh -c '...'" wrapper needs to be added.
 #
 # @return an option to attempt to optimize the command execution removing the
-# /bin/sh -c &#34;...&#34; wrapper when possible.
+# /bin/sh -c "..." wrapper when possible.
 option::localRun shlex()
 
-# Fetch an OCI image&#39;s manifest from the registry. This uses the current platform
+# Fetch an OCI image's manifest from the registry. This uses the current platform
 # by default.
 #
 # @param ref a docker registry reference. if not fully qualified, it will be
@@ -1284,8 +1400,8 @@ string manifest(string ref) binds (string digest, string config, string index)
 
 # Specify the platform whose manifest should be returned instead of the default.
 #
-# @param os operating system name, eg &#34;linux&#34;
-# @param arch architecture name, eg &#34;amd64&#34;
+# @param os operating system name, eg "linux"
+# @param arch architecture name, eg "amd64"
 option::manifest platform(string os, string arch)
 
 # Process text as a Go text template.
@@ -1304,6 +1420,27 @@ string template(string text)
 # @return an option to add a field to the template.
 option::template stringField(string name, string value)
 
+# Compares two strings, for example to branch on a local environment variable
+# in an if statement.
+#
+# @param a the string to compare.
+# @param b the string to compare with.
+# @return true if both strings are equal.
+bool equals(string a, string b)
+
+# Checks whether a string is non-empty, for example to check if a local
+# environment variable is set with notEmpty(localEnv("CI")).
+#
+# @param value the string to check.
+# @return true if the string is not empty.
+bool notEmpty(string value)
+
+# Negates a boolean.
+#
+# @param value the boolean to negate.
+# @return true if the value is false, false otherwise.
+bool not(bool value)
+
 # Executes pipeline or filesystem target(s). Multiple targets specified within
 # a stage is executed in parallel. 
 #
@@ -6,12 +6,14 @@
 package checker
 
 import (
+	"context"
 	"fmt"
 	"sort"
 
 	"github.com/openllb/hlb/diagnostic"
 	"github.com/openllb/hlb/errdefs"
 	"github.com/openllb/hlb/parser"
+	"github.com/openllb/hlb/pkg/tracing"
 )
 
 func SemanticPass(mod *parser.Module) error {
@@ -39,6 +41,17 @@ func CheckReferences(mod *parser.Module) error {
 	return c.CheckReferences(mod)
 }
 
+// Traced runs a pass of the checker, such as SemanticPass or Check, in a span
+// with the given name.
+func Traced(ctx context.Context, name string, pass func(mod *parser.Module) error, mod *parser.Module) error {
+	_, span := tracing.Start(ctx, name, tracing.String("hlb.filename", mod.Pos.Filename))
+	defer span.End()
+
+	err := pass(mod)
+	span.SetError(err)
+	return err
+}
+
 type checker struct {
 	checkRefs bool
 	errs      []error
@@ -139,6 +152,34 @@ func (c *checker) SemanticPass(mod *parser.Module) error {
 			}
 			lit.Body.Type = lit.Type
 		},
+		// Conditional branches share the scope and type of their parent block.
+		func(block *parser.BlockStmt, is *parser.IfStmt) {
+			for _, body := range is.Blocks() {
+				body.Scope = block.Scope
+				body.Type = block.Type
+			}
+		},
+		// Loop bodies have a lexical scope for the loop variable and share the type
+		// of their parent block.
+		func(block *parser.BlockStmt, fs *parser.ForStmt) {
+			fs.Field = &parser.Field{
+				Mixin: parser.Mixin{Pos: fs.Type.Pos, EndPos: fs.Name.EndPos},
+				Type:  fs.Type,
+				Name:  fs.Name,
+			}
+			fs.Body.Scope = parser.NewScope(fs, block.Scope)
+			fs.Body.Scope.Insert(&parser.Object{
+				Kind:  fs.Field.Kind(),
+				Ident: fs.Field.Name,
+				Node:  fs.Field,
+			})
+			fs.Body.Type = block.Type
+		},
+		// Function literals within a block share its scope, which may be nested
+		// inside the function scope (e.g. in a loop body).
+		func(block *parser.BlockStmt, lit *parser.FuncLit) {
+			lit.Body.Scope = block.Scope
+		},
 	)
 
 	// Binds must be handled in a second pass to ensure all bindable identifiers
@@ -170,6 +211,16 @@ func (c *checker) checkBinds(mod *parser.Module) {
 		func(fun *parser.FuncDecl, _ *parser.WithClause, block *parser.BlockStmt) {
 			block.Closure = fun
 		},
+		// Conditional branches share the closure of their parent block.
+		func(block *parser.BlockStmt, is *parser.IfStmt) {
+			for _, body := range is.Blocks() {
+				body.Closure = block.Closure
+			}
+		},
+		// Loop bodies share the closure of their parent block.
+		func(block *parser.BlockStmt, fs *parser.ForStmt) {
+			fs.Body.Closure = block.Closure
+		},
 		// Register bind clauses in the parent function body.
 		// There are 3 primary rules for binds listed below.
 		// 1. Option blocks do not have a closure for bindings.
@@ -299,11 +350,19 @@ func (c *checker) checkFieldList(fields []*parser.Field) error {
 func (c *checker) checkBlock(block *parser.BlockStmt) error {
 	for _, stmt := range block.Stmts() {
 		kset := parser.NewKindSet(block.Kind())
+		if block.Kind().IsList() {
+			// Statements in list blocks are either elements or lists to append.
+			kset = parser.NewKindSet(block.Kind().Elem(), block.Kind())
+		}
 
 		var err error
 		switch {
 		case stmt.Call != nil:
 			err = c.checkCallStmt(block.Scope, kset, stmt.Call)
+		case stmt.If != nil:
+			err = c.checkIfStmt(block.Scope, stmt.If)
+		case stmt.For != nil:
+			err = c.checkForStmt(block.Scope, stmt.For)
 		case stmt.Expr != nil:
 			err = c.checkExpr(block.Scope, kset, stmt.Expr.Expr)
 		}
@@ -333,6 +392,37 @@ func (c *checker) checkCallStmt(scope *parser.Scope, kset *parser.KindSet, call
 	return c.checkCall(scope, kset, call.Name, call.Args, call.WithClause)
 }
 
+func (c *checker) checkIfStmt(scope *parser.Scope, is *parser.IfStmt) error {
+	err := c.checkExpr(scope, parser.NewKindSet(parser.Bool), is.Condition.Expr())
+	if err != nil {
+		return err
+	}
+
+	err = c.checkBlock(is.Body)
+	if err != nil {
+		return err
+	}
+
+	if is.Else != nil {
+		switch {
+		case is.Else.If != nil:
+			return c.checkIfStmt(scope, is.Else.If)
+		case is.Else.Body != nil:
+			return c.checkBlock(is.Else.Body)
+		}
+	}
+	return nil
+}
+
+func (c *checker) checkForStmt(scope *parser.Scope, fs *parser.ForStmt) error {
+	kset := parser.NewKindSet(parser.ListOf(fs.Field.Kind()))
+	err := c.checkExpr(scope, kset, fs.Iterable.Expr())
+	if err != nil {
+		return err
+	}
+	return c.checkBlock(fs.Body)
+}
+
 func (c *checker) skip(ie *parser.IdentExpr) bool {
 	// Skip references when not checking references and skip non-references
 	// when checking references.
@@ -407,6 +497,8 @@ func (c *checker) checkExpr(scope *parser.Scope, kset *parser.KindSet, expr *par
 			return err
 		}
 		return nil
+	case expr.ListLit != nil:
+		return c.checkListLit(scope, kset, expr.ListLit)
 	case expr.CallExpr != nil:
 		return c.checkCallExpr(scope, kset, expr.CallExpr)
 	}
@@ -449,6 +541,41 @@ func (c *checker) checkBasicLit(scope *parser.Scope, kind parser.Kind, lit *pars
 	return nil
 }
 
+func (c *checker) checkListLit(scope *parser.Scope, kset *parser.KindSet, lit *parser.ListLit) error {
+	var err error
+	for _, kind := range kset.Kinds() {
+		if !kind.IsList() {
+			continue
+		}
+
+		// Like statements in list blocks, elements are either elements or lists
+		// to append.
+		err = c.checkElems(scope, parser.NewKindSet(kind.Elem(), kind), lit.Elems())
+		if err == nil {
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	actual := lit.Kind()
+	if actual == parser.None {
+		actual = parser.ListOf(parser.None)
+	}
+	return c.checkType(lit, kset, actual)
+}
+
+func (c *checker) checkElems(scope *parser.Scope, kset *parser.KindSet, elems []*parser.Expr) error {
+	for _, elem := range elems {
+		err := c.checkExpr(scope, kset, elem)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *checker) checkStringFragments(scope *parser.Scope, fragments []*parser.StringFragment) error {
 	kset := parser.NewKindSet(parser.String, parser.Int, parser.Bool)
 	for _, f := range fragments {
@@ -334,6 +334,178 @@ func TestChecker_Check(t *testing.T) {
 		}
 		`,
 		nil,
+	}, {
+		"if else in fs, option and pipeline blocks",
+		`
+		fs default(bool cond) {
+			image "alpine"
+			if cond {
+				run "echo true" with option {
+					if true {
+						dir "/tmp"
+					}
+				}
+			} else if false {
+				scratch
+			} else {
+				run "echo false"
+			}
+		}
+		pipeline build(bool cond) {
+			if cond {
+				stage default(cond)
+			}
+		}
+		`,
+		nil,
+	}, {
+		"if condition from bool builtins",
+		`
+		fs default() {
+			if not(equals(localEnv("CI"), "true")) {
+				scratch
+			} else if notEmpty(localEnv("HOME")) {
+				image "alpine"
+			}
+		}
+		`,
+		nil,
+	}, {
+		"errors when if condition is not a bool",
+		`
+		fs default(string cond) {
+			if cond {
+				scratch
+			}
+		}
+		`,
+		func(mod *parser.Module) error {
+			return errdefs.WithWrongType(
+				parser.Find(mod, "cond", parser.WithSkip(1)),
+				[]parser.Kind{parser.Bool},
+				parser.String,
+				errdefs.Defined(parser.Find(mod, "cond")),
+			)
+		},
+	}, {
+		"errors when if branch has the wrong type",
+		`
+		fs default(bool cond) {
+			if cond {
+				format "/tmp"
+			}
+		}
+		`,
+		func(mod *parser.Module) error {
+			return errdefs.WithWrongType(
+				parser.Find(mod, "format"),
+				[]parser.Kind{parser.Filesystem},
+				parser.String,
+				errdefs.Defined(parser.Find(builtin.Module, "format")),
+			)
+		},
+	}, {
+		"for loop over list",
+		`
+		[]string platforms() {
+			"linux/amd64"
+			format "linux/%s" "arm64"
+		}
+		[]string all(string extra) {
+			platforms
+			extra
+		}
+		fs default() {
+			image "golang"
+			for string platform in all("darwin/amd64") {
+				run string { format "go build %s" platform; }
+			}
+		}
+		`,
+		nil,
+	}, {
+		"for loop over list literal",
+		`
+		[]string platforms() {
+			["linux/amd64", format("linux/%s", "arm64")]
+		}
+		fs build([]string platforms) {
+			image "golang"
+			for string platform in [platforms, "darwin/amd64"] {
+				run string { format "go build %s" platform; }
+			}
+		}
+		fs default() {
+			build ["windows/amd64"]
+		}
+		`,
+		nil,
+	}, {
+		"errors when for loop variable does not match the list",
+		`
+		[]string platforms() {
+			"linux/amd64"
+		}
+		fs default() {
+			for fs platform in platforms {
+				scratch
+			}
+		}
+		`,
+		func(mod *parser.Module) error {
+			return errdefs.WithWrongType(
+				parser.Find(mod, "platforms", parser.WithSkip(1)),
+				[]parser.Kind{parser.ListOf(parser.Filesystem)},
+				parser.ListOf(parser.String),
+				errdefs.Defined(parser.Find(mod, "platforms")),
+			)
+		},
+	}, {
+		"errors when list element has the wrong type",
+		`
+		[]string platforms() {
+			scratch
+		}
+		`,
+		func(mod *parser.Module) error {
+			return errdefs.WithWrongType(
+				parser.Find(mod, "scratch"),
+				[]parser.Kind{parser.ListOf(parser.String), parser.String},
+				parser.Filesystem,
+				errdefs.Defined(parser.Find(builtin.Module, "scratch")),
+			)
+		},
+	}, {
+		"errors when list literal element has the wrong type",
+		`
+		fs default() {
+			for string platform in ["linux/amd64", scratch] {
+				scratch
+			}
+		}
+		`,
+		func(mod *parser.Module) error {
+			return errdefs.WithWrongType(
+				parser.Find(mod, "scratch"),
+				[]parser.Kind{parser.ListOf(parser.String), parser.String},
+				parser.Filesystem,
+				errdefs.Defined(parser.Find(builtin.Module, "scratch")),
+			)
+		},
+	}, {
+		"errors when list literal is not used as a list",
+		`
+		fs default() {
+			image ["alpine"]
+		}
+		`,
+		func(mod *parser.Module) error {
+			return errdefs.WithWrongType(
+				parser.Find(mod, `["alpine"]`),
+				[]parser.Kind{parser.String},
+				parser.ListOf(parser.String),
+			)
+		},
 	}, {
 		"errors when fs statement is called in a pipeline block",
 		`
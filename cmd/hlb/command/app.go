@@ -32,10 +32,13 @@ func App() *cli.App {
 	app.Commands = []*cli.Command{
 		versionCommand,
 		runCommand,
+		graphCommand,
+		docCommand,
 		formatCommand,
 		lintCommand,
 		moduleCommand,
 		langserverCommand,
+		dapCommand,
 	}
 	return app
 }
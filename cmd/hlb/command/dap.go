@@ -0,0 +1,37 @@
+package command
+
+import (
+	"log"
+	"os"
+
+	"github.com/openllb/hlb/dapserver"
+	cli "github.com/urfave/cli/v2"
+)
+
+var dapCommand = &cli.Command{
+	Name:  "dap",
+	Usage: "run hlb debug adapter protocol server over stdio",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "logfile",
+			Usage: "file to log output",
+			Value: "/tmp/hlb-dap.log",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		f, err := os.Create(c.String("logfile"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		log.SetOutput(f)
+
+		cln, ctx, err := Client(c)
+		if err != nil {
+			return err
+		}
+
+		s := dapserver.NewServer(cln)
+		return s.Listen(ctx, os.Stdin, os.Stdout)
+	},
+}
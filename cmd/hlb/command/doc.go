@@ -0,0 +1,105 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/openllb/hlb/builtin"
+	"github.com/openllb/hlb/checker"
+	"github.com/openllb/hlb/diagnostic"
+	"github.com/openllb/hlb/errdefs"
+	"github.com/openllb/hlb/module"
+	"github.com/openllb/hlb/parser"
+	cli "github.com/urfave/cli/v2"
+)
+
+var docCommand = &cli.Command{
+	Name:      "doc",
+	Usage:     "generates reference docs for the exported functions of a hlb module",
+	ArgsUsage: "<*.hlb>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "set format of the docs (markdown, html, json)",
+			Value: "markdown",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		rc, err := ModuleReadCloser(c.Args().Slice())
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		return Doc(Context(), rc, DocInfo{
+			Format:    c.String("format"),
+			ErrOutput: os.Stderr,
+			Output:    os.Stdout,
+		})
+	},
+}
+
+type DocInfo struct {
+	Format    string
+	ErrOutput io.Writer
+	Output    io.Writer
+}
+
+func Doc(ctx context.Context, r io.Reader, info DocInfo) (err error) {
+	if info.Output == nil {
+		info.Output = os.Stdout
+	}
+
+	switch info.Format {
+	case "markdown", "html", "json":
+	default:
+		return fmt.Errorf("unrecognized format %q", info.Format)
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		// Handle diagnostic errors.
+		spans := diagnostic.Spans(err)
+		for _, span := range spans {
+			fmt.Fprintf(info.ErrOutput, "%s\n", span.Pretty(ctx))
+		}
+
+		err = errdefs.WithAbort(err, len(spans))
+	}()
+
+	ctx = diagnostic.WithSources(ctx, builtin.Sources())
+	mod, err := parser.Parse(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	err = checker.SemanticPass(mod)
+	if err != nil {
+		return err
+	}
+
+	// Checking marks the exported functions.
+	err = checker.Check(mod)
+	if err != nil {
+		return err
+	}
+
+	doc, err := module.GenerateDocumentation(mod)
+	if err != nil {
+		return err
+	}
+
+	switch info.Format {
+	case "html":
+		return doc.WriteHTML(info.Output)
+	case "json":
+		return doc.WriteJSON(info.Output)
+	default:
+		return doc.WriteMarkdown(info.Output)
+	}
+}
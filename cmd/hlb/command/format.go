@@ -1,6 +1,7 @@
 package command
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/alecthomas/participle/lexer"
 	"github.com/openllb/hlb/parser"
+	"github.com/pmezard/go-difflib/difflib"
 	cli "github.com/urfave/cli/v2"
 )
 
@@ -23,6 +25,23 @@ var formatCommand = &cli.Command{
 			Aliases: []string{"w"},
 			Usage:   "write result to (source) file instead of stdout",
 		},
+		&cli.BoolFlag{
+			Name:  "check",
+			Usage: "print a diff of files that aren't formatted and exit with a non-zero status",
+		},
+		&cli.BoolFlag{
+			Name:    "diff",
+			Aliases: []string{"d"},
+			Usage:   "print a diff instead of the formatted result",
+		},
+		&cli.BoolFlag{
+			Name:  "sort-imports",
+			Usage: "sort imports by name, grouping remote modules before local files",
+		},
+		&cli.IntFlag{
+			Name:  "width",
+			Usage: "wrap parameter and argument lists longer than width, 0 to never wrap",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		rs, cleanup, err := collectReaders(c)
@@ -37,42 +56,111 @@ var formatCommand = &cli.Command{
 		}()
 
 		return Format(Context(), rs, FormatInfo{
-			Write: c.Bool("write"),
+			Write:       c.Bool("write"),
+			Check:       c.Bool("check"),
+			Diff:        c.Bool("diff"),
+			SortImports: c.Bool("sort-imports"),
+			Width:       c.Int("width"),
+			Output:      os.Stdout,
 		})
 	},
 }
 
 type FormatInfo struct {
-	Write bool
+	Write       bool
+	Check       bool
+	Diff        bool
+	SortImports bool
+	Width       int
+	Output      io.Writer
 }
 
 func Format(ctx context.Context, rs []io.Reader, info FormatInfo) error {
-	modules, err := parser.ParseMultiple(ctx, rs)
+	if info.Output == nil {
+		info.Output = os.Stdout
+	}
+
+	// Keep the sources to diff against their formatted result.
+	var (
+		srcs  [][]byte
+		named []io.Reader
+	)
+	for _, r := range rs {
+		dt, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		srcs = append(srcs, dt)
+		named = append(named, &parser.NamedReader{
+			Reader: bytes.NewReader(dt),
+			Value:  lexer.NameOfReader(r),
+		})
+	}
+
+	modules, err := parser.ParseMultiple(ctx, named)
 	if err != nil {
 		return err
 	}
 
-	if info.Write {
-		for i, mod := range modules {
-			filename := lexer.NameOfReader(rs[i])
-			if filename == "" {
-				return fmt.Errorf("Unable to write, file name unavailable")
+	var opts []parser.UnparseOption
+	if info.SortImports {
+		opts = append(opts, parser.WithSortImports())
+	}
+	if info.Width > 0 {
+		opts = append(opts, parser.WithWidth(info.Width))
+	}
+
+	var unformatted int
+	for i, mod := range modules {
+		formatted := mod.Unparse(opts...)
+		filename := lexer.NameOfReader(rs[i])
+
+		if info.Check || info.Diff {
+			if formatted == string(srcs[i]) {
+				continue
 			}
-			info, err := os.Stat(filename)
+			unformatted++
+
+			diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(string(srcs[i])),
+				B:        difflib.SplitLines(formatted),
+				FromFile: fmt.Sprintf("a/%s", mod.Pos.Filename),
+				ToFile:   fmt.Sprintf("b/%s", mod.Pos.Filename),
+				Context:  3,
+			})
 			if err != nil {
 				return err
 			}
+			fmt.Fprint(info.Output, diff)
+		}
 
-			err = ioutil.WriteFile(filename, []byte(mod.String()), info.Mode())
-			if err != nil {
-				return err
+		if !info.Write {
+			if !info.Check && !info.Diff {
+				fmt.Fprintf(info.Output, "%s", formatted)
 			}
+			continue
+		}
+
+		if filename == "" {
+			return fmt.Errorf("Unable to write, file name unavailable")
 		}
-	} else {
-		for _, mod := range modules {
-			fmt.Printf("%s", mod)
+		fi, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(filename, []byte(formatted), fi.Mode())
+		if err != nil {
+			return err
 		}
 	}
 
+	if info.Check && unformatted > 0 {
+		files := "file is"
+		if unformatted > 1 {
+			files = "files are"
+		}
+		return fmt.Errorf("%d %s not formatted, run `hlb format -w` to format them", unformatted, files)
+	}
 	return nil
 }
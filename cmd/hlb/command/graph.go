@@ -0,0 +1,145 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/moby/buildkit/client"
+	"github.com/openllb/hlb"
+	"github.com/openllb/hlb/builtin"
+	"github.com/openllb/hlb/codegen"
+	"github.com/openllb/hlb/diagnostic"
+	"github.com/openllb/hlb/errdefs"
+	"github.com/openllb/hlb/parser"
+	"github.com/openllb/hlb/solver"
+	cli "github.com/urfave/cli/v2"
+)
+
+var graphCommand = &cli.Command{
+	Name:      "graph",
+	Usage:     "prints the graph of requests and their LLB ops without solving",
+	ArgsUsage: "<*.hlb>",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "target",
+			Aliases: []string{"t"},
+			Usage:   "specify target filesystem to graph",
+			Value:   cli.NewStringSlice("default"),
+		},
+		&cli.StringSliceFlag{
+			Name:  "arg",
+			Usage: "set a parameter of the target functions, e.g. --arg version=1.2.3",
+		},
+		&cli.StringSliceFlag{
+			Name:  "replace",
+			Usage: "replace an import with a local module, e.g. --replace go=../go.hlb",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "set format of the graph (dot, mermaid, json)",
+			Value: "dot",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		rc, err := ModuleReadCloser(c.Args().Slice())
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		cln, ctx, err := Client(c)
+		if err != nil {
+			return err
+		}
+
+		return Graph(ctx, cln, rc, GraphInfo{
+			Targets:   c.StringSlice("target"),
+			Args:      c.StringSlice("arg"),
+			Replace:   c.StringSlice("replace"),
+			Format:    c.String("format"),
+			ErrOutput: os.Stderr,
+			Output:    os.Stdout,
+		})
+	},
+}
+
+type GraphInfo struct {
+	Targets   []string
+	Args      []string
+	Replace   []string
+	Format    string
+	ErrOutput io.Writer
+	Output    io.Writer
+}
+
+func Graph(ctx context.Context, cln *client.Client, rc io.Reader, info GraphInfo) (err error) {
+	if len(info.Targets) == 0 {
+		info.Targets = []string{"default"}
+	}
+	if info.Output == nil {
+		info.Output = os.Stdout
+	}
+
+	switch info.Format {
+	case "dot", "mermaid", "json":
+	default:
+		return fmt.Errorf("unrecognized format %q", info.Format)
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		// Handle diagnostic errors.
+		spans := diagnostic.Spans(err)
+		for _, span := range spans {
+			fmt.Fprintf(info.ErrOutput, "%s\n", span.Pretty(ctx))
+		}
+
+		err = errdefs.WithAbort(err, len(spans))
+	}()
+
+	ctx = diagnostic.WithSources(ctx, builtin.Sources())
+	mod, err := parser.Parse(ctx, rc)
+	if err != nil {
+		return err
+	}
+
+	targets, err := compileTargets(info.Targets, info.Args)
+	if err != nil {
+		return err
+	}
+
+	ctx, err = withReplace(ctx, info.Replace)
+	if err != nil {
+		return err
+	}
+
+	// Exports are solved while compiling, so they are collected to be graphed
+	// with the targets instead.
+	exports := &codegen.Exports{}
+	ctx = codegen.WithExports(ctx, exports)
+	ctx = codegen.WithImageResolver(ctx, codegen.NewCachedImageResolver(cln))
+
+	solveReq, err := hlb.Compile(ctx, cln, mod, targets, codegen.WithDebugger(codegen.NewNoopDebugger()))
+	if err != nil {
+		return err
+	}
+
+	graph, err := solver.NewGraph(solver.Parallel(solveReq, exports.Request()))
+	if err != nil {
+		return err
+	}
+
+	switch info.Format {
+	case "mermaid":
+		return graph.WriteMermaid(info.Output)
+	case "json":
+		return graph.WriteJSON(info.Output)
+	default:
+		return graph.WriteDot(info.Output)
+	}
+}
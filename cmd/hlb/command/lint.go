@@ -7,12 +7,16 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"text/tabwriter"
 
+	"github.com/openllb/hlb"
 	"github.com/openllb/hlb/builtin"
 	"github.com/openllb/hlb/checker"
+	"github.com/openllb/hlb/codegen"
 	"github.com/openllb/hlb/diagnostic"
 	"github.com/openllb/hlb/errdefs"
 	"github.com/openllb/hlb/linter"
+	"github.com/openllb/hlb/module"
 	"github.com/openllb/hlb/parser"
 	cli "github.com/urfave/cli/v2"
 )
@@ -26,8 +30,34 @@ var lintCommand = &cli.Command{
 			Name:  "fix",
 			Usage: "write module with lint errors fixed and formatted to source file",
 		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "set format of diagnostics (text, json, sarif)",
+			Value: "text",
+		},
+		&cli.StringSliceFlag{
+			Name:  "enable",
+			Usage: "enable a lint rule by ID",
+		},
+		&cli.StringSliceFlag{
+			Name:  "disable",
+			Usage: "disable a lint rule by ID",
+		},
+		&cli.StringFlag{
+			Name:  "config",
+			Usage: "path to a lint config",
+			Value: linter.ConfigPath,
+		},
+		&cli.BoolFlag{
+			Name:  "rules",
+			Usage: "list the lint rules and exit",
+		},
 	},
 	Action: func(c *cli.Context) error {
+		if c.Bool("rules") {
+			return LintRules(os.Stdout)
+		}
+
 		rc, err := ModuleReadCloser(c.Args().Slice())
 		if err != nil {
 			return err
@@ -35,63 +65,196 @@ var lintCommand = &cli.Command{
 		defer rc.Close()
 
 		return Lint(Context(), rc, LintInfo{
-			Fix: c.Bool("fix"),
+			Fix:     c.Bool("fix"),
+			Format:  c.String("format"),
+			Enable:  c.StringSlice("enable"),
+			Disable: c.StringSlice("disable"),
+			Config:  c.String("config"),
+			Output:  os.Stdout,
 		})
 
 	},
 }
 
 type LintInfo struct {
-	Fix bool
+	Fix     bool
+	Format  string
+	Enable  []string
+	Disable []string
+	Config  string
+	Output  io.Writer
 }
 
 func Lint(ctx context.Context, r io.Reader, info LintInfo) error {
+	if info.Output == nil {
+		info.Output = os.Stdout
+	}
+
+	structured := false
+	switch info.Format {
+	case "", "text":
+	case "json", "sarif":
+		structured = !info.Fix
+	default:
+		return fmt.Errorf("unrecognized format %q", info.Format)
+	}
+
+	lintOpts, err := lintOptions(info)
+	if err != nil {
+		return err
+	}
+
 	ctx = diagnostic.WithSources(ctx, builtin.Sources())
 	mod, err := parser.Parse(ctx, r)
 	if err != nil {
+		if structured {
+			return WriteDiagnostics(info.Output, info.Format, err)
+		}
 		return err
 	}
 
 	err = checker.SemanticPass(mod)
 	if err != nil {
+		if structured {
+			return WriteDiagnostics(info.Output, info.Format, err)
+		}
 		return err
 	}
 
-	err = linter.Lint(ctx, mod, linter.WithRecursive())
+	// Unpinned images that are locked suggest pinning the locked digest.
+	lock, err := module.ReadLock(module.LockFile(mod))
 	if err != nil {
-		spans := diagnostic.Spans(err)
-		for _, span := range spans {
-			if !info.Fix {
-				fmt.Fprintf(os.Stderr, "%s\n", span.Pretty(ctx))
-				continue
-			}
+		if !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		ctx = codegen.WithLock(ctx, lock, codegen.LockPin)
+	}
+
+	err = linter.Lint(ctx, mod, lintOpts...)
+	if structured {
+		// Deprecations are only warnings, so the module is still checked.
+		return WriteDiagnostics(info.Output, info.Format, err, checker.Check(mod))
+	}
 
+	if err != nil {
+		var (
+			spans    = diagnostic.Spans(err)
+			fixable  int
+			reported int
+		)
+		for _, span := range spans {
+			// Only deprecations are fixed in place, other findings may only
+			// suggest a fix.
 			var em *errdefs.ErrModule
 			if errors.As(span, &em) {
-				filename := em.Module.Pos.Filename
-				info, err := os.Stat(filename)
-				if err != nil {
-					return err
-				}
+				fixable++
+				if info.Fix {
+					filename := em.Module.Pos.Filename
+					info, err := os.Stat(filename)
+					if err != nil {
+						return err
+					}
 
-				err = ioutil.WriteFile(filename, []byte(em.Module.String()), info.Mode())
-				if err != nil {
-					return err
+					err = ioutil.WriteFile(filename, []byte(em.Module.String()), info.Mode())
+					if err != nil {
+						return err
+					}
+					continue
 				}
 			}
+
+			fmt.Fprintf(os.Stderr, "%s\n", span.Pretty(ctx))
+			reported++
 		}
-		if info.Fix {
+		if reported == 0 {
 			return nil
 		}
 
-		color := diagnostic.Color(ctx)
-		fmt.Fprint(os.Stderr, color.Sprintf(
-			color.Bold("\nRun %s to automatically fix lint errors.\n"),
-			color.Green(fmt.Sprintf("`hlb lint --fix %s`", mod.Pos.Filename)),
-		))
+		if fixable > 0 && !info.Fix {
+			color := diagnostic.Color(ctx)
+			fmt.Fprint(os.Stderr, color.Sprintf(
+				color.Bold("\nRun %s to automatically fix lint errors.\n"),
+				color.Green(fmt.Sprintf("`hlb lint --fix %s`", mod.Pos.Filename)),
+			))
+		}
 
-		return errdefs.WithAbort(err, len(spans))
+		return errdefs.WithAbort(err, reported)
 	}
 
 	return checker.Check(mod)
 }
+
+// lintOptions configures the linter with the lint config, and then the rules
+// enabled or disabled by flags.
+func lintOptions(info LintInfo) ([]linter.LintOption, error) {
+	opts := []linter.LintOption{linter.WithRecursive()}
+	if info.Config != "" {
+		cfg, err := linter.ReadConfig(info.Config)
+		if err != nil {
+			// Only the default config is optional.
+			if !os.IsNotExist(err) || info.Config != linter.ConfigPath {
+				return nil, err
+			}
+		} else {
+			opts = append(opts, linter.WithConfig(cfg))
+		}
+	}
+
+	cfg := &linter.Config{Enable: info.Enable, Disable: info.Disable}
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, linter.WithConfig(cfg)), nil
+}
+
+// LintRules writes the registered lint rules with their default severity.
+func LintRules(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, rule := range linter.Rules() {
+		state := ""
+		if rule.OptIn {
+			state = " (opt-in)"
+		}
+		fmt.Fprintf(tw, "%s\t%s%s\t%s\n", rule.ID, rule.Severity, state, rule.Doc)
+	}
+	return tw.Flush()
+}
+
+// WriteDiagnostics writes the diagnostics of errors in a machine-readable
+// format. It returns an abort error if any diagnostic is an error rather than a
+// warning.
+func WriteDiagnostics(w io.Writer, format string, errs ...error) error {
+	var diags []diagnostic.Diagnostic
+	for _, err := range errs {
+		diags = append(diags, diagnostic.Report(err)...)
+	}
+	return writeDiagnostics(w, format, diags)
+}
+
+func writeDiagnostics(w io.Writer, format string, diags []diagnostic.Diagnostic) error {
+	var err error
+	switch format {
+	case "json":
+		err = diagnostic.WriteJSON(w, diags)
+	case "sarif":
+		err = diagnostic.WriteSARIF(w, "hlb", hlb.Version, diags)
+	default:
+		err = fmt.Errorf("unrecognized format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	var numErrs int
+	for _, diag := range diags {
+		if diag.Severity == diagnostic.SeverityError.String() {
+			numErrs++
+		}
+	}
+	if numErrs > 0 {
+		return errdefs.WithAbort(fmt.Errorf("%d errors", numErrs), numErrs)
+	}
+	return nil
+}
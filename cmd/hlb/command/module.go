@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/moby/buildkit/client"
+	"github.com/openllb/hlb"
 	"github.com/openllb/hlb/builtin"
 	"github.com/openllb/hlb/checker"
 	"github.com/openllb/hlb/codegen"
@@ -30,6 +31,9 @@ var moduleCommand = &cli.Command{
 		moduleVendorCommand,
 		moduleTidyCommand,
 		moduleTreeCommand,
+		moduleLockCommand,
+		moduleVerifyCommand,
+		modulePublishCommand,
 	},
 }
 
@@ -43,6 +47,10 @@ var moduleVendorCommand = &cli.Command{
 			Aliases: []string{"t"},
 			Usage:   "specify import targets to vendor, by default all imports are vendored",
 		},
+		&cli.StringSliceFlag{
+			Name:  "replace",
+			Usage: "replace an import with a local module, e.g. --replace go=../go.hlb",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		cln, ctx, err := Client(c)
@@ -53,6 +61,7 @@ var moduleVendorCommand = &cli.Command{
 		return Vendor(ctx, cln, VendorInfo{
 			Args:      c.Args().Slice(),
 			Targets:   c.StringSlice("target"),
+			Replace:   c.StringSlice("replace"),
 			Tidy:      false,
 			ErrOutput: os.Stderr,
 		})
@@ -63,6 +72,12 @@ var moduleTidyCommand = &cli.Command{
 	Name:      "tidy",
 	Usage:     "add missing and remove unused modules",
 	ArgsUsage: "<*.hlb>",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "replace",
+			Usage: "replace an import with a local module, e.g. --replace go=../go.hlb",
+		},
+	},
 	Action: func(c *cli.Context) error {
 		cln, ctx, err := Client(c)
 		if err != nil {
@@ -71,6 +86,7 @@ var moduleTidyCommand = &cli.Command{
 
 		return Vendor(ctx, cln, VendorInfo{
 			Args:      c.Args().Slice(),
+			Replace:   c.StringSlice("replace"),
 			Tidy:      true,
 			ErrOutput: os.Stderr,
 		})
@@ -86,6 +102,10 @@ var moduleTreeCommand = &cli.Command{
 			Name:  "long",
 			Usage: "print the full module digests",
 		},
+		&cli.StringSliceFlag{
+			Name:  "replace",
+			Usage: "replace an import with a local module, e.g. --replace go=../go.hlb",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		cln, ctx, err := Client(c)
@@ -96,7 +116,99 @@ var moduleTreeCommand = &cli.Command{
 		return Tree(ctx, cln, TreeInfo{
 			Args:      c.Args().Slice(),
 			Long:      c.Bool("long"),
+			Replace:   c.StringSlice("replace"),
+			ErrOutput: os.Stderr,
+		})
+	},
+}
+
+var moduleLockCommand = &cli.Command{
+	Name:      "lock",
+	Usage:     "pin the image digests and git commits used by targets",
+	ArgsUsage: "<*.hlb>",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "target",
+			Aliases: []string{"t"},
+			Usage:   "specify targets to lock",
+			Value:   cli.NewStringSlice("default"),
+		},
+		&cli.StringSliceFlag{
+			Name:  "arg",
+			Usage: "set a parameter of the target functions, e.g. --arg version=1.2.3",
+		},
+		&cli.StringSliceFlag{
+			Name:  "replace",
+			Usage: "replace an import with a local module, e.g. --replace go=../go.hlb",
+		},
+		&cli.BoolFlag{
+			Name:  "prune",
+			Usage: "remove the entries of the lockfile that the targets don't use",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		cln, ctx, err := Client(c)
+		if err != nil {
+			return err
+		}
+
+		return Lock(ctx, cln, LockInfo{
+			Args:       c.Args().Slice(),
+			Targets:    c.StringSlice("target"),
+			TargetArgs: c.StringSlice("arg"),
+			Replace:    c.StringSlice("replace"),
+			Prune:      c.Bool("prune"),
+			ErrOutput:  os.Stderr,
+		})
+	},
+}
+
+var moduleVerifyCommand = &cli.Command{
+	Name:      "verify",
+	Usage:     "verify vendored modules match their checksums and remote imports",
+	ArgsUsage: "<*.hlb>",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "replace",
+			Usage: "replace an import with a local module, e.g. --replace go=../go.hlb",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		cln, ctx, err := Client(c)
+		if err != nil {
+			return err
+		}
+
+		return Verify(ctx, cln, VerifyInfo{
+			Args:      c.Args().Slice(),
+			Replace:   c.StringSlice("replace"),
 			ErrOutput: os.Stderr,
+			Output:    os.Stdout,
+		})
+	},
+}
+
+var modulePublishCommand = &cli.Command{
+	Name:      "publish",
+	Usage:     "push a module directory as an OCI artifact",
+	ArgsUsage: "<ref>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "dir",
+			Usage: "directory of the module to publish, must contain module.hlb",
+			Value: ".",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("requires exactly one reference to publish to, e.g. docker.io/org/module:tag or oci-layout://path:tag")
+		}
+
+		return Publish(Context(), PublishInfo{
+			Ref:       c.Args().First(),
+			Dir:       c.String("dir"),
+			ErrOutput: os.Stderr,
+			Output:    os.Stdout,
 		})
 	},
 }
@@ -104,6 +216,7 @@ var moduleTreeCommand = &cli.Command{
 type VendorInfo struct {
 	Args      []string
 	Targets   []string
+	Replace   []string
 	Tidy      bool
 	ErrOutput io.Writer
 }
@@ -155,6 +268,11 @@ func Vendor(ctx context.Context, cln *client.Client, info VendorInfo) (err error
 		return err
 	}
 
+	ctx, err = withReplace(ctx, info.Replace)
+	if err != nil {
+		return err
+	}
+
 	hasImports := false
 	parser.Match(mod, parser.MatchOpts{},
 		func(imp *parser.ImportDecl) {
@@ -224,9 +342,102 @@ func findVendoredModule(errNotExist error, name string) (io.ReadCloser, error) {
 	return os.Open(filepath.Join(matchedModules[0], module.ModuleFilename))
 }
 
+type LockInfo struct {
+	Args       []string
+	Targets    []string
+	TargetArgs []string
+	Replace    []string
+	Prune      bool
+	ErrOutput  io.Writer
+}
+
+// Lock compiles the targets of a module to record the digests of images and
+// the commits of git refs reachable from them into the lockfile. The entries
+// of other targets are kept, unless the lockfile is pruned.
+func Lock(ctx context.Context, cln *client.Client, info LockInfo) (err error) {
+	if len(info.Targets) == 0 {
+		info.Targets = []string{"default"}
+	}
+
+	rc, err := ModuleReadCloser(info.Args)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		// Handle diagnostic errors.
+		spans := diagnostic.Spans(err)
+		for _, span := range spans {
+			fmt.Fprintf(info.ErrOutput, "%s\n", span.Pretty(ctx))
+		}
+
+		err = errdefs.WithAbort(err, len(spans))
+	}()
+
+	ctx = diagnostic.WithSources(ctx, builtin.Sources())
+	mod, err := parser.Parse(ctx, rc)
+	if err != nil {
+		return err
+	}
+
+	targets, err := compileTargets(info.Targets, info.TargetArgs)
+	if err != nil {
+		return err
+	}
+
+	ctx, err = withReplace(ctx, info.Replace)
+	if err != nil {
+		return err
+	}
+
+	p, err := solver.NewProgress(ctx)
+	if err != nil {
+		return err
+	}
+
+	lockFile := module.LockFile(mod)
+	lock := &codegen.Lock{}
+	if !info.Prune {
+		lock, err = module.ReadLock(lockFile)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			lock = &codegen.Lock{}
+		}
+	}
+
+	// Exports are collected instead of solved, only the sources they depend on
+	// are of interest.
+	ctx = codegen.WithLock(ctx, lock, codegen.LockUpdate)
+	ctx = codegen.WithExports(ctx, &codegen.Exports{})
+	ctx = codegen.WithImageResolver(ctx, codegen.NewCachedImageResolver(cln))
+	ctx = codegen.WithGitResolver(ctx, codegen.NewCachedGitResolver(cln))
+
+	p.Go(func(ctx context.Context) error {
+		defer p.Release()
+		ctx = codegen.WithMultiWriter(ctx, p.MultiWriter())
+		_, err := hlb.Compile(ctx, cln, mod, targets, codegen.WithDebugger(codegen.NewNoopDebugger()))
+		return err
+	})
+
+	err = p.Wait()
+	if err != nil {
+		return err
+	}
+
+	return module.WriteLock(lockFile, lock)
+}
+
 type TreeInfo struct {
 	Args      []string
 	Long      bool
+	Replace   []string
 	ErrOutput io.Writer
 }
 
@@ -269,6 +480,11 @@ func Tree(ctx context.Context, cln *client.Client, info TreeInfo) (err error) {
 		return err
 	}
 
+	ctx, err = withReplace(ctx, info.Replace)
+	if err != nil {
+		return err
+	}
+
 	exist, err := module.ModulesPathExist()
 	if err != nil {
 		return err
@@ -304,3 +520,174 @@ func Tree(ctx context.Context, cln *client.Client, info TreeInfo) (err error) {
 	fmt.Println(tree)
 	return nil
 }
+
+// withReplace returns a context that replaces imports by the rules of the
+// replace file, where the given rules take precedence.
+func withReplace(ctx context.Context, rules []string) (context.Context, error) {
+	replace, err := module.ReadReplace(module.ReplacePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return ctx, err
+		}
+		replace = make(module.Replace)
+	}
+
+	overrides, err := module.ParseReplace(rules)
+	if err != nil {
+		return ctx, err
+	}
+	for name, path := range overrides {
+		replace[name] = path
+	}
+
+	if len(replace) == 0 {
+		return ctx, nil
+	}
+	return module.WithReplace(ctx, replace), nil
+}
+
+type VerifyInfo struct {
+	Args      []string
+	Replace   []string
+	ErrOutput io.Writer
+	Output    io.Writer
+}
+
+// Verify checks the vendored modules against the sum file, and resolves the
+// imports of a module to check that they still match what was vendored.
+func Verify(ctx context.Context, cln *client.Client, info VerifyInfo) (err error) {
+	if info.Output == nil {
+		info.Output = os.Stdout
+	}
+
+	rc, err := ModuleReadCloser(info.Args)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		// Handle diagnostic errors.
+		spans := diagnostic.Spans(err)
+		for _, span := range spans {
+			fmt.Fprintf(info.ErrOutput, "%s\n", span.Pretty(ctx))
+		}
+
+		err = errdefs.WithAbort(err, len(spans))
+	}()
+
+	ctx = diagnostic.WithSources(ctx, builtin.Sources())
+	mod, err := parser.Parse(ctx, rc)
+	if err != nil {
+		return err
+	}
+
+	err = checker.SemanticPass(mod)
+	if err != nil {
+		return err
+	}
+
+	_ = linter.Lint(ctx, mod, linter.WithRecursive())
+
+	err = checker.Check(mod)
+	if err != nil {
+		return err
+	}
+
+	ctx, err = withReplace(ctx, info.Replace)
+	if err != nil {
+		return err
+	}
+
+	p, err := solver.NewProgress(ctx)
+	if err != nil {
+		return err
+	}
+
+	var failures []string
+	p.Go(func(ctx context.Context) error {
+		defer p.Release()
+
+		var err error
+		ctx = codegen.WithMultiWriter(ctx, p.MultiWriter())
+		failures, err = module.Verify(ctx, cln, mod)
+		return err
+	})
+
+	err = p.Wait()
+	if err != nil {
+		return err
+	}
+
+	for _, failure := range failures {
+		fmt.Fprintf(info.ErrOutput, "%s\n", failure)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d modules failed verification", len(failures))
+	}
+
+	fmt.Fprintln(info.Output, "all modules verified")
+	return nil
+}
+
+type PublishInfo struct {
+	Ref       string
+	Dir       string
+	ErrOutput io.Writer
+	Output    io.Writer
+}
+
+// Publish checks the module in a directory and pushes it as an OCI artifact.
+func Publish(ctx context.Context, info PublishInfo) (err error) {
+	if info.Output == nil {
+		info.Output = os.Stdout
+	}
+
+	f, err := os.Open(filepath.Join(info.Dir, module.ModuleFilename))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		// Handle diagnostic errors.
+		spans := diagnostic.Spans(err)
+		for _, span := range spans {
+			fmt.Fprintf(info.ErrOutput, "%s\n", span.Pretty(ctx))
+		}
+
+		err = errdefs.WithAbort(err, len(spans))
+	}()
+
+	ctx = diagnostic.WithSources(ctx, builtin.Sources())
+	mod, err := parser.Parse(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	err = checker.SemanticPass(mod)
+	if err != nil {
+		return err
+	}
+
+	err = checker.Check(mod)
+	if err != nil {
+		return err
+	}
+
+	dgst, err := module.Publish(ctx, info.Dir, info.Ref)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(info.Output, "published %s with digest %s\n", info.Ref, dgst)
+	return nil
+}
@@ -6,16 +6,20 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/mattn/go-isatty"
 	"github.com/moby/buildkit/client"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/openllb/hlb"
 	"github.com/openllb/hlb/builtin"
 	"github.com/openllb/hlb/codegen"
 	"github.com/openllb/hlb/diagnostic"
 	"github.com/openllb/hlb/errdefs"
 	"github.com/openllb/hlb/local"
+	"github.com/openllb/hlb/module"
 	"github.com/openllb/hlb/parser"
+	"github.com/openllb/hlb/pkg/tracing"
 	"github.com/openllb/hlb/solver"
 	cli "github.com/urfave/cli/v2"
 	"github.com/xlab/treeprint"
@@ -36,6 +40,14 @@ var runCommand = &cli.Command{
 			Usage:   "specify target filesystem to solve",
 			Value:   cli.NewStringSlice("default"),
 		},
+		&cli.StringSliceFlag{
+			Name:  "arg",
+			Usage: "set a parameter of the target functions, e.g. --arg version=1.2.3",
+		},
+		&cli.StringSliceFlag{
+			Name:  "replace",
+			Usage: "replace an import with a local module, e.g. --replace go=../go.hlb",
+		},
 		&cli.BoolFlag{
 			Name:  "debug",
 			Usage: "jump into a source level debugger for hlb",
@@ -44,11 +56,58 @@ var runCommand = &cli.Command{
 			Name:  "tree",
 			Usage: "print out the request tree without solving",
 		},
+		&cli.GenericFlag{
+			Name:  "llb",
+			Usage: "print out the LLB definitions without solving, --llb=pb only prints a single definition (json, pb)",
+			Value: &llbFormat{},
+		},
+		&cli.StringSliceFlag{
+			Name:  "cache-to",
+			Usage: "export the build cache, e.g. --cache-to type=local,dest=/tmp/cache (local, inline, registry)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "cache-from",
+			Usage: "import a build cache, e.g. --cache-from type=local,src=/tmp/cache (local, inline, registry)",
+		},
+		&cli.BoolFlag{
+			Name:  "locked",
+			Usage: "fail if an image or git ref is not pinned by the lockfile",
+		},
+		&cli.BoolFlag{
+			Name:  "check-lock",
+			Usage: "warn if a git ref or oci module has moved from what is pinned by the lockfile, which contacts their remotes",
+		},
 		&cli.StringFlag{
 			Name:  "log-output",
-			Usage: "set type of log output (auto, tty, plain)",
+			Usage: "set type of log output (auto, tty, plain, json)",
 			Value: "auto",
 		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "set format of diagnostics (text, json, sarif)",
+			Value: "text",
+		},
+		&cli.BoolFlag{
+			Name:  "summary",
+			Usage: "print a timing report of the slowest ops, cache hits and critical path after solving",
+		},
+		&cli.StringFlag{
+			Name:  "report",
+			Usage: "write a timing report as JSON after solving, e.g. --report build-report.json",
+		},
+		&cli.StringFlag{
+			Name:    "trace-endpoint",
+			Usage:   "export spans of the compile and solve phases to an OTLP/HTTP endpoint, e.g. --trace-endpoint http://localhost:4318",
+			EnvVars: []string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"},
+		},
+		&cli.StringFlag{
+			Name:  "trace-file",
+			Usage: "write spans of the compile and solve phases as OTLP/JSON to a file, e.g. --trace-file trace.json",
+		},
+		&cli.BoolFlag{
+			Name:  "shell-on-error",
+			Usage: "start a shell in the container of a failed exec, implies plain log output",
+		},
 		&cli.BoolFlag{
 			Name:    "backtrace",
 			Usage:   "print out the backtrace when encountering an error",
@@ -68,27 +127,51 @@ var runCommand = &cli.Command{
 		}
 
 		return Run(ctx, cln, rc, RunInfo{
-			Debug:     c.Bool("debug"),
-			Tree:      c.Bool("tree"),
-			Targets:   c.StringSlice("target"),
-			LLB:       c.Bool("llb"),
-			Backtrace: c.Bool("backtrace"),
-			LogOutput: c.String("log-output"),
-			ErrOutput: os.Stderr,
-			Output:    os.Stdout,
+			Debug:         c.Bool("debug"),
+			Tree:          c.Bool("tree"),
+			Targets:       c.StringSlice("target"),
+			Args:          c.StringSlice("arg"),
+			Replace:       c.StringSlice("replace"),
+			CacheTo:       c.StringSlice("cache-to"),
+			CacheFrom:     c.StringSlice("cache-from"),
+			LLB:           c.Generic("llb").(*llbFormat).String(),
+			Locked:        c.Bool("locked"),
+			CheckLock:     c.Bool("check-lock"),
+			Backtrace:     c.Bool("backtrace"),
+			ShellOnError:  c.Bool("shell-on-error"),
+			LogOutput:     c.String("log-output"),
+			Format:        c.String("format"),
+			Summary:       c.Bool("summary"),
+			Report:        c.String("report"),
+			TraceFile:     c.String("trace-file"),
+			TraceEndpoint: c.String("trace-endpoint"),
+			ErrOutput:     os.Stderr,
+			Output:        os.Stdout,
 		})
 	},
 }
 
 type RunInfo struct {
-	Debug     bool
-	Tree      bool
-	Backtrace bool
-	Targets   []string
-	LLB       bool
-	LogOutput string
-	ErrOutput io.Writer
-	Output    io.Writer
+	Debug         bool
+	Tree          bool
+	Backtrace     bool
+	ShellOnError  bool
+	Targets       []string
+	Args          []string
+	Replace       []string
+	CacheTo       []string
+	CacheFrom     []string
+	LLB           string
+	Locked        bool
+	CheckLock     bool
+	LogOutput     string
+	Format        string
+	Summary       bool
+	Report        string
+	TraceFile     string
+	TraceEndpoint string
+	ErrOutput     io.Writer
+	Output        io.Writer
 
 	// override defaults sources as necessary
 	Environ []string
@@ -113,7 +196,40 @@ func Run(ctx context.Context, cln *client.Client, rc io.ReadCloser, info RunInfo
 	ctx = local.WithOs(ctx, info.Os)
 	ctx = local.WithArch(ctx, info.Arch)
 
+	tracer, err := newTracer(info.TraceEndpoint, info.TraceFile)
+	if err != nil {
+		return err
+	}
+	if tracer != nil {
+		ctx = tracing.WithTracer(ctx, tracer)
+
+		var span *tracing.Span
+		ctx, span = tracing.Start(ctx, "hlb run", tracing.StringSlice("hlb.targets", info.Targets))
+		defer func() {
+			span.SetError(err)
+			span.End()
+
+			// Spans are exported for failed builds too, as the failure is part
+			// of the trace.
+			terr := tracer.Shutdown(context.Background())
+			if err == nil {
+				err = terr
+			}
+		}()
+	}
+
 	var progressOpts []solver.ProgressOption
+	if info.ShellOnError {
+		// The tty progress UI redraws over the shell, so fall back to plain.
+		switch info.LogOutput {
+		case "", "auto":
+			info.LogOutput = "plain"
+		case "plain", "json":
+		default:
+			return fmt.Errorf("--shell-on-error cannot be used with log-output %q", info.LogOutput)
+		}
+	}
+
 	if info.LogOutput == "" || info.LogOutput == "auto" {
 		// assume plain output, will upgrade if we detect tty
 		info.LogOutput = "plain"
@@ -129,10 +245,18 @@ func Run(ctx context.Context, cln *client.Client, rc io.ReadCloser, info RunInfo
 		progressOpts = append(progressOpts, solver.WithLogOutput(solver.LogOutputTTY))
 	case "plain":
 		progressOpts = append(progressOpts, solver.WithLogOutput(solver.LogOutputPlain))
+	case "json":
+		progressOpts = append(progressOpts, solver.WithLogOutput(solver.LogOutputJSON))
 	default:
 		return fmt.Errorf("unrecognized log-output %q", info.LogOutput)
 	}
 
+	var rec *solver.Recorder
+	if info.Summary || info.Report != "" {
+		rec = solver.NewRecorder()
+		progressOpts = append(progressOpts, solver.WithRecorder(rec))
+	}
+
 	var p solver.Progress
 	if info.Debug {
 		p = solver.NewDebugProgress(ctx)
@@ -143,12 +267,47 @@ func Run(ctx context.Context, cln *client.Client, rc io.ReadCloser, info RunInfo
 			return err
 		}
 		ctx = codegen.WithMultiWriter(ctx, p.MultiWriter())
+		ctx = solver.WithProgress(ctx, p)
 	}
 
 	ctx = diagnostic.WithSources(ctx, builtin.Sources())
 
+	switch info.Format {
+	case "", "text":
+	case "json", "sarif":
+		var (
+			mu       sync.Mutex
+			warnings []error
+		)
+		ctx = diagnostic.WithWarningHandler(ctx, func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			warnings = append(warnings, err)
+		})
+
+		defer func() {
+			var diags []diagnostic.Diagnostic
+			for _, warning := range warnings {
+				diags = append(diags, diagnostic.Report(warning)...)
+			}
+
+			if err != nil {
+				backtrace := diagnostic.Backtrace(ctx, err)
+				if len(backtrace) > 0 {
+					diags = append(diags, diagnostic.ReportBacktrace(err, backtrace))
+				} else {
+					diags = append(diags, diagnostic.Report(err)...)
+				}
+			}
+
+			err = writeDiagnostics(info.Output, info.Format, diags)
+		}()
+	default:
+		return fmt.Errorf("unrecognized format %q", info.Format)
+	}
+
 	defer func() {
-		if err == nil {
+		if err == nil || info.Format == "json" || info.Format == "sarif" {
 			return
 		}
 
@@ -207,12 +366,54 @@ func Run(ctx context.Context, cln *client.Client, rc io.ReadCloser, info RunInfo
 		return err
 	}
 
-	var targets []codegen.Target
-	for _, target := range info.Targets {
-		targets = append(targets, codegen.Target{Name: target})
+	targets, err := compileTargets(info.Targets, info.Args)
+	if err != nil {
+		return err
 	}
 
+	ctx, err = withReplace(ctx, info.Replace)
+	if err != nil {
+		return err
+	}
+
+	// Sources are pinned by the lockfile of the module if there is one.
+	lockFile := module.LockFile(mod)
+	lock, err := module.ReadLock(lockFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if info.Locked {
+			return fmt.Errorf("--locked requires %s, run `hlb module lock` to create it", lockFile)
+		}
+	} else {
+		mode := codegen.LockPin
+		if info.Locked {
+			mode = codegen.LockStrict
+		}
+		ctx = codegen.WithLock(ctx, lock, mode)
+		if info.CheckLock {
+			ctx = codegen.WithLockCheck(ctx)
+		}
+	}
+
+	// Exports are solved while compiling, so they are collected instead when
+	// only printing the LLB definitions.
+	var exports *codegen.Exports
+	if info.LLB != "" {
+		exports = &codegen.Exports{}
+		ctx = codegen.WithExports(ctx, exports)
+	}
+
+	// Caches apply to the exports solved while compiling too.
+	cacheOpts, err := cacheOptions(info.CacheTo, info.CacheFrom)
+	if err != nil {
+		return err
+	}
+	ctx = solver.WithSolveOptions(ctx, cacheOpts...)
+
 	ctx = codegen.WithImageResolver(ctx, codegen.NewCachedImageResolver(cln))
+	ctx = codegen.WithGitResolver(ctx, codegen.NewCachedGitResolver(cln))
 	solveReq, err := hlb.Compile(ctx, cln, mod, targets)
 	if err != nil {
 		// Ignore early exits from the debugger.
@@ -222,7 +423,11 @@ func Run(ctx context.Context, cln *client.Client, rc io.ReadCloser, info RunInfo
 		return err
 	}
 
-	if solveReq == nil || info.Debug || info.Tree {
+	if solveReq != nil && exports != nil {
+		solveReq = solver.Parallel(solveReq, exports.Request())
+	}
+
+	if solveReq == nil || info.Debug || info.Tree || info.LLB != "" {
 		p.Release()
 		err = p.Wait()
 		if err != nil {
@@ -245,12 +450,185 @@ func Run(ctx context.Context, cln *client.Client, rc io.ReadCloser, info RunInfo
 		return nil
 	}
 
+	if info.LLB != "" {
+		l, err := solveReq.LLB()
+		if err != nil {
+			return err
+		}
+
+		switch info.LLB {
+		case "json":
+			return l.WriteJSON(info.Output)
+		case "pb":
+			return l.WriteProtobuf(info.Output)
+		default:
+			return fmt.Errorf("unrecognized llb format %q", info.LLB)
+		}
+	}
+
+	var solveOpts []solver.SolveOption
+	if info.ShellOnError {
+		solveOpts = append(solveOpts, solver.WithErrorHandler(shellOnError(ctx, info)))
+	}
+
 	p.Go(func(ctx context.Context) error {
 		defer p.Release()
+		ctx = solver.WithSolveOptions(ctx, solveOpts...)
 		return solveReq.Solve(ctx, cln, p.MultiWriter())
 	})
 
-	return p.Wait()
+	err = p.Wait()
+	if rec != nil {
+		// Reports are written for failed builds too, as the timings up to the
+		// failure are still useful.
+		rerr := writeReport(rec.Report(), info)
+		if err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// newTracer returns a tracer exporting spans to an OTLP/HTTP endpoint or a
+// file, or nil if tracing is disabled.
+func newTracer(endpoint, filename string) (*tracing.Tracer, error) {
+	var exporter tracing.Exporter
+	switch {
+	case endpoint != "" && filename != "":
+		return nil, fmt.Errorf("--trace-endpoint cannot be used with --trace-file")
+	case endpoint != "":
+		var err error
+		exporter, err = tracing.NewOTLPExporter(endpoint)
+		if err != nil {
+			return nil, err
+		}
+	case filename != "":
+		exporter = tracing.NewFileExporter(filename)
+	default:
+		return nil, nil
+	}
+
+	return tracing.NewTracer(exporter,
+		tracing.String("service.name", "hlb"),
+		tracing.String("service.version", hlb.Version),
+	)
+}
+
+// writeReport prints the timing report of a build and writes it as JSON if
+// requested.
+func writeReport(report *solver.Report, info RunInfo) error {
+	if info.Summary {
+		err := report.WriteText(info.ErrOutput)
+		if err != nil {
+			return err
+		}
+	}
+
+	if info.Report == "" {
+		return nil
+	}
+
+	f, err := os.Create(info.Report)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return report.WriteJSON(f)
+}
+
+// cacheOptions returns the solve options to export and import the build cache
+// given as specs, e.g. type=local,dest=/tmp/cache.
+func cacheOptions(cacheTo, cacheFrom []string) ([]solver.SolveOption, error) {
+	var opts []solver.SolveOption
+	for _, spec := range cacheTo {
+		entry, err := solver.ParseCacheOption(spec)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, solver.WithCacheExport(entry))
+	}
+	for _, spec := range cacheFrom {
+		entry, err := solver.ParseCacheOption(spec)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Type == solver.CacheInline {
+			entry.Type = solver.CacheRegistry
+		}
+		opts = append(opts, solver.WithCacheImport(entry))
+	}
+	return opts, nil
+}
+
+// compileTargets returns the targets to compile with the arguments given as
+// name=value pairs.
+func compileTargets(names, args []string) ([]codegen.Target, error) {
+	targetArgs := make(map[string]string)
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid arg %q, expected name=value", arg)
+		}
+		targetArgs[kv[0]] = kv[1]
+	}
+
+	var targets []codegen.Target
+	for _, name := range names {
+		targets = append(targets, codegen.Target{Name: name, Args: targetArgs})
+	}
+	return targets, nil
+}
+
+// llbFormat is the value of the llb flag, which may be set without a format
+// like a bool flag to print JSON.
+type llbFormat struct {
+	format string
+}
+
+func (f *llbFormat) Set(value string) error {
+	switch value {
+	case "true":
+		f.format = "json"
+	case "false":
+		f.format = ""
+	case "json", "pb":
+		f.format = value
+	default:
+		return fmt.Errorf("unrecognized llb format %q", value)
+	}
+	return nil
+}
+
+func (f *llbFormat) String() string {
+	return f.format
+}
+
+func (f *llbFormat) IsBoolFlag() bool {
+	return true
+}
+
+// shellOnError returns an error handler that starts a shell in the container
+// of the first failed exec. The sources of the given context are used to print
+// the HLB source of the failure.
+func shellOnError(sctx context.Context, info RunInfo) solver.ErrorHandler {
+	var once sync.Once
+	return func(ctx context.Context, c gateway.Client, solveErr error) {
+		once.Do(func() {
+			backtrace := diagnostic.Backtrace(sctx, solveErr)
+			if len(backtrace) > 0 {
+				span := backtrace[len(backtrace)-1]
+				fmt.Fprintf(info.ErrOutput, "%s\n", span.Pretty(sctx, diagnostic.WithNumContext(2)))
+			}
+
+			ok, err := solver.RunContainerOnError(ctx, c, solveErr, []string{"/bin/sh"}, os.Stdin, info.Output, info.ErrOutput)
+			if !ok {
+				fmt.Fprintf(info.ErrOutput, "failure did not come from an exec, skipping shell\n")
+			} else if err != nil {
+				fmt.Fprintf(info.ErrOutput, "shell exited: %s\n", err)
+			}
+		})
+	}
 }
 
 func ModuleReadCloser(args []string) (io.ReadCloser, error) {
@@ -0,0 +1,25 @@
+package codegen
+
+import (
+	"context"
+
+	"github.com/moby/buildkit/client"
+)
+
+type Equals struct{}
+
+func (e Equals) Call(ctx context.Context, cln *client.Client, ret Register, opts Option, a, b string) error {
+	return ret.Set(a == b)
+}
+
+type NotEmpty struct{}
+
+func (ne NotEmpty) Call(ctx context.Context, cln *client.Client, ret Register, opts Option, value string) error {
+	return ret.Set(value != "")
+}
+
+type Not struct{}
+
+func (n Not) Call(ctx context.Context, cln *client.Client, ret Register, opts Option, value bool) error {
+	return ret.Set(!value)
+}
@@ -7,7 +7,9 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
@@ -21,11 +23,14 @@ import (
 	gateway "github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/moby/buildkit/session/filesync"
 	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
 	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/openllb/hlb/diagnostic"
 	"github.com/openllb/hlb/errdefs"
 	"github.com/openllb/hlb/local"
 	"github.com/openllb/hlb/parser"
 	"github.com/openllb/hlb/pkg/llbutil"
+	"github.com/openllb/hlb/pkg/ociutil"
 	"github.com/openllb/hlb/solver"
 	fstypes "github.com/tonistiigi/fsutil/types"
 	"golang.org/x/sync/errgroup"
@@ -40,9 +45,17 @@ func (s Scratch) Call(ctx context.Context, cln *client.Client, ret Register, opt
 type Image struct{}
 
 func (i Image) Call(ctx context.Context, cln *client.Client, ret Register, opts Option, ref string) error {
-	var imageOpts []llb.ImageOption
+	var (
+		imageOpts []llb.ImageOption
+		solveOpts []solver.SolveOption
+	)
 	for _, opt := range opts {
-		imageOpts = append(imageOpts, opt.(llb.ImageOption))
+		switch o := opt.(type) {
+		case llb.ImageOption:
+			imageOpts = append(imageOpts, o)
+		case solver.SolveOption:
+			solveOpts = append(solveOpts, o)
+		}
 	}
 	for _, opt := range SourceMap(ctx) {
 		imageOpts = append(imageOpts, opt)
@@ -54,18 +67,52 @@ func (i Image) Call(ctx context.Context, cln *client.Client, ret Register, opts
 	}
 	ref = reference.TagNameOnly(named).String()
 
+	// Refs with digests are already pinned.
 	var (
-		st       = llb.Image(ref, imageOpts...)
+		lv     = lockFrom(ctx)
+		locked digest.Digest
+	)
+	if _, ok := named.(reference.Canonical); ok {
+		lv = nil
+	}
+	if lv != nil && lv.mode != LockUpdate {
+		locked = lv.lock.image(ref)
+		if locked == "" && lv.mode == LockStrict {
+			return errdefs.WithNotLocked(Arg(ctx, 0), fmt.Sprintf("image `%s`", ref))
+		}
+	}
+
+	pinnedRef := ref
+	if locked != "" {
+		pinnedRef = fmt.Sprintf("%s@%s", ref, locked)
+	}
+
+	var (
+		st       = llb.Image(pinnedRef, imageOpts...)
 		image    = &specs.Image{}
 		resolver = ImageResolver(ctx)
 	)
 
 	if resolver != nil {
-		_, config, err := resolver.ResolveImageConfig(ctx, ref, llb.ResolveImageConfigOpt{})
+		dgst, config, err := resolver.ResolveImageConfig(ctx, ref, llb.ResolveImageConfigOpt{})
 		if err != nil {
 			return err
 		}
 
+		if lv != nil && lv.mode == LockUpdate {
+			lv.lock.setImage(ref, dgst)
+		}
+
+		// The config of the ref may have changed with its digest.
+		if locked != "" && dgst != locked {
+			diagnostic.Warn(ctx, errdefs.WithLockDrift(Arg(ctx, 0), fmt.Sprintf("image `%s`", ref), locked.String(), dgst.String()))
+
+			_, config, err = resolver.ResolveImageConfig(ctx, pinnedRef, llb.ResolveImageConfigOpt{})
+			if err != nil {
+				return err
+			}
+		}
+
 		st, err = st.WithImageConfig(config)
 		if err != nil {
 			return err
@@ -78,8 +125,9 @@ func (i Image) Call(ctx context.Context, cln *client.Client, ret Register, opts
 	}
 
 	return ret.Set(Filesystem{
-		State: st,
-		Image: image,
+		State:     st,
+		Image:     image,
+		SolveOpts: solveOpts,
 	})
 }
 
@@ -97,6 +145,105 @@ func (h HTTP) Call(ctx context.Context, cln *client.Client, ret Register, opts O
 	return ret.Set(llb.HTTP(url, httpOpts...))
 }
 
+type OCI struct{}
+
+func (o OCI) Call(ctx context.Context, cln *client.Client, ret Register, opts Option, ref string) error {
+	lockRef, pinned, err := artifactRef(ref)
+	if err != nil {
+		return errdefs.WithInvalidImageRef(err, Arg(ctx, 0), ref)
+	}
+
+	// Refs with digests are already pinned.
+	var (
+		lv     = lockFrom(ctx)
+		locked digest.Digest
+		source = fmt.Sprintf("oci `%s`", lockRef)
+	)
+	if pinned {
+		lv = nil
+	}
+	if lv != nil && lv.mode != LockUpdate {
+		locked = lv.lock.artifact(lockRef)
+		if locked == "" && lv.mode == LockStrict {
+			return errdefs.WithNotLocked(Arg(ctx, 0), source)
+		}
+	}
+
+	pullRef := ref
+	switch {
+	case locked != "":
+		pullRef = fmt.Sprintf("%s@%s", lockRef, locked)
+
+		// Resolving the ref contacts its registry, so drift is only checked
+		// when asked to.
+		if lockCheck(ctx) {
+			dgst, err := ociutil.Resolve(ctx, ref)
+			if err == nil && dgst != locked {
+				diagnostic.Warn(ctx, errdefs.WithLockDrift(Arg(ctx, 0), source, locked.String(), dgst.String()))
+			}
+		}
+	case lv != nil && lv.mode == LockUpdate:
+		dgst, err := ociutil.Resolve(ctx, ref)
+		if err != nil {
+			return errdefs.WithPullArtifact(err, Arg(ctx, 0), ref)
+		}
+		lv.lock.setArtifact(lockRef, dgst)
+		pullRef = fmt.Sprintf("%s@%s", lockRef, dgst)
+	}
+
+	fs, err := ZeroValue().Filesystem()
+	if err != nil {
+		return err
+	}
+
+	// The artifact is only pulled when the filesystem is marshaled, so that
+	// imports can be resolved from the ref by the vendor and replace
+	// resolvers first.
+	var (
+		arg       = Arg(ctx, 0)
+		sourceMap = SourceMap(ctx)
+	)
+	fs.State = fs.State.Async(func(ctx context.Context, st llb.State) (llb.State, error) {
+		artifact, err := ociutil.Pull(ctx, pullRef)
+		if err != nil {
+			return st, errdefs.WithPullArtifact(err, arg, ref)
+		}
+
+		var filenames []string
+		for filename := range artifact.Files {
+			filenames = append(filenames, filename)
+		}
+		sort.Strings(filenames)
+
+		for _, filename := range filenames {
+			if dir := path.Dir(filename); dir != "." {
+				st = st.File(llb.Mkdir(path.Join("/", dir), 0755, llb.WithParents(true)), sourceMap...)
+			}
+			st = st.File(llb.Mkfile(path.Join("/", filename), 0644, artifact.Files[filename]), sourceMap...)
+		}
+		return st, nil
+	})
+
+	// Keep the ref so that imports can pull the artifact without solving.
+	fs.artifactRef, fs.artifactOutput = pullRef, fs.State.Output()
+	return ret.Set(fs)
+}
+
+// artifactRef returns the ref an artifact is locked by, and whether the ref is
+// already pinned to a digest.
+func artifactRef(ref string) (string, bool, error) {
+	if strings.HasPrefix(ref, ociutil.LayoutPrefix) {
+		return ref, strings.Contains(ref, "@"), nil
+	}
+
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", false, err
+	}
+	_, pinned := named.(reference.Canonical)
+	return reference.TagNameOnly(named).String(), pinned, nil
+}
+
 type Git struct{}
 
 func (g Git) Call(ctx context.Context, cln *client.Client, ret Register, opts Option, remote, ref string) error {
@@ -108,6 +255,42 @@ func (g Git) Call(ctx context.Context, cln *client.Client, ret Register, opts Op
 		gitOpts = append(gitOpts, opt)
 	}
 
+	if lv := lockFrom(ctx); lv != nil && !commitRegexp.MatchString(ref) {
+		var (
+			source   = fmt.Sprintf("git `%s` `%s`", remote, ref)
+			resolver = GitResolver(ctx)
+		)
+		switch lv.mode {
+		case LockUpdate:
+			if resolver == nil {
+				return Arg(ctx, 1).WithError(fmt.Errorf("cannot lock %s without a git resolver", source))
+			}
+			commit, err := resolver.ResolveGitCommit(ctx, remote, ref)
+			if err != nil {
+				return Arg(ctx, 1).WithError(err)
+			}
+			lv.lock.setGit(remote, ref, commit)
+		default:
+			locked := lv.lock.git(remote, ref)
+			if locked == "" {
+				if lv.mode == LockStrict {
+					return errdefs.WithNotLocked(Arg(ctx, 1), source)
+				}
+				break
+			}
+
+			// A ref that can no longer be resolved, such as a deleted branch,
+			// still builds the locked commit.
+			if resolver != nil && lockCheck(ctx) {
+				commit, err := resolver.ResolveGitCommit(ctx, remote, ref)
+				if err == nil && commit != locked {
+					diagnostic.Warn(ctx, errdefs.WithLockDrift(Arg(ctx, 1), source, locked, commit))
+				}
+			}
+			ref = locked
+		}
+	}
+
 	return ret.Set(llb.Git(remote, ref, gitOpts...))
 }
 
@@ -557,6 +740,7 @@ func (dp DockerPush) Call(ctx context.Context, cln *client.Client, ret Register,
 	}
 
 	var dgst string
+	exportFS.SolveOpts = append(exportFS.SolveOpts, exportSolveOpts(opts)...)
 	exportFS.SolveOpts = append(exportFS.SolveOpts,
 		solver.WithImageSpec(exportFS.Image),
 		solver.WithPushImage(ref),
@@ -578,11 +762,14 @@ func (dp DockerPush) Call(ctx context.Context, cln *client.Client, ret Register,
 
 	g, ctx := errgroup.WithContext(ctx)
 
-	g.Go(func() error {
-		return request.Solve(ctx, cln, MultiWriter(ctx))
-	})
+	solved := solveExport(ctx, g, cln, request)
 
 	if Binding(ctx).Binds() == "digest" {
+		// The callback setting the digest never fires for collected exports.
+		if !solved {
+			return ret.Set(UnpushedDigest)
+		}
+
 		err = g.Wait()
 		if err != nil {
 			return err
@@ -618,6 +805,7 @@ func (dl DockerLoad) Call(ctx context.Context, cln *client.Client, ret Register,
 		return err
 	}
 
+	exportFS.SolveOpts = append(exportFS.SolveOpts, exportSolveOpts(opts)...)
 	exportFS.SolveOpts = append(exportFS.SolveOpts,
 		solver.WithImageSpec(exportFS.Image),
 		solver.WithDownloadDockerTarball(ref),
@@ -638,62 +826,113 @@ func (dl DockerLoad) Call(ctx context.Context, cln *client.Client, ret Register,
 
 	g, ctx := errgroup.WithContext(ctx)
 
-	g.Go(func() error {
-		return request.Solve(ctx, cln, MultiWriter(ctx))
-	})
+	if solveExport(ctx, g, cln, request) {
+		g.Go(func() error {
+			return loadDockerImage(ctx, r, ref)
+		})
+	}
 
-	g.Go(func() (err error) {
-		dockerOnce.Do(func() {
-			dockerCli, err = command.NewDockerCli()
-			if err != nil {
-				return
-			}
+	fs, err := ret.Filesystem()
+	if err != nil {
+		return err
+	}
 
-			err = dockerCli.Initialize(flags.NewClientOptions())
-			if err != nil {
-				return
-			}
+	fs.SolveOpts = append(fs.SolveOpts, WithCallbackErrgroup(ctx, g))
 
-			_, err = dockerCli.Client().ServerVersion(ctx)
-		})
+	return ret.Set(fs)
+}
+
+func loadDockerImage(ctx context.Context, r *io.PipeReader, ref string) (err error) {
+	dockerOnce.Do(func() {
+		dockerCli, err = command.NewDockerCli()
 		if err != nil {
-			r.CloseWithError(err)
-			return err
+			return
 		}
 
-		defer func() {
-			if err != nil {
-				err = r.CloseWithError(err)
-			} else {
-				err = r.Close()
-			}
-		}()
+		err = dockerCli.Initialize(flags.NewClientOptions())
+		if err != nil {
+			return
+		}
 
-		resp, err := dockerCli.Client().ImageLoad(ctx, r, true)
+		_, err = dockerCli.Client().ServerVersion(ctx)
+	})
+	if err != nil {
+		r.CloseWithError(err)
+		return err
+	}
+
+	defer func() {
 		if err != nil {
-			return err
+			err = r.CloseWithError(err)
+		} else {
+			err = r.Close()
 		}
-		defer resp.Body.Close()
+	}()
 
-		mw := MultiWriter(ctx)
-		if mw == nil {
-			_, err = io.Copy(ioutil.Discard, resp.Body)
-			return err
+	resp, err := dockerCli.Client().ImageLoad(ctx, r, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	mw := MultiWriter(ctx)
+	if mw == nil {
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+		return err
+	}
+
+	pw := mw.WithPrefix("", false)
+	progress.FromReader(pw, fmt.Sprintf("importing %s to docker", ref), resp.Body)
+	return nil
+}
+
+// exportSolveOpts returns the solve options of an export, such as the caches
+// to export to.
+func exportSolveOpts(opts Option) []solver.SolveOption {
+	var solveOpts []solver.SolveOption
+	for _, opt := range opts {
+		if o, ok := opt.(solver.SolveOption); ok {
+			solveOpts = append(solveOpts, o)
 		}
+	}
+	return solveOpts
+}
+
+// solveExport solves the request of an export in the errgroup, unless exports
+// are collected with WithExports. It returns true if the request is solved.
+func solveExport(ctx context.Context, g *errgroup.Group, cln *client.Client, request solver.Request) bool {
+	if exports := exportsFrom(ctx); exports != nil {
+		exports.add(request)
+		return false
+	}
 
-		pw := mw.WithPrefix("", false)
-		progress.FromReader(pw, fmt.Sprintf("importing %s to docker", ref), resp.Body)
-		return nil
+	g.Go(func() error {
+		return request.Solve(ctx, cln, MultiWriter(ctx))
 	})
+	return true
+}
 
-	fs, err := ret.Filesystem()
+// createExportFile creates the file a tarball is exported to, or discards the
+// tarball if exports are collected with WithExports.
+func createExportFile(ctx context.Context, localPath string) (io.WriteCloser, error) {
+	if exportsFrom(ctx) != nil {
+		return nopWriteCloser{ioutil.Discard}, nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(localPath), 0755)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fs.SolveOpts = append(fs.SolveOpts, WithCallbackErrgroup(ctx, g))
+	return os.Create(localPath)
+}
 
-	return ret.Set(fs)
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
 }
 
 type Download struct{}
@@ -709,6 +948,7 @@ func (d Download) Call(ctx context.Context, cln *client.Client, ret Register, op
 		return err
 	}
 
+	exportFS.SolveOpts = append(exportFS.SolveOpts, exportSolveOpts(opts)...)
 	exportFS.SolveOpts = append(exportFS.SolveOpts, solver.WithDownload(localPath))
 	exportFS.SessionOpts = append(exportFS.SessionOpts, llbutil.WithSyncTargetDir(localPath))
 
@@ -724,9 +964,7 @@ func (d Download) Call(ctx context.Context, cln *client.Client, ret Register, op
 
 	g, ctx := errgroup.WithContext(ctx)
 
-	g.Go(func() error {
-		return request.Solve(ctx, cln, MultiWriter(ctx))
-	})
+	solveExport(ctx, g, cln, request)
 
 	fs, err := ret.Filesystem()
 	if err != nil {
@@ -746,12 +984,7 @@ func (dt DownloadTarball) Call(ctx context.Context, cln *client.Client, ret Regi
 		return err
 	}
 
-	err = os.MkdirAll(filepath.Dir(localPath), 0755)
-	if err != nil {
-		return err
-	}
-
-	f, err := os.Create(localPath)
+	f, err := createExportFile(ctx, localPath)
 	if err != nil {
 		return err
 	}
@@ -776,9 +1009,7 @@ func (dt DownloadTarball) Call(ctx context.Context, cln *client.Client, ret Regi
 
 	g, ctx := errgroup.WithContext(ctx)
 
-	g.Go(func() error {
-		return request.Solve(ctx, cln, MultiWriter(ctx))
-	})
+	solveExport(ctx, g, cln, request)
 
 	fs, err := ret.Filesystem()
 	if err != nil {
@@ -798,12 +1029,7 @@ func (dot DownloadOCITarball) Call(ctx context.Context, cln *client.Client, ret
 		return err
 	}
 
-	err = os.MkdirAll(filepath.Dir(localPath), 0755)
-	if err != nil {
-		return err
-	}
-
-	f, err := os.Create(localPath)
+	f, err := createExportFile(ctx, localPath)
 	if err != nil {
 		return err
 	}
@@ -828,9 +1054,7 @@ func (dot DownloadOCITarball) Call(ctx context.Context, cln *client.Client, ret
 
 	g, ctx := errgroup.WithContext(ctx)
 
-	g.Go(func() error {
-		return request.Solve(ctx, cln, MultiWriter(ctx))
-	})
+	solveExport(ctx, g, cln, request)
 
 	fs, err := ret.Filesystem()
 	if err != nil {
@@ -850,12 +1074,7 @@ func (dot DownloadDockerTarball) Call(ctx context.Context, cln *client.Client, r
 		return err
 	}
 
-	err = os.MkdirAll(filepath.Dir(localPath), 0755)
-	if err != nil {
-		return err
-	}
-
-	f, err := os.Create(localPath)
+	f, err := createExportFile(ctx, localPath)
 	if err != nil {
 		return err
 	}
@@ -883,9 +1102,7 @@ func (dot DownloadDockerTarball) Call(ctx context.Context, cln *client.Client, r
 
 	g, ctx := errgroup.WithContext(ctx)
 
-	g.Go(func() error {
-		return request.Solve(ctx, cln, MultiWriter(ctx))
-	})
+	solveExport(ctx, g, cln, request)
 
 	fs, err := ret.Filesystem()
 	if err != nil {
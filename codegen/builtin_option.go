@@ -36,6 +36,61 @@ func (ir Resolve) Call(ctx context.Context, cln *client.Client, ret Register, op
 	return nil
 }
 
+type CacheFrom struct{}
+
+func (cf CacheFrom) Call(ctx context.Context, cln *client.Client, ret Register, opts Option, typ, ref string) error {
+	retOpts, err := ret.Option()
+	if err != nil {
+		return err
+	}
+
+	ref, err = cacheRef(ctx, typ, ref)
+	if err != nil {
+		return err
+	}
+
+	entry, err := solver.NewCacheImport(typ, ref)
+	if err != nil {
+		return err
+	}
+
+	return ret.Set(append(retOpts, solver.WithCacheImport(entry)))
+}
+
+type CacheTo struct{}
+
+func (ct CacheTo) Call(ctx context.Context, cln *client.Client, ret Register, opts Option, typ, ref string) error {
+	retOpts, err := ret.Option()
+	if err != nil {
+		return err
+	}
+
+	ref, err = cacheRef(ctx, typ, ref)
+	if err != nil {
+		return err
+	}
+
+	entry, err := solver.NewCacheExport(typ, ref)
+	if err != nil {
+		return err
+	}
+
+	return ret.Set(append(retOpts, solver.WithCacheExport(entry)))
+}
+
+// cacheRef validates the cache type and resolves local caches relative to the
+// module.
+func cacheRef(ctx context.Context, typ, ref string) (string, error) {
+	switch typ {
+	case solver.CacheLocal:
+		return parser.ResolvePath(ModuleDir(ctx), ref)
+	case solver.CacheInline, solver.CacheRegistry:
+		return ref, nil
+	default:
+		return "", errdefs.WithInvalidCacheType(Arg(ctx, 0), typ, solver.CacheTypes)
+	}
+}
+
 type Checksum struct{}
 
 func (c Checksum) Call(ctx context.Context, cln *client.Client, ret Register, opts Option, dgst digest.Digest) error {
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -45,19 +46,49 @@ func New(cln *client.Client, opts ...CodeGenOption) (*CodeGen, error) {
 	return cg, nil
 }
 
+// Target is a function to compile. Args are the unconverted values of the
+// function's parameters by name, which may be shared by multiple targets.
 type Target struct {
 	Name string
+	Args map[string]string
 }
 
 func (cg *CodeGen) Generate(ctx context.Context, mod *parser.Module, targets []Target) (solver.Request, error) {
-	var requests []solver.Request
+	var (
+		requests []solver.Request
+		used     = make(map[string]struct{})
+	)
 
 	for i, target := range targets {
-		_, ok := mod.Scope.Objects[target.Name]
+		obj, ok := mod.Scope.Objects[target.Name]
 		if !ok {
 			return nil, fmt.Errorf("target %q is not defined in %s", target.Name, mod.Pos.Filename)
 		}
 
+		// Aliases inherit the signature of their enclosing function.
+		var fun *parser.FuncDecl
+		switch n := obj.Node.(type) {
+		case *parser.FuncDecl:
+			fun = n
+		case *parser.BindClause:
+			if b := n.TargetBinding(target.Name); b != nil {
+				fun = b.Bind.Closure
+			}
+		}
+
+		var args []Value
+		if fun != nil {
+			var err error
+			args, err = targetArgs(fun, target.Args)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, param := range fun.Params.Fields() {
+				used[param.Name.Text] = struct{}{}
+			}
+		}
+
 		// Yield before compiling anything.
 		err := cg.Debug(ctx, mod.Scope, mod, nil)
 		if err != nil {
@@ -69,9 +100,11 @@ func (cg *CodeGen) Generate(ctx context.Context, mod *parser.Module, targets []T
 		ie.Pos.Filename = "target"
 		ie.Pos.Line = i
 
-		// Every target has a return register.
+		// Every target has a return register, and its progress is tagged with
+		// the target.
+		prefix := fmt.Sprintf("target %s", target.Name)
 		ret := NewRegister()
-		err = cg.EmitIdentExpr(ctx, mod.Scope, ie, ie.Ident, nil, nil, nil, ret)
+		err = cg.EmitIdentExpr(solver.WithProgressPrefix(ctx, prefix), mod.Scope, ie, ie.Ident, args, nil, nil, ret)
 		if err != nil {
 			return nil, err
 		}
@@ -81,12 +114,73 @@ func (cg *CodeGen) Generate(ctx context.Context, mod *parser.Module, targets []T
 			return nil, err
 		}
 
-		requests = append(requests, request)
+		requests = append(requests, solver.Prefixed(prefix, request))
+	}
+
+	// Arguments are shared between targets, so they are only unknown if no
+	// target has a parameter with that name.
+	var unknown []string
+	for _, target := range targets {
+		for name := range target.Args {
+			if _, ok := used[name]; !ok {
+				unknown = append(unknown, name)
+				used[name] = struct{}{}
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown target args %s", strings.Join(unknown, ", "))
 	}
 
 	return solver.Parallel(requests...), nil
 }
 
+// targetArgs converts the arguments of a target to the kinds of the target
+// function's parameters.
+func targetArgs(fun *parser.FuncDecl, args map[string]string) ([]Value, error) {
+	var values []Value
+	for _, param := range fun.Params.Fields() {
+		switch param.Kind() {
+		case parser.String, parser.Int, parser.Bool:
+		default:
+			return nil, errdefs.WithUnsupportedTargetParam(fun, param)
+		}
+		if param.Modifier != nil {
+			return nil, errdefs.WithUnsupportedTargetParam(fun, param)
+		}
+
+		arg, ok := args[param.Name.Text]
+		if !ok {
+			return nil, errdefs.WithMissingTargetArg(fun, param)
+		}
+
+		var (
+			v   interface{} = arg
+			err error
+		)
+		switch param.Kind() {
+		case parser.Int:
+			// Integers are parsed with the same prefixes as literals, like 0x777.
+			var n int64
+			n, err = strconv.ParseInt(arg, 0, 64)
+			v = int(n)
+		case parser.Bool:
+			v, err = strconv.ParseBool(arg)
+		}
+		if err != nil {
+			return nil, errdefs.WithInvalidTargetArg(fun, param, arg)
+		}
+
+		value, err := NewValue(v)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
 func (cg *CodeGen) EmitExpr(ctx context.Context, scope *parser.Scope, expr *parser.Expr, args []Value, opts Option, b *parser.Binding, ret Register) error {
 	ctx = WithProgramCounter(ctx, expr)
 
@@ -95,6 +189,8 @@ func (cg *CodeGen) EmitExpr(ctx context.Context, scope *parser.Scope, expr *pars
 		return cg.EmitFuncLit(ctx, scope, expr.FuncLit, b, ret)
 	case expr.BasicLit != nil:
 		return cg.EmitBasicLit(ctx, scope, expr.BasicLit, ret)
+	case expr.ListLit != nil:
+		return cg.EmitListLit(ctx, scope, expr.ListLit, b, ret)
 	case expr.CallExpr != nil:
 		return cg.EmitCallExpr(ctx, scope, expr.CallExpr, ret)
 	default:
@@ -113,7 +209,7 @@ func (cg *CodeGen) EmitBasicLit(ctx context.Context, scope *parser.Scope, lit *p
 	case lit.Numeric != nil:
 		return ret.Set(int(lit.Numeric.Value))
 	case lit.Bool != nil:
-		return ret.Set(*lit.Bool)
+		return ret.Set(lit.Bool.Value)
 	case lit.Str != nil:
 		return cg.EmitStringLit(ctx, scope, lit.Str, ret)
 	case lit.RawString != nil:
@@ -127,6 +223,41 @@ func (cg *CodeGen) EmitBasicLit(ctx context.Context, scope *parser.Scope, lit *p
 	}
 }
 
+func (cg *CodeGen) EmitListLit(ctx context.Context, scope *parser.Scope, lit *parser.ListLit, b *parser.Binding, ret Register) error {
+	// A list literal emitted as a statement of a list block is evaluated with
+	// the element type, but is appended to the block as a list. Arguments are
+	// evaluated without a type, so then the type is taken from the elements.
+	kind := ReturnType(ctx)
+	if kind != parser.None && !kind.IsList() {
+		kind = parser.ListOf(kind)
+	}
+
+	var values []Value
+	elemCtx := WithReturnType(ctx, kind.Elem())
+	for _, expr := range lit.Elems() {
+		elem := NewRegister()
+		err := cg.EmitExpr(elemCtx, scope, expr, nil, nil, b, elem)
+		if err != nil {
+			return err
+		}
+
+		if elem.Kind().IsList() {
+			elems, err := elem.List()
+			if err != nil {
+				return err
+			}
+			values = append(values, elems...)
+		} else {
+			values = append(values, elem)
+		}
+	}
+
+	if kind == parser.None && len(values) > 0 {
+		kind = parser.ListOf(values[0].Kind())
+	}
+	return ret.Set(NewList(kind, values...))
+}
+
 func (cg *CodeGen) EmitStringLit(ctx context.Context, scope *parser.Scope, str *parser.StringLit, ret Register) error {
 	var pieces []string
 	for _, f := range str.Fragments {
@@ -364,15 +495,22 @@ func (cg *CodeGen) EmitBuiltinDecl(ctx context.Context, scope *parser.Scope, bd
 	return nil
 }
 
-func (cg *CodeGen) EmitFuncDecl(ctx context.Context, fun *parser.FuncDecl, args []Value, b *parser.Binding, ret Register) error {
+func (cg *CodeGen) EmitFuncDecl(ctx context.Context, fun *parser.FuncDecl, args []Value, b *parser.Binding, ret Register) (err error) {
 	ctx = WithProgramCounter(ctx, fun.Name)
 
+	name := fun.Name.Text
+	if b != nil {
+		name = b.Name.Text
+	}
+
+	ctx, span := startSpan(ctx, "func", name, fun.Name)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	params := fun.Params.Fields()
 	if len(params) != len(args) {
-		name := fun.Name.Text
-		if b != nil {
-			name = b.Name.Text
-		}
 		return errdefs.WithInternalErrorf(ProgramCounter(ctx), "`%s` expected %d args, got %d", name, len(params), len(args))
 	}
 
@@ -391,7 +529,7 @@ func (cg *CodeGen) EmitFuncDecl(ctx context.Context, fun *parser.FuncDecl, args
 	}
 
 	// Yield before executing a function.
-	err := cg.Debug(ctx, scope, fun.Name, ret)
+	err = cg.Debug(ctx, scope, fun.Name, ret)
 	if err != nil {
 		return err
 	}
@@ -404,6 +542,10 @@ func (cg *CodeGen) EmitBinding(ctx context.Context, b *parser.Binding, args []Va
 }
 
 func (cg *CodeGen) EmitBlock(ctx context.Context, scope *parser.Scope, block *parser.BlockStmt, b *parser.Binding, ret Register) error {
+	if block.Kind().IsList() {
+		return cg.EmitListBlock(ctx, scope, block, b, ret)
+	}
+
 	ctx = WithReturnType(ctx, block.Kind())
 
 	for _, stmt := range block.Stmts() {
@@ -411,6 +553,10 @@ func (cg *CodeGen) EmitBlock(ctx context.Context, scope *parser.Scope, block *pa
 		switch {
 		case stmt.Call != nil:
 			err = cg.EmitCallStmt(ctx, scope, stmt.Call, b, ret)
+		case stmt.If != nil:
+			err = cg.EmitIfStmt(ctx, scope, stmt.If, b, ret)
+		case stmt.For != nil:
+			err = cg.EmitForStmt(ctx, scope, stmt.For, b, ret)
 		case stmt.Expr != nil:
 			err = cg.EmitExpr(ctx, scope, stmt.Expr.Expr, nil, nil, b, ret)
 		default:
@@ -424,16 +570,85 @@ func (cg *CodeGen) EmitBlock(ctx context.Context, scope *parser.Scope, block *pa
 	return nil
 }
 
-func (cg *CodeGen) EmitCallStmt(ctx context.Context, scope *parser.Scope, call *parser.CallStmt, b *parser.Binding, ret Register) error {
+func (cg *CodeGen) EmitListBlock(ctx context.Context, scope *parser.Scope, block *parser.BlockStmt, b *parser.Binding, ret Register) error {
+	kind := block.Kind()
+	values, err := ret.List()
+	if err != nil {
+		return err
+	}
+
+	err = ret.Set(NewList(kind, values...))
+	if err != nil {
+		return err
+	}
+
+	// Call and expression statements are emitted into their own register as an
+	// element of the list, while if and for statements append to the list
+	// directly.
+	elemCtx := WithReturnType(ctx, kind.Elem())
+	for _, stmt := range block.Stmts() {
+		elem := NewRegister()
+		switch {
+		case stmt.Call != nil:
+			err = cg.EmitCallStmt(elemCtx, scope, stmt.Call, b, elem)
+		case stmt.If != nil:
+			err = cg.EmitIfStmt(ctx, scope, stmt.If, b, ret)
+		case stmt.For != nil:
+			err = cg.EmitForStmt(ctx, scope, stmt.For, b, ret)
+		case stmt.Expr != nil:
+			err = cg.EmitExpr(elemCtx, scope, stmt.Expr.Expr, nil, nil, b, elem)
+		default:
+			return errdefs.WithInternalErrorf(stmt, "invalid stmt")
+		}
+		if err != nil {
+			return err
+		}
+
+		// Skip statements that did not produce an element, like breakpoints.
+		if elem.Kind() == parser.None {
+			continue
+		}
+
+		values, err = ret.List()
+		if err != nil {
+			return err
+		}
+
+		if elem.Kind() == kind {
+			elems, err := elem.List()
+			if err != nil {
+				return err
+			}
+			values = append(values, elems...)
+		} else {
+			values = append(values, elem)
+		}
+
+		err = ret.Set(NewList(kind, values...))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cg *CodeGen) EmitCallStmt(ctx context.Context, scope *parser.Scope, call *parser.CallStmt, b *parser.Binding, ret Register) (err error) {
 	ctx = WithFrame(ctx, Frame{call.Name})
 
+	ctx, span := startSpan(ctx, "call", call.Name.String(), call.Name)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	// Yield for breakpoints in the source.
 	if call.Breakpoint() {
 		return cg.Debug(ctx, scope, call.Name, ret)
 	}
 
 	// Yield before executing the next call statement.
-	err := cg.Debug(ctx, scope, call.Name, ret)
+	err = cg.Debug(ctx, scope, call.Name, ret)
 	if err != nil {
 		return err
 	}
@@ -474,6 +689,61 @@ func (cg *CodeGen) EmitCallStmt(ctx context.Context, scope *parser.Scope, call *
 	return cg.EmitIdentExpr(ctx, scope, call.Name, call.Name.Ident, args, opts, binding, ret)
 }
 
+func (cg *CodeGen) EmitIfStmt(ctx context.Context, scope *parser.Scope, is *parser.IfStmt, b *parser.Binding, ret Register) error {
+	values, err := cg.Evaluate(ctx, scope, parser.Bool, nil, is.Condition.Expr())
+	if err != nil {
+		return err
+	}
+
+	cond, err := values[0].Bool()
+	if err != nil {
+		return err
+	}
+
+	// Only the selected branch is emitted.
+	switch {
+	case cond:
+		return cg.EmitBlock(ctx, scope, is.Body, b, ret)
+	case is.Else == nil:
+		return nil
+	case is.Else.If != nil:
+		return cg.EmitIfStmt(ctx, scope, is.Else.If, b, ret)
+	default:
+		return cg.EmitBlock(ctx, scope, is.Else.Body, b, ret)
+	}
+}
+
+func (cg *CodeGen) EmitForStmt(ctx context.Context, scope *parser.Scope, fs *parser.ForStmt, b *parser.Binding, ret Register) error {
+	values, err := cg.Evaluate(ctx, scope, parser.ListOf(fs.Field.Kind()), nil, fs.Iterable.Expr())
+	if err != nil {
+		return err
+	}
+
+	elems, err := values[0].List()
+	if err != nil {
+		return err
+	}
+
+	// The loop is unrolled by emitting the body for every element, each with a
+	// new scope binding the element to the loop variable.
+	for _, elem := range elems {
+		iter := parser.NewScope(fs, scope)
+		iter.Insert(&parser.Object{
+			Kind:  fs.Field.Kind(),
+			Ident: fs.Field.Name,
+			Node:  fs.Field,
+			Data:  elem,
+		})
+
+		err = cg.EmitBlock(ctx, iter, fs.Body, b, ret)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (cg *CodeGen) Evaluate(ctx context.Context, scope *parser.Scope, hint parser.Kind, b *parser.Binding, exprs ...*parser.Expr) (values []Value, err error) {
 	for _, expr := range exprs {
 		ctx = WithProgramCounter(ctx, expr)
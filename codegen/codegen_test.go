@@ -3,12 +3,15 @@ package codegen_test
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/lithammer/dedent"
+	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/client/llb"
 	"github.com/moby/buildkit/identity"
 	"github.com/moby/buildkit/solver/pb"
@@ -21,6 +24,8 @@ import (
 	"github.com/openllb/hlb/local"
 	"github.com/openllb/hlb/parser"
 	"github.com/openllb/hlb/pkg/llbutil"
+	"github.com/openllb/hlb/pkg/ociutil"
+	"github.com/openllb/hlb/pkg/tracing"
 	"github.com/openllb/hlb/solver"
 	"github.com/stretchr/testify/require"
 	"github.com/xlab/treeprint"
@@ -58,6 +63,48 @@ func LocalState(ctx context.Context, t *testing.T, localPath string, opts ...llb
 	return llb.Local(id, opts...)
 }
 
+// parseModule parses, lints and checks a test module.
+func parseModule(ctx context.Context, t *testing.T, hlb string) *parser.Module {
+	mod, err := parser.Parse(ctx, strings.NewReader(dedent.Dedent(hlb)))
+	require.NoError(t, err)
+
+	err = checker.SemanticPass(mod)
+	require.NoError(t, err)
+
+	_ = linter.Lint(ctx, mod)
+
+	err = checker.Check(mod)
+	require.NoError(t, err)
+	return mod
+}
+
+// generate generates the request of targets in a test module.
+func generate(ctx context.Context, t *testing.T, hlb string, targets ...codegen.Target) (solver.Request, error) {
+	mod := parseModule(ctx, t, hlb)
+
+	cg, err := codegen.New(nil)
+	require.NoError(t, err)
+
+	return cg.Generate(ctx, mod, targets)
+}
+
+// requireTree requires a request to have the same tree as the expected
+// request.
+func requireTree(t *testing.T, expected, actual solver.Request) {
+	expectedTree := treeprint.New()
+	err := expected.Tree(expectedTree)
+	require.NoError(t, err)
+	t.Logf("expected: %s", expectedTree)
+
+	actualTree := treeprint.New()
+	err = actual.Tree(actualTree)
+	require.NoError(t, err)
+	t.Logf("actual: %s", actualTree)
+
+	// Compare trees.
+	require.Equal(t, expectedTree.String(), actualTree.String())
+}
+
 type testCase struct {
 	name      string
 	targets   []string
@@ -359,6 +406,170 @@ func TestCodeGen(t *testing.T) {
 		func(ctx context.Context, t *testing.T) solver.Request {
 			return Expect(t, llb.Image("busybox"))
 		},
+	}, {
+		"if else",
+		[]string{"default"},
+		`
+		fs default() {
+			foo false
+		}
+
+		fs foo(bool cond) {
+			if cond {
+				image "alpine"
+			} else if true {
+				image "busybox"
+			} else {
+				scratch
+			}
+			run "echo ${cond}" with option {
+				shlex
+				if cond { dir "/tmp"; } else { dir "/src"; }
+			}
+		}
+		`, "",
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return Expect(t, llb.Image("busybox").Run(llb.Shlex("echo false"), llb.Dir("/src")).Root())
+		},
+	}, {
+		"for loop over list literal",
+		[]string{"default"},
+		`
+		[]string platforms() {
+			["linux/amd64", "linux/arm64"]
+		}
+
+		fs build([]string platforms) {
+			image "golang"
+			for string platform in [platforms, "darwin/amd64"] {
+				run string { format "go build %s" platform; } with shlex
+			}
+		}
+
+		fs default() {
+			build [platforms, "windows/amd64"]
+		}
+		`, "",
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return Expect(t, llb.Image("golang").
+				Run(llb.Shlex("go build linux/amd64")).
+				Run(llb.Shlex("go build linux/arm64")).
+				Run(llb.Shlex("go build windows/amd64")).
+				Run(llb.Shlex("go build darwin/amd64")).
+				Root())
+		},
+	}, {
+		"empty list literals",
+		[]string{"default"},
+		`
+		fs build([]string flags) {
+			image "golang"
+			for string flag in flags {
+				run flag with shlex
+			}
+		}
+
+		fs default() {
+			build []
+			for string platform in [] {
+				run "go build" with shlex
+			}
+		}
+		`, "",
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return Expect(t, llb.Image("golang"))
+		},
+	}, {
+		"if on local env",
+		[]string{"default"},
+		`
+		fs default() {
+			if equals(localEnv("CI"), "true") {
+				image "alpine"
+			} else {
+				image "busybox"
+			}
+			if notEmpty(localEnv("CI")) {
+				run "echo ci" with shlex
+			}
+			if not(notEmpty(localEnv("CI"))) {
+				run "echo local" with shlex
+			}
+		}
+		`, "",
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return Expect(t, llb.Image("alpine").Run(llb.Shlex("echo ci")).Root())
+		},
+	}, {
+		"if else in pipeline",
+		[]string{"default"},
+		`
+		pipeline default() {
+			build true
+		}
+
+		pipeline build(bool parallel) {
+			if parallel {
+				stage fs { image "alpine"; } fs { image "busybox"; }
+			} else {
+				stage fs { image "alpine"; }
+				stage fs { image "busybox"; }
+			}
+		}
+		`, "",
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return solver.Parallel(
+				Expect(t, llb.Image("alpine")),
+				Expect(t, llb.Image("busybox")),
+			)
+		},
+	}, {
+		"for loop over list",
+		[]string{"default"},
+		`
+		[]string platforms() {
+			"linux/amd64"
+			more
+		}
+
+		[]string more() {
+			"linux/arm64"
+			"windows/amd64"
+		}
+
+		fs default() {
+			image "golang"
+			for string platform in platforms {
+				run string { format "go build %s" platform; } with shlex
+			}
+		}
+		`, "",
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return Expect(t, llb.Image("golang").
+				Run(llb.Shlex("go build linux/amd64")).
+				Run(llb.Shlex("go build linux/arm64")).
+				Run(llb.Shlex("go build windows/amd64")).
+				Root())
+		},
+	}, {
+		"list target",
+		[]string{"default"},
+		`
+		[]fs default() {
+			image "alpine"
+			busybox
+		}
+
+		fs busybox() {
+			image "busybox"
+		}
+		`, "",
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return solver.Parallel(
+				Expect(t, llb.Image("alpine")),
+				Expect(t, llb.Image("busybox")),
+			)
+		},
 	}, {
 		"local",
 		[]string{"default"},
@@ -842,6 +1053,9 @@ func TestCodeGen(t *testing.T) {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+			// The first value of an environment variable takes precedence, so CI
+			// is set regardless of the environment running the tests.
+			ctx = local.WithEnviron(ctx, append([]string{"CI=true"}, os.Environ()...))
 			mod, err := parser.Parse(ctx, strings.NewReader(dedent.Dedent(tc.hlb)))
 			require.NoError(t, err, tc.name)
 
@@ -905,3 +1119,535 @@ func TestCodeGen(t *testing.T) {
 		})
 	}
 }
+
+func TestCodeGenTargetArgs(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name   string
+		args   map[string]string
+		hlb    string
+		fn     func(ctx context.Context, t *testing.T) solver.Request
+		errMsg string
+	}{{
+		"converts args to param kinds",
+		map[string]string{"ref": "alpine", "mode": "0x777", "race": "true"},
+		`
+		fs default(string ref, int mode, bool race) {
+			image ref
+			mkdir "testDir" mode
+			if race {
+				run "go test -race"
+			}
+		}
+		`,
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return Expect(t, llb.Image("alpine").File(
+				llb.Mkdir("testDir", os.FileMode(0x777)),
+			).Run(
+				llb.Args([]string{"/bin/sh", "-c", "go test -race"}),
+			).Root())
+		},
+		"",
+	}, {
+		"alias inherits params",
+		map[string]string{"ref": "alpine"},
+		`
+		fs build(string ref) {
+			image ref
+			run "touch /out/foo" with option {
+				mount scratch "/out" as default
+				shlex
+			}
+		}
+		`,
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return Expect(t, llb.Image("alpine").Run(
+				llb.Shlex("touch /out/foo"),
+			).AddMount("/out", llb.Scratch()))
+		},
+		"",
+	}, {
+		"missing arg",
+		map[string]string{"ref": "alpine"},
+		`
+		fs default(string ref, bool race) {
+			image ref
+		}
+		`,
+		nil,
+		"target `default` is missing arg `race`",
+	}, {
+		"mistyped arg",
+		map[string]string{"workers": "four"},
+		`
+		fs default(int workers) {
+			image "alpine"
+		}
+		`,
+		nil,
+		"cannot use \"four\" as int for arg `workers` of target `default`",
+	}, {
+		"unknown arg",
+		map[string]string{"ref": "alpine", "tag": "latest"},
+		`
+		fs default(string ref) {
+			image ref
+		}
+		`,
+		nil,
+		"unknown target args tag",
+	}, {
+		"unsupported param",
+		map[string]string{},
+		`
+		fs default(fs input) {
+			input
+		}
+		`,
+		nil,
+		"target `default` has a parameter that cannot be passed as an arg",
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+			ctx = codegen.WithSessionID(ctx, identity.NewID())
+			request, err := generate(ctx, t, tc.hlb, codegen.Target{Name: "default", Args: tc.args})
+			if tc.errMsg != "" {
+				require.Error(t, err, tc.name)
+				require.Contains(t, err.Error(), tc.errMsg, tc.name)
+				return
+			}
+			require.NoError(t, err, tc.name)
+
+			requireTree(t, tc.fn(ctx, t), request)
+		})
+	}
+}
+
+func TestCodeGenExports(t *testing.T) {
+	t.Parallel()
+
+	const hlb = `
+	fs default() {
+		image "alpine"
+		download "./out"
+		dockerPush "openllb/hlb" as (digest pushed)
+	}
+
+	fs pinned() {
+		scratch
+		mkfile "digest" 0o644 pushed
+	}
+	`
+
+	exports := &codegen.Exports{}
+	ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+	ctx = codegen.WithSessionID(ctx, identity.NewID())
+	request, err := generate(codegen.WithExports(ctx, exports), t, hlb, codegen.Target{Name: "default"})
+	require.NoError(t, err)
+
+	l, err := request.LLB()
+	require.NoError(t, err)
+	require.Len(t, l.Definitions(), 1)
+
+	l, err = exports.Request().LLB()
+	require.NoError(t, err)
+	require.Len(t, l.Parallel, 2)
+	require.Equal(t, "out", filepath.Base(l.Parallel[0].SolveOptions.OutputLocal))
+	require.Equal(t, "docker.io/openllb/hlb:latest", l.Parallel[1].SolveOptions.OutputPushImage)
+
+	// Digests of collected pushes are bound to a placeholder, as nothing is
+	// pushed.
+	request, err = generate(codegen.WithExports(ctx, &codegen.Exports{}), t, hlb, codegen.Target{Name: "pinned"})
+	require.NoError(t, err)
+
+	l, err = request.LLB()
+	require.NoError(t, err)
+
+	var mkfile *pb.FileActionMkFile
+	for _, op := range l.Ops {
+		if file := op.Op.GetFile(); file != nil {
+			mkfile = file.Actions[0].GetMkfile()
+		}
+	}
+	require.NotNil(t, mkfile)
+	require.Equal(t, codegen.UnpushedDigest, string(mkfile.Data))
+}
+
+func TestCodeGenCache(t *testing.T) {
+	t.Parallel()
+
+	exports := &codegen.Exports{}
+	ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+	ctx = codegen.WithExports(ctx, exports)
+	ctx = codegen.WithSessionID(ctx, identity.NewID())
+	request, err := generate(ctx, t, `
+	fs default() {
+		image "alpine" with option {
+			cacheFrom "inline" "openllb/hlb"
+			cacheFrom "local" "./cache"
+		}
+		dockerPush "openllb/hlb" with option {
+			cacheTo "inline" ""
+			cacheTo "local" "./cache"
+		}
+	}
+	`, codegen.Target{Name: "default"})
+	require.NoError(t, err)
+
+	// Caches are imported by every solve of the filesystem, but only exported
+	// with the push.
+	l, err := request.LLB()
+	require.NoError(t, err)
+	require.Len(t, l.Definitions(), 1)
+	require.Len(t, l.SolveOptions.CacheImports, 2)
+	require.Empty(t, l.SolveOptions.CacheExports)
+
+	l, err = exports.Request().LLB()
+	require.NoError(t, err)
+
+	info := l.SolveOptions
+	require.Equal(t, []client.CacheOptionsEntry{{
+		Type:  "registry",
+		Attrs: map[string]string{"ref": "openllb/hlb"},
+	}, {
+		Type:  "local",
+		Attrs: map[string]string{"src": info.CacheImports[1].Attrs["src"]},
+	}}, info.CacheImports)
+	require.Equal(t, "cache", filepath.Base(info.CacheImports[1].Attrs["src"]))
+
+	require.Equal(t, []client.CacheOptionsEntry{{
+		Type:  "inline",
+		Attrs: map[string]string{},
+	}, {
+		Type:  "local",
+		Attrs: map[string]string{"dest": info.CacheImports[1].Attrs["src"]},
+	}}, info.CacheExports)
+}
+
+func TestCodeGenGraph(t *testing.T) {
+	t.Parallel()
+
+	ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+	ctx = codegen.WithSessionID(ctx, identity.NewID())
+	request, err := generate(ctx, t, `
+	fs default() {
+		image "alpine"
+		run "make" with option {
+			mount git("https://github.com/openllb/hlb.git", "master") "/src"
+		}
+	}
+	`, codegen.Target{Name: "default"})
+	require.NoError(t, err)
+
+	graph, err := solver.NewGraph(request)
+	require.NoError(t, err)
+	require.Len(t, graph.Vertices, 3)
+
+	// Inputs are marshalled in no particular order.
+	vertices := make(map[string]solver.Vertex)
+	for _, v := range graph.Vertices {
+		vertices[v.Type+v.Source] = v
+	}
+
+	image, git, exec := vertices["sourcedocker-image"], vertices["sourcegit"], vertices["exec"]
+	require.Equal(t, "<stdin>:3:2", image.Location)
+	require.Equal(t, "<stdin>:5:9", git.Location)
+	require.Equal(t, "<stdin>:4:2", exec.Location)
+
+	require.ElementsMatch(t, []solver.Edge{
+		{From: image.Digest, To: exec.Digest},
+		{From: git.Digest, To: exec.Digest, Label: "/src"},
+	}, graph.Edges)
+}
+
+func TestCodeGenLock(t *testing.T) {
+	t.Parallel()
+
+	const (
+		imageDigest   = "sha256:4ff3ca91275773af45cb4b0834e12b7eb47d1c18f770a0b151381cd227f4c253"
+		commit        = "4c3e6e2b0e7a4b2e53ef8ea1bb1b32b1d1e3e0a1"
+		updatedCommit = "9a1f5a0d3f7d0b3c1e0c6a1d2b8e7f6a5d4c3b2a"
+	)
+
+	dir, err := ioutil.TempDir("", "codegen")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	layout := ociutil.LayoutPrefix + dir
+	_, err = ociutil.Push(context.Background(), layout+":latest", map[string][]byte{
+		"build.hlb": []byte("fs default() {\n\tscratch\n}\n"),
+	})
+	require.NoError(t, err)
+
+	lock := &codegen.Lock{
+		Images:    []codegen.LockedImage{{Ref: "docker.io/library/alpine:latest", Digest: imageDigest}},
+		Git:       []codegen.LockedGit{{Remote: "https://github.com/openllb/hlb.git", Ref: "master", Commit: commit}},
+		Artifacts: []codegen.LockedImage{{Ref: layout + ":v1", Digest: imageDigest}},
+	}
+	resolver := gitResolver{
+		"https://github.com/openllb/hlb.git#master": commit,
+		"https://github.com/openllb/hlb.git#v0.3":   updatedCommit,
+	}
+
+	for _, tc := range []struct {
+		name   string
+		mode   codegen.LockMode
+		hlb    string
+		fn     func(ctx context.Context, t *testing.T) solver.Request
+		errMsg string
+	}{{
+		"pins locked image",
+		codegen.LockPin,
+		`
+		fs default() {
+			image "alpine"
+		}
+		`,
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return Expect(t, llb.Image("docker.io/library/alpine:latest@"+imageDigest))
+		},
+		"",
+	}, {
+		"pins locked git ref",
+		codegen.LockStrict,
+		`
+		fs default() {
+			git "https://github.com/openllb/hlb.git" "master"
+		}
+		`,
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return Expect(t, llb.Git("https://github.com/openllb/hlb.git", commit))
+		},
+		"",
+	}, {
+		"resolves unlocked image",
+		codegen.LockPin,
+		`
+		fs default() {
+			image "busybox"
+		}
+		`,
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return Expect(t, llb.Image("docker.io/library/busybox:latest"))
+		},
+		"",
+	}, {
+		"fails on unlocked image when strict",
+		codegen.LockStrict,
+		`
+		fs default() {
+			image "busybox"
+		}
+		`,
+		nil,
+		"image `docker.io/library/busybox:latest` is not locked",
+	}, {
+		"skips pinned refs when strict",
+		codegen.LockStrict,
+		`
+		fs default() {
+			image "busybox@` + imageDigest + `"
+		}
+		`,
+		func(ctx context.Context, t *testing.T) solver.Request {
+			return Expect(t, llb.Image("docker.io/library/busybox@"+imageDigest))
+		},
+		"",
+	}, {
+		"locks resolved git ref",
+		codegen.LockUpdate,
+		`
+		fs default() {
+			git "https://github.com/openllb/hlb.git" "v0.3"
+		}
+		`,
+		func(ctx context.Context, t *testing.T) solver.Request {
+			require.Contains(t, lock.Git, codegen.LockedGit{Remote: "https://github.com/openllb/hlb.git", Ref: "v0.3", Commit: updatedCommit})
+			return Expect(t, llb.Git("https://github.com/openllb/hlb.git", "v0.3"))
+		},
+		"",
+	}, {
+		"fails on unresolved git ref when locking",
+		codegen.LockUpdate,
+		`
+		fs default() {
+			git "https://github.com/openllb/hlb.git" "missing"
+		}
+		`,
+		nil,
+		"git ref \"missing\" not found",
+	}, {
+		"fails on unlocked oci module when strict",
+		codegen.LockStrict,
+		`
+		fs default() {
+			oci "` + layout + `:latest"
+		}
+		`,
+		nil,
+		"oci `" + layout + ":latest` is not locked",
+	}, {
+		"pins locked oci module",
+		codegen.LockPin,
+		`
+		fs default() {
+			oci "` + layout + `:v1"
+		}
+		`,
+		nil,
+		imageDigest + " not found in " + dir,
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+			ctx = codegen.WithLock(ctx, lock, tc.mode)
+			ctx = codegen.WithGitResolver(ctx, resolver)
+			ctx = codegen.WithSessionID(ctx, identity.NewID())
+			request, err := generate(ctx, t, tc.hlb, codegen.Target{Name: "default"})
+			if tc.errMsg != "" {
+				require.Error(t, err, tc.name)
+				require.Contains(t, err.Error(), tc.errMsg, tc.name)
+				return
+			}
+			require.NoError(t, err, tc.name)
+
+			requireTree(t, tc.fn(ctx, t), request)
+		})
+	}
+}
+
+func TestCodeGenLockCheck(t *testing.T) {
+	t.Parallel()
+
+	const (
+		commit        = "4c3e6e2b0e7a4b2e53ef8ea1bb1b32b1d1e3e0a1"
+		updatedCommit = "9a1f5a0d3f7d0b3c1e0c6a1d2b8e7f6a5d4c3b2a"
+	)
+
+	lock := &codegen.Lock{
+		Git: []codegen.LockedGit{{Remote: "https://github.com/openllb/hlb.git", Ref: "master", Commit: commit}},
+	}
+	resolver := &countingGitResolver{gitResolver: gitResolver{
+		"https://github.com/openllb/hlb.git#master": updatedCommit,
+	}}
+
+	for _, check := range []bool{false, true} {
+		ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+		ctx = codegen.WithLock(ctx, lock, codegen.LockPin)
+		ctx = codegen.WithGitResolver(ctx, resolver)
+		ctx = codegen.WithSessionID(ctx, identity.NewID())
+		if check {
+			ctx = codegen.WithLockCheck(ctx)
+		}
+
+		var warnings []error
+		ctx = diagnostic.WithWarningHandler(ctx, func(err error) {
+			warnings = append(warnings, err)
+		})
+
+		request, err := generate(ctx, t, `
+		fs default() {
+			git "https://github.com/openllb/hlb.git" "master"
+		}
+		`, codegen.Target{Name: "default"})
+		require.NoError(t, err)
+		requireTree(t, Expect(t, llb.Git("https://github.com/openllb/hlb.git", commit)), request)
+
+		// Locked git refs are only resolved to check for drift when asked to,
+		// as resolving clones the repository.
+		if !check {
+			require.Zero(t, resolver.calls)
+			require.Empty(t, warnings)
+			continue
+		}
+		require.Equal(t, 1, resolver.calls)
+		require.Len(t, warnings, 1)
+		require.Contains(t, warnings[0].Error(), "has drifted from the lock")
+	}
+}
+
+// gitResolver resolves git refs keyed by their remote and ref.
+type gitResolver map[string]string
+
+func (r gitResolver) ResolveGitCommit(ctx context.Context, remote, ref string) (string, error) {
+	commit, ok := r[fmt.Sprintf("%s#%s", remote, ref)]
+	if !ok {
+		return "", fmt.Errorf("git ref %q not found in %s", ref, remote)
+	}
+	return commit, nil
+}
+
+// countingGitResolver counts the git refs it resolves.
+type countingGitResolver struct {
+	gitResolver
+	calls int
+}
+
+func (r *countingGitResolver) ResolveGitCommit(ctx context.Context, remote, ref string) (string, error) {
+	r.calls++
+	return r.gitResolver.ResolveGitCommit(ctx, remote, ref)
+}
+
+type spanRecorder struct {
+	spans []*tracing.Span
+}
+
+func (r *spanRecorder) ExportSpans(ctx context.Context, resource []tracing.Attribute, spans []*tracing.Span) error {
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func TestCodeGenTracing(t *testing.T) {
+	t.Parallel()
+
+	rec := &spanRecorder{}
+	tracer, err := tracing.NewTracer(rec)
+	require.NoError(t, err)
+
+	ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+	ctx = tracing.WithTracer(ctx, tracer)
+	ctx = codegen.WithSessionID(ctx, identity.NewID())
+	_, err = generate(ctx, t, `
+	fs default() {
+		build
+	}
+
+	fs build() {
+		image "alpine"
+		run "make"
+	}
+	`, codegen.Target{Name: "default"})
+	require.NoError(t, err)
+
+	err = tracer.Shutdown(ctx)
+	require.NoError(t, err)
+
+	spans := make(map[string]*tracing.Span)
+	for _, span := range rec.spans {
+		spans[span.Name] = span
+	}
+	require.Contains(t, spans, "parse")
+
+	attrs := func(span *tracing.Span) map[string]interface{} {
+		m := make(map[string]interface{})
+		for _, attr := range span.Attributes {
+			m[attr.Key] = attr.Value
+		}
+		return m
+	}
+
+	// Functions and calls are nested in the spans of the calls emitting them,
+	// and tagged with the backtrace of those calls.
+	def, build, run := spans["func default"], spans["func build"], spans["call run"]
+	require.NotNil(t, def)
+	require.NotNil(t, build)
+	require.NotNil(t, run)
+	require.Equal(t, spans["call build"].ID, build.Parent)
+	require.Equal(t, build.ID, run.Parent)
+	require.Equal(t, def.TraceID, run.TraceID)
+
+	require.Equal(t, "<stdin>", attrs(run)["code.filepath"])
+	require.Equal(t, int64(8), attrs(run)["code.lineno"])
+	require.Equal(t, []string{"<stdin>:3:2 build", "<stdin>:8:2 run"}, attrs(run)["hlb.backtrace"])
+}
@@ -2,7 +2,9 @@ package codegen
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
+	"sync"
 
 	"github.com/docker/buildx/util/progress"
 	"github.com/moby/buildkit/client"
@@ -12,6 +14,7 @@ import (
 	"github.com/openllb/hlb/diagnostic"
 	"github.com/openllb/hlb/parser"
 	"github.com/openllb/hlb/pkg/llbutil"
+	"github.com/openllb/hlb/pkg/tracing"
 	"github.com/openllb/hlb/solver"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
@@ -25,7 +28,11 @@ type (
 	sessionIDKey      struct{}
 	multiwriterKey    struct{}
 	imageResolverKey  struct{}
+	gitResolverKey    struct{}
 	backtraceKey      struct{}
+	exportsKey        struct{}
+	lockKey           struct{}
+	lockCheckKey      struct{}
 )
 
 func WithProgramCounter(ctx context.Context, node parser.Node) context.Context {
@@ -105,6 +112,15 @@ func ImageResolver(ctx context.Context) llb.ImageMetaResolver {
 	return resolver
 }
 
+func WithGitResolver(ctx context.Context, resolver GitCommitResolver) context.Context {
+	return context.WithValue(ctx, gitResolverKey{}, resolver)
+}
+
+func GitResolver(ctx context.Context) GitCommitResolver {
+	resolver, _ := ctx.Value(gitResolverKey{}).(GitCommitResolver)
+	return resolver
+}
+
 type Frame struct {
 	parser.Node
 }
@@ -119,6 +135,29 @@ func Backtrace(ctx context.Context) []Frame {
 	return frames
 }
 
+// startSpan starts a span for emitting a function or call, tagged with its
+// source location and the backtrace of calls that led to it.
+func startSpan(ctx context.Context, kind, name string, node parser.Node) (context.Context, *tracing.Span) {
+	if !tracing.Enabled(ctx) {
+		return ctx, nil
+	}
+
+	var backtrace []string
+	for _, frame := range Backtrace(ctx) {
+		pos := frame.Position()
+		backtrace = append(backtrace, fmt.Sprintf("%s:%d:%d %s", pos.Filename, pos.Line, pos.Column, frame.Node))
+	}
+
+	pos := node.Position()
+	return tracing.Start(ctx, fmt.Sprintf("%s %s", kind, name),
+		tracing.String("code.function", name),
+		tracing.String("code.filepath", pos.Filename),
+		tracing.Int("code.lineno", pos.Line),
+		tracing.Int("code.column", pos.Column),
+		tracing.StringSlice("hlb.backtrace", backtrace),
+	)
+}
+
 func WithBacktraceError(ctx context.Context, err error) error {
 	for _, frame := range Backtrace(ctx) {
 		err = errdefs.WithSource(err, errdefs.Source{
@@ -135,6 +174,41 @@ func WithBacktraceError(ctx context.Context, err error) error {
 	return errors.WithStack(err)
 }
 
+// UnpushedDigest is bound to the digest of dockerPush when exports are
+// collected with WithExports, as no image is pushed to produce a digest.
+const UnpushedDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+// Exports collects the requests of exports like dockerPush and download,
+// which are otherwise solved while generating code.
+type Exports struct {
+	mu       sync.Mutex
+	requests []solver.Request
+}
+
+func (e *Exports) add(request solver.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests = append(e.requests, request)
+}
+
+// Request returns the collected requests to be solved in parallel.
+func (e *Exports) Request() solver.Request {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return solver.Parallel(e.requests...)
+}
+
+// WithExports collects the requests of exports instead of solving them, so
+// that the generated requests can be inspected without side effects.
+func WithExports(ctx context.Context, exports *Exports) context.Context {
+	return context.WithValue(ctx, exportsKey{}, exports)
+}
+
+func exportsFrom(ctx context.Context) *Exports {
+	exports, _ := ctx.Value(exportsKey{}).(*Exports)
+	return exports
+}
+
 func WithCallbackErrgroup(ctx context.Context, g *errgroup.Group) solver.SolveOption {
 	return func(info *solver.SolveInfo) error {
 		info.Callbacks = append(info.Callbacks,
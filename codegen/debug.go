@@ -1,7 +1,6 @@
 package codegen
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
@@ -12,12 +11,16 @@ import (
 	shellquote "github.com/kballard/go-shellquote"
 	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/client/llb"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/moby/buildkit/solver/pb"
-	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/openllb/hlb/diagnostic"
 	"github.com/openllb/hlb/errdefs"
 	"github.com/openllb/hlb/parser"
+	"github.com/openllb/hlb/pkg/llbutil"
+	"github.com/openllb/hlb/solver"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
@@ -38,7 +41,10 @@ type snapshot struct {
 	ret   Value
 }
 
-func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader) Debugger {
+// NewDebugger returns a debugger reading commands from an input, which is also
+// forwarded to the processes started with exec.
+func NewDebugger(c *client.Client, w io.Writer, in *solver.Input) Debugger {
+
 	var (
 		fun               *parser.FuncDecl
 		next              *parser.FuncDecl
@@ -61,13 +67,20 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader) Debugger {
 			// Keep track of whether we're in global scope or a lexical scope.
 			switch n := node.(type) {
 			case *parser.Module:
-				staticBreakpoints = findStaticBreakpoints(n)
+				staticBreakpoints = FindStaticBreakpoints(n)
 				breakpoints = staticBreakpoints
 
 				// Don't print source code on the first debug section.
 				showList = false
 			default:
-				fun = scope.Node.(*parser.FuncDecl)
+				// Loop bodies have their own scope, so find the scope of the
+				// enclosing function.
+				for fs := scope; fs != nil; fs = fs.Outer {
+					if fd, ok := fs.Node.(*parser.FuncDecl); ok {
+						fun = fd
+						break
+					}
+				}
 				if node == fun.Name {
 					for _, bp := range breakpoints {
 						if bp.Call != nil {
@@ -109,7 +122,7 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader) Debugger {
 			for {
 				fmt.Fprint(w, "(hlb) ")
 
-				command, err := r.ReadString('\n')
+				command, err := in.ReadString('\n')
 				if err != nil {
 					return err
 				}
@@ -226,6 +239,22 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader) Debugger {
 					}
 
 					fmt.Fprintf(w, "Environment %s\n", env)
+				case "exec":
+					fs, err := s.ret.Filesystem()
+					if err != nil {
+						fmt.Fprintf(w, "current step is not in a fs scope\n")
+						continue
+					}
+
+					args := args[1:]
+					if len(args) == 0 {
+						args = []string{"/bin/sh"}
+					}
+
+					err = execWithFS(ctx, c, fs, in, w, args...)
+					if err != nil {
+						fmt.Fprintf(w, "err: %s\n", err)
+					}
 				case "exit":
 					return ErrDebugExit
 				case "funcs":
@@ -258,6 +287,7 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader) Debugger {
 					fmt.Fprintf(w, "# Filesystem\n")
 					fmt.Fprintf(w, "dir - print working directory\n")
 					fmt.Fprintf(w, "env - print environment\n")
+					fmt.Fprintf(w, "exec [ <command> ] - run an interactive process in the filesystem\n")
 					fmt.Fprintf(w, "network - print network mode\n")
 					fmt.Fprintf(w, "security - print security mode\n")
 				case "list", "l":
@@ -379,7 +409,7 @@ type Breakpoint struct {
 	Call *parser.CallStmt
 }
 
-func findStaticBreakpoints(mod *parser.Module) []*Breakpoint {
+func FindStaticBreakpoints(mod *parser.Module) []*Breakpoint {
 	var breakpoints []*Breakpoint
 
 	parser.Match(mod, parser.MatchOpts{},
@@ -399,12 +429,17 @@ func findStaticBreakpoints(mod *parser.Module) []*Breakpoint {
 }
 
 func printGraph(ctx context.Context, st llb.State, sh string) error {
-	def, err := st.Marshal(ctx, llb.LinuxAmd64)
+	platform, err := statePlatform(ctx, st)
 	if err != nil {
 		return err
 	}
 
-	ops, err := loadLLB(def)
+	def, err := st.Marshal(ctx, llb.Platform(platform))
+	if err != nil {
+		return err
+	}
+
+	graph, err := solver.NewGraph(solver.Single(&solver.Params{Def: def}))
 	if err != nil {
 		return err
 	}
@@ -413,8 +448,7 @@ func printGraph(ctx context.Context, st llb.State, sh string) error {
 	defer r.Close()
 
 	go func() {
-		defer w.Close()
-		writeDot(ops, w)
+		w.CloseWithError(graph.WriteDot(w))
 	}()
 
 	if sh == "" {
@@ -430,77 +464,120 @@ func printGraph(ctx context.Context, st llb.State, sh string) error {
 	return cmd.Run()
 }
 
-type llbOp struct {
-	Op         pb.Op
-	Digest     digest.Digest
-	OpMetadata pb.OpMetadata
-}
+// execWithFS solves the filesystem and runs an interactive process in a
+// container with the filesystem as its root. The working directory,
+// environment, network and security mode of the filesystem are preserved.
+func execWithFS(ctx context.Context, cln *client.Client, fs Filesystem, stdin io.Reader, w io.Writer, args ...string) error {
+	platform, err := statePlatform(ctx, fs.State)
+	if err != nil {
+		return err
+	}
 
-func loadLLB(def *llb.Definition) ([]llbOp, error) {
-	var ops []llbOp
-	for _, dt := range def.Def {
-		var op pb.Op
-		if err := (&op).Unmarshal(dt); err != nil {
-			return nil, err
-		}
-		dgst := digest.FromBytes(dt)
-		ent := llbOp{Op: op, Digest: dgst, OpMetadata: def.Metadata[dgst]}
-		ops = append(ops, ent)
+	def, err := fs.State.Marshal(ctx, llb.Platform(platform))
+	if err != nil {
+		return err
 	}
-	return ops, nil
-}
 
-func writeDot(ops []llbOp, w io.Writer) {
-	fmt.Fprintln(w, "digraph {")
-	defer fmt.Fprintln(w, "}")
-	for _, op := range ops {
-		name, shape := attr(op.Digest, op.Op)
-		fmt.Fprintf(w, "  %q [label=%q shape=%q];\n", op.Digest, name, shape)
+	env, err := fs.State.Env(ctx)
+	if err != nil {
+		return err
 	}
-	for _, op := range ops {
-		for i, inp := range op.Op.Inputs {
-			label := ""
-			if eo, ok := op.Op.Op.(*pb.Op_Exec); ok {
-				for _, m := range eo.Exec.Mounts {
-					if int(m.Input) == i && m.Dest != "/" {
-						label = m.Dest
-					}
-				}
-			}
-			fmt.Fprintf(w, "  %q -> %q [label=%q];\n", inp.Digest, op.Digest, label)
+
+	dir, err := fs.State.GetDir(ctx)
+	if err != nil {
+		return err
+	}
+
+	network, err := fs.State.GetNetwork(ctx)
+	if err != nil {
+		return err
+	}
+
+	security, err := fs.State.GetSecurity(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Only keep the entitlements of the filesystem, we don't want to export it.
+	info := &solver.SolveInfo{}
+	for _, opt := range fs.SolveOpts {
+		err = opt(info)
+		if err != nil {
+			return err
 		}
 	}
-}
 
-func attr(dgst digest.Digest, op pb.Op) (string, string) {
-	switch op := op.Op.(type) {
-	case *pb.Op_Source:
-		return op.Source.Identifier, "ellipse"
-	case *pb.Op_Exec:
-		return strings.Join(op.Exec.Meta.Args, " "), "box"
-	case *pb.Op_Build:
-		return "build", "box3d"
-	case *pb.Op_File:
-		names := []string{}
-
-		for _, action := range op.File.Actions {
-			var name string
-
-			switch act := action.Action.(type) {
-			case *pb.FileAction_Copy:
-				name = fmt.Sprintf("copy{src=%s, dest=%s}", act.Copy.Src, act.Copy.Dest)
-			case *pb.FileAction_Mkfile:
-				name = fmt.Sprintf("mkfile{path=%s}", act.Mkfile.Path)
-			case *pb.FileAction_Mkdir:
-				name = fmt.Sprintf("mkdir{path=%s}", act.Mkdir.Path)
-			case *pb.FileAction_Rm:
-				name = fmt.Sprintf("rm{path=%s}", act.Rm.Path)
+	var solveOpts []solver.SolveOption
+	for _, e := range info.Entitlements {
+		solveOpts = append(solveOpts, solver.WithEntitlement(e))
+	}
+
+	s, err := llbutil.NewSession(ctx, fs.SessionOpts...)
+	if err != nil {
+		return err
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		return s.Run(ctx, cln.Dialer())
+	})
+
+	g.Go(func() error {
+		return solver.Build(ctx, cln, s, nil, func(ctx context.Context, c gateway.Client) (*gateway.Result, error) {
+			res, err := c.Solve(ctx, gateway.SolveRequest{
+				Definition: def.ToPB(),
+			})
+			if err != nil {
+				return nil, err
 			}
 
-			names = append(names, name)
-		}
-		return strings.Join(names, ","), "note"
-	default:
-		return dgst.String(), "plaintext"
+			ref, err := res.SingleRef()
+			if err != nil {
+				return nil, err
+			}
+
+			if ref == nil {
+				return nil, errors.New("cannot exec in an empty filesystem")
+			}
+
+			return gateway.NewResult(), solver.RunContainer(ctx, c,
+				gateway.NewContainerRequest{
+					Mounts: []gateway.Mount{{
+						Dest:      "/",
+						MountType: pb.MountType_BIND,
+						Ref:       ref,
+					}},
+					NetMode: network,
+					Platform: &pb.Platform{
+						OS:           platform.OS,
+						Architecture: platform.Architecture,
+						Variant:      platform.Variant,
+					},
+				},
+				gateway.StartRequest{
+					Args:         args,
+					Env:          env,
+					Cwd:          dir,
+					SecurityMode: security,
+				},
+				stdin, w, w,
+			)
+		}, solveOpts...)
+	})
+
+	return g.Wait()
+}
+
+// statePlatform returns the platform of a state, such as the platform of its
+// image config, or linux/amd64 which states are marshalled with by default.
+func statePlatform(ctx context.Context, st llb.State) (specs.Platform, error) {
+	platform, err := st.GetPlatform(ctx)
+	if err != nil {
+		return specs.Platform{}, err
+	}
+	if platform == nil {
+		return specs.Platform{OS: "linux", Architecture: "amd64"}, nil
 	}
+	return *platform, nil
 }
@@ -0,0 +1,175 @@
+package codegen
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/docker/distribution/reference"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// Lock pins the digests of image refs, the commits of git refs and the
+// digests of module artifacts, so that builds are reproducible while the refs
+// move.
+type Lock struct {
+	Images    []LockedImage `json:"images"`
+	Git       []LockedGit   `json:"git"`
+	Artifacts []LockedImage `json:"artifacts,omitempty"`
+
+	mu sync.Mutex
+}
+
+type LockedImage struct {
+	Ref    string        `json:"ref"`
+	Digest digest.Digest `json:"digest"`
+}
+
+type LockedGit struct {
+	Remote string `json:"remote"`
+	Ref    string `json:"ref"`
+	Commit string `json:"commit"`
+}
+
+// LockMode is how sources are locked while generating code.
+type LockMode int
+
+const (
+	// LockPin pins sources that are locked, and resolves the others.
+	LockPin LockMode = iota
+
+	// LockStrict pins sources that are locked, and fails on the others.
+	LockStrict
+
+	// LockUpdate resolves every source and records it in the lock.
+	LockUpdate
+)
+
+type lockValue struct {
+	lock *Lock
+	mode LockMode
+}
+
+func WithLock(ctx context.Context, lock *Lock, mode LockMode) context.Context {
+	return context.WithValue(ctx, lockKey{}, &lockValue{lock, mode})
+}
+
+func lockFrom(ctx context.Context) *lockValue {
+	lv, _ := ctx.Value(lockKey{}).(*lockValue)
+	return lv
+}
+
+// WithLockCheck checks whether the locked git refs and oci modules have drifted
+// from the lock. Resolving them contacts their remotes, so they are only
+// resolved when locking otherwise.
+func WithLockCheck(ctx context.Context) context.Context {
+	return context.WithValue(ctx, lockCheckKey{}, true)
+}
+
+func lockCheck(ctx context.Context) bool {
+	check, _ := ctx.Value(lockCheckKey{}).(bool)
+	return check
+}
+
+// LockedDigest returns the digest an image ref is locked to by the lock in the
+// context, or an empty digest if it isn't locked.
+func LockedDigest(ctx context.Context, ref string) digest.Digest {
+	lv := lockFrom(ctx)
+	if lv == nil {
+		return ""
+	}
+
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return ""
+	}
+	return lv.lock.image(reference.TagNameOnly(named).String())
+}
+
+func (l *Lock) image(ref string) digest.Digest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return lockedDigest(l.Images, ref)
+}
+
+func (l *Lock) setImage(ref string, dgst digest.Digest) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Images = setLockedDigest(l.Images, ref, dgst)
+}
+
+func (l *Lock) artifact(ref string) digest.Digest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return lockedDigest(l.Artifacts, ref)
+}
+
+func (l *Lock) setArtifact(ref string, dgst digest.Digest) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Artifacts = setLockedDigest(l.Artifacts, ref, dgst)
+}
+
+func lockedDigest(images []LockedImage, ref string) digest.Digest {
+	for _, image := range images {
+		if image.Ref == ref {
+			return image.Digest
+		}
+	}
+	return ""
+}
+
+func setLockedDigest(images []LockedImage, ref string, dgst digest.Digest) []LockedImage {
+	for i, image := range images {
+		if image.Ref == ref {
+			images[i].Digest = dgst
+			return images
+		}
+	}
+	return append(images, LockedImage{Ref: ref, Digest: dgst})
+}
+
+func (l *Lock) git(remote, ref string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, git := range l.Git {
+		if git.Remote == remote && git.Ref == ref {
+			return git.Commit
+		}
+	}
+	return ""
+}
+
+func (l *Lock) setGit(remote, ref, commit string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, git := range l.Git {
+		if git.Remote == remote && git.Ref == ref {
+			l.Git[i].Commit = commit
+			return
+		}
+	}
+	l.Git = append(l.Git, LockedGit{Remote: remote, Ref: ref, Commit: commit})
+}
+
+// Sort sorts the entries of the lock so that it is stable when written.
+func (l *Lock) Sort() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sort.SliceStable(l.Images, func(i, j int) bool {
+		return l.Images[i].Ref < l.Images[j].Ref
+	})
+	sort.SliceStable(l.Artifacts, func(i, j int) bool {
+		return l.Artifacts[i].Ref < l.Artifacts[j].Ref
+	})
+	sort.SliceStable(l.Git, func(i, j int) bool {
+		if l.Git[i].Remote != l.Git[j].Remote {
+			return l.Git[i].Remote < l.Git[j].Remote
+		}
+		return l.Git[i].Ref < l.Git[j].Ref
+	})
+}
+
+// commitRegexp matches git refs that are commits, which are already pinned.
+var commitRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
@@ -2,6 +2,8 @@ package codegen
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/docker/buildx/util/progress"
@@ -11,6 +13,7 @@ import (
 	digest "github.com/opencontainers/go-digest"
 	"github.com/openllb/hlb/pkg/llbutil"
 	"github.com/openllb/hlb/solver"
+	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -75,3 +78,96 @@ func (r *cachedImageResolver) ResolveImageConfig(ctx context.Context, ref string
 	r.mu.Unlock()
 	return
 }
+
+// GitCommitResolver resolves the commit a git ref points to.
+type GitCommitResolver interface {
+	ResolveGitCommit(ctx context.Context, remote, ref string) (string, error)
+}
+
+func NewCachedGitResolver(cln *client.Client) GitCommitResolver {
+	return &cachedGitResolver{
+		cln:   cln,
+		cache: make(map[string]string),
+	}
+}
+
+type cachedGitResolver struct {
+	cln   *client.Client
+	cache map[string]string
+	mu    sync.RWMutex
+}
+
+// ResolveGitCommit resolves a git ref with BuildKit, so that refs are matched
+// the same way as when they are solved, with the credentials of BuildKit. The
+// git directory is kept in the checkout, where HEAD is detached at the commit
+// of the ref.
+func (r *cachedGitResolver) ResolveGitCommit(ctx context.Context, remote, ref string) (commit string, err error) {
+	key := fmt.Sprintf("%s#%s", remote, ref)
+
+	r.mu.RLock()
+	commit, ok := r.cache[key]
+	r.mu.RUnlock()
+	if ok {
+		return commit, nil
+	}
+
+	def, err := llb.Git(remote, ref, llb.KeepGitDir()).Marshal(ctx)
+	if err != nil {
+		return
+	}
+
+	s, err := llbutil.NewSession(ctx)
+	if err != nil {
+		return
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		return s.Run(ctx, r.cln.Dialer())
+	})
+
+	g.Go(func() error {
+		var pw progress.Writer
+
+		mw := MultiWriter(ctx)
+		if mw != nil {
+			pw = mw.WithPrefix("", false)
+		}
+
+		return solver.Build(ctx, r.cln, s, pw, func(ctx context.Context, c gateway.Client) (res *gateway.Result, err error) {
+			res, err = c.Solve(ctx, gateway.SolveRequest{
+				Definition: def.ToPB(),
+				Evaluate:   true,
+			})
+			if err != nil {
+				return
+			}
+
+			checkout, err := res.SingleRef()
+			if err != nil {
+				return
+			}
+
+			dt, err := checkout.ReadFile(ctx, gateway.ReadRequest{Filename: ".git/HEAD"})
+			if err != nil {
+				return
+			}
+			commit = strings.TrimSpace(string(dt))
+			return gateway.NewResult(), nil
+		})
+	})
+
+	err = g.Wait()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve git ref %q of %s", ref, remote)
+	}
+	if !commitRegexp.MatchString(commit) {
+		return "", fmt.Errorf("git ref %q of %s resolved to %q, expected a commit", ref, remote, commit)
+	}
+
+	r.mu.Lock()
+	r.cache[key] = commit
+	r.mu.Unlock()
+	return commit, nil
+}
@@ -55,6 +55,8 @@ type Value interface {
 	Filesystem() (Filesystem, error)
 	String() (string, error)
 	Int() (int, error)
+	Bool() (bool, error)
+	List() ([]Value, error)
 	Option() (Option, error)
 	Request() (solver.Request, error)
 	Reflect(reflect.Type) (reflect.Value, error)
@@ -65,6 +67,11 @@ func NewValue(iface interface{}) (Value, error) {
 	case Value:
 		return v, nil
 	case Filesystem:
+		// Validating the state of an artifact would pull it, and it only
+		// creates the artifact's files.
+		if v.ArtifactRef() != "" {
+			return &fsValue{&nilValue{}, v}, nil
+		}
 		return &fsValue{&nilValue{}, v}, validateState(v.State)
 	case llb.State:
 		zero := ZeroValue()
@@ -78,6 +85,8 @@ func NewValue(iface interface{}) (Value, error) {
 		return &stringValue{&nilValue{}, v}, nil
 	case int:
 		return &intValue{&nilValue{}, v}, nil
+	case bool:
+		return &boolValue{&nilValue{}, v}, nil
 	case Option:
 		return &optValue{&nilValue{}, v}, nil
 	case solver.Request:
@@ -109,6 +118,14 @@ func (v *nilValue) Int() (int, error) {
 	return 0, fmt.Errorf("cannot coerce to int")
 }
 
+func (v *nilValue) Bool() (bool, error) {
+	return false, fmt.Errorf("cannot coerce to bool")
+}
+
+func (v *nilValue) List() ([]Value, error) {
+	return nil, fmt.Errorf("cannot coerce to list")
+}
+
 func (v *nilValue) String() (string, error) {
 	return "", fmt.Errorf("cannot coerce to string")
 }
@@ -146,6 +163,14 @@ func (v *zeroValue) Int() (int, error) {
 	return 0, nil
 }
 
+func (v *zeroValue) Bool() (bool, error) {
+	return false, nil
+}
+
+func (v *zeroValue) List() ([]Value, error) {
+	return nil, nil
+}
+
 func (v *zeroValue) String() (string, error) {
 	return "", nil
 }
@@ -167,9 +192,30 @@ type Filesystem struct {
 	Image       *specs.Image
 	SolveOpts   []solver.SolveOption
 	SessionOpts []llbutil.SessionOption
+
+	// artifactRef is the ref of the HLB module artifact that the filesystem was
+	// created from, as long as its state is still the artifact's output.
+	artifactRef    string
+	artifactOutput llb.Output
+}
+
+// ArtifactRef returns the ref of the HLB module artifact the filesystem
+// contains, so that it can be pulled without solving. It returns an empty
+// string if the filesystem was not created from an artifact or has been
+// modified since.
+func (fs Filesystem) ArtifactRef() string {
+	if fs.artifactRef == "" || fs.State.Output() != fs.artifactOutput {
+		return ""
+	}
+	return fs.artifactRef
 }
 
+// Digest returns the digest of the filesystem's LLB. Filesystems of artifacts
+// are digested by their ref instead, so that they aren't pulled.
 func (fs Filesystem) Digest(ctx context.Context) (digest.Digest, error) {
+	if ref := fs.ArtifactRef(); ref != "" {
+		return digest.FromString(ref), nil
+	}
 	dgst, _, _, _, err := fs.State.Output().Vertex(ctx).Marshal(ctx, &llb.Constraints{})
 	return dgst, err
 }
@@ -193,6 +239,9 @@ func (v *fsValue) Filesystem() (Filesystem, error) {
 		Image:       &image,
 		SolveOpts:   make([]solver.SolveOption, len(v.fs.SolveOpts)),
 		SessionOpts: make([]llbutil.SessionOption, len(v.fs.SessionOpts)),
+
+		artifactRef:    v.fs.artifactRef,
+		artifactOutput: v.fs.artifactOutput,
 	}
 	copy(fs.SolveOpts, v.fs.SolveOpts)
 	copy(fs.SessionOpts, v.fs.SessionOpts)
@@ -262,6 +311,64 @@ func (v *intValue) Reflect(t reflect.Type) (reflect.Value, error) {
 	return ReflectTo(v, t)
 }
 
+type boolValue struct {
+	Value
+	b bool
+}
+
+func (v *boolValue) Kind() parser.Kind {
+	return parser.Bool
+}
+
+func (v *boolValue) Bool() (bool, error) {
+	return v.b, nil
+}
+
+func (v *boolValue) String() (string, error) {
+	return strconv.FormatBool(v.b), nil
+}
+
+func (v *boolValue) Reflect(t reflect.Type) (reflect.Value, error) {
+	return ReflectTo(v, t)
+}
+
+// NewList returns a list value of the given kind, containing values.
+func NewList(kind parser.Kind, values ...Value) Value {
+	return &listValue{&nilValue{}, kind, values}
+}
+
+type listValue struct {
+	Value
+	kind   parser.Kind
+	values []Value
+}
+
+func (v *listValue) Kind() parser.Kind {
+	return v.kind
+}
+
+func (v *listValue) List() ([]Value, error) {
+	values := make([]Value, len(v.values))
+	copy(values, v.values)
+	return values, nil
+}
+
+func (v *listValue) Request() (solver.Request, error) {
+	var requests []solver.Request
+	for _, value := range v.values {
+		request, err := value.Request()
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return solver.Parallel(requests...), nil
+}
+
+func (v *listValue) Reflect(t reflect.Type) (reflect.Value, error) {
+	return ReflectTo(v, t)
+}
+
 type optValue struct {
 	Value
 	opt Option
@@ -296,6 +403,7 @@ var (
 	rFilesystem = reflect.TypeOf(Filesystem{})
 	rString     = reflect.TypeOf("")
 	rInt        = reflect.TypeOf(0)
+	rBool       = reflect.TypeOf(false)
 	rOption     = reflect.TypeOf((Option)([]interface{}{}))
 	rRequest    = reflect.TypeOf((*solver.Request)(nil)).Elem()
 	rFileMode   = reflect.TypeOf(os.FileMode(0))
@@ -318,6 +426,8 @@ func ReflectTo(v Value, t reflect.Type) (reflect.Value, error) {
 		iface, err = v.String()
 	case rInt:
 		iface, err = v.Int()
+	case rBool:
+		iface, err = v.Bool()
 	case rOption:
 		iface, err = v.Option()
 	case rRequest:
@@ -0,0 +1,910 @@
+package dapserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/go-dap"
+	"github.com/moby/buildkit/client"
+	"github.com/openllb/hlb"
+	"github.com/openllb/hlb/builtin"
+	"github.com/openllb/hlb/codegen"
+	"github.com/openllb/hlb/diagnostic"
+	"github.com/openllb/hlb/local"
+	"github.com/openllb/hlb/parser"
+	"github.com/pkg/errors"
+)
+
+// There is only ever one thread of execution in the HLB debugger.
+const threadID = 1
+
+const (
+	localsReference = iota + 1
+	filesystemReference
+)
+
+// LaunchArgs are the arguments of a DAP launch request.
+type LaunchArgs struct {
+	Program     string   `json:"program"`
+	Targets     []string `json:"targets"`
+	Cwd         string   `json:"cwd"`
+	StopOnEntry bool     `json:"stopOnEntry"`
+}
+
+type DAPServer struct {
+	cln *client.Client
+
+	w   io.Writer
+	wmu sync.Mutex
+	seq int64
+
+	mu           sync.Mutex
+	ctx          context.Context
+	args         LaunchArgs
+	mod          *parser.Module
+	lines        map[string][]int
+	breakpoints  []*codegen.Breakpoint
+	history      []*snapshot
+	historyIndex int
+	sourceRefs   map[string]int
+	started      bool
+	stopped      bool
+
+	resume chan command
+}
+
+type snapshot struct {
+	ctx    context.Context
+	scope  *parser.Scope
+	node   parser.Node
+	ret    codegen.Value
+	target string
+}
+
+// level returns the call depth of the snapshot, where the statements of a
+// function have the same level as its declaration.
+func (s *snapshot) level() int {
+	frames := codegen.Backtrace(s.ctx)
+	if len(frames) > 0 && frames[len(frames)-1].Node == s.node {
+		return len(frames) - 1
+	}
+	return len(frames)
+}
+
+type command int
+
+const (
+	commandContinue command = iota
+	commandNext
+	commandStepIn
+	commandStepOut
+	commandStepBack
+	commandReverseContinue
+	commandDisconnect
+)
+
+func NewServer(cln *client.Client) *DAPServer {
+	return &DAPServer{
+		cln:        cln,
+		lines:      make(map[string][]int),
+		sourceRefs: make(map[string]int),
+		resume:     make(chan command),
+	}
+}
+
+func (s *DAPServer) Listen(ctx context.Context, r io.Reader, w io.Writer) error {
+	defer func() {
+		r := recover()
+		if r != nil {
+			log.Printf("listen recovered panic: %s", r)
+		}
+	}()
+
+	log.Printf("hlb-dap listening")
+	s.w = w
+	s.ctx = diagnostic.WithSources(ctx, builtin.Sources())
+
+	br := bufio.NewReader(r)
+	for {
+		msg, err := dap.ReadProtocolMessage(br)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			var derr *dap.DecodeProtocolMessageFieldError
+			if errors.As(err, &derr) {
+				log.Printf("skipping message: %s", err)
+				continue
+			}
+			return err
+		}
+
+		done, err := s.handle(msg)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+func (s *DAPServer) handle(msg dap.Message) (done bool, err error) {
+	switch req := msg.(type) {
+	case *dap.InitializeRequest:
+		log.Printf("initialize %q", req.Arguments.ClientID)
+		err = s.send(&dap.InitializeResponse{
+			Response: s.response(req.Request),
+			Body: dap.Capabilities{
+				SupportsConfigurationDoneRequest: true,
+				SupportsStepBack:                 true,
+			},
+		})
+		if err != nil {
+			return
+		}
+		err = s.send(&dap.InitializedEvent{Event: s.event("initialized")})
+	case *dap.LaunchRequest:
+		err = s.launch(req)
+	case *dap.SetBreakpointsRequest:
+		err = s.setBreakpoints(req)
+	case *dap.SetExceptionBreakpointsRequest:
+		err = s.send(&dap.SetExceptionBreakpointsResponse{Response: s.response(req.Request)})
+	case *dap.ConfigurationDoneRequest:
+		err = s.send(&dap.ConfigurationDoneResponse{Response: s.response(req.Request)})
+		if err != nil {
+			return
+		}
+		s.start()
+	case *dap.ThreadsRequest:
+		err = s.send(&dap.ThreadsResponse{
+			Response: s.response(req.Request),
+			Body: dap.ThreadsResponseBody{
+				Threads: []dap.Thread{{Id: threadID, Name: "hlb"}},
+			},
+		})
+	case *dap.StackTraceRequest:
+		err = s.stackTrace(req)
+	case *dap.ScopesRequest:
+		err = s.scopes(req)
+	case *dap.VariablesRequest:
+		err = s.variables(req)
+	case *dap.SourceRequest:
+		err = s.source(req)
+	case *dap.ContinueRequest:
+		err = s.send(&dap.ContinueResponse{
+			Response: s.response(req.Request),
+			Body:     dap.ContinueResponseBody{AllThreadsContinued: true},
+		})
+		s.step(commandContinue)
+	case *dap.NextRequest:
+		err = s.send(&dap.NextResponse{Response: s.response(req.Request)})
+		s.step(commandNext)
+	case *dap.StepInRequest:
+		err = s.send(&dap.StepInResponse{Response: s.response(req.Request)})
+		s.step(commandStepIn)
+	case *dap.StepOutRequest:
+		err = s.send(&dap.StepOutResponse{Response: s.response(req.Request)})
+		s.step(commandStepOut)
+	case *dap.StepBackRequest:
+		err = s.send(&dap.StepBackResponse{Response: s.response(req.Request)})
+		s.step(commandStepBack)
+	case *dap.ReverseContinueRequest:
+		err = s.send(&dap.ReverseContinueResponse{Response: s.response(req.Request)})
+		s.step(commandReverseContinue)
+	case *dap.DisconnectRequest:
+		log.Printf("disconnect")
+		s.step(commandDisconnect)
+		return true, s.send(&dap.DisconnectResponse{Response: s.response(req.Request)})
+	default:
+		// Requests are decoded into their concrete types, so round trip
+		// through JSON to find the command to respond to.
+		var base dap.Request
+		dt, err := json.Marshal(msg)
+		if err != nil {
+			return false, err
+		}
+		err = json.Unmarshal(dt, &base)
+		if err != nil {
+			return false, err
+		}
+		return false, s.sendError(base, fmt.Errorf("unsupported command %q", base.Command))
+	}
+	return
+}
+
+func (s *DAPServer) launch(req *dap.LaunchRequest) error {
+	dt, err := json.Marshal(req.Arguments)
+	if err != nil {
+		return err
+	}
+
+	var args LaunchArgs
+	err = json.Unmarshal(dt, &args)
+	if err != nil {
+		return s.sendError(req.Request, err)
+	}
+	if args.Program == "" {
+		args.Program = "build.hlb"
+	}
+	if len(args.Targets) == 0 {
+		args.Targets = []string{"default"}
+	}
+	log.Printf("launch %q %s", args.Program, args.Targets)
+
+	// Open the program by its absolute path so that every node in the module
+	// can be mapped back to a source on disk.
+	args.Program, err = filepath.Abs(args.Program)
+	if err != nil {
+		return s.sendError(req.Request, err)
+	}
+
+	f, err := os.Open(args.Program)
+	if err != nil {
+		return s.sendError(req.Request, err)
+	}
+	defer f.Close()
+
+	ctx, err := local.WithCwd(s.ctx, args.Cwd)
+	if err != nil {
+		return s.sendError(req.Request, err)
+	}
+
+	mod, err := parser.Parse(ctx, f)
+	if err != nil {
+		return s.sendError(req.Request, err)
+	}
+
+	s.mu.Lock()
+	s.ctx = ctx
+	s.args = args
+	s.mod = mod
+	s.resolveBreakpoints()
+	s.mu.Unlock()
+
+	return s.send(&dap.LaunchResponse{Response: s.response(req.Request)})
+}
+
+func (s *DAPServer) setBreakpoints(req *dap.SetBreakpointsRequest) error {
+	filename := req.Arguments.Source.Path
+
+	var lines []int
+	for _, bp := range req.Arguments.Breakpoints {
+		lines = append(lines, bp.Line)
+	}
+	if len(req.Arguments.Breakpoints) == 0 {
+		lines = append(lines, req.Arguments.Lines...)
+	}
+	log.Printf("set breakpoints %q %v", filename, lines)
+
+	s.mu.Lock()
+	s.lines[filename] = lines
+	resolved := s.resolveBreakpoints()
+	s.mu.Unlock()
+
+	var breakpoints []dap.Breakpoint
+	for _, line := range lines {
+		bp := dap.Breakpoint{
+			Source: req.Arguments.Source,
+			Line:   line,
+		}
+		if resolved[filename][line] {
+			bp.Verified = true
+		} else {
+			bp.Message = "no function or call statement on this line"
+		}
+		breakpoints = append(breakpoints, bp)
+	}
+
+	return s.send(&dap.SetBreakpointsResponse{
+		Response: s.response(req.Request),
+		Body: dap.SetBreakpointsResponseBody{
+			Breakpoints: breakpoints,
+		},
+	})
+}
+
+// resolveBreakpoints maps the requested source lines to function declarations
+// and call statements of the launched module. Calls to the builtin
+// `breakpoint` are always breakpoints. The caller must hold the lock.
+func (s *DAPServer) resolveBreakpoints() map[string]map[int]bool {
+	resolved := make(map[string]map[int]bool)
+	if s.mod == nil {
+		return resolved
+	}
+
+	s.breakpoints = codegen.FindStaticBreakpoints(s.mod)
+
+	isRequested := func(node parser.Node) bool {
+		pos := node.Position()
+		for _, line := range s.lines[pos.Filename] {
+			if line == pos.Line {
+				if resolved[pos.Filename] == nil {
+					resolved[pos.Filename] = make(map[int]bool)
+				}
+				resolved[pos.Filename][line] = true
+				return true
+			}
+		}
+		return false
+	}
+
+	parser.Match(s.mod, parser.MatchOpts{},
+		func(fun *parser.FuncDecl) {
+			if isRequested(fun) {
+				s.breakpoints = append(s.breakpoints, &codegen.Breakpoint{
+					Func: fun,
+				})
+			}
+		},
+		func(fun *parser.FuncDecl, call *parser.CallStmt) {
+			if !call.Breakpoint() && isRequested(call) {
+				s.breakpoints = append(s.breakpoints, &codegen.Breakpoint{
+					Func: fun,
+					Call: call,
+				})
+			}
+		},
+	)
+
+	return resolved
+}
+
+// start compiles the launched module with a debugger that yields control
+// to the DAP client.
+func (s *DAPServer) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.mod == nil {
+		return
+	}
+	s.started = true
+
+	ctx := codegen.WithImageResolver(s.ctx, codegen.NewCachedImageResolver(s.cln))
+
+	var targets []codegen.Target
+	for _, target := range s.args.Targets {
+		targets = append(targets, codegen.Target{Name: target})
+	}
+
+	go func() {
+		_, err := hlb.Compile(ctx, s.cln, s.mod, targets, codegen.WithDebugger(s.debugger()))
+		exitCode := 0
+		if err != nil && err != codegen.ErrDebugExit {
+			exitCode = 1
+			s.output(ctx, err)
+		}
+
+		err = s.send(&dap.ExitedEvent{
+			Event: s.event("exited"),
+			Body:  dap.ExitedEventBody{ExitCode: exitCode},
+		})
+		if err != nil {
+			log.Printf("failed to send exited event: %s", err)
+		}
+
+		err = s.send(&dap.TerminatedEvent{Event: s.event("terminated")})
+		if err != nil {
+			log.Printf("failed to send terminated event: %s", err)
+		}
+	}()
+}
+
+// debugger returns a codegen.Debugger that records every step in the history
+// and blocks until the DAP client resumes execution. Stepping backwards
+// replays the snapshots in the history without re-running codegen.
+func (s *DAPServer) debugger() codegen.Debugger {
+	var (
+		target string
+		mode   = commandContinue
+		level  int
+		first  = true
+	)
+
+	if s.args.StopOnEntry {
+		mode = commandStepIn
+	}
+
+	return func(ctx context.Context, scope *parser.Scope, node parser.Node, ret codegen.Value) error {
+		snap := &snapshot{ctx, scope, node, ret, target}
+		if fun, ok := node.(*parser.Ident); ok && snap.level() == 0 {
+			target = fun.Text
+			snap.target = target
+		}
+
+		s.mu.Lock()
+		s.history = append(s.history, snap)
+		s.historyIndex = len(s.history) - 1
+		s.mu.Unlock()
+
+		shouldStop := func(snap *snapshot) (string, bool) {
+			if first {
+				first = false
+				return "entry", mode == commandStepIn
+			}
+			if _, ok := snap.node.(*parser.Module); ok {
+				return "", false
+			}
+			if s.isBreakpoint(snap.node) {
+				return "breakpoint", true
+			}
+			switch mode {
+			case commandStepIn:
+				return "step", true
+			case commandNext:
+				return "step", snap.level() <= level
+			case commandStepOut:
+				return "step", snap.level() < level
+			}
+			return "", false
+		}
+
+		reason, ok := shouldStop(snap)
+		if !ok {
+			return nil
+		}
+
+		for {
+			s.mu.Lock()
+			s.stopped = true
+			s.mu.Unlock()
+
+			err := s.send(&dap.StoppedEvent{
+				Event: s.event("stopped"),
+				Body: dap.StoppedEventBody{
+					Reason:            reason,
+					ThreadId:          threadID,
+					AllThreadsStopped: true,
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			mode = <-s.resume
+
+			s.mu.Lock()
+			index := s.historyIndex
+			s.mu.Unlock()
+
+			level = s.history[index].level()
+			reason = "step"
+
+			switch mode {
+			case commandDisconnect:
+				return codegen.ErrDebugExit
+			case commandStepBack:
+				if index > 0 {
+					index--
+				}
+			case commandReverseContinue:
+				for index > 0 {
+					index--
+					if s.isBreakpoint(s.history[index].node) {
+						reason = "breakpoint"
+						break
+					}
+				}
+			default:
+				// Replay the history until we stop or catch up to the
+				// current step of codegen.
+				stopped := false
+				for !stopped && index < len(s.history)-1 {
+					index++
+					reason, stopped = shouldStop(s.history[index])
+				}
+				if !stopped {
+					s.mu.Lock()
+					s.historyIndex = index
+					s.mu.Unlock()
+					return nil
+				}
+			}
+
+			s.mu.Lock()
+			s.historyIndex = index
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *DAPServer) isBreakpoint(node parser.Node) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, bp := range s.breakpoints {
+		if bp.Call != nil {
+			if bp.Call.Name == node {
+				return true
+			}
+		} else if bp.Func.Name == node {
+			return true
+		}
+	}
+	return false
+}
+
+// step resumes a stopped debugger, it is a no-op if the program is running.
+func (s *DAPServer) step(cmd command) {
+	s.mu.Lock()
+	stopped := s.stopped
+	s.stopped = false
+	s.mu.Unlock()
+
+	if stopped {
+		s.resume <- cmd
+	}
+}
+
+// current returns the snapshot the debugger is stopped at.
+func (s *DAPServer) current() *snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.history) == 0 {
+		return nil
+	}
+	return s.history[s.historyIndex]
+}
+
+func (s *DAPServer) stackTrace(req *dap.StackTraceRequest) error {
+	var frames []dap.StackFrame
+
+	snap := s.current()
+	if snap != nil {
+		if _, ok := snap.node.(*parser.Module); ok {
+			frames = append(frames, s.stackFrame(0, "<entry>", snap.node))
+		} else {
+			name := snap.target
+			if fun := enclosingFunc(snap.scope); fun != nil {
+				name = fun.Name.Text
+			}
+			frames = append(frames, s.stackFrame(0, name, snap.node))
+
+			backtrace := codegen.Backtrace(snap.ctx)
+			if len(backtrace) > 0 && backtrace[len(backtrace)-1].Node == snap.node {
+				backtrace = backtrace[:len(backtrace)-1]
+			}
+
+			// Each frame in the backtrace is a call site, which is executing
+			// in the function called by the frame before it.
+			for i := len(backtrace) - 1; i >= 0; i-- {
+				name := snap.target
+				if i > 0 {
+					name = backtrace[i-1].Node.String()
+				}
+				frames = append(frames, s.stackFrame(len(frames), name, backtrace[i].Node))
+			}
+		}
+	}
+
+	return s.send(&dap.StackTraceResponse{
+		Response: s.response(req.Request),
+		Body: dap.StackTraceResponseBody{
+			StackFrames: frames,
+			TotalFrames: len(frames),
+		},
+	})
+}
+
+func (s *DAPServer) stackFrame(id int, name string, node parser.Node) dap.StackFrame {
+	pos, end := node.Position(), node.End()
+	return dap.StackFrame{
+		Id:        id,
+		Name:      name,
+		Source:    s.sourceOf(pos.Filename),
+		Line:      pos.Line,
+		Column:    pos.Column,
+		EndLine:   end.Line,
+		EndColumn: end.Column,
+	}
+}
+
+// sourceOf returns a DAP source for a filename. Sources that don't exist on
+// disk, like the builtin module, are given a reference so that their contents
+// can be retrieved with a source request.
+func (s *DAPServer) sourceOf(filename string) dap.Source {
+	if _, err := os.Stat(filename); err == nil {
+		path, err := filepath.Abs(filename)
+		if err == nil {
+			return dap.Source{Name: filepath.Base(path), Path: path}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ref, ok := s.sourceRefs[filename]
+	if !ok {
+		ref = len(s.sourceRefs) + 1
+		s.sourceRefs[filename] = ref
+	}
+
+	return dap.Source{
+		Name:             filename,
+		SourceReference:  ref,
+		PresentationHint: "deemphasize",
+	}
+}
+
+func (s *DAPServer) source(req *dap.SourceRequest) error {
+	ref := req.Arguments.SourceReference
+	if ref == 0 {
+		ref = req.Arguments.Source.SourceReference
+	}
+
+	var filename string
+	s.mu.Lock()
+	for name, r := range s.sourceRefs {
+		if r == ref {
+			filename = name
+		}
+	}
+	s.mu.Unlock()
+
+	fb := diagnostic.Sources(s.ctx).Get(filename)
+	if fb == nil {
+		return s.sendError(req.Request, fmt.Errorf("unknown source reference %d", ref))
+	}
+
+	return s.send(&dap.SourceResponse{
+		Response: s.response(req.Request),
+		Body: dap.SourceResponseBody{
+			Content:  string(fb.Bytes()),
+			MimeType: "text/x-hlb",
+		},
+	})
+}
+
+func (s *DAPServer) scopes(req *dap.ScopesRequest) error {
+	scopes := []dap.Scope{{
+		Name:               "Locals",
+		PresentationHint:   "locals",
+		VariablesReference: localsReference,
+	}}
+
+	snap := s.current()
+	if snap != nil && snap.ret != nil && snap.ret.Kind() == parser.Filesystem {
+		scopes = append(scopes, dap.Scope{
+			Name:               "Filesystem",
+			VariablesReference: filesystemReference,
+		})
+	}
+
+	return s.send(&dap.ScopesResponse{
+		Response: s.response(req.Request),
+		Body:     dap.ScopesResponseBody{Scopes: scopes},
+	})
+}
+
+func (s *DAPServer) variables(req *dap.VariablesRequest) error {
+	variables := []dap.Variable{}
+
+	snap := s.current()
+	if snap != nil {
+		switch req.Arguments.VariablesReference {
+		case localsReference:
+			variables = append(variables, locals(snap)...)
+		case filesystemReference:
+			variables = append(variables, filesystem(snap)...)
+		}
+	}
+
+	return s.send(&dap.VariablesResponse{
+		Response: s.response(req.Request),
+		Body:     dap.VariablesResponseBody{Variables: variables},
+	})
+}
+
+// locals returns the function parameters and loop variables in scope of the
+// snapshot.
+func locals(snap *snapshot) []dap.Variable {
+	var variables []dap.Variable
+	seen := make(map[string]struct{})
+	for scope := snap.scope; scope != nil; scope = scope.Outer {
+		if _, ok := scope.Node.(*parser.Module); ok {
+			break
+		}
+
+		var names []string
+		for name := range scope.Objects {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+
+			obj := scope.Objects[name]
+			if _, ok := obj.Node.(*parser.Field); !ok {
+				continue
+			}
+
+			variables = append(variables, dap.Variable{
+				Name:  name,
+				Value: formatValue(snap.ctx, obj.Data),
+				Type:  string(obj.Kind),
+			})
+		}
+	}
+	return variables
+}
+
+// filesystem returns the state of the filesystem being built at the
+// snapshot.
+func filesystem(snap *snapshot) []dap.Variable {
+	fs, err := snap.ret.Filesystem()
+	if err != nil {
+		return nil
+	}
+
+	var variables []dap.Variable
+	add := func(name string, value interface{}, err error) {
+		if err != nil {
+			value = fmt.Sprintf("err: %s", err)
+		}
+		variables = append(variables, dap.Variable{
+			Name:  name,
+			Value: fmt.Sprint(value),
+		})
+	}
+
+	ctx := snap.ctx
+	dgst, err := fs.Digest(ctx)
+	add("digest", dgst, err)
+	dir, err := fs.State.GetDir(ctx)
+	add("dir", strconv.Quote(dir), err)
+	env, err := fs.State.Env(ctx)
+	add("env", env, err)
+	network, err := fs.State.GetNetwork(ctx)
+	add("network", network, err)
+	security, err := fs.State.GetSecurity(ctx)
+	add("security", security, err)
+	return variables
+}
+
+func formatValue(ctx context.Context, data interface{}) string {
+	v, ok := data.(codegen.Value)
+	if !ok {
+		return fmt.Sprintf("%#v", data)
+	}
+
+	if v.Kind().IsList() {
+		values, err := v.List()
+		if err != nil {
+			return fmt.Sprintf("err: %s", err)
+		}
+		var elems []string
+		for _, elem := range values {
+			elems = append(elems, formatValue(ctx, elem))
+		}
+		return fmt.Sprintf("[%s]", strings.Join(elems, ", "))
+	}
+
+	var (
+		value interface{}
+		err   error
+	)
+	switch v.Kind() {
+	case parser.String:
+		var str string
+		str, err = v.String()
+		value = strconv.Quote(str)
+	case parser.Int:
+		value, err = v.Int()
+	case parser.Bool:
+		value, err = v.Bool()
+	case parser.Filesystem:
+		var fs codegen.Filesystem
+		fs, err = v.Filesystem()
+		if err == nil {
+			value, err = fs.Digest(ctx)
+		}
+	default:
+		var opts codegen.Option
+		opts, err = v.Option()
+		value = fmt.Sprintf("%s (%d options)", v.Kind(), len(opts))
+	}
+	if err != nil {
+		return fmt.Sprintf("err: %s", err)
+	}
+	return fmt.Sprint(value)
+}
+
+func enclosingFunc(scope *parser.Scope) *parser.FuncDecl {
+	for ; scope != nil; scope = scope.Outer {
+		if fun, ok := scope.Node.(*parser.FuncDecl); ok {
+			return fun
+		}
+	}
+	return nil
+}
+
+// output sends the diagnostics of an error as output events.
+func (s *DAPServer) output(ctx context.Context, err error) {
+	spans := diagnostic.Spans(err)
+	if len(spans) == 0 {
+		err = s.send(&dap.OutputEvent{
+			Event: s.event("output"),
+			Body: dap.OutputEventBody{
+				Category: "stderr",
+				Output:   fmt.Sprintf("%s\n", err),
+			},
+		})
+		if err != nil {
+			log.Printf("failed to send output event: %s", err)
+		}
+		return
+	}
+
+	for _, span := range spans {
+		err = s.send(&dap.OutputEvent{
+			Event: s.event("output"),
+			Body: dap.OutputEventBody{
+				Category: "stderr",
+				Output:   fmt.Sprintf("%s\n", span.Pretty(ctx)),
+			},
+		})
+		if err != nil {
+			log.Printf("failed to send output event: %s", err)
+		}
+	}
+}
+
+func (s *DAPServer) response(req dap.Request) dap.Response {
+	return dap.Response{
+		ProtocolMessage: s.message("response"),
+		Command:         req.Command,
+		RequestSeq:      req.Seq,
+		Success:         true,
+	}
+}
+
+func (s *DAPServer) event(event string) dap.Event {
+	return dap.Event{
+		ProtocolMessage: s.message("event"),
+		Event:           event,
+	}
+}
+
+func (s *DAPServer) sendError(req dap.Request, err error) error {
+	log.Printf("%s failed: %s", req.Command, err)
+	resp := s.response(req)
+	resp.Success = false
+	resp.Message = err.Error()
+	return s.send(&dap.ErrorResponse{
+		Response: resp,
+		Body: dap.ErrorResponseBody{
+			Error: dap.ErrorMessage{
+				Format:   err.Error(),
+				ShowUser: true,
+			},
+		},
+	})
+}
+
+func (s *DAPServer) message(typ string) dap.ProtocolMessage {
+	return dap.ProtocolMessage{
+		Seq:  int(atomic.AddInt64(&s.seq, 1)),
+		Type: typ,
+	}
+}
+
+// send writes a message to the client, messages may be sent concurrently from
+// the request loop and the debugger.
+func (s *DAPServer) send(msg dap.Message) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	return dap.WriteProtocolMessage(s.w, msg)
+}
@@ -0,0 +1,151 @@
+package dapserver
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-dap"
+	"github.com/stretchr/testify/require"
+)
+
+const testProgram = `fs default() {
+	scratch
+	build "x"
+}
+
+fs build(string name) {
+	mkdir name 0o755
+}
+`
+
+// testClient is a DAP client talking to a server over pipes.
+type testClient struct {
+	t   *testing.T
+	w   io.Writer
+	br  *bufio.Reader
+	seq int
+}
+
+func (c *testClient) request(command string) dap.Request {
+	c.seq++
+	return dap.Request{
+		ProtocolMessage: dap.ProtocolMessage{Seq: c.seq, Type: "request"},
+		Command:         command,
+	}
+}
+
+func (c *testClient) send(msg dap.Message) {
+	require.NoError(c.t, dap.WriteProtocolMessage(c.w, msg))
+}
+
+// expect reads the next message from the server, which must have the same
+// type as msg.
+func (c *testClient) expect(msg dap.Message) dap.Message {
+	actual, err := dap.ReadProtocolMessage(c.br)
+	require.NoError(c.t, err)
+	require.IsType(c.t, msg, actual)
+	return actual
+}
+
+func TestDebugSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dapserver")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	program := filepath.Join(dir, "build.hlb")
+	err = ioutil.WriteFile(program, []byte(testProgram), 0644)
+	require.NoError(t, err)
+
+	sr, cw := io.Pipe()
+	cr, sw := io.Pipe()
+	c := &testClient{t: t, w: cw, br: bufio.NewReader(cr)}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(nil).Listen(context.Background(), sr, sw)
+	}()
+
+	c.send(&dap.InitializeRequest{Request: c.request("initialize")})
+	c.expect(&dap.InitializeResponse{})
+	c.expect(&dap.InitializedEvent{})
+
+	c.send(&dap.LaunchRequest{
+		Request: c.request("launch"),
+		Arguments: map[string]interface{}{
+			"program":     program,
+			"cwd":         dir,
+			"stopOnEntry": true,
+		},
+	})
+	c.expect(&dap.LaunchResponse{})
+
+	// Breakpoints are only verified on function declarations and call
+	// statements.
+	c.send(&dap.SetBreakpointsRequest{
+		Request: c.request("setBreakpoints"),
+		Arguments: dap.SetBreakpointsArguments{
+			Source:      dap.Source{Path: program},
+			Breakpoints: []dap.SourceBreakpoint{{Line: 7}, {Line: 5}},
+		},
+	})
+	bps := c.expect(&dap.SetBreakpointsResponse{}).(*dap.SetBreakpointsResponse)
+	require.Len(t, bps.Body.Breakpoints, 2)
+	require.True(t, bps.Body.Breakpoints[0].Verified)
+	require.False(t, bps.Body.Breakpoints[1].Verified)
+	require.Equal(t, "no function or call statement on this line", bps.Body.Breakpoints[1].Message)
+
+	c.send(&dap.ConfigurationDoneRequest{Request: c.request("configurationDone")})
+	c.expect(&dap.ConfigurationDoneResponse{})
+	stopped := c.expect(&dap.StoppedEvent{}).(*dap.StoppedEvent)
+	require.Equal(t, "entry", stopped.Body.Reason)
+
+	c.send(&dap.ContinueRequest{Request: c.request("continue")})
+	c.expect(&dap.ContinueResponse{})
+	stopped = c.expect(&dap.StoppedEvent{}).(*dap.StoppedEvent)
+	require.Equal(t, "breakpoint", stopped.Body.Reason)
+
+	// The top frame is the breakpoint in the called function, followed by
+	// its call site in the target.
+	c.send(&dap.StackTraceRequest{Request: c.request("stackTrace")})
+	trace := c.expect(&dap.StackTraceResponse{}).(*dap.StackTraceResponse)
+	require.True(t, len(trace.Body.StackFrames) >= 2)
+	require.Equal(t, "build", trace.Body.StackFrames[0].Name)
+	require.Equal(t, 7, trace.Body.StackFrames[0].Line)
+	require.Equal(t, program, trace.Body.StackFrames[0].Source.Path)
+	require.Equal(t, 3, trace.Body.StackFrames[1].Line)
+
+	c.send(&dap.ScopesRequest{Request: c.request("scopes")})
+	scopes := c.expect(&dap.ScopesResponse{}).(*dap.ScopesResponse)
+	require.Equal(t, "Locals", scopes.Body.Scopes[0].Name)
+
+	c.send(&dap.VariablesRequest{
+		Request:   c.request("variables"),
+		Arguments: dap.VariablesArguments{VariablesReference: localsReference},
+	})
+	variables := c.expect(&dap.VariablesResponse{}).(*dap.VariablesResponse)
+	require.Equal(t, []dap.Variable{{
+		Name:  "name",
+		Value: `"x"`,
+		Type:  "string",
+	}}, variables.Body.Variables)
+
+	c.send(&dap.EvaluateRequest{Request: c.request("evaluate")})
+	errResp := c.expect(&dap.ErrorResponse{}).(*dap.ErrorResponse)
+	require.False(t, errResp.Success)
+	require.Equal(t, `unsupported command "evaluate"`, errResp.Message)
+
+	c.send(&dap.ContinueRequest{Request: c.request("continue")})
+	c.expect(&dap.ContinueResponse{})
+	exited := c.expect(&dap.ExitedEvent{}).(*dap.ExitedEvent)
+	require.Equal(t, 0, exited.Body.ExitCode)
+	c.expect(&dap.TerminatedEvent{})
+
+	c.send(&dap.DisconnectRequest{Request: c.request("disconnect")})
+	c.expect(&dap.DisconnectResponse{})
+	require.NoError(t, <-done)
+}
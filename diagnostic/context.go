@@ -2,14 +2,17 @@ package diagnostic
 
 import (
 	"context"
+	"fmt"
+	"os"
 
 	"github.com/logrusorgru/aurora"
 	"github.com/openllb/hlb/pkg/filebuffer"
 )
 
 type (
-	sourcesKey struct{}
-	colorKey   struct{}
+	sourcesKey        struct{}
+	colorKey          struct{}
+	warningHandlerKey struct{}
 )
 
 func WithSources(ctx context.Context, sources *filebuffer.Sources) context.Context {
@@ -35,3 +38,30 @@ func Color(ctx context.Context) aurora.Aurora {
 	}
 	return color
 }
+
+// WarningHandler handles diagnostics that don't fail a program, such as
+// deprecations.
+type WarningHandler func(err error)
+
+func WithWarningHandler(ctx context.Context, handler WarningHandler) context.Context {
+	return context.WithValue(ctx, warningHandlerKey{}, handler)
+}
+
+// Warnings returns the warning handler of the context, or nil if warnings
+// should be printed.
+func Warnings(ctx context.Context) WarningHandler {
+	handler, _ := ctx.Value(warningHandlerKey{}).(WarningHandler)
+	return handler
+}
+
+// Warn sends a warning to the warning handler of the context, or prints its
+// spans to stderr if there is no handler.
+func Warn(ctx context.Context, err error) {
+	if handler := Warnings(ctx); handler != nil {
+		handler(err)
+		return
+	}
+	for _, span := range Spans(err) {
+		fmt.Fprintf(os.Stderr, "%s\n", span.Pretty(ctx))
+	}
+}
@@ -0,0 +1,158 @@
+package diagnostic
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/alecthomas/participle/lexer"
+)
+
+// Diagnostic is a machine-readable form of an error and its spans.
+type Diagnostic struct {
+	Severity   string       `json:"severity"`
+	Rule       string       `json:"rule,omitempty"`
+	Message    string       `json:"message"`
+	Location   *Location    `json:"location,omitempty"`
+	Spans      []SpanReport `json:"spans,omitempty"`
+	Suggestion *Edit        `json:"suggestion,omitempty"`
+}
+
+// SpanReport is a machine-readable form of a span.
+type SpanReport struct {
+	Location
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+}
+
+// Location is a range of source in a file. The end is exclusive.
+type Location struct {
+	Filename string   `json:"filename"`
+	Start    Position `json:"start"`
+	End      Position `json:"end"`
+}
+
+// Position is a 1-based line and column.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func NewLocation(start, end lexer.Position) Location {
+	return Location{
+		Filename: start.Filename,
+		Start:    Position{Line: start.Line, Column: start.Column},
+		End:      Position{Line: end.Line, Column: end.Column},
+	}
+}
+
+// Edit is a suggested replacement of the source at a location.
+type Edit struct {
+	Location
+	Text string `json:"text"`
+}
+
+// Fixable is implemented by errors that suggest an edit to fix them.
+type Fixable interface {
+	Edit() Edit
+}
+
+// Report returns the diagnostics of an error. Errors without spans are
+// reported with only their message.
+func Report(err error) []Diagnostic {
+	if err == nil {
+		return nil
+	}
+
+	errs := []error{err}
+	var e *Error
+	if errors.As(err, &e) {
+		errs = e.Diagnostics
+	}
+
+	var diags []Diagnostic
+	for _, err := range errs {
+		var se *SpanError
+		if !errors.As(err, &se) {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError.String(),
+				Message:  Cause(err),
+			})
+			continue
+		}
+
+		diag := newDiagnostic(se)
+
+		var fixable Fixable
+		if errors.As(err, &fixable) {
+			edit := fixable.Edit()
+			diag.Suggestion = &edit
+		}
+
+		diags = append(diags, diag)
+	}
+	return diags
+}
+
+// ReportBacktrace returns the diagnostic of an error with a backtrace. The
+// location is the last frame, and the earlier frames are reported as
+// secondary spans.
+func ReportBacktrace(err error, backtrace []*SpanError) Diagnostic {
+	last := backtrace[len(backtrace)-1]
+
+	diag := newDiagnostic(last)
+	diag.Message = Cause(err)
+	for i := len(backtrace) - 2; i >= 0; i-- {
+		for _, span := range backtrace[i].Spans {
+			diag.Spans = append(diag.Spans, SpanReport{
+				Location: NewLocation(span.Start, span.End),
+				Type:     "secondary",
+				Message:  span.Message,
+			})
+		}
+	}
+	return diag
+}
+
+func newDiagnostic(se *SpanError) Diagnostic {
+	diag := Diagnostic{
+		Severity: se.Severity.String(),
+		Rule:     se.Rule,
+	}
+	if se.Err != nil {
+		diag.Message = se.Err.Error()
+	}
+
+	loc := NewLocation(se.Pos, se.Pos)
+	for _, span := range se.Spans {
+		typ := "secondary"
+		if span.Type == Primary {
+			typ = "primary"
+			if diag.Location == nil {
+				loc = NewLocation(span.Start, span.End)
+				diag.Location = &loc
+			}
+		}
+
+		diag.Spans = append(diag.Spans, SpanReport{
+			Location: NewLocation(span.Start, span.End),
+			Type:     typ,
+			Message:  span.Message,
+		})
+	}
+	if diag.Location == nil {
+		diag.Location = &loc
+	}
+
+	return diag
+}
+
+// WriteJSON writes diagnostics as a JSON array.
+func WriteJSON(w io.Writer, diags []Diagnostic) error {
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diags)
+}
@@ -0,0 +1,149 @@
+package diagnostic
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/participle/lexer"
+	"github.com/stretchr/testify/require"
+)
+
+type fixError struct {
+	error
+	edit Edit
+}
+
+func (e *fixError) Edit() Edit {
+	return e.edit
+}
+
+func testPos(line, column int) lexer.Position {
+	return lexer.Position{Filename: "build.hlb", Line: line, Column: column}
+}
+
+// testError returns an error with a diagnostic without spans, an error with
+// primary and secondary spans, and a warning of a lint rule with a fix.
+func testError() error {
+	return &Error{
+		Diagnostics: []error{
+			errors.New("rpc error: code = Unknown desc = failed to solve"),
+			WithError(
+				errors.New("unknown func `foo`"),
+				testPos(3, 2),
+				Spanf(Secondary, testPos(1, 1), testPos(1, 4), "in this function"),
+				Spanf(Primary, testPos(3, 2), testPos(3, 5), "did you mean `for`?"),
+			),
+			WithError(
+				&fixError{
+					error: errors.New("group is deprecated"),
+					edit: Edit{
+						Location: NewLocation(testPos(5, 1), testPos(5, 6)),
+						Text:     "stage",
+					},
+				},
+				testPos(5, 1),
+				Spanf(Primary, testPos(5, 1), testPos(5, 6), "use `stage` instead"),
+				WithSeverity(SeverityWarning),
+				WithRule("deprecated"),
+			),
+		},
+	}
+}
+
+const expectedDiagnostics = `[{
+	"severity": "error",
+	"message": "failed to solve"
+}, {
+	"severity": "error",
+	"message": "unknown func ` + "`foo`" + `",
+	"location": {
+		"filename": "build.hlb",
+		"start": {"line": 3, "column": 2},
+		"end": {"line": 3, "column": 5}
+	},
+	"spans": [{
+		"filename": "build.hlb",
+		"start": {"line": 1, "column": 1},
+		"end": {"line": 1, "column": 4},
+		"type": "secondary",
+		"message": "in this function"
+	}, {
+		"filename": "build.hlb",
+		"start": {"line": 3, "column": 2},
+		"end": {"line": 3, "column": 5},
+		"type": "primary",
+		"message": "did you mean ` + "`for`" + `?"
+	}]
+}, {
+	"severity": "warning",
+	"rule": "deprecated",
+	"message": "group is deprecated",
+	"location": {
+		"filename": "build.hlb",
+		"start": {"line": 5, "column": 1},
+		"end": {"line": 5, "column": 6}
+	},
+	"spans": [{
+		"filename": "build.hlb",
+		"start": {"line": 5, "column": 1},
+		"end": {"line": 5, "column": 6},
+		"type": "primary",
+		"message": "use ` + "`stage`" + ` instead"
+	}],
+	"suggestion": {
+		"filename": "build.hlb",
+		"start": {"line": 5, "column": 1},
+		"end": {"line": 5, "column": 6},
+		"text": "stage"
+	}
+}]`
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, Report(nil))
+
+	var buf bytes.Buffer
+	err := WriteJSON(&buf, Report(testError()))
+	require.NoError(t, err)
+	require.JSONEq(t, expectedDiagnostics, buf.String())
+
+	// Without diagnostics, an empty array is written.
+	buf.Reset()
+	err = WriteJSON(&buf, Report(nil))
+	require.NoError(t, err)
+	require.Equal(t, "[]\n", buf.String())
+}
+
+func TestReportBacktrace(t *testing.T) {
+	t.Parallel()
+
+	caller := WithError(nil, testPos(2, 2), Spanf(Primary, testPos(2, 2), testPos(2, 7), "called here"))
+	callee := WithError(nil, testPos(7, 2), Spanf(Primary, testPos(7, 2), testPos(7, 5), ""))
+
+	var backtrace []*SpanError
+	for _, err := range []error{caller, callee} {
+		var se *SpanError
+		require.True(t, errors.As(err, &se))
+		backtrace = append(backtrace, se)
+	}
+
+	// The last frame is the location of the diagnostic, and the frames that
+	// called it are secondary spans.
+	diag := ReportBacktrace(errors.New("exit code: 1"), backtrace)
+	require.Equal(t, "error", diag.Severity)
+	require.Equal(t, "exit code: 1", diag.Message)
+	require.Equal(t, &Location{
+		Filename: "build.hlb",
+		Start:    Position{Line: 7, Column: 2},
+		End:      Position{Line: 7, Column: 5},
+	}, diag.Location)
+	require.Len(t, diag.Spans, 2)
+	require.Equal(t, "primary", diag.Spans[0].Type)
+	require.Equal(t, SpanReport{
+		Location: NewLocation(testPos(2, 2), testPos(2, 7)),
+		Type:     "secondary",
+		Message:  "called here",
+	}, diag.Spans[1])
+}
@@ -0,0 +1,161 @@
+package diagnostic
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// SARIF 2.1.0 log, only declaring the properties written by WriteSARIF.
+// See: https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId,omitempty"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion          `json:"deletedRegion"`
+	InsertedContent sarifArtifactContent `json:"insertedContent"`
+}
+
+type sarifArtifactContent struct {
+	Text string `json:"text"`
+}
+
+// WriteSARIF writes diagnostics as a SARIF log with a single run of the named
+// tool.
+func WriteSARIF(w io.Writer, name, version string, diags []Diagnostic) error {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           name,
+				Version:        version,
+				InformationURI: "https://github.com/openllb/hlb",
+			},
+		},
+		Results: []sarifResult{},
+	}
+
+	for _, diag := range diags {
+		result := sarifResult{
+			RuleID:  diag.Rule,
+			Level:   diag.Severity,
+			Message: sarifMessage{Text: diag.Message},
+		}
+
+		if diag.Location != nil {
+			result.Locations = append(result.Locations, newSARIFLocation(*diag.Location))
+		}
+
+		for i, span := range diag.Spans {
+			loc := newSARIFLocation(span.Location)
+			loc.ID = i + 1
+			if span.Message != "" {
+				loc.Message = &sarifMessage{Text: span.Message}
+			}
+			result.RelatedLocations = append(result.RelatedLocations, loc)
+		}
+
+		if diag.Suggestion != nil {
+			result.Fixes = append(result.Fixes, sarifFix{
+				Description: sarifMessage{Text: diag.Suggestion.Text},
+				ArtifactChanges: []sarifArtifactChange{{
+					ArtifactLocation: sarifArtifactLocation{URI: diag.Suggestion.Filename},
+					Replacements: []sarifReplacement{{
+						DeletedRegion:   newSARIFRegion(diag.Suggestion.Location),
+						InsertedContent: sarifArtifactContent{Text: diag.Suggestion.Text},
+					}},
+				}},
+			})
+		}
+
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+func newSARIFLocation(loc Location) sarifLocation {
+	return sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: loc.Filename},
+			Region:           newSARIFRegion(loc),
+		},
+	}
+}
+
+func newSARIFRegion(loc Location) sarifRegion {
+	return sarifRegion{
+		StartLine:   loc.Start.Line,
+		StartColumn: loc.Start.Column,
+		EndLine:     loc.End.Line,
+		EndColumn:   loc.End.Column,
+	}
+}
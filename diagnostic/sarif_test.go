@@ -0,0 +1,100 @@
+package diagnostic
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const expectedSARIF = `{
+	"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+	"version": "2.1.0",
+	"runs": [{
+		"tool": {
+			"driver": {
+				"name": "hlb",
+				"version": "v0.4.0",
+				"informationUri": "https://github.com/openllb/hlb"
+			}
+		},
+		"results": [{
+			"level": "error",
+			"message": {"text": "failed to solve"}
+		}, {
+			"level": "error",
+			"message": {"text": "unknown func ` + "`foo`" + `"},
+			"locations": [{
+				"physicalLocation": {
+					"artifactLocation": {"uri": "build.hlb"},
+					"region": {"startLine": 3, "startColumn": 2, "endLine": 3, "endColumn": 5}
+				}
+			}],
+			"relatedLocations": [{
+				"id": 1,
+				"physicalLocation": {
+					"artifactLocation": {"uri": "build.hlb"},
+					"region": {"startLine": 1, "startColumn": 1, "endLine": 1, "endColumn": 4}
+				},
+				"message": {"text": "in this function"}
+			}, {
+				"id": 2,
+				"physicalLocation": {
+					"artifactLocation": {"uri": "build.hlb"},
+					"region": {"startLine": 3, "startColumn": 2, "endLine": 3, "endColumn": 5}
+				},
+				"message": {"text": "did you mean ` + "`for`" + `?"}
+			}]
+		}, {
+			"ruleId": "deprecated",
+			"level": "warning",
+			"message": {"text": "group is deprecated"},
+			"locations": [{
+				"physicalLocation": {
+					"artifactLocation": {"uri": "build.hlb"},
+					"region": {"startLine": 5, "startColumn": 1, "endLine": 5, "endColumn": 6}
+				}
+			}],
+			"relatedLocations": [{
+				"id": 1,
+				"physicalLocation": {
+					"artifactLocation": {"uri": "build.hlb"},
+					"region": {"startLine": 5, "startColumn": 1, "endLine": 5, "endColumn": 6}
+				},
+				"message": {"text": "use ` + "`stage`" + ` instead"}
+			}],
+			"fixes": [{
+				"description": {"text": "stage"},
+				"artifactChanges": [{
+					"artifactLocation": {"uri": "build.hlb"},
+					"replacements": [{
+						"deletedRegion": {"startLine": 5, "startColumn": 1, "endLine": 5, "endColumn": 6},
+						"insertedContent": {"text": "stage"}
+					}]
+				}]
+			}]
+		}]
+	}]
+}`
+
+func TestWriteSARIF(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := WriteSARIF(&buf, "hlb", "v0.4.0", Report(testError()))
+	require.NoError(t, err)
+	require.JSONEq(t, expectedSARIF, buf.String())
+
+	// A run without diagnostics still has an empty list of results.
+	buf.Reset()
+	err = WriteSARIF(&buf, "hlb", "", nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": [{
+			"tool": {"driver": {"name": "hlb", "informationUri": "https://github.com/openllb/hlb"}},
+			"results": []
+		}]
+	}`, buf.String())
+}
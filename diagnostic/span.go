@@ -19,6 +19,23 @@ const (
 	Secondary
 )
 
+// Severity is the severity of a span error.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
 type Span struct {
 	Message string
 	Type    Type
@@ -39,6 +56,21 @@ func Spanf(t Type, start, end lexer.Position, format string, a ...interface{}) O
 	}
 }
 
+// WithSeverity sets the severity of a span error, which is an error by
+// default.
+func WithSeverity(severity Severity) Option {
+	return func(se *SpanError) {
+		se.Severity = severity
+	}
+}
+
+// WithRule sets the ID of the lint rule that reported a span error.
+func WithRule(rule string) Option {
+	return func(se *SpanError) {
+		se.Rule = rule
+	}
+}
+
 func WithError(err error, pos lexer.Position, opts ...Option) error {
 	se := &SpanError{
 		Err: err,
@@ -51,9 +83,11 @@ func WithError(err error, pos lexer.Position, opts ...Option) error {
 }
 
 type SpanError struct {
-	Err   error
-	Pos   lexer.Position
-	Spans []Span
+	Err      error
+	Pos      lexer.Position
+	Spans    []Span
+	Severity Severity
+	Rule     string
 }
 
 func (se *SpanError) Error() string {
@@ -242,9 +276,17 @@ func (se *SpanError) Pretty(ctx context.Context, opts ...PrettyOption) string {
 
 	var title string
 	if se.Err != nil {
+		label := se.Severity.String()
+		if se.Rule != "" {
+			label = fmt.Sprintf("%s[%s]", label, se.Rule)
+		}
+		severity := color.Red(label)
+		if se.Severity == SeverityWarning {
+			severity = color.Yellow(label)
+		}
 		title = color.Sprintf(
 			"%s: %s\n",
-			color.Bold(color.Red("error")),
+			color.Bold(severity),
 			color.Bold(se.Err),
 		)
 	}
@@ -47,10 +47,39 @@ func (e *ErrModule) Error() string {
 	return e.Err.Error()
 }
 
+// ErrFix is an error that can be fixed by replacing the source of Node with
+// the unparsed Fix.
+type ErrFix struct {
+	Node parser.Node
+	Fix  parser.Node
+	Err  error
+}
+
+func (e *ErrFix) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrFix) Error() string {
+	return e.Err.Error()
+}
+
+// Edit returns the fix as a replacement of the source of Node.
+func (e *ErrFix) Edit() diagnostic.Edit {
+	return diagnostic.Edit{
+		Location: diagnostic.NewLocation(e.Node.Position(), e.Node.End()),
+		Text:     e.Fix.String(),
+	}
+}
+
+func WithFix(err error, node, fix parser.Node) error {
+	return &ErrFix{Node: node, Fix: fix, Err: err}
+}
+
 func WithDeprecated(mod *parser.Module, node parser.Node, format string, a ...interface{}) error {
 	return node.WithError(
 		&ErrModule{mod, fmt.Errorf(format, a...)},
 		node.Spanf(diagnostic.Primary, format, a...),
+		diagnostic.WithSeverity(diagnostic.SeverityWarning),
 	)
 }
 
@@ -65,6 +94,27 @@ func WithInvalidCompileTarget(ident parser.Node) error {
 	)
 }
 
+func WithMissingTargetArg(fun *parser.FuncDecl, param *parser.Field) error {
+	return fun.WithError(
+		fmt.Errorf("target `%s` is missing arg `%s`", fun.Name, param.Name),
+		param.Spanf(diagnostic.Primary, "missing --arg %s=<%s>", param.Name, param.Type),
+	)
+}
+
+func WithInvalidTargetArg(fun *parser.FuncDecl, param *parser.Field, arg string) error {
+	return fun.WithError(
+		fmt.Errorf("cannot use %q as %s for arg `%s` of target `%s`", arg, param.Type, param.Name, fun.Name),
+		param.Spanf(diagnostic.Primary, "cannot use %q as %s", arg, param.Type),
+	)
+}
+
+func WithUnsupportedTargetParam(fun *parser.FuncDecl, param *parser.Field) error {
+	return fun.WithError(
+		fmt.Errorf("target `%s` has a parameter that cannot be passed as an arg", fun.Name),
+		param.Spanf(diagnostic.Primary, "only string, int and bool parameters can be passed as args"),
+	)
+}
+
 func WithWrongType(expr parser.Node, expected []parser.Kind, actual parser.Kind, opts ...diagnostic.Option) error {
 	opts = append(opts, expr.Spanf(
 		diagnostic.Primary,
@@ -91,15 +141,40 @@ func WithImportPathNotExist(err error, expr parser.Node, filename string) error
 	)
 }
 
+func WithReplacePathNotExist(err error, name parser.Node, path string) error {
+	return name.WithError(
+		err,
+		name.Spanf(diagnostic.Primary, "replaced by %q, which has no module", path),
+	)
+}
+
+func WithVendorChecksumMismatch(expr parser.Node, vp, expected, actual string) error {
+	return expr.WithError(
+		fmt.Errorf("vendored module %s does not match its checksum", vp),
+		expr.Spanf(diagnostic.Primary, "expected %s but got %s, run `hlb module vendor` to restore it", expected, actual),
+	)
+}
+
+func WithVendorNotSummed(expr parser.Node, vp string) error {
+	return expr.WithError(
+		fmt.Errorf("vendored module %s has no checksum", vp),
+		expr.Spanf(diagnostic.Primary, "not in hlb.sum, run `hlb module tidy` to record it"),
+	)
+}
+
 func WithUndefinedIdent(ident parser.Node, suggested *parser.Object, opts ...diagnostic.Option) error {
 	opts = append(opts, ident.Spanf(diagnostic.Primary, "undefined or not in scope"))
 	if suggested != nil {
 		opts = append(opts, suggested.Ident.Spanf(diagnostic.Secondary, "did you mean `%s`?", suggested.Ident))
 	}
-	return ident.WithError(
+	err := ident.WithError(
 		fmt.Errorf("`%s` is undefined or not in scope", ident),
 		opts...,
 	)
+	if suggested != nil {
+		err = WithFix(err, ident, &parser.Ident{Text: suggested.Ident.Text})
+	}
+	return err
 }
 
 func WithNotImport(ie *parser.IdentExpr, decl parser.Node) error {
@@ -194,6 +269,28 @@ func WithInvalidImageRef(err error, arg parser.Node, ref string) error {
 	)
 }
 
+func WithPullArtifact(err error, arg parser.Node, ref string) error {
+	return arg.WithError(
+		err,
+		arg.Spanf(diagnostic.Primary, "failed to pull module `%s`", ref),
+	)
+}
+
+func WithNotLocked(arg parser.Node, source string) error {
+	return arg.WithError(
+		fmt.Errorf("%s is not locked", source),
+		arg.Spanf(diagnostic.Primary, "not locked, run `hlb module lock` to lock it"),
+	)
+}
+
+func WithLockDrift(arg parser.Node, source, locked, resolved string) error {
+	return arg.WithError(
+		fmt.Errorf("%s has drifted from the lock", source),
+		arg.Spanf(diagnostic.Primary, "locked to %s but resolved to %s", locked, resolved),
+		diagnostic.WithSeverity(diagnostic.SeverityWarning),
+	)
+}
+
 func WithInvalidNetworkMode(arg parser.Node, mode string, modes []string) error {
 	suggestion := diagnostic.Suggestion(mode, modes)
 	if suggestion != "" {
@@ -227,6 +324,17 @@ func WithInvalidSharingMode(arg parser.Node, mode string, modes []string) error
 	)
 }
 
+func WithInvalidCacheType(arg parser.Node, typ string, types []string) error {
+	suggestion := diagnostic.Suggestion(typ, types)
+	if suggestion != "" {
+		suggestion = fmt.Sprintf("\ndid you mean `%s`?", suggestion)
+	}
+	return arg.WithError(
+		fmt.Errorf("invalid cache type `%s`", typ),
+		arg.Spanf(diagnostic.Primary, "invalid cache type `%s`%s", typ, suggestion),
+	)
+}
+
 func WithImportWithinImport(ie, decl parser.Node) error {
 	return ie.WithError(
 		fmt.Errorf("cannot use import within import"),
@@ -243,6 +351,50 @@ func WithBindCacheMount(as, cache parser.Node) error {
 	)
 }
 
+func WithUnpinnedImage(arg parser.Node, ref string) error {
+	return arg.WithError(
+		fmt.Errorf("image `%s` is not pinned to a digest", ref),
+		arg.Spanf(diagnostic.Primary, "not pinned, add a digest or run `hlb module lock` to lock it"),
+	)
+}
+
+func WithUnsafeRunOption(option parser.Node, name string, target parser.Node) error {
+	return option.WithError(
+		fmt.Errorf("exported target `%s` runs with `%s`", target, name),
+		option.Spanf(diagnostic.Primary, "should not be used by exported targets"),
+		target.Spanf(diagnostic.Secondary, "exported target defined here"),
+	)
+}
+
+func WithLocalInExportedTarget(callee, target parser.Node) error {
+	return callee.WithError(
+		fmt.Errorf("exported target `%s` depends on the local system with `%s`", target, callee),
+		callee.Spanf(diagnostic.Primary, "not reproducible across systems"),
+		target.Spanf(diagnostic.Secondary, "exported target defined here"),
+	)
+}
+
+func WithUnusedFunction(name parser.Node) error {
+	return name.WithError(
+		fmt.Errorf("function `%s` is unused", name),
+		name.Spanf(diagnostic.Primary, "unused function, remove it or export it"),
+	)
+}
+
+func WithUnusedParam(param, fun parser.Node) error {
+	return param.WithError(
+		fmt.Errorf("parameter `%s` of `%s` is unused", param, fun),
+		param.Spanf(diagnostic.Primary, "unused parameter"),
+	)
+}
+
+func WithCopyLocalContext(arg parser.Node) error {
+	return arg.WithError(
+		fmt.Errorf("copy from local `.` without include or exclude patterns"),
+		arg.Spanf(diagnostic.Primary, "copies the whole directory, use `includePatterns` or `excludePatterns`"),
+	)
+}
+
 func OneOfKinds(kinds []parser.Kind) string {
 	if len(kinds) == 1 {
 		return fmt.Sprintf("type %s", kinds[0])
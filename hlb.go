@@ -1,9 +1,7 @@
 package hlb
 
 import (
-	"bufio"
 	"context"
-	"fmt"
 	"os"
 
 	"github.com/moby/buildkit/client"
@@ -17,20 +15,28 @@ import (
 	"github.com/openllb/hlb/solver"
 )
 
-func Compile(ctx context.Context, cln *client.Client, mod *parser.Module, targets []codegen.Target) (solver.Request, error) {
-	err := checker.SemanticPass(mod)
+func Compile(ctx context.Context, cln *client.Client, mod *parser.Module, targets []codegen.Target, opts ...codegen.CodeGenOption) (solver.Request, error) {
+	err := checker.Traced(ctx, "checker.SemanticPass", checker.SemanticPass, mod)
 	if err != nil {
 		return nil, err
 	}
 
-	err = linter.Lint(ctx, mod, linter.WithRecursive())
+	lintOpts := []linter.LintOption{linter.WithRecursive()}
+	cfg, err := linter.ReadConfig(linter.ConfigPath)
 	if err != nil {
-		for _, span := range diagnostic.Spans(err) {
-			fmt.Fprintf(os.Stderr, "%s\n", span.Pretty(ctx))
+		if !os.IsNotExist(err) {
+			return nil, err
 		}
+	} else {
+		lintOpts = append(lintOpts, linter.WithConfig(cfg))
 	}
 
-	err = checker.Check(mod)
+	err = linter.Lint(ctx, mod, lintOpts...)
+	if err != nil {
+		diagnostic.Warn(ctx, err)
+	}
+
+	err = checker.Traced(ctx, "checker.Check", checker.Check, mod)
 	if err != nil {
 		return nil, err
 	}
@@ -51,13 +57,12 @@ func Compile(ctx context.Context, cln *client.Client, mod *parser.Module, target
 		return nil, err
 	}
 
-	var opts []codegen.CodeGenOption
+	var defaultOpts []codegen.CodeGenOption
 	if codegen.MultiWriter(ctx) == nil {
-		r := bufio.NewReader(os.Stdin)
-		opts = append(opts, codegen.WithDebugger(codegen.NewDebugger(cln, os.Stderr, r)))
+		defaultOpts = append(defaultOpts, codegen.WithDebugger(codegen.NewDebugger(cln, os.Stderr, solver.NewInput(os.Stdin))))
 	}
 
-	cg, err := codegen.New(cln, opts...)
+	cg, err := codegen.New(cln, append(defaultOpts, opts...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,250 @@
+package langserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/openllb/hlb/diagnostic"
+	"github.com/openllb/hlb/errdefs"
+	"github.com/openllb/hlb/parser"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// CodeAction is a code action literal, which the LSP library doesn't declare.
+type CodeAction struct {
+	Title       string             `json:"title"`
+	Kind        lsp.CodeActionKind `json:"kind,omitempty"`
+	Diagnostics []lsp.Diagnostic   `json:"diagnostics,omitempty"`
+	IsPreferred bool               `json:"isPreferred,omitempty"`
+	Edit        *lsp.WorkspaceEdit `json:"edit,omitempty"`
+}
+
+var (
+	identRegexp = regexp.MustCompile(`^[a-zA-Z_][\w:]*$`)
+
+	keywords = map[string]struct{}{
+		"import": {},
+		"export": {},
+		"from":   {},
+		"with":   {},
+		"as":     {},
+		"if":     {},
+		"else":   {},
+		"for":    {},
+		"in":     {},
+		"true":   {},
+		"false":  {},
+	}
+)
+
+func (ls *LangServer) textDocumentCodeActionHandler(ctx context.Context, params lsp.CodeActionParams) ([]CodeAction, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document code action %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []CodeAction
+	for _, fix := range append(fixes(td.LintErr), fixes(td.Err)...) {
+		r := newRangeFromNode(fix.Node)
+		if !isRangeOverlapping(r, params.Range) {
+			continue
+		}
+
+		var diags []lsp.Diagnostic
+		for _, diag := range params.Context.Diagnostics {
+			if isRangeOverlapping(r, diag.Range) {
+				diags = append(diags, diag)
+			}
+		}
+
+		actions = append(actions, CodeAction{
+			Title:       fmt.Sprintf("Replace with `%s`", fix.Fix),
+			Kind:        lsp.CAKQuickFix,
+			Diagnostics: diags,
+			IsPreferred: true,
+			Edit: &lsp.WorkspaceEdit{
+				Changes: map[string][]lsp.TextEdit{
+					string(uri): {newTextEdit(fix.Node, fix.Fix)},
+				},
+			},
+		})
+	}
+
+	return actions, nil
+}
+
+func (ls *LangServer) textDocumentRenameHandler(ctx context.Context, params lsp.RenameParams) (*lsp.WorkspaceEdit, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document rename %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if td.Module == nil || td.Module.Scope == nil {
+		return nil, fmt.Errorf("cannot rename in %q: %w", uri, td.Err)
+	}
+
+	scope, obj := objectAtPosition(td.Module, params.Position)
+	if obj == nil {
+		return nil, fmt.Errorf("no renameable identifier at %d:%d", params.Position.Line+1, params.Position.Character+1)
+	}
+
+	name := params.NewName
+	if _, ok := keywords[name]; ok || !identRegexp.MatchString(name) {
+		return nil, fmt.Errorf("`%s` is not a valid identifier", name)
+	}
+
+	edit := &lsp.WorkspaceEdit{
+		Changes: make(map[string][]lsp.TextEdit),
+	}
+	if name == obj.Ident.Text {
+		return edit, nil
+	}
+
+	// Renaming must not collide with or shadow any name visible from the
+	// declaration or its references.
+	for scope != nil && scope.Objects[obj.Ident.Text] != obj {
+		scope = scope.Outer
+	}
+	if scope != nil && scope.Lookup(name) != nil {
+		return nil, fmt.Errorf("`%s` is already declared", name)
+	}
+
+	refs := references(td.Module, obj)
+	for _, ref := range refs {
+		if ref.Scope.Lookup(name) != nil {
+			pos := ref.Ident.Position()
+			return nil, fmt.Errorf("`%s` is already declared in the scope of the reference at %d:%d", name, pos.Line, pos.Column)
+		}
+	}
+
+	fix := &parser.Ident{Text: name}
+	edits := []lsp.TextEdit{newTextEdit(obj.Ident, fix)}
+	for _, ref := range refs {
+		edits = append(edits, newTextEdit(ref.Ident, fix))
+	}
+	edit.Changes[string(uri)] = edits
+
+	// Exported objects may also be referenced by modules importing this one.
+	if scope == td.Module.Scope && obj.Exported {
+		for importURI, idents := range ls.importerReferences(ctx, uri, obj.Ident.Text) {
+			for _, ident := range idents {
+				edit.Changes[string(importURI)] = append(edit.Changes[string(importURI)], newTextEdit(ident, fix))
+			}
+		}
+	}
+
+	return edit, nil
+}
+
+// importerReferences returns the references to an exported name from the HLB
+// files in the same directory that import the document by its local path.
+func (ls *LangServer) importerReferences(ctx context.Context, uri lsp.DocumentURI, name string) map[lsp.DocumentURI][]*parser.Ident {
+	filename := strings.TrimPrefix(string(uri), "file://")
+	dir := filepath.Dir(filename)
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.hlb"))
+	if err != nil {
+		log.Printf("failed to find importers: %s", err)
+		return nil
+	}
+
+	refs := make(map[lsp.DocumentURI][]*parser.Ident)
+	for _, match := range matches {
+		if match == filename {
+			continue
+		}
+
+		importerURI := lsp.DocumentURI(fmt.Sprintf("file://%s", match))
+		td, err := ls.textDocument(importerURI)
+		if err != nil {
+			data, err := ioutil.ReadFile(match)
+			if err != nil {
+				log.Printf("failed to read file: %s", err)
+				continue
+			}
+			td = NewTextDocument(ctx, importerURI, string(data))
+		}
+
+		if td.Module == nil || td.Module.Scope == nil {
+			continue
+		}
+
+		imports := make(map[*parser.Object]struct{})
+		for _, obj := range td.Module.Scope.Objects {
+			id, ok := obj.Node.(*parser.ImportDecl)
+			if !ok || id.Expr == nil || id.Expr.BasicLit == nil || id.Expr.BasicLit.Str == nil {
+				continue
+			}
+
+			path := id.Expr.BasicLit.Str.Unquoted()
+			if filepath.Join(dir, path) == filename {
+				imports[obj] = struct{}{}
+			}
+		}
+
+		if len(imports) == 0 {
+			continue
+		}
+
+		parser.Match(td.Module, parser.MatchOpts{},
+			func(block *parser.BlockStmt, ie *parser.IdentExpr) {
+				if block.Scope == nil || ie.Reference == nil || ie.Reference.Ident.Text != name {
+					return
+				}
+				if _, ok := imports[block.Scope.Lookup(ie.Ident.Text)]; ok {
+					refs[importerURI] = append(refs[importerURI], ie.Reference.Ident)
+				}
+			},
+		)
+	}
+
+	return refs
+}
+
+// fixes returns the errors that can be fixed from a diagnostic error.
+func fixes(err error) []*errdefs.ErrFix {
+	if err == nil {
+		return nil
+	}
+
+	errs := []error{err}
+	var de *diagnostic.Error
+	if errors.As(err, &de) {
+		errs = de.Diagnostics
+	}
+
+	var errFixes []*errdefs.ErrFix
+	for _, err := range errs {
+		var fix *errdefs.ErrFix
+		if errors.As(err, &fix) {
+			errFixes = append(errFixes, fix)
+		}
+	}
+	return errFixes
+}
+
+func newTextEdit(node, fix parser.Node) lsp.TextEdit {
+	return lsp.TextEdit{
+		Range:   newRangeFromNode(node),
+		NewText: fix.String(),
+	}
+}
+
+func isRangeOverlapping(a, b lsp.Range) bool {
+	before := func(p, q lsp.Position) bool {
+		return p.Line < q.Line || (p.Line == q.Line && p.Character < q.Character)
+	}
+	return !before(a.End, b.Start) && !before(b.End, a.Start)
+}
@@ -0,0 +1,208 @@
+package langserver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeAction(t *testing.T) {
+	t.Parallel()
+
+	ls := openDocument(t, dedent.Dedent(`
+	group default() {
+		parallel fs { scratch; }
+	}
+	`))
+
+	actions, err := ls.textDocumentCodeActionHandler(context.Background(), lsp.CodeActionParams{
+		TextDocument: testDocument(),
+		Range: lsp.Range{
+			Start: lsp.Position{Line: 2, Character: 1},
+			End:   lsp.Position{Line: 2, Character: 1},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, actions, 1)
+	require.Equal(t, "Replace with `stage`", actions[0].Title)
+	require.Equal(t, []lsp.TextEdit{{
+		Range: lsp.Range{
+			Start: lsp.Position{Line: 2, Character: 1},
+			End:   lsp.Position{Line: 2, Character: 9},
+		},
+		NewText: "stage",
+	}}, actions[0].Edit.Changes[string(testURI)])
+}
+
+const renameProgram = `
+fs default() {
+	build "x"
+}
+
+fs build(string name) {
+	mkdir name 0o755
+}
+
+fs other(string path) {
+	build path
+}
+`
+
+func rename(ls *LangServer, uri lsp.DocumentURI, pos lsp.Position, name string) (*lsp.WorkspaceEdit, error) {
+	return ls.textDocumentRenameHandler(context.Background(), lsp.RenameParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     pos,
+		NewName:      name,
+	})
+}
+
+func renameEdit(line, char, length int, name string) lsp.TextEdit {
+	return lsp.TextEdit{
+		Range: lsp.Range{
+			Start: lsp.Position{Line: line, Character: char},
+			End:   lsp.Position{Line: line, Character: char + length},
+		},
+		NewText: name,
+	}
+}
+
+func TestRename(t *testing.T) {
+	t.Parallel()
+
+	ls := openDocument(t, renameProgram)
+
+	// Renaming a function from one of its references renames its declaration
+	// and every reference.
+	edit, err := rename(ls, testURI, lsp.Position{Line: 2, Character: 1}, "compile")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]lsp.TextEdit{
+		string(testURI): {
+			renameEdit(5, 3, 5, "compile"),
+			renameEdit(2, 1, 5, "compile"),
+			renameEdit(10, 1, 5, "compile"),
+		},
+	}, edit.Changes)
+
+	// Renaming a parameter only renames it within its function.
+	edit, err = rename(ls, testURI, lsp.Position{Line: 5, Character: 16}, "path")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]lsp.TextEdit{
+		string(testURI): {
+			renameEdit(5, 16, 4, "path"),
+			renameEdit(6, 7, 4, "path"),
+		},
+	}, edit.Changes)
+}
+
+func TestRenameErrors(t *testing.T) {
+	t.Parallel()
+
+	ls := openDocument(t, renameProgram)
+
+	for _, tc := range []struct {
+		name string
+		pos  lsp.Position
+		new  string
+		err  string
+	}{{
+		"keyword",
+		lsp.Position{Line: 5, Character: 3},
+		"for",
+		"`for` is not a valid identifier",
+	}, {
+		"invalid identifier",
+		lsp.Position{Line: 5, Character: 3},
+		"foo-bar",
+		"`foo-bar` is not a valid identifier",
+	}, {
+		"not an identifier",
+		lsp.Position{Line: 6, Character: 12},
+		"foo",
+		"no renameable identifier at 7:13",
+	}, {
+		"collision",
+		lsp.Position{Line: 5, Character: 3},
+		"default",
+		"`default` is already declared",
+	}, {
+		"collision with an outer scope",
+		lsp.Position{Line: 5, Character: 16},
+		"other",
+		"`other` is already declared",
+	}, {
+		"shadowed by a reference's scope",
+		lsp.Position{Line: 5, Character: 3},
+		"path",
+		"`path` is already declared in the scope of the reference at 11:2",
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := rename(ls, testURI, tc.pos, tc.new)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestRenameImporters(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "langserver")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "build.hlb"), []byte(dedent.Dedent(`
+	import lib from "./lib.hlb"
+	import other from "./other.hlb"
+
+	fs default() {
+		lib.build "x"
+		other.build "y"
+	}
+	`)), 0644)
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "other.hlb"), []byte(dedent.Dedent(`
+	export build
+
+	fs build(string name) {
+		scratch
+	}
+	`)), 0644)
+	require.NoError(t, err)
+
+	libURI := lsp.DocumentURI("file://" + filepath.Join(dir, "lib.hlb"))
+	ls := NewServer()
+	err = ls.textDocumentDidOpenHandler(context.Background(), lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{
+			URI: libURI,
+			Text: dedent.Dedent(`
+			export build
+
+			fs build(string name) {
+				mkdir name 0o755
+			}
+			`),
+		},
+	})
+	require.NoError(t, err)
+
+	// Renaming an exported function also renames the references of the
+	// modules importing it, but not the same name imported from elsewhere.
+	edit, err := rename(ls, libURI, lsp.Position{Line: 3, Character: 3}, "compile")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]lsp.TextEdit{
+		string(libURI): {
+			renameEdit(3, 3, 5, "compile"),
+			renameEdit(1, 7, 5, "compile"),
+		},
+		"file://" + filepath.Join(dir, "build.hlb"): {
+			renameEdit(5, 5, 5, "compile"),
+		},
+	}, edit.Changes)
+}
@@ -0,0 +1,115 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/openllb/hlb/parser"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func (ls *LangServer) textDocumentFormattingHandler(ctx context.Context, params lsp.DocumentFormattingParams) ([]lsp.TextEdit, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document formatting %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	// The text document's module has lint fixes applied, so the text is parsed
+	// again to format it as written.
+	mod, err := parser.Parse(ctx, strings.NewReader(td.Text))
+	if err != nil {
+		return nil, err
+	}
+
+	formatted := mod.String()
+	if formatted == td.Text {
+		return nil, nil
+	}
+
+	lines := strings.Split(td.Text, "\n")
+	last := lines[len(lines)-1]
+	return []lsp.TextEdit{{
+		Range: lsp.Range{
+			End: utf16Position(lines, lsp.Position{
+				Line:      len(lines) - 1,
+				Character: len([]rune(last)),
+			}),
+		},
+		NewText: formatted,
+	}}, nil
+}
+
+func (ls *LangServer) textDocumentRangeFormattingHandler(ctx context.Context, params lsp.DocumentRangeFormattingParams) ([]lsp.TextEdit, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document range formatting %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	mod, err := parser.Parse(ctx, strings.NewReader(td.Text))
+	if err != nil {
+		return nil, err
+	}
+
+	// Only whole declarations are formatted, the spacing between them is left
+	// as written.
+	lines := strings.Split(td.Text, "\n")
+	var edits []lsp.TextEdit
+	for _, decl := range mod.Decls {
+		var node parser.Node
+		switch {
+		case decl.Import != nil:
+			node = decl.Import
+		case decl.Export != nil:
+			node = decl.Export
+		case decl.Func != nil:
+			node = decl.Func
+		default:
+			continue
+		}
+
+		r := utf16Range(lines, newRangeFromNode(node))
+		if !isRangeOverlapping(r, params.Range) {
+			continue
+		}
+
+		edits = append(edits, lsp.TextEdit{
+			Range:   r,
+			NewText: node.String(),
+		})
+	}
+
+	return edits, nil
+}
+
+// utf16Range converts a range counted in runes, like the positions of nodes,
+// into UTF-16 code units, which LSP positions are counted in.
+func utf16Range(lines []string, r lsp.Range) lsp.Range {
+	return lsp.Range{
+		Start: utf16Position(lines, r.Start),
+		End:   utf16Position(lines, r.End),
+	}
+}
+
+// utf16Position converts a position counted in runes into UTF-16 code units.
+func utf16Position(lines []string, pos lsp.Position) lsp.Position {
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return pos
+	}
+
+	runes := []rune(lines[pos.Line])
+	if pos.Character < len(runes) {
+		runes = runes[:pos.Character]
+	}
+	return lsp.Position{
+		Line:      pos.Line,
+		Character: len(utf16.Encode(runes)),
+	}
+}
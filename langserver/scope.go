@@ -37,3 +37,26 @@ var (
 		Comment:   "comment.hlb",
 	}
 )
+
+// TokenType returns the semantic token type of the scope.
+func (s Scope) TokenType() string {
+	return scopeAsTokenType[s]
+}
+
+var (
+	// Standard semantic token types:
+	// https://microsoft.github.io/language-server-protocol/specifications/specification-3-16/#semanticTokenTypes
+	scopeAsTokenType = map[Scope]string{
+		String:    "string",
+		Constant:  "enumMember",
+		Numeric:   "number",
+		Variable:  "variable",
+		Parameter: "parameter",
+		Keyword:   "keyword",
+		Modifier:  "modifier",
+		Type:      "type",
+		Function:  "function",
+		Module:    "namespace",
+		Comment:   "comment",
+	}
+)
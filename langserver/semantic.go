@@ -0,0 +1,109 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// The LSP library predates semantic tokens, so the types introduced in LSP
+// 3.16 are declared here.
+
+type ServerCapabilities struct {
+	lsp.ServerCapabilities
+
+	SemanticTokensProvider *SemanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+}
+
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+}
+
+type SemanticTokensOptions struct {
+	Legend SemanticTokensLegend `json:"legend"`
+	Full   bool                 `json:"full"`
+}
+
+type SemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+type SemanticTokensParams struct {
+	TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+}
+
+type SemanticTokens struct {
+	Data []uint32 `json:"data"`
+}
+
+// semanticTokensLegend indexes token types by their Scope value.
+func semanticTokensLegend() SemanticTokensLegend {
+	legend := SemanticTokensLegend{
+		TokenModifiers: []string{},
+	}
+	for s := String; s <= Comment; s++ {
+		legend.TokenTypes = append(legend.TokenTypes, s.TokenType())
+	}
+	return legend
+}
+
+func (ls *LangServer) textDocumentSemanticTokensFullHandler(ctx context.Context, params SemanticTokensParams) (*SemanticTokens, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document semantic tokens %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := &SemanticTokens{Data: []uint32{}}
+	if td.Module == nil {
+		return tokens, nil
+	}
+
+	lines := make(map[int]lsp.SemanticHighlightingTokens)
+	highlightModule(lines, td.Module)
+
+	var sortedLines []int
+	for line := range lines {
+		sortedLines = append(sortedLines, line)
+	}
+	sort.Ints(sortedLines)
+
+	// Each token is encoded relative to the previous one as five integers:
+	// delta line, delta start character, length, token type and modifiers.
+	var prevLine, prevChar, prevEnd uint32
+	for _, line := range sortedLines {
+		lineTokens := lines[line]
+		sort.SliceStable(lineTokens, func(i, j int) bool {
+			return lineTokens[i].Character < lineTokens[j].Character
+		})
+
+		for _, token := range lineTokens {
+			// Multi-line nodes such as heredocs don't have a usable length.
+			if token.Length == 0 || int(token.Length) > 1<<15 {
+				continue
+			}
+
+			// Tokens must not overlap.
+			if uint32(line) == prevLine && token.Character < prevEnd {
+				continue
+			}
+
+			deltaLine := uint32(line) - prevLine
+			deltaChar := token.Character
+			if deltaLine == 0 {
+				deltaChar -= prevChar
+			}
+
+			tokens.Data = append(tokens.Data, deltaLine, deltaChar, uint32(token.Length), uint32(token.Scope), 0)
+			prevLine, prevChar = uint32(line), token.Character
+			prevEnd = prevChar + uint32(token.Length)
+		}
+	}
+
+	return tokens, nil
+}
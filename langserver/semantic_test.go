@@ -0,0 +1,30 @@
+package langserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSemanticTokensFull(t *testing.T) {
+	t.Parallel()
+
+	ls := openDocument(t, "fs default() {\n\timage \"alpine\"\n}\n")
+
+	tokens, err := ls.textDocumentSemanticTokensFullHandler(context.Background(), SemanticTokensParams{
+		TextDocument: testDocument(),
+	})
+	require.NoError(t, err)
+
+	// Tokens are relative to the previous token: delta line, delta start,
+	// length, type and modifiers.
+	require.Equal(t, []uint32{
+		0, 0, 2, uint32(Type), 0,
+		0, 3, 7, uint32(Function), 0,
+		1, 1, 5, uint32(Variable), 0,
+		0, 6, 1, uint32(String), 0,
+		0, 1, 6, uint32(String), 0,
+		0, 6, 1, uint32(String), 0,
+	}, tokens.Data)
+}
@@ -27,8 +27,9 @@ import (
 )
 
 type LangServer struct {
-	server *jrpc2.Server
-	capset map[Capability]struct{}
+	server  *jrpc2.Server
+	capset  map[Capability]struct{}
+	rootURI lsp.DocumentURI
 
 	tds map[lsp.DocumentURI]TextDocument
 	tmu sync.RWMutex
@@ -52,15 +53,24 @@ func NewServer() *LangServer {
 	}
 
 	ls.server = jrpc2.NewServer(handler.Map{
-		"initialize":              handler.New(ls.initializeHandler),
-		"exit":                    handler.New(ls.exitHandler),
-		"$/cancelRequest":         handler.New(ls.cancelRequestHandler),
-		"textDocument/didOpen":    handler.New(ls.textDocumentDidOpenHandler),
-		"textDocument/didClose":   handler.New(ls.textDocumentDidCloseHandler),
-		"textDocument/didChange":  handler.New(ls.textDocumentDidChangeHandler),
-		"textDocument/hover":      handler.New(ls.textDocumentHoverHandler),
-		"textDocument/definition": handler.New(ls.textDocumentDefinitionHandler),
-		"textDocument/completion": handler.New(ls.textDocumentCompletionHandler),
+		"initialize":                       handler.New(ls.initializeHandler),
+		"exit":                             handler.New(ls.exitHandler),
+		"$/cancelRequest":                  handler.New(ls.cancelRequestHandler),
+		"textDocument/didOpen":             handler.New(ls.textDocumentDidOpenHandler),
+		"textDocument/didClose":            handler.New(ls.textDocumentDidCloseHandler),
+		"textDocument/didChange":           handler.New(ls.textDocumentDidChangeHandler),
+		"textDocument/hover":               handler.New(ls.textDocumentHoverHandler),
+		"textDocument/definition":          handler.New(ls.textDocumentDefinitionHandler),
+		"textDocument/completion":          handler.New(ls.textDocumentCompletionHandler),
+		"textDocument/references":          handler.New(ls.textDocumentReferencesHandler),
+		"textDocument/rename":              handler.New(ls.textDocumentRenameHandler),
+		"textDocument/codeAction":          handler.New(ls.textDocumentCodeActionHandler),
+		"textDocument/formatting":          handler.New(ls.textDocumentFormattingHandler),
+		"textDocument/rangeFormatting":     handler.New(ls.textDocumentRangeFormattingHandler),
+		"textDocument/signatureHelp":       handler.New(ls.textDocumentSignatureHelpHandler),
+		"textDocument/documentSymbol":      handler.New(ls.textDocumentDocumentSymbolHandler),
+		"textDocument/semanticTokens/full": handler.New(ls.textDocumentSemanticTokensFullHandler),
+		"workspace/symbol":                 handler.New(ls.workspaceSymbolHandler),
 	}, &jrpc2.ServerOptions{
 		AllowPush: true,
 	})
@@ -81,8 +91,9 @@ func (ls *LangServer) Listen(ctx context.Context, r io.Reader, w io.WriteCloser)
 	return s.Wait()
 }
 
-func (ls *LangServer) initializeHandler(ctx context.Context, params lsp.InitializeParams) (lsp.InitializeResult, error) {
+func (ls *LangServer) initializeHandler(ctx context.Context, params lsp.InitializeParams) (InitializeResult, error) {
 	log.Printf("initialize %q", params.RootURI)
+	ls.rootURI = params.RootURI
 
 	highlightCap := params.Capabilities.TextDocument.SemanticHighlightingCapabilities
 	if highlightCap != nil && highlightCap.SemanticHighlighting {
@@ -90,31 +101,47 @@ func (ls *LangServer) initializeHandler(ctx context.Context, params lsp.Initiali
 		log.Printf("detected cap semantic highlighting")
 	}
 
-	return lsp.InitializeResult{
-		Capabilities: lsp.ServerCapabilities{
-			DefinitionProvider: true,
-			HoverProvider:      true,
-			TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
-				Options: &lsp.TextDocumentSyncOptions{
-					OpenClose: true,
-					Change:    lsp.TDSKFull,
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			ServerCapabilities: lsp.ServerCapabilities{
+				DefinitionProvider:              true,
+				HoverProvider:                   true,
+				ReferencesProvider:              true,
+				DocumentSymbolProvider:          true,
+				WorkspaceSymbolProvider:         true,
+				RenameProvider:                  true,
+				CodeActionProvider:              true,
+				DocumentFormattingProvider:      true,
+				DocumentRangeFormattingProvider: true,
+				SignatureHelpProvider: &lsp.SignatureHelpOptions{
+					TriggerCharacters: []string{" ", "("},
 				},
-			},
-			SemanticHighlighting: &lsp.SemanticHighlightingOptions{
-				Scopes: [][]string{
-					{String.String()},
-					{Constant.String()},
-					{Numeric.String()},
-					{Variable.String()},
-					{Parameter.String()},
-					{Keyword.String()},
-					{Modifier.String()},
-					{Type.String()},
-					{Function.String()},
-					{Module.String()},
-					{Comment.String()},
+				TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
+					Options: &lsp.TextDocumentSyncOptions{
+						OpenClose: true,
+						Change:    lsp.TDSKFull,
+					},
+				},
+				SemanticHighlighting: &lsp.SemanticHighlightingOptions{
+					Scopes: [][]string{
+						{String.String()},
+						{Constant.String()},
+						{Numeric.String()},
+						{Variable.String()},
+						{Parameter.String()},
+						{Keyword.String()},
+						{Modifier.String()},
+						{Type.String()},
+						{Function.String()},
+						{Module.String()},
+						{Comment.String()},
+					},
 				},
 			},
+			SemanticTokensProvider: &SemanticTokensOptions{
+				Legend: semanticTokensLegend(),
+				Full:   true,
+			},
 		},
 	}, nil
 }
@@ -293,6 +320,34 @@ func highlightBlock(lines map[int]lsp.SemanticHighlightingTokens, block *parser.
 			}
 
 		},
+		func(is *parser.IfStmt) {
+			if is.If != nil {
+				highlightNode(lines, is.If, Keyword)
+			}
+			if is.Condition != nil {
+				highlightExpr(lines, is.Condition.Expr())
+			}
+			if is.Else != nil && is.Else.Else != nil {
+				highlightNode(lines, is.Else.Else, Keyword)
+			}
+		},
+		func(fs *parser.ForStmt) {
+			if fs.For != nil {
+				highlightNode(lines, fs.For, Keyword)
+			}
+			if fs.Type != nil {
+				highlightNode(lines, fs.Type, Type)
+			}
+			if fs.Name != nil {
+				highlightNode(lines, fs.Name, Parameter)
+			}
+			if fs.In != nil {
+				highlightNode(lines, fs.In, Keyword)
+			}
+			if fs.Iterable != nil {
+				highlightExpr(lines, fs.Iterable.Expr())
+			}
+		},
 		func(expr *parser.ExprStmt) {
 			if expr.Expr != nil {
 				highlightExpr(lines, expr.Expr)
@@ -340,6 +395,10 @@ func highlightExpr(lines map[int]lsp.SemanticHighlightingTokens, expr *parser.Ex
 				highlightHeredocFragment(lines, f)
 			}
 		}
+	case expr.ListLit != nil:
+		for _, elem := range expr.ListLit.Elems() {
+			highlightExpr(lines, elem)
+		}
 	case expr.CallExpr != nil:
 		call := expr.CallExpr
 		if call.Name != nil {
@@ -738,6 +797,7 @@ type TextDocument struct {
 	Module     *parser.Module
 	Text       string
 	Err        error
+	LintErr    error
 }
 
 func NewTextDocument(ctx context.Context, uri lsp.DocumentURI, text string) TextDocument {
@@ -763,7 +823,7 @@ func NewTextDocument(ctx context.Context, uri lsp.DocumentURI, text string) Text
 		return td
 	}
 
-	_ = linter.Lint(ctx, td.Module)
+	td.LintErr = linter.Lint(ctx, td.Module)
 
 	td.Err = checker.Check(td.Module)
 	if td.Err != nil {
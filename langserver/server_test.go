@@ -0,0 +1,156 @@
+package langserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+const testURI = lsp.DocumentURI("file:///build.hlb")
+
+func openDocument(t *testing.T, text string) *LangServer {
+	ls := NewServer()
+	err := ls.textDocumentDidOpenHandler(context.Background(), lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: testURI, Text: text},
+	})
+	require.NoError(t, err)
+	return ls
+}
+
+func testDocument() lsp.TextDocumentIdentifier {
+	return lsp.TextDocumentIdentifier{URI: testURI}
+}
+
+func TestFormatting(t *testing.T) {
+	t.Parallel()
+
+	ls := openDocument(t, "fs default() {\nimage   \"alpine\"\n}\n\n\nfs  foo() { scratch; }\n")
+
+	edits, err := ls.textDocumentFormattingHandler(context.Background(), lsp.DocumentFormattingParams{
+		TextDocument: testDocument(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []lsp.TextEdit{{
+		Range: lsp.Range{
+			End: lsp.Position{Line: 6, Character: 0},
+		},
+		NewText: "fs default() {\n\timage \"alpine\"\n}\n\nfs foo() { scratch }\n",
+	}}, edits)
+
+	// Only the declarations overlapping the range are formatted.
+	edits, err = ls.textDocumentRangeFormattingHandler(context.Background(), lsp.DocumentRangeFormattingParams{
+		TextDocument: testDocument(),
+		Range: lsp.Range{
+			Start: lsp.Position{Line: 5, Character: 0},
+			End:   lsp.Position{Line: 5, Character: 1},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []lsp.TextEdit{{
+		Range: lsp.Range{
+			Start: lsp.Position{Line: 5, Character: 0},
+			End:   lsp.Position{Line: 5, Character: 22},
+		},
+		NewText: "fs foo() { scratch }",
+	}}, edits)
+
+	// Ranges are counted in UTF-16 code units, so characters outside of the
+	// basic multilingual plane count twice.
+	ls = openDocument(t, "fs  default() { image \"ålpine😀\"; }\n# 😀")
+	edits, err = ls.textDocumentFormattingHandler(context.Background(), lsp.DocumentFormattingParams{
+		TextDocument: testDocument(),
+	})
+	require.NoError(t, err)
+	require.Len(t, edits, 1)
+	require.Equal(t, lsp.Position{Line: 1, Character: 4}, edits[0].Range.End)
+
+	edits, err = ls.textDocumentRangeFormattingHandler(context.Background(), lsp.DocumentRangeFormattingParams{
+		TextDocument: testDocument(),
+		Range: lsp.Range{
+			Start: lsp.Position{Line: 0, Character: 0},
+			End:   lsp.Position{Line: 0, Character: 1},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []lsp.TextEdit{{
+		Range: lsp.Range{
+			Start: lsp.Position{Line: 0, Character: 0},
+			End:   lsp.Position{Line: 0, Character: 35},
+		},
+		NewText: "fs default() { image \"ålpine😀\" }",
+	}}, edits)
+
+	// Formatted documents have no edits.
+	ls = openDocument(t, "fs default() {\n\timage \"alpine\"\n}\n")
+	edits, err = ls.textDocumentFormattingHandler(context.Background(), lsp.DocumentFormattingParams{
+		TextDocument: testDocument(),
+	})
+	require.NoError(t, err)
+	require.Empty(t, edits)
+}
+
+func TestSignatureHelp(t *testing.T) {
+	t.Parallel()
+
+	text := dedent.Dedent(`
+	fs default() {
+		build "a" "b" "c"
+		run "make" with option {
+			dir "/src"
+		}
+	}
+
+	# Builds the sources.
+	#
+	# @param name the name of the build.
+	# @param sources the sources to build.
+	fs build(string name, variadic string sources) {
+		image "alpine"
+	}
+	`)
+	ls := openDocument(t, text)
+
+	help := func(line, character int) *lsp.SignatureHelp {
+		help, err := ls.textDocumentSignatureHelpHandler(context.Background(), lsp.TextDocumentPositionParams{
+			TextDocument: testDocument(),
+			Position:     lsp.Position{Line: line, Character: character},
+		})
+		require.NoError(t, err)
+		return help
+	}
+
+	// User functions are documented by their doc comment, and variadic
+	// parameters stay active for the remaining arguments.
+	sig := help(2, 8)
+	require.NotNil(t, sig)
+	require.Equal(t, 0, sig.ActiveParameter)
+	require.Equal(t, "fs build(string name, variadic string sources)", sig.Signatures[0].Label)
+	require.Equal(t, "Builds the sources.", sig.Signatures[0].Documentation)
+	require.Equal(t, "the name of the build.", sig.Signatures[0].Parameters[0].Documentation)
+
+	sig = help(2, 19)
+	require.NotNil(t, sig)
+	require.Equal(t, 1, sig.ActiveParameter)
+
+	// Builtins are documented by the builtin module.
+	sig = help(3, 5)
+	require.NotNil(t, sig)
+	require.Equal(t, "fs run(variadic string arg)", sig.Signatures[0].Label)
+	require.NotEmpty(t, sig.Signatures[0].Documentation)
+
+	// With clauses list the options of the call.
+	sig = help(3, 18)
+	require.NotNil(t, sig)
+
+	var labels []string
+	for _, sig := range sig.Signatures {
+		labels = append(labels, sig.Label)
+	}
+	require.Contains(t, labels, "option::run dir(string path)")
+
+	// Positions outside of calls have no signature.
+	require.Nil(t, help(0, 0))
+}
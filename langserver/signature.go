@@ -0,0 +1,230 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/openllb/doxygen-parser/doxygen"
+	"github.com/openllb/hlb/builtin"
+	"github.com/openllb/hlb/parser"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func (ls *LangServer) textDocumentSignatureHelpHandler(ctx context.Context, params lsp.TextDocumentPositionParams) (*lsp.SignatureHelp, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document signature help %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if td.Module == nil || td.Module.Scope == nil {
+		return nil, nil
+	}
+
+	var (
+		pos   = params.Position
+		lines = strings.Split(td.Text, "\n")
+		help  *lsp.SignatureHelp
+	)
+
+	// Calls are visited from the outermost to the innermost, so the last call
+	// containing the position is the one being typed.
+	parser.Match(td.Module,
+		parser.MatchOpts{
+			Filter: func(node parser.Node) bool {
+				return isPositionWithinCall(lines, pos, node)
+			},
+		},
+		func(block *parser.BlockStmt, call *parser.CallStmt) {
+			if call.WithClause != nil && isPositionAfter(pos, call.WithClause.With) {
+				help = withSignatureHelp(block.Scope, call)
+				return
+			}
+			help = callSignatureHelp(block, call.Name, call.Args, pos)
+		},
+		func(block *parser.BlockStmt, call *parser.CallExpr) {
+			help = callSignatureHelp(block, call.Name, call.Args(), pos)
+		},
+	)
+
+	return help, nil
+}
+
+// callSignatureHelp returns the signature of the callee with the argument at
+// the position as the active parameter.
+func callSignatureHelp(block *parser.BlockStmt, ie *parser.IdentExpr, args []*parser.Expr, pos lsp.Position) *lsp.SignatureHelp {
+	if block.Scope == nil || ie == nil || ie.Reference != nil {
+		return nil
+	}
+
+	obj := block.Scope.Lookup(ie.Ident.Text)
+	if obj == nil {
+		return nil
+	}
+
+	var fun *parser.FuncDecl
+	switch n := obj.Node.(type) {
+	case *parser.FuncDecl:
+		fun = n
+	case *parser.BuiltinDecl:
+		fun = builtinFuncDecl(n, block.Kind(), ie.Ident.Text)
+	}
+	if fun == nil {
+		return nil
+	}
+
+	sig := newSignatureInformation(fun)
+
+	active := 0
+	for _, arg := range args {
+		if isPositionAfter(pos, arg) {
+			active++
+		}
+	}
+
+	// Variadic parameters absorb the remaining arguments.
+	if n := len(sig.Parameters); n > 0 && active >= n {
+		fields := fun.Params.Fields()
+		if last := fields[len(fields)-1]; last.Modifier != nil && last.Modifier.Variadic != nil {
+			active = n - 1
+		}
+	}
+
+	return &lsp.SignatureHelp{
+		Signatures:      []lsp.SignatureInformation{sig},
+		ActiveParameter: active,
+	}
+}
+
+// withSignatureHelp returns the signatures of the option functions that are
+// valid in the with clause of a call.
+func withSignatureHelp(scope *parser.Scope, call *parser.CallStmt) *lsp.SignatureHelp {
+	if scope == nil || call.Name == nil || call.Name.Reference != nil {
+		return nil
+	}
+
+	kind := parser.Kind(fmt.Sprintf("%s::%s", parser.Option, call.Name.Ident))
+
+	var funs []*parser.FuncDecl
+	if lookup, ok := builtin.Lookup.ByKind[kind]; ok {
+		var names []string
+		for name := range lookup.Func {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			obj := scope.Lookup(name)
+			if obj == nil {
+				continue
+			}
+			if decl, ok := obj.Node.(*parser.BuiltinDecl); ok {
+				if fun := builtinFuncDecl(decl, kind, name); fun != nil {
+					funs = append(funs, fun)
+				}
+			}
+		}
+	}
+
+	for _, obj := range scope.Defined() {
+		if fun, ok := obj.Node.(*parser.FuncDecl); ok && fun.Kind() == kind {
+			funs = append(funs, fun)
+		}
+	}
+
+	if len(funs) == 0 {
+		return nil
+	}
+
+	help := &lsp.SignatureHelp{}
+	for _, fun := range funs {
+		help.Signatures = append(help.Signatures, newSignatureInformation(fun))
+	}
+	return help
+}
+
+// builtinFuncDecl returns the declaration of a builtin for the kind of the
+// block it is called in, or its only declaration if it has just one kind.
+func builtinFuncDecl(decl *parser.BuiltinDecl, kind parser.Kind, name string) *parser.FuncDecl {
+	if lookup, ok := builtin.Lookup.ByKind[kind]; ok {
+		if _, ok := lookup.Func[name]; ok {
+			return decl.FuncDeclByKind[kind]
+		}
+	}
+
+	if len(decl.FuncDeclByKind) == 1 {
+		for _, fun := range decl.FuncDeclByKind {
+			return fun
+		}
+	}
+	return nil
+}
+
+func newSignatureInformation(fun *parser.FuncDecl) lsp.SignatureInformation {
+	var (
+		sig       lsp.SignatureInformation
+		paramDocs = make(map[string]string)
+	)
+
+	if fun.Doc != nil {
+		var commentBlock []string
+		for _, comment := range fun.Doc.List {
+			text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "#"))
+			commentBlock = append(commentBlock, fmt.Sprintf("%s\n", text))
+		}
+
+		group, err := doxygen.Parse(strings.NewReader(strings.Join(commentBlock, "")))
+		if err == nil {
+			sig.Documentation = strings.TrimSpace(group.Doc)
+			for _, param := range group.Params {
+				paramDocs[param.Name] = param.Description
+			}
+		}
+	}
+
+	var labels []string
+	if fun.Params != nil {
+		for _, field := range fun.Params.Fields() {
+			label := field.String()
+			labels = append(labels, label)
+			sig.Parameters = append(sig.Parameters, lsp.ParameterInformation{
+				Label:         label,
+				Documentation: paramDocs[field.Name.Text],
+			})
+		}
+	}
+
+	sig.Label = fmt.Sprintf("%s %s(%s)", fun.Type, fun.Name, strings.Join(labels, ", "))
+	return sig
+}
+
+// isPositionWithinCall returns true if the position is within the node, or
+// only separated from its end by whitespace on the same line, where the next
+// argument of a call would be typed.
+func isPositionWithinCall(lines []string, pos lsp.Position, node parser.Node) bool {
+	if isPositionWithinNode(pos, node) {
+		return true
+	}
+
+	end := node.End()
+	if pos.Line != end.Line-1 || pos.Line >= len(lines) || pos.Character < end.Column-1 {
+		return false
+	}
+
+	line := lines[pos.Line]
+	if pos.Character > len(line) || end.Column-1 > len(line) {
+		return false
+	}
+	return strings.TrimSpace(line[end.Column-1:pos.Character]) == ""
+}
+
+// isPositionAfter returns true if the position is after the end of the node.
+func isPositionAfter(pos lsp.Position, node parser.Node) bool {
+	end := node.End()
+	return pos.Line > end.Line-1 || (pos.Line == end.Line-1 && pos.Character > end.Column-1)
+}
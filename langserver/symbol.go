@@ -0,0 +1,283 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/openllb/hlb/parser"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func (ls *LangServer) textDocumentDocumentSymbolHandler(ctx context.Context, params lsp.DocumentSymbolParams) ([]lsp.SymbolInformation, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document symbol %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if td.Module == nil || td.Module.Scope == nil {
+		return nil, nil
+	}
+
+	return moduleSymbols(td.Module, uri), nil
+}
+
+func (ls *LangServer) workspaceSymbolHandler(ctx context.Context, params lsp.WorkspaceSymbolParams) ([]lsp.SymbolInformation, error) {
+	log.Printf("workspace symbol %q", params.Query)
+
+	query := strings.ToLower(params.Query)
+
+	var symbols []lsp.SymbolInformation
+	for _, td := range ls.workspaceTextDocuments(ctx) {
+		if td.Module == nil || td.Module.Scope == nil {
+			continue
+		}
+
+		for _, sym := range moduleSymbols(td.Module, td.Identifier.URI) {
+			// Only top-level symbols are searchable in the workspace.
+			if sym.ContainerName != "" {
+				continue
+			}
+
+			if strings.Contains(strings.ToLower(sym.Name), query) {
+				symbols = append(symbols, sym)
+			}
+		}
+	}
+
+	if params.Limit > 0 && len(symbols) > params.Limit {
+		symbols = symbols[:params.Limit]
+	}
+
+	return symbols, nil
+}
+
+func (ls *LangServer) textDocumentReferencesHandler(ctx context.Context, params lsp.ReferenceParams) ([]lsp.Location, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document references %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if td.Module == nil || td.Module.Scope == nil {
+		return nil, nil
+	}
+
+	_, obj := objectAtPosition(td.Module, params.Position)
+	if obj == nil {
+		return nil, nil
+	}
+
+	var locs []lsp.Location
+	if params.Context.IncludeDeclaration {
+		locs = append(locs, *newLocationFromNode(uri, obj.Ident))
+	}
+
+	for _, ref := range references(td.Module, obj) {
+		locs = append(locs, *newLocationFromNode(uri, ref.Ident))
+	}
+
+	return locs, nil
+}
+
+// moduleSymbols returns the symbols declared in a module. Function parameters
+// are returned with the function as their container.
+func moduleSymbols(mod *parser.Module, uri lsp.DocumentURI) []lsp.SymbolInformation {
+	var symbols []lsp.SymbolInformation
+	for _, obj := range mod.Scope.Objects {
+		sym := lsp.SymbolInformation{
+			Name:     obj.Ident.Text,
+			Location: *newLocationFromNode(uri, obj.Ident),
+		}
+
+		switch n := obj.Node.(type) {
+		case *parser.ImportDecl:
+			sym.Kind = lsp.SKModule
+		case *parser.FuncDecl:
+			sym.Kind = lsp.SKFunction
+			if n.Params != nil {
+				for _, param := range n.Params.Fields() {
+					symbols = append(symbols, lsp.SymbolInformation{
+						Name:          param.Name.Text,
+						Kind:          lsp.SKVariable,
+						Location:      *newLocationFromNode(uri, param.Name),
+						ContainerName: n.Name.Text,
+					})
+				}
+			}
+		case *parser.BindClause:
+			sym.Kind = lsp.SKFunction
+		default:
+			continue
+		}
+
+		symbols = append(symbols, sym)
+	}
+
+	sort.SliceStable(symbols, func(i, j int) bool {
+		a, b := symbols[i].Location.Range.Start, symbols[j].Location.Range.Start
+		if a.Line == b.Line {
+			return a.Character < b.Character
+		}
+		return a.Line < b.Line
+	})
+
+	return symbols
+}
+
+// objectAtPosition returns the object declared or referenced by the
+// identifier at the position, and the scope it was looked up from.
+func objectAtPosition(mod *parser.Module, pos lsp.Position) (*parser.Scope, *parser.Object) {
+	var (
+		scope *parser.Scope
+		obj   *parser.Object
+	)
+
+	lookup := func(s *parser.Scope, ident *parser.Ident) {
+		if s != nil && ident != nil && isPositionWithinNode(pos, ident) {
+			scope, obj = s, s.Lookup(ident.Text)
+		}
+	}
+
+	parser.Match(mod,
+		parser.MatchOpts{
+			Filter: func(node parser.Node) bool {
+				return isPositionWithinNode(pos, node)
+			},
+		},
+		func(id *parser.ImportDecl) {
+			lookup(mod.Scope, id.Name)
+		},
+		func(ed *parser.ExportDecl) {
+			lookup(mod.Scope, ed.Name)
+		},
+		func(fun *parser.FuncDecl) {
+			lookup(mod.Scope, fun.Name)
+		},
+		func(fun *parser.FuncDecl, field *parser.Field) {
+			lookup(fun.Scope, field.Name)
+		},
+		func(fs *parser.ForStmt) {
+			if fs.Body != nil {
+				lookup(fs.Body.Scope, fs.Name)
+			}
+		},
+		func(block *parser.BlockStmt, bc *parser.BindClause) {
+			lookup(block.Scope, bc.Ident)
+			if bc.Binds != nil {
+				for _, b := range bc.Binds.Binds() {
+					lookup(block.Scope, b.Target)
+				}
+			}
+		},
+		func(block *parser.BlockStmt, ie *parser.IdentExpr) {
+			lookup(block.Scope, ie.Ident)
+		},
+	)
+
+	// Builtins are not declared in any module.
+	if obj != nil {
+		if _, ok := obj.Node.(*parser.BuiltinDecl); ok {
+			return nil, nil
+		}
+	}
+	return scope, obj
+}
+
+// reference is an identifier referring to an object, and the scope it is
+// resolved from.
+type reference struct {
+	Scope *parser.Scope
+	Ident *parser.Ident
+}
+
+// references returns the identifiers in the module that refer to the object,
+// excluding its declaration.
+func references(mod *parser.Module, obj *parser.Object) []reference {
+	var refs []reference
+	parser.Match(mod, parser.MatchOpts{},
+		func(ed *parser.ExportDecl) {
+			if ed.Name != nil && mod.Scope.Lookup(ed.Name.Text) == obj {
+				refs = append(refs, reference{mod.Scope, ed.Name})
+			}
+		},
+		func(block *parser.BlockStmt, ie *parser.IdentExpr) {
+			if block.Scope != nil && ie.Ident != nil && block.Scope.Lookup(ie.Ident.Text) == obj {
+				refs = append(refs, reference{block.Scope, ie.Ident})
+			}
+		},
+	)
+	return refs
+}
+
+func (ls *LangServer) textDocument(uri lsp.DocumentURI) (TextDocument, error) {
+	ls.tmu.RLock()
+	defer ls.tmu.RUnlock()
+
+	td, ok := ls.tds[uri]
+	if !ok {
+		return td, fmt.Errorf("unknown uri %q", uri)
+	}
+	return td, nil
+}
+
+// workspaceTextDocuments returns the open text documents and the HLB files
+// in the workspace root. Vendored modules are skipped.
+func (ls *LangServer) workspaceTextDocuments(ctx context.Context) []TextDocument {
+	ls.tmu.RLock()
+	var tds []TextDocument
+	for _, td := range ls.tds {
+		tds = append(tds, td)
+	}
+	ls.tmu.RUnlock()
+
+	if ls.rootURI == "" {
+		return tds
+	}
+
+	root := strings.TrimPrefix(string(ls.rootURI), "file://")
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(path) != ".hlb" {
+			return nil
+		}
+
+		uri := lsp.DocumentURI(fmt.Sprintf("file://%s", path))
+		if _, err := ls.textDocument(uri); err == nil {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		tds = append(tds, NewTextDocument(ctx, uri, string(data)))
+		return nil
+	})
+	if err != nil {
+		log.Printf("failed to walk workspace: %s", err)
+	}
+
+	return tds
+}
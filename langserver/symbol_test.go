@@ -0,0 +1,147 @@
+package langserver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentSymbol(t *testing.T) {
+	t.Parallel()
+
+	ls := openDocument(t, dedent.Dedent(`
+	fs default() {
+		build "x"
+	}
+
+	fs build(string name) {
+		image "alpine"
+		run "make" with option {
+			mount scratch "/out" as out
+		}
+	}
+	`))
+
+	symbols, err := ls.textDocumentDocumentSymbolHandler(context.Background(), lsp.DocumentSymbolParams{
+		TextDocument: testDocument(),
+	})
+	require.NoError(t, err)
+
+	var names []string
+	for _, sym := range symbols {
+		names = append(names, sym.Name+"/"+sym.ContainerName)
+	}
+	require.Equal(t, []string{"default/", "build/", "name/build", "out/"}, names)
+}
+
+func TestReferences(t *testing.T) {
+	t.Parallel()
+
+	ls := openDocument(t, dedent.Dedent(`
+	export build
+
+	fs default() {
+		build "x"
+		for string platform in ["linux", "darwin"] {
+			build platform
+			run platform
+		}
+	}
+
+	fs build(string name) {
+		mkdir name 0o755
+	}
+	`))
+
+	references := func(pos lsp.Position, includeDecl bool) []lsp.Position {
+		locs, err := ls.textDocumentReferencesHandler(context.Background(), lsp.ReferenceParams{
+			TextDocumentPositionParams: lsp.TextDocumentPositionParams{
+				TextDocument: testDocument(),
+				Position:     pos,
+			},
+			Context: lsp.ReferenceContext{IncludeDeclaration: includeDecl},
+		})
+		require.NoError(t, err)
+
+		var starts []lsp.Position
+		for _, loc := range locs {
+			require.Equal(t, testURI, loc.URI)
+			starts = append(starts, loc.Range.Start)
+		}
+		return starts
+	}
+
+	// References to a function from its declaration, including its export.
+	build := lsp.Position{Line: 11, Character: 3}
+	require.Equal(t, []lsp.Position{
+		{Line: 1, Character: 7},
+		{Line: 4, Character: 1},
+		{Line: 6, Character: 2},
+	}, references(build, false))
+	require.Equal(t, []lsp.Position{
+		{Line: 11, Character: 3},
+		{Line: 1, Character: 7},
+		{Line: 4, Character: 1},
+		{Line: 6, Character: 2},
+	}, references(build, true))
+
+	// References to a for-loop variable from one of its uses.
+	require.Equal(t, []lsp.Position{
+		{Line: 5, Character: 12},
+		{Line: 6, Character: 8},
+		{Line: 7, Character: 6},
+	}, references(lsp.Position{Line: 7, Character: 6}, true))
+
+	// A builtin has no references in the module.
+	require.Empty(t, references(lsp.Position{Line: 12, Character: 1}, true))
+}
+
+func TestWorkspaceSymbol(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "langserver")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"build.hlb":                  "fs buildAll() { scratch; }\n",
+		"sub/lib.hlb":                "fs buildLib(string name) { scratch; }\n",
+		".hlb/modules/dep/build.hlb": "fs buildDep() { scratch; }\n",
+		"README.md":                  "fs buildDoc()\n",
+	}
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		require.NoError(t, err)
+		err = ioutil.WriteFile(path, []byte(text), 0644)
+		require.NoError(t, err)
+	}
+
+	ls := NewServer()
+	_, err = ls.initializeHandler(context.Background(), lsp.InitializeParams{
+		RootURI: lsp.DocumentURI("file://" + dir),
+	})
+	require.NoError(t, err)
+
+	symbols, err := ls.workspaceSymbolHandler(context.Background(), lsp.WorkspaceSymbolParams{
+		Query: "BUILD",
+	})
+	require.NoError(t, err)
+
+	// Only top-level symbols are found, and dot-directories like the vendored
+	// modules are skipped.
+	locations := make(map[string]lsp.DocumentURI)
+	for _, sym := range symbols {
+		locations[sym.Name] = sym.Location.URI
+	}
+	require.Equal(t, map[string]lsp.DocumentURI{
+		"buildAll": lsp.DocumentURI("file://" + filepath.Join(dir, "build.hlb")),
+		"buildLib": lsp.DocumentURI("file://" + filepath.Join(dir, "sub/lib.hlb")),
+	}, locations)
+}
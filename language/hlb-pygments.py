@@ -43,7 +43,7 @@ class HlbLexer(RegexLexer):
             (u'(as)((?:[\\t ]+))(\\b[a-zA-Z_][a-zA-Z0-9_]*\\b)', bygroups(Keyword, Punctuation, Name.Variable)),
             (u'(binds)((?:[\\t ]+))(\\()', bygroups(Keyword, Punctuation, Punctuation), 'binding'),
             (u'(\\bstring\\b|\\bint\\b|\\bbool\\b|\\bfs\\b|\\bgroup\\b|\\boption(?!::)\\b|\\boption::(?:copy|frontend|git|http|image|local|mkdir|mkfile|mount|rm|run|secret|ssh|template)\\b)((?:[\\t ]+))(\\{)', bygroups(Keyword.Type, Punctuation, Punctuation), 'block'),
-            (u'(\\b((?!(allowEmptyWildcard|allowNotFound|allowWildcard|cache|checksum|chmod|chown|contentsOnly|copy|createDestPath|createParents|createdTime|dir|dockerLoad|dockerPush|download|downloadDockerTarball|downloadOCITarball|downloadTarball|env|excludePatterns|filename|followPaths|followSymlinks|format|forward|frontend|gid|git|host|http|id|ignoreCache|image|includePatterns|input|insecure|keepGitDir|local|localEnv|localPaths|locked|mkdir|mkfile|mode|mount|network|node|opt|parallel|private|readonly|readonlyRootfs|resolve|rm|run|sandbox|scratch|secret|security|shared|sourcePath|ssh|stringField|target|template|tmpfs|uid|unix|unpack|unset|user|value)\\b)[a-zA-Z_][a-zA-Z0-9]*\\b))', bygroups(Name.Variable)),
+            (u'(\\b((?!(allowEmptyWildcard|allowNotFound|allowWildcard|cache|cacheFrom|cacheTo|checksum|chmod|chown|contentsOnly|copy|createDestPath|createParents|createdTime|dir|dockerLoad|dockerPush|download|downloadDockerTarball|downloadOCITarball|downloadTarball|env|equals|excludePatterns|filename|followPaths|followSymlinks|format|forward|frontend|gid|git|host|http|id|ignoreCache|image|includePatterns|input|insecure|isSet|keepGitDir|local|localEnv|localPaths|locked|mkdir|mkfile|mode|mount|network|node|not|oci|opt|parallel|private|readonly|readonlyRootfs|resolve|rm|run|sandbox|scratch|secret|security|shared|sourcePath|ssh|stringField|target|template|tmpfs|uid|unix|unpack|unset|user|value)\\b)[a-zA-Z_][a-zA-Z0-9]*\\b))', bygroups(Name.Variable)),
             (u'(\\b[a-zA-Z_][a-zA-Z0-9_]*\\b)', bygroups(Name.Builtin)),
             ('(\n|\r|\r\n)', String),
             ('.', String),
@@ -0,0 +1,73 @@
+package linter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/openllb/hlb/diagnostic"
+)
+
+// ConfigPath is the path of the lint config for the module in the current
+// working directory.
+var ConfigPath = "hlb.lint"
+
+// Config enables, disables and overrides the severity of lint rules by ID.
+type Config struct {
+	Enable   []string          `json:"enable,omitempty"`
+	Disable  []string          `json:"disable,omitempty"`
+	Severity map[string]string `json:"severity,omitempty"`
+}
+
+// ReadConfig reads a lint config in JSON. The error satisfies os.IsNotExist if
+// there is no config.
+func ReadConfig(path string) (*Config, error) {
+	dt, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	err = json.Unmarshal(dt, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate returns an error if the config refers to a rule that isn't
+// registered, or to an unknown severity.
+func (cfg *Config) Validate() error {
+	for _, ids := range [][]string{cfg.Enable, cfg.Disable} {
+		for _, id := range ids {
+			if LookupRule(id) == nil {
+				return fmt.Errorf("unknown lint rule %q", id)
+			}
+		}
+	}
+	for id, severity := range cfg.Severity {
+		if LookupRule(id) == nil {
+			return fmt.Errorf("unknown lint rule %q", id)
+		}
+		if _, err := parseSeverity(severity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseSeverity(severity string) (diagnostic.Severity, error) {
+	switch severity {
+	case diagnostic.SeverityError.String():
+		return diagnostic.SeverityError, nil
+	case diagnostic.SeverityWarning.String():
+		return diagnostic.SeverityWarning, nil
+	default:
+		return 0, fmt.Errorf("unknown severity %q, expected error or warning", severity)
+	}
+}
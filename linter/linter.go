@@ -2,17 +2,21 @@ package linter
 
 import (
 	"context"
+	"errors"
 	"os"
+	"strings"
+	"unicode"
 
 	"github.com/openllb/hlb/checker"
 	"github.com/openllb/hlb/codegen"
 	"github.com/openllb/hlb/diagnostic"
-	"github.com/openllb/hlb/errdefs"
 	"github.com/openllb/hlb/parser"
 )
 
 type Linter struct {
 	Recursive bool
+	enabled   map[string]bool
+	severity  map[string]diagnostic.Severity
 	errs      []error
 }
 
@@ -24,6 +28,30 @@ func WithRecursive() LintOption {
 	}
 }
 
+// WithConfig enables, disables and overrides the severity of rules. When
+// given multiple times, later configs take precedence.
+func WithConfig(cfg *Config) LintOption {
+	return func(l *Linter) {
+		if l.enabled == nil {
+			l.enabled = make(map[string]bool)
+		}
+		if l.severity == nil {
+			l.severity = make(map[string]diagnostic.Severity)
+		}
+		for _, id := range cfg.Enable {
+			l.enabled[id] = true
+		}
+		for _, id := range cfg.Disable {
+			l.enabled[id] = false
+		}
+		for id, severity := range cfg.Severity {
+			if s, err := parseSeverity(severity); err == nil {
+				l.severity[id] = s
+			}
+		}
+	}
+}
+
 func Lint(ctx context.Context, mod *parser.Module, opts ...LintOption) error {
 	l := Linter{}
 	for _, opt := range opts {
@@ -36,44 +64,75 @@ func Lint(ctx context.Context, mod *parser.Module, opts ...LintOption) error {
 	return nil
 }
 
+// Lint runs every registered rule on a module. Disabled rules still run so
+// that deprecated syntax is fixed before compiling, but only the findings of
+// enabled rules that aren't suppressed by an ignore comment are reported.
 func (l *Linter) Lint(ctx context.Context, mod *parser.Module) {
-	parser.Match(mod, parser.MatchOpts{},
-		func(id *parser.ImportDecl) {
-			if id.DeprecatedPath != nil {
-				l.errs = append(l.errs, errdefs.WithDeprecated(
-					mod, id.DeprecatedPath,
-					`import path without keyword "from" is deprecated`,
-				))
-				id.From = &parser.From{Text: "from"}
-				id.Expr = &parser.Expr{
-					BasicLit: &parser.BasicLit{
-						Str: id.DeprecatedPath,
-					},
-				}
-			}
-			if l.Recursive {
+	ignores := ignoreComments(mod)
+	for _, rule := range Rules() {
+		rule := rule
+		rule.Lint(ctx, mod, func(err error) {
+			l.report(rule, ignores, err)
+		})
+	}
+
+	if l.Recursive {
+		parser.Match(mod, parser.MatchOpts{},
+			func(id *parser.ImportDecl) {
 				l.LintRecursive(ctx, mod, id.Expr)
+			},
+		)
+	}
+}
+
+func (l *Linter) report(rule *Rule, ignores map[int][]string, err error) {
+	enabled, ok := l.enabled[rule.ID]
+	if !ok {
+		enabled = !rule.OptIn
+	}
+	if !enabled {
+		return
+	}
+
+	var se *diagnostic.SpanError
+	if errors.As(err, &se) {
+		for _, id := range ignores[se.Pos.Line] {
+			if id == rule.ID {
+				return
 			}
-		},
-		func(t *parser.Type) {
-			if string(t.Kind) == "group" {
-				l.errs = append(l.errs, errdefs.WithDeprecated(
-					mod, t,
-					"type `group` is deprecated, use `pipeline` instead",
-				))
-				t.Kind = parser.Pipeline
+		}
+
+		se.Rule = rule.ID
+		se.Severity = rule.Severity
+		if severity, ok := l.severity[rule.ID]; ok {
+			se.Severity = severity
+		}
+	}
+
+	l.errs = append(l.errs, err)
+}
+
+// ignoreComments returns the rule IDs suppressed on each line of a module. An
+// ignore comment like `# hlb:ignore RULE-ID` suppresses findings on its own
+// line and on the line after it.
+func ignoreComments(mod *parser.Module) map[int][]string {
+	ignores := make(map[int][]string)
+	parser.Match(mod, parser.MatchOpts{},
+		func(c *parser.Comment) {
+			text := strings.TrimSpace(strings.TrimPrefix(c.Text, "#"))
+			if !strings.HasPrefix(text, "hlb:ignore") {
+				return
 			}
-		},
-		func(call *parser.CallStmt) {
-			if call.Name != nil && call.Name.Ident.Text == "parallel" {
-				l.errs = append(l.errs, errdefs.WithDeprecated(
-					mod, call.Name,
-					"function `parallel` is deprecated, use `stage` instead",
-				))
-				call.Name.Ident.Text = "stage"
+
+			ids := strings.FieldsFunc(strings.TrimPrefix(text, "hlb:ignore"), func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			})
+			for _, line := range []int{c.Pos.Line, c.Pos.Line + 1} {
+				ignores[line] = append(ignores[line], ids...)
 			}
 		},
 	)
+	return ignores
 }
 
 func (l *Linter) LintRecursive(ctx context.Context, mod *parser.Module, expr *parser.Expr) {
@@ -2,6 +2,8 @@ package linter
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -17,6 +19,7 @@ import (
 type testCase struct {
 	name  string
 	input string
+	opts  []LintOption
 	fn    func(*parser.Module) error
 }
 
@@ -28,11 +31,177 @@ func TestLinter_Lint(t *testing.T) {
 		`
 		import foo "./foo.hlb"
 		`,
+		nil,
 		func(mod *parser.Module) error {
-			return errdefs.WithDeprecated(
+			return withRule(errdefs.WithDeprecated(
 				mod, parser.Find(mod, `"./foo.hlb"`).(*parser.StringLit),
 				`import path without keyword "from" is deprecated`,
-			)
+			), "deprecated-import-path", diagnostic.SeverityWarning)
+		},
+	}, {
+		"ignore comments",
+		`
+		# hlb:ignore deprecated-group
+		group foo() {
+			parallel bar # hlb:ignore deprecated-parallel
+		}
+
+		group bar() {
+			parallel bar # hlb:ignore deprecated-group
+		}
+		`,
+		nil,
+		func(mod *parser.Module) error {
+			// Deprecations are fixed in place, so find nodes by their fixed syntax.
+			return &diagnostic.Error{Diagnostics: []error{
+				withRule(errdefs.WithDeprecated(
+					mod, parser.Find(mod, "pipeline bar").(*parser.FuncDecl).Type,
+					"type `group` is deprecated, use `pipeline` instead",
+				), "deprecated-group", diagnostic.SeverityWarning),
+				withRule(errdefs.WithDeprecated(
+					mod, parser.Find(mod, "stage", parser.WithSkip(1)).(*parser.IdentExpr),
+					"function `parallel` is deprecated, use `stage` instead",
+				), "deprecated-parallel", diagnostic.SeverityWarning),
+			}}
+		},
+	}, {
+		"config",
+		`
+		group foo() {
+			parallel bar
+		}
+		`,
+		[]LintOption{
+			WithConfig(&Config{
+				Disable:  []string{"deprecated-group"},
+				Severity: map[string]string{"deprecated-parallel": "error"},
+			}),
+		},
+		func(mod *parser.Module) error {
+			return withRule(errdefs.WithDeprecated(
+				mod, parser.Find(mod, "stage").(*parser.IdentExpr),
+				"function `parallel` is deprecated, use `stage` instead",
+			), "deprecated-parallel", diagnostic.SeverityError)
+		},
+	}, {
+		"config enable after disable",
+		`
+		group foo() {}
+		`,
+		[]LintOption{
+			WithConfig(&Config{Disable: []string{"deprecated-group"}}),
+			WithConfig(&Config{Enable: []string{"deprecated-group"}}),
+		},
+		func(mod *parser.Module) error {
+			return withRule(errdefs.WithDeprecated(
+				mod, parser.Find(mod, "pipeline").(*parser.Type),
+				"type `group` is deprecated, use `pipeline` instead",
+			), "deprecated-group", diagnostic.SeverityWarning)
+		},
+	}, {
+		"unpinned image",
+		`
+		fs default() {
+			image "alpine"
+			image "alpine@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		}
+		`,
+		[]LintOption{WithConfig(&Config{Enable: []string{"unpinned-image"}})},
+		func(mod *parser.Module) error {
+			return withRule(errdefs.WithUnpinnedImage(
+				parser.Find(mod, `"alpine"`).(*parser.Expr), "alpine",
+			), "unpinned-image", diagnostic.SeverityWarning)
+		},
+	}, {
+		"unsafe run options in exported target",
+		`
+		export build
+		fs build() {
+			image "alpine@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+			run "make" with option {
+				ignoreCache
+				security "insecure"
+			}
+		}
+		fs test() {
+			build
+			run "make test" with ignoreCache
+		}
+		`,
+		nil,
+		func(mod *parser.Module) error {
+			name := parser.Find(mod, "build", parser.WithSkip(1)).(*parser.Ident)
+			insecure := parser.Find(mod, `"insecure"`).(*parser.Expr)
+			return &diagnostic.Error{Diagnostics: []error{
+				withRule(errdefs.WithUnsafeRunOption(
+					parser.Find(mod, "ignoreCache").(*parser.IdentExpr), "ignoreCache", name,
+				), "unsafe-run-option", diagnostic.SeverityWarning),
+				withRule(errdefs.WithUnsafeRunOption(
+					insecure, `security "insecure"`, name,
+				), "unsafe-run-option", diagnostic.SeverityWarning),
+			}}
+		},
+	}, {
+		"local in exported target",
+		`
+		export build
+		fs build() {
+			scratch
+			env "USER" localEnv("USER")
+		}
+		`,
+		[]LintOption{WithConfig(&Config{Enable: []string{"local-in-exported-target"}})},
+		func(mod *parser.Module) error {
+			return withRule(errdefs.WithLocalInExportedTarget(
+				parser.Find(mod, "localEnv").(*parser.IdentExpr),
+				parser.Find(mod, "build", parser.WithSkip(1)).(*parser.Ident),
+			), "local-in-exported-target", diagnostic.SeverityWarning)
+		},
+	}, {
+		"unused function and param",
+		`
+		export build
+		fs build() {
+			scratch
+			stage foo("x")
+		}
+		fs foo(string used, string unused) {
+			run used
+		}
+		fs bar() {}
+		fs default() {}
+		`,
+		[]LintOption{WithConfig(&Config{Enable: []string{"unused-function"}})},
+		func(mod *parser.Module) error {
+			return &diagnostic.Error{Diagnostics: []error{
+				withRule(errdefs.WithUnusedFunction(
+					parser.Find(mod, "bar").(*parser.Ident),
+				), "unused-function", diagnostic.SeverityWarning),
+				withRule(errdefs.WithUnusedParam(
+					parser.Find(mod, "unused").(*parser.Ident),
+					parser.Find(mod, "foo", parser.WithSkip(1)).(*parser.Ident),
+				), "unused-param", diagnostic.SeverityWarning),
+			}}
+		},
+	}, {
+		"copy from local context",
+		`
+		fs default() {
+			scratch
+			copy local(".") "/" "/src"
+			copy fs {
+				local "." with option {
+					excludePatterns ".git"
+				}
+			} "/" "/src"
+			copy local("./src") "/" "/src"
+		}
+		`,
+		nil,
+		func(mod *parser.Module) error {
+			return withRule(errdefs.WithCopyLocalContext(
+				parser.Find(mod, `"."`),
+			), "copy-local-context", diagnostic.SeverityWarning)
 		},
 	}} {
 		tc := tc
@@ -46,7 +215,7 @@ func TestLinter_Lint(t *testing.T) {
 			err = checker.SemanticPass(mod)
 			require.NoError(t, err)
 
-			err = Lint(ctx, mod)
+			err = Lint(ctx, mod, tc.opts...)
 
 			var expected error
 			if tc.fn != nil {
@@ -57,6 +226,39 @@ func TestLinter_Lint(t *testing.T) {
 	}
 }
 
+func TestLinter_Fixes(t *testing.T) {
+	t.Parallel()
+
+	ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+	mod, err := parser.Parse(ctx, strings.NewReader(dedent.Dedent(`
+	import foo "./foo.hlb"
+
+	group default() {
+		parallel fs { scratch; }
+	}
+	`)))
+	require.NoError(t, err)
+
+	err = checker.SemanticPass(mod)
+	require.NoError(t, err)
+
+	var de *diagnostic.Error
+	require.True(t, errors.As(Lint(ctx, mod), &de))
+
+	// Each deprecation suggests replacing its node with the fixed syntax.
+	var fixes []string
+	for _, err := range de.Diagnostics {
+		var fix *errdefs.ErrFix
+		require.True(t, errors.As(err, &fix))
+		fixes = append(fixes, fmt.Sprintf("%d:%d %s", fix.Node.Position().Line, fix.Node.Position().Column, fix.Fix))
+	}
+	require.Equal(t, []string{
+		`4:1 pipeline`,
+		`2:1 import foo from "./foo.hlb"`,
+		`5:2 stage`,
+	}, fixes)
+}
+
 func validateError(t *testing.T, ctx context.Context, expected, actual error, name string) {
 	switch {
 	case expected == nil:
@@ -77,3 +279,12 @@ func validateError(t *testing.T, ctx context.Context, expected, actual error, na
 		}
 	}
 }
+
+func withRule(err error, rule string, severity diagnostic.Severity) error {
+	var se *diagnostic.SpanError
+	if errors.As(err, &se) {
+		se.Rule = rule
+		se.Severity = severity
+	}
+	return err
+}
@@ -0,0 +1,266 @@
+package linter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openllb/hlb/codegen"
+	"github.com/openllb/hlb/diagnostic"
+	"github.com/openllb/hlb/errdefs"
+	"github.com/openllb/hlb/parser"
+)
+
+func init() {
+	Register(&Rule{
+		ID:       "unpinned-image",
+		Severity: diagnostic.SeverityWarning,
+		Doc:      "Images without a digest may change between builds. A fix is suggested for images locked in hlb.lock.",
+		OptIn:    true,
+		Lint: func(ctx context.Context, mod *parser.Module, report ReportFunc) {
+			matchCalls(mod, func(c call) {
+				if !isBuiltin(mod, c.name, "image") || len(c.args) == 0 {
+					return
+				}
+
+				ref, ok := stringValue(c.args[0])
+				if !ok || strings.Contains(ref, "@") {
+					return
+				}
+
+				err := errdefs.WithUnpinnedImage(c.args[0], ref)
+				if dgst := codegen.LockedDigest(ctx, ref); dgst != "" {
+					err = errdefs.WithFix(err, c.args[0], parser.NewStringExpr(fmt.Sprintf("%s@%s", ref, dgst)))
+				}
+				report(err)
+			})
+		},
+	})
+
+	Register(&Rule{
+		ID:       "unsafe-run-option",
+		Severity: diagnostic.SeverityWarning,
+		Doc:      "Exported targets should not run with `ignoreCache` or `security \"insecure\"`, as they are imported by other modules.",
+		Lint: func(ctx context.Context, mod *parser.Module, report ReportFunc) {
+			for _, fun := range exportedFuncs(mod) {
+				fun := fun
+				matchCalls(fun.Body, func(c call) {
+					if !isBuiltin(mod, c.name, "run") || c.with == nil {
+						return
+					}
+
+					matchCalls(c.with, func(opt call) {
+						switch {
+						case isBuiltin(mod, opt.name, "ignoreCache"):
+							report(errdefs.WithUnsafeRunOption(opt.name, "ignoreCache", fun.Name))
+						case isBuiltin(mod, opt.name, "security") && len(opt.args) > 0:
+							if mode, ok := stringValue(opt.args[0]); ok && mode == "insecure" {
+								report(errdefs.WithFix(
+									errdefs.WithUnsafeRunOption(opt.args[0], `security "insecure"`, fun.Name),
+									opt.args[0], parser.NewStringExpr("sandbox"),
+								))
+							}
+						}
+					})
+				})
+			}
+		},
+	})
+
+	Register(&Rule{
+		ID:       "local-in-exported-target",
+		Severity: diagnostic.SeverityWarning,
+		Doc:      "Exported targets meant to be reproducible should not depend on the local system with `localEnv`, `localRun` or `localCwd`.",
+		OptIn:    true,
+		Lint: func(ctx context.Context, mod *parser.Module, report ReportFunc) {
+			for _, fun := range exportedFuncs(mod) {
+				fun := fun
+				matchCalls(fun.Body, func(c call) {
+					if isBuiltin(mod, c.name, "localEnv", "localRun", "localCwd") {
+						report(errdefs.WithLocalInExportedTarget(c.name, fun.Name))
+					}
+				})
+			}
+		},
+	})
+
+	Register(&Rule{
+		ID:       "unused-function",
+		Severity: diagnostic.SeverityWarning,
+		Doc:      "Functions that are neither exported nor called are dead code. Opt-in because functions of the module being run may be called as targets.",
+		OptIn:    true,
+		Lint: func(ctx context.Context, mod *parser.Module, report ReportFunc) {
+			used := usedIdents(mod)
+			exported := exportedSet(mod)
+			parser.Match(mod, parser.MatchOpts{},
+				func(fun *parser.FuncDecl) {
+					name := fun.Name.Text
+					if name == "default" || exported[name] || used[name] {
+						return
+					}
+					report(errdefs.WithUnusedFunction(fun.Name))
+				},
+			)
+		},
+	})
+
+	Register(&Rule{
+		ID:       "unused-param",
+		Severity: diagnostic.SeverityWarning,
+		Doc:      "Parameters of functions that aren't exported should be used by the function.",
+		Lint: func(ctx context.Context, mod *parser.Module, report ReportFunc) {
+			exported := exportedSet(mod)
+			parser.Match(mod, parser.MatchOpts{},
+				func(fun *parser.FuncDecl) {
+					if exported[fun.Name.Text] || fun.Params == nil || fun.Body == nil {
+						return
+					}
+
+					used := usedIdents(fun.Body)
+					for _, field := range fun.Params.Fields() {
+						if !used[field.Name.Text] {
+							report(errdefs.WithUnusedParam(field.Name, fun.Name))
+						}
+					}
+				},
+			)
+		},
+	})
+
+	Register(&Rule{
+		ID:       "copy-local-context",
+		Severity: diagnostic.SeverityWarning,
+		Doc:      "Copying from `local \".\"` without `includePatterns` or `excludePatterns` sends the whole directory, and invalidates the cache whenever any file changes.",
+		Lint: func(ctx context.Context, mod *parser.Module, report ReportFunc) {
+			matchCalls(mod, func(c call) {
+				if !isBuiltin(mod, c.name, "copy") || len(c.args) == 0 {
+					return
+				}
+
+				matchCalls(c.args[0], func(src call) {
+					if !isBuiltin(mod, src.name, "local") || len(src.args) == 0 {
+						return
+					}
+					if path, ok := stringValue(src.args[0]); !ok || (path != "." && path != "./") {
+						return
+					}
+					if src.with != nil && hasPatterns(mod, src.with) {
+						return
+					}
+					report(errdefs.WithCopyLocalContext(src.args[0]))
+				})
+			})
+		},
+	})
+}
+
+// call is either a call statement or a call expression.
+type call struct {
+	name *parser.IdentExpr
+	args []*parser.Expr
+	with *parser.WithClause
+}
+
+func matchCalls(root parser.Node, f func(call)) {
+	parser.Match(root, parser.MatchOpts{},
+		func(cs *parser.CallStmt) {
+			f(call{cs.Name, cs.Args, cs.WithClause})
+		},
+		func(ce *parser.CallExpr) {
+			f(call{ce.Name, ce.Args(), nil})
+		},
+	)
+}
+
+// isBuiltin returns true if the identifier refers to one of the named builtins
+// rather than a function defined in the module.
+func isBuiltin(mod *parser.Module, ie *parser.IdentExpr, names ...string) bool {
+	if ie == nil || ie.Reference != nil {
+		return false
+	}
+
+	for _, name := range names {
+		if ie.Ident.Text != name {
+			continue
+		}
+		if obj := mod.Scope.Lookup(name); obj != nil {
+			if _, ok := obj.Node.(*parser.FuncDecl); ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// stringValue returns the value of a string literal without interpolation.
+func stringValue(expr *parser.Expr) (string, bool) {
+	if expr == nil || expr.BasicLit == nil {
+		return "", false
+	}
+
+	switch {
+	case expr.BasicLit.Str != nil:
+		for _, f := range expr.BasicLit.Str.Fragments {
+			if f.Interpolated != nil {
+				return "", false
+			}
+		}
+		return expr.BasicLit.Str.Unquoted(), true
+	case expr.BasicLit.RawString != nil:
+		return expr.BasicLit.RawString.Text, true
+	}
+	return "", false
+}
+
+// hasPatterns returns true if a with clause may set include or exclude
+// patterns. Options that aren't literals are assumed to set them.
+func hasPatterns(mod *parser.Module, with *parser.WithClause) bool {
+	if with.Expr == nil || with.Expr.FuncLit == nil {
+		return true
+	}
+
+	found := false
+	matchCalls(with, func(opt call) {
+		if isBuiltin(mod, opt.name, "includePatterns", "excludePatterns") {
+			found = true
+		}
+	})
+	return found
+}
+
+func exportedSet(mod *parser.Module) map[string]bool {
+	exported := make(map[string]bool)
+	for _, name := range parser.ExportedNames(mod) {
+		exported[name] = true
+	}
+	return exported
+}
+
+func exportedFuncs(mod *parser.Module) []*parser.FuncDecl {
+	exported := exportedSet(mod)
+
+	var funs []*parser.FuncDecl
+	parser.Match(mod, parser.MatchOpts{},
+		func(fun *parser.FuncDecl) {
+			if exported[fun.Name.Text] && fun.Body != nil {
+				funs = append(funs, fun)
+			}
+		},
+	)
+	return funs
+}
+
+// usedIdents returns the identifiers referenced within a node, excluding
+// references to imported modules.
+func usedIdents(root parser.Node) map[string]bool {
+	used := make(map[string]bool)
+	parser.Match(root, parser.MatchOpts{},
+		func(ie *parser.IdentExpr) {
+			if ie.Reference == nil {
+				used[ie.Ident.Text] = true
+			}
+		},
+	)
+	return used
+}
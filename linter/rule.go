@@ -0,0 +1,74 @@
+package linter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/openllb/hlb/diagnostic"
+	"github.com/openllb/hlb/parser"
+)
+
+// Rule is a lint rule that reports findings in a module.
+type Rule struct {
+	// ID uniquely identifies the rule in configs and ignore comments.
+	ID string
+
+	// Severity is the default severity of the rule's findings.
+	Severity diagnostic.Severity
+
+	// Doc describes what the rule reports and why.
+	Doc string
+
+	// OptIn rules only report findings when they are enabled.
+	OptIn bool
+
+	// Lint reports the findings of the rule in a module. Rules may also fix
+	// their findings in place, which are written back by `hlb lint --fix`.
+	Lint func(ctx context.Context, mod *parser.Module, report ReportFunc)
+}
+
+// ReportFunc reports a finding of a rule. The error is expected to be a span
+// error, so that it can be suppressed by ignore comments.
+type ReportFunc func(err error)
+
+var (
+	rulesMu sync.RWMutex
+	rules   = make(map[string]*Rule)
+)
+
+// Register registers a lint rule, so that it runs whenever a module is linted.
+// It panics if a rule with the same ID has already been registered.
+func Register(rule *Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+
+	if _, ok := rules[rule.ID]; ok {
+		panic(fmt.Sprintf("lint rule %q is already registered", rule.ID))
+	}
+	rules[rule.ID] = rule
+}
+
+// Rules returns the registered lint rules sorted by ID.
+func Rules() []*Rule {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	var sorted []*Rule
+	for _, rule := range rules {
+		sorted = append(sorted, rule)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
+// LookupRule returns the registered lint rule with the ID, or nil if there is
+// none.
+func LookupRule(id string) *Rule {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	return rules[id]
+}
@@ -0,0 +1,90 @@
+package linter
+
+import (
+	"context"
+
+	"github.com/openllb/hlb/diagnostic"
+	"github.com/openllb/hlb/errdefs"
+	"github.com/openllb/hlb/parser"
+)
+
+func init() {
+	Register(&Rule{
+		ID:       "deprecated-import-path",
+		Severity: diagnostic.SeverityWarning,
+		Doc:      "Import paths without the keyword `from` are deprecated, use `import foo from \"./foo.hlb\"` instead.",
+		Lint: func(ctx context.Context, mod *parser.Module, report ReportFunc) {
+			parser.Match(mod, parser.MatchOpts{},
+				func(id *parser.ImportDecl) {
+					if id.DeprecatedPath == nil {
+						return
+					}
+					fixed := &parser.ImportDecl{
+						Mixin:  id.Mixin,
+						Import: id.Import,
+						Name:   id.Name,
+						From:   &parser.From{Text: "from"},
+						Expr: &parser.Expr{
+							BasicLit: &parser.BasicLit{
+								Str: id.DeprecatedPath,
+							},
+						},
+					}
+					report(errdefs.WithFix(errdefs.WithDeprecated(
+						mod, id.DeprecatedPath,
+						`import path without keyword "from" is deprecated`,
+					), id, fixed))
+
+					// Deprecations are also fixed in the module, which is
+					// written back by lint --fix.
+					id.From, id.Expr = fixed.From, fixed.Expr
+				},
+			)
+		},
+	})
+
+	Register(&Rule{
+		ID:       "deprecated-group",
+		Severity: diagnostic.SeverityWarning,
+		Doc:      "The type `group` is deprecated, use `pipeline` instead.",
+		Lint: func(ctx context.Context, mod *parser.Module, report ReportFunc) {
+			parser.Match(mod, parser.MatchOpts{},
+				func(t *parser.Type) {
+					if string(t.Kind) != "group" {
+						return
+					}
+					fixed := &parser.Type{Mixin: t.Mixin, Kind: parser.Pipeline}
+					report(errdefs.WithFix(errdefs.WithDeprecated(
+						mod, t,
+						"type `group` is deprecated, use `pipeline` instead",
+					), t, fixed))
+					t.Kind = fixed.Kind
+				},
+			)
+		},
+	})
+
+	Register(&Rule{
+		ID:       "deprecated-parallel",
+		Severity: diagnostic.SeverityWarning,
+		Doc:      "The function `parallel` is deprecated, use `stage` instead.",
+		Lint: func(ctx context.Context, mod *parser.Module, report ReportFunc) {
+			parser.Match(mod, parser.MatchOpts{},
+				func(call *parser.CallStmt) {
+					if call.Name == nil || call.Name.Ident.Text != "parallel" {
+						return
+					}
+					fixed := &parser.IdentExpr{
+						Mixin: call.Name.Mixin,
+						Ident: &parser.Ident{Mixin: call.Name.Ident.Mixin, Text: "stage"},
+					}
+					report(errdefs.WithFix(errdefs.WithDeprecated(
+						mod, call.Name,
+						"function `parallel` is deprecated, use `stage` instead",
+					), call.Name, fixed))
+					call.Name.Ident.Text = fixed.Ident.Text
+				},
+			)
+		},
+	})
+}
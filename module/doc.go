@@ -0,0 +1,350 @@
+package module
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/lithammer/dedent"
+	"github.com/openllb/doxygen-parser/doxygen"
+	"github.com/openllb/hlb/parser"
+)
+
+// Documentation contains the exported functions of a module.
+type Documentation struct {
+	Module string  `json:"module"`
+	Funcs  []*Func `json:"funcs"`
+}
+
+// Func documents an exported function, or an exported alias which inherits
+// the signature of the function it is declared in.
+type Func struct {
+	Doc      string   `json:"doc,omitempty"`
+	Type     string   `json:"type"`
+	Name     string   `json:"name"`
+	Params   []Field  `json:"params"`
+	Effects  []Field  `json:"effects,omitempty"`
+	Return   string   `json:"return,omitempty"`
+	Examples []string `json:"examples,omitempty"`
+
+	// AliasOf is the function an exported alias is declared in.
+	AliasOf string `json:"aliasOf,omitempty"`
+}
+
+type Field struct {
+	Doc      string `json:"doc,omitempty"`
+	Variadic bool   `json:"variadic,omitempty"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+}
+
+// Signature returns the declaration of the function without its body.
+func (f *Func) Signature() string {
+	signature := fmt.Sprintf("%s %s(%s)", f.Type, f.Name, joinFields(f.Params))
+	if len(f.Effects) > 0 {
+		signature = fmt.Sprintf("%s binds (%s)", signature, joinFields(f.Effects))
+	}
+	return signature
+}
+
+func joinFields(fields []Field) string {
+	var strs []string
+	for _, field := range fields {
+		str := fmt.Sprintf("%s %s", field.Type, field.Name)
+		if field.Variadic {
+			str = fmt.Sprintf("variadic %s", str)
+		}
+		strs = append(strs, str)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// GenerateDocumentation documents the exported functions of a checked module
+// from their doxygen comments. Besides the `@param` and `@return` commands,
+// the lines after an `@example` command are kept verbatim as an example.
+func GenerateDocumentation(mod *parser.Module) (*Documentation, error) {
+	doc := &Documentation{
+		Module: mod.Pos.Filename,
+		Funcs:  []*Func{},
+	}
+
+	for _, name := range parser.ExportedNames(mod) {
+		obj := mod.Scope.Lookup(name)
+		if obj == nil || !obj.Exported {
+			continue
+		}
+
+		switch n := obj.Node.(type) {
+		case *parser.FuncDecl:
+			fun, err := newFuncDoc(n)
+			if err != nil {
+				return nil, err
+			}
+			doc.Funcs = append(doc.Funcs, fun)
+		case *parser.BindClause:
+			b := n.TargetBinding(name)
+			if b == nil || b.Bind.Closure == nil {
+				continue
+			}
+
+			fun, err := newAliasDoc(b)
+			if err != nil {
+				return nil, err
+			}
+			doc.Funcs = append(doc.Funcs, fun)
+		}
+	}
+
+	sort.SliceStable(doc.Funcs, func(i, j int) bool {
+		return doc.Funcs[i].Name < doc.Funcs[j].Name
+	})
+	return doc, nil
+}
+
+func newFuncDoc(fun *parser.FuncDecl) (*Func, error) {
+	group, examples, err := parseDoc(fun.Doc)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Func{
+		Doc:      strings.TrimSpace(group.Doc),
+		Type:     fun.Type.String(),
+		Name:     fun.Name.Text,
+		Params:   []Field{},
+		Return:   group.Return.Description,
+		Examples: examples,
+	}
+
+	if fun.Params != nil {
+		doc.Params = newFieldDocs(group, fun.Params.Fields())
+	}
+
+	if fun.Effects != nil && fun.Effects.Effects != nil {
+		doc.Effects = newFieldDocs(group, fun.Effects.Effects.Fields())
+	}
+
+	return doc, nil
+}
+
+// newAliasDoc documents an alias with the doc comment of the call statement
+// that declares it. Aliases inherit the parameters of their enclosing function,
+// but not its effects.
+func newAliasDoc(b *parser.Binding) (*Func, error) {
+	closure := b.Bind.Closure
+
+	var cg *parser.CommentGroup
+	if closure.Body != nil {
+		parser.Match(closure.Body, parser.MatchOpts{},
+			func(call *parser.CallStmt) {
+				if call.BindClause == b.Bind {
+					cg = call.Doc
+				}
+			},
+		)
+	}
+
+	group, examples, err := parseDoc(cg)
+	if err != nil {
+		return nil, err
+	}
+
+	closureGroup, _, err := parseDoc(closure.Doc)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Func{
+		Doc:      strings.TrimSpace(group.Doc),
+		Type:     b.Field.Type.String(),
+		Name:     b.Name.Text,
+		Params:   []Field{},
+		Return:   group.Return.Description,
+		Examples: examples,
+		AliasOf:  closure.Name.Text,
+	}
+
+	if closure.Params != nil {
+		doc.Params = newFieldDocs(closureGroup, closure.Params.Fields())
+	}
+
+	return doc, nil
+}
+
+func newFieldDocs(group *doxygen.Group, fields []*parser.Field) []Field {
+	docs := []Field{}
+	for _, field := range fields {
+		doc := Field{
+			Variadic: field.Modifier != nil && field.Modifier.Variadic != nil,
+			Type:     field.Type.String(),
+			Name:     field.Name.Text,
+		}
+		for _, param := range group.Params {
+			if param.Name == doc.Name {
+				doc.Doc = param.Description
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// parseDoc parses the doxygen commands of a comment group, except for
+// examples which are returned separately.
+func parseDoc(cg *parser.CommentGroup) (*doxygen.Group, []string, error) {
+	if cg == nil {
+		return &doxygen.Group{}, nil, nil
+	}
+
+	var (
+		lines    []string
+		examples []string
+		example  []string
+	)
+
+	endExample := func() {
+		if example != nil {
+			examples = append(examples, strings.TrimSpace(dedent.Dedent(strings.Join(example, "\n"))))
+			example = nil
+		}
+	}
+
+	for _, comment := range cg.List {
+		text := strings.TrimPrefix(strings.TrimRight(comment.Text, "\r\n"), "#")
+		text = strings.TrimPrefix(text, " ")
+
+		trimmed := strings.TrimSpace(text)
+		switch {
+		case strings.HasPrefix(trimmed, "@example"):
+			endExample()
+			example = []string{}
+			if rest := strings.TrimSpace(strings.TrimPrefix(trimmed, "@example")); rest != "" {
+				example = append(example, rest)
+			}
+			continue
+		case strings.HasPrefix(trimmed, "@"):
+			endExample()
+		case example != nil:
+			example = append(example, text)
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("%s\n", trimmed))
+	}
+	endExample()
+
+	group, err := doxygen.Parse(strings.NewReader(strings.Join(lines, "")))
+	if err != nil {
+		return nil, nil, err
+	}
+	return group, examples, nil
+}
+
+// WriteJSON writes the documentation as indented JSON.
+func (d *Documentation) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// WriteMarkdown writes the documentation as markdown.
+func (d *Documentation) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", d.Module)
+
+	for _, fun := range d.Funcs {
+		fmt.Fprintf(&b, "## `%s`\n\n", fun.Signature())
+		if fun.AliasOf != "" {
+			fmt.Fprintf(&b, "Alias declared in `%s`.\n\n", fun.AliasOf)
+		}
+		if fun.Doc != "" {
+			fmt.Fprintf(&b, "%s\n\n", fun.Doc)
+		}
+
+		writeFields := func(title string, fields []Field) {
+			if len(fields) == 0 {
+				return
+			}
+			fmt.Fprintf(&b, "%s:\n\n", title)
+			for _, field := range fields {
+				fmt.Fprintf(&b, "- `%s`", joinFields([]Field{field}))
+				if field.Doc != "" {
+					fmt.Fprintf(&b, " %s", field.Doc)
+				}
+				b.WriteString("\n")
+			}
+			b.WriteString("\n")
+		}
+		writeFields("Parameters", fun.Params)
+		writeFields("Binds", fun.Effects)
+
+		if fun.Return != "" {
+			fmt.Fprintf(&b, "Returns `%s` %s\n\n", fun.Type, fun.Return)
+		}
+
+		for _, example := range fun.Examples {
+			fmt.Fprintf(&b, "```hlb\n%s\n```\n\n", example)
+		}
+	}
+
+	_, err := io.WriteString(w, strings.TrimRight(b.String(), "\n")+"\n")
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("doc").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Module}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; }
+code, pre { font-family: monospace; background: #f5f5f5; }
+pre { padding: 1em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>{{.Module}}</h1>
+{{- range .Funcs}}
+<section id="{{.Name}}">
+<h2><code>{{.Signature}}</code></h2>
+{{- if .AliasOf}}
+<p>Alias declared in <code>{{.AliasOf}}</code>.</p>
+{{- end}}
+{{- if .Doc}}
+<p>{{.Doc}}</p>
+{{- end}}
+{{- if .Params}}
+<h3>Parameters</h3>
+<ul>
+{{- range .Params}}
+<li><code>{{if .Variadic}}variadic {{end}}{{.Type}} {{.Name}}</code> {{.Doc}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Effects}}
+<h3>Binds</h3>
+<ul>
+{{- range .Effects}}
+<li><code>{{if .Variadic}}variadic {{end}}{{.Type}} {{.Name}}</code> {{.Doc}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Return}}
+<p>Returns <code>{{.Type}}</code> {{.Return}}</p>
+{{- end}}
+{{- range .Examples}}
+<pre><code>{{.}}</code></pre>
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes the documentation as a standalone HTML page.
+func (d *Documentation) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, d)
+}
@@ -0,0 +1,87 @@
+package module
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	"github.com/openllb/hlb/builtin"
+	"github.com/openllb/hlb/checker"
+	"github.com/openllb/hlb/diagnostic"
+	"github.com/openllb/hlb/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateDocumentation(t *testing.T) {
+	t.Parallel()
+
+	ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+	mod, err := parser.Parse(ctx, strings.NewReader(dedent.Dedent(`
+		export build
+		export nodeModules
+		export version
+
+		# Builds a node project.
+		#
+		# @param src the source of the project.
+		# @param out the built output.
+		# @return the project built.
+		# @example
+		#   fs default() {
+		#       build local(".")
+		#   }
+		fs build(fs src) binds (fs out) {
+			image "node"
+			run "npm install" with option {
+				# The installed node modules.
+				mount src "/src" as nodeModules
+			}
+		}
+
+		string version() { "16" }
+
+		fs unexported() {}
+	`)))
+	require.NoError(t, err)
+
+	err = checker.SemanticPass(mod)
+	require.NoError(t, err)
+
+	err = checker.Check(mod)
+	require.NoError(t, err)
+
+	doc, err := GenerateDocumentation(mod)
+	require.NoError(t, err)
+
+	src := Field{Doc: "the source of the project.", Type: "fs", Name: "src"}
+	require.Equal(t, []*Func{{
+		Doc:      "Builds a node project.",
+		Type:     "fs",
+		Name:     "build",
+		Params:   []Field{src},
+		Effects:  []Field{{Doc: "the built output.", Type: "fs", Name: "out"}},
+		Return:   "the project built.",
+		Examples: []string{"fs default() {\n    build local(\".\")\n}"},
+	}, {
+		Doc:     "The installed node modules.",
+		Type:    "fs",
+		Name:    "nodeModules",
+		Params:  []Field{src},
+		AliasOf: "build",
+	}, {
+		Type:   "string",
+		Name:   "version",
+		Params: []Field{},
+	}}, doc.Funcs)
+
+	var sb strings.Builder
+	err = doc.WriteMarkdown(&sb)
+	require.NoError(t, err)
+	require.Contains(t, sb.String(), "## `fs build(fs src) binds (fs out)`\n\nBuilds a node project.\n\n")
+
+	sb.Reset()
+	err = doc.WriteHTML(&sb)
+	require.NoError(t, err)
+	require.Contains(t, sb.String(), "<h2><code>fs nodeModules(fs src)</code></h2>\n<p>Alias declared in <code>build</code>.</p>")
+}
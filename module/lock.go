@@ -0,0 +1,60 @@
+package module
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/openllb/hlb/codegen"
+	"github.com/openllb/hlb/parser"
+)
+
+// LockPath is the name of the lockfile pinning the sources of a module, next
+// to the module. It is expected to be committed to git repositories alongside
+// DotHLBPath.
+var LockPath = "hlb.lock"
+
+// LockFile returns the path of the lockfile of a module. Modules read from
+// stdin use the lockfile in the current working directory.
+func LockFile(mod *parser.Module) string {
+	filename := mod.Pos.Filename
+	if filename == "" || filename == "<stdin>" || filename == os.Stdin.Name() {
+		return LockPath
+	}
+	return filepath.Join(filepath.Dir(filename), LockPath)
+}
+
+// ReadLock reads a lockfile. The error satisfies os.IsNotExist if there is no
+// lockfile.
+func ReadLock(path string) (*codegen.Lock, error) {
+	dt, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &codegen.Lock{}
+	err = json.Unmarshal(dt, lock)
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// WriteLock writes a lockfile with its entries sorted.
+func WriteLock(path string, lock *codegen.Lock) error {
+	lock.Sort()
+	if lock.Images == nil {
+		lock.Images = []codegen.LockedImage{}
+	}
+	if lock.Git == nil {
+		lock.Git = []codegen.LockedGit{}
+	}
+
+	dt, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(dt, '\n'), 0644)
+}
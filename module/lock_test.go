@@ -0,0 +1,35 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openllb/hlb/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockFile(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		filename string
+		expected string
+	}{{
+		"build.hlb",
+		"hlb.lock",
+	}, {
+		filepath.Join("examples", "node.hlb"),
+		filepath.Join("examples", "hlb.lock"),
+	}, {
+		"<stdin>",
+		"hlb.lock",
+	}, {
+		os.Stdin.Name(),
+		"hlb.lock",
+	}} {
+		mod := &parser.Module{}
+		mod.Pos.Filename = tc.filename
+		require.Equal(t, tc.expected, LockFile(mod), tc.filename)
+	}
+}
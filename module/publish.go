@@ -0,0 +1,56 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/openllb/hlb/pkg/ociutil"
+)
+
+// Publish pushes the HLB files of a module directory as an OCI artifact, and
+// returns the digest of its manifest. The directory must contain
+// ModuleFilename, and hidden directories like DotHLBPath are skipped.
+//
+// Published modules can be imported with the oci builtin, for example
+// `import foo from oci("registry/foo:tag")`.
+func Publish(ctx context.Context, dir, ref string) (digest.Digest, error) {
+	files := make(map[string][]byte)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path != dir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(path) != ".hlb" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(rel)], err = ioutil.ReadFile(path)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if _, ok := files[ModuleFilename]; !ok {
+		return "", fmt.Errorf("%s has no %s to publish", dir, ModuleFilename)
+	}
+
+	return ociutil.Push(ctx, ref, files)
+}
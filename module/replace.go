@@ -0,0 +1,108 @@
+package module
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openllb/hlb/errdefs"
+	"github.com/openllb/hlb/parser"
+)
+
+// ReplacePath is the path of the file with replace rules for the module in the
+// current working directory. Replace rules are meant for local development, so
+// it is not expected to be committed to git repositories.
+var ReplacePath = "hlb.replace"
+
+// Replace maps the names of imports to local paths that are used instead of
+// resolving them. A path is either a directory containing ModuleFilename or an
+// HLB file. Imports of imported modules are named by their path from the root
+// module, like `go.shared` for the import `shared` of the import `go`, as
+// their names are only unique within the module declaring them.
+type Replace map[string]string
+
+type replaceKey struct{}
+
+// WithReplace returns a context that replaces imports while resolving the
+// import graph.
+func WithReplace(ctx context.Context, replace Replace) context.Context {
+	return context.WithValue(ctx, replaceKey{}, replace)
+}
+
+func replaceFrom(ctx context.Context) Replace {
+	replace, _ := ctx.Value(replaceKey{}).(Replace)
+	return replace
+}
+
+// ParseReplace parses replace rules in the form name=path, where the name may
+// be the dotted path of an import of an imported module. Relative paths are
+// relative to the current working directory.
+func ParseReplace(rules []string) (Replace, error) {
+	replace := make(Replace)
+	for _, rule := range rules {
+		kv := strings.SplitN(rule, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("invalid replace %q, expected name=path", rule)
+		}
+		replace[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return replace, nil
+}
+
+// ReadReplace reads replace rules from a file with a rule per line. Blank lines
+// and lines starting with # are ignored. The error satisfies os.IsNotExist if
+// there is no file.
+func ReadReplace(path string) (Replace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	replace, err := ParseReplace(rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return replace, nil
+}
+
+// resolveReplace returns the resolved root and the filename of a replaced
+// import. The path must be an HLB file or a directory containing
+// ModuleFilename.
+func resolveReplace(id *parser.ImportDecl, path string) (Resolved, string, error) {
+	root, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	filename := filepath.Join(root, ModuleFilename)
+	fi, err := os.Stat(root)
+	if err == nil {
+		if fi.IsDir() {
+			_, err = os.Stat(filename)
+		} else {
+			root, filename = filepath.Dir(root), root
+		}
+	}
+	if err != nil {
+		return nil, "", errdefs.WithReplacePathNotExist(err, id.Name, path)
+	}
+
+	return &localResolved{"", root}, filename, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"sync"
 
@@ -20,6 +21,8 @@ import (
 	"github.com/openllb/hlb/linter"
 	"github.com/openllb/hlb/parser"
 	"github.com/openllb/hlb/pkg/llbutil"
+	"github.com/openllb/hlb/pkg/ociutil"
+	"github.com/openllb/hlb/pkg/tracing"
 	"github.com/openllb/hlb/solver"
 	"golang.org/x/sync/errgroup"
 )
@@ -64,7 +67,7 @@ func NewResolver(cln *client.Client) (Resolver, error) {
 		return &remoteResolver{cln, root}, nil
 	}
 
-	return &vendorResolver{root}, nil
+	return &vendorResolver{modulePath: root, sumPath: SumPath}, nil
 }
 
 // ModulesPathExist returns true if the modules directory exists in the current
@@ -89,6 +92,11 @@ func modulesPathExist() (string, bool, error) {
 
 type vendorResolver struct {
 	modulePath string
+	sumPath    string
+
+	sumOnce sync.Once
+	sum     Sum
+	sumErr  error
 }
 
 func (r *vendorResolver) Resolve(ctx context.Context, id *parser.ImportDecl, fs codegen.Filesystem) (Resolved, error) {
@@ -99,7 +107,11 @@ func (r *vendorResolver) Resolve(ctx context.Context, id *parser.ImportDecl, fs
 
 	rc, err := res.Open(ModuleFilename)
 	if err == nil {
-		return res, rc.Close()
+		err = rc.Close()
+		if err != nil {
+			return res, err
+		}
+		return res, r.verify(id, res.Digest())
 	}
 	if !os.IsNotExist(err) {
 		return res, err
@@ -108,6 +120,35 @@ func (r *vendorResolver) Resolve(ctx context.Context, id *parser.ImportDecl, fs
 	return res, fmt.Errorf("missing module %q from vendor, run `hlb mod vendor --target %s %s` to vendor module", id.Name, id.Name, id.Pos.Filename)
 }
 
+// verify checks the vendored module against the sum file. Modules vendored
+// before there was a sum file are not verified.
+func (r *vendorResolver) verify(id *parser.ImportDecl, dgst digest.Digest) error {
+	r.sumOnce.Do(func() {
+		r.sum, r.sumErr = ReadSum(r.sumPath)
+		if os.IsNotExist(r.sumErr) {
+			r.sumErr = nil
+		}
+	})
+	if r.sumErr != nil || r.sum == nil {
+		return r.sumErr
+	}
+
+	vp := VendorPath(r.modulePath, dgst)
+	expected, ok := r.sum[dgst]
+	if !ok {
+		return errdefs.WithVendorNotSummed(id.Name, vp)
+	}
+
+	actual, err := ChecksumDir(vp)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return errdefs.WithVendorChecksumMismatch(id.Name, vp, expected.String(), actual.String())
+	}
+	return nil
+}
+
 func resolveLocal(ctx context.Context, modulePath string, fs codegen.Filesystem) (Resolved, error) {
 	dgst, err := fs.Digest(ctx)
 	if err != nil {
@@ -155,15 +196,30 @@ func (r *remoteResolver) Resolve(ctx context.Context, id *parser.ImportDecl, fs
 		return nil, err
 	}
 
+	root := fmt.Sprintf("%s#%s", id.Pos.Filename, id.Name)
+
+	// Modules published as OCI artifacts are pulled directly, so they don't
+	// need to be solved.
+	if ref := fs.ArtifactRef(); ref != "" {
+		artifact, err := ociutil.Pull(ctx, ref)
+		if err != nil {
+			return nil, errdefs.WithPullArtifact(err, id.Expr, ref)
+		}
+		return &artifactResolved{root, dgst, artifact.Files}, nil
+	}
+
 	def, err := fs.State.Marshal(ctx, llb.LinuxAmd64)
 	if err != nil {
 		return nil, err
 	}
 
+	prefix := fmt.Sprintf("import %s", id.Name)
+	ctx = solver.WithProgressPrefix(ctx, prefix)
+
 	var pw progress.Writer
 	mw := codegen.MultiWriter(ctx)
 	if mw != nil {
-		pw = mw.WithPrefix(fmt.Sprintf("import %s", id.Name), true)
+		pw = mw.WithPrefix(prefix, true)
 	}
 
 	// Block constructing remoteResolved until the graph is solved and assigned to
@@ -221,7 +277,6 @@ func (r *remoteResolver) Resolve(ctx context.Context, id *parser.ImportDecl, fs
 		return nil, g.Wait()
 	}
 
-	root := fmt.Sprintf("%s#%s", id.Pos.Filename, id.Name)
 	return &remoteResolved{root, dgst, ref, g, ctx, closed}, nil
 }
 
@@ -264,6 +319,30 @@ func (r *remoteResolved) Close() error {
 	return r.g.Wait()
 }
 
+type artifactResolved struct {
+	root  string
+	dgst  digest.Digest
+	files map[string][]byte
+}
+
+func (r *artifactResolved) Digest() digest.Digest {
+	return r.dgst
+}
+
+func (r *artifactResolved) Open(filename string) (io.ReadCloser, error) {
+	dt, ok := r.files[path.Clean(filepath.ToSlash(filename))]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return &parser.NamedReader{
+		Reader: bytes.NewReader(dt),
+		Value:  filepath.Join(r.root, filename),
+	}, nil
+}
+
+func (r *artifactResolved) Close() error { return nil }
+
 type tidyResolver struct {
 	cln    *client.Client
 	remote *remoteResolver
@@ -345,6 +424,13 @@ type VisitInfo struct {
 	ImportDecl *parser.ImportDecl
 	Ret        codegen.Value
 	Digest     digest.Digest
+
+	// Path is the name of the import prefixed by the names of the imports
+	// leading to it from the root module, separated by dots.
+	Path string
+
+	// Replace is the local path of the import if it was replaced.
+	Replace string
 }
 
 type resolveGraphInfo struct {
@@ -360,10 +446,20 @@ func ResolveGraph(ctx context.Context, cln *client.Client, resolver Resolver, re
 		resolver: resolver,
 		visitor:  visitor,
 	}
-	return resolveGraph(ctx, info, res, mod)
+	return resolveGraph(ctx, info, res, mod, "")
 }
 
-func resolveGraph(ctx context.Context, info *resolveGraphInfo, res Resolved, mod *parser.Module) error {
+// resolveGraph resolves the imports of a module, whose imports are named with
+// the prefix of its path from the root module.
+func resolveGraph(ctx context.Context, info *resolveGraphInfo, res Resolved, mod *parser.Module, prefix string) (err error) {
+	// Imported modules are resolved recursively, so their spans are nested in
+	// the span of the module importing them.
+	ctx, span := tracing.Start(ctx, "module.ResolveGraph", tracing.String("hlb.filename", mod.Pos.Filename))
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	g, ctx := errgroup.WithContext(ctx)
 
 	var (
@@ -381,17 +477,32 @@ func resolveGraph(ctx context.Context, info *resolveGraphInfo, res Resolved, mod
 			res := res
 			g.Go(func() error {
 				var (
-					ctx = codegen.WithProgramCounter(ctx, id.Expr)
-					ret = codegen.NewRegister()
+					ctx      = codegen.WithProgramCounter(ctx, id.Expr)
+					ret      = codegen.NewRegister()
+					path     = prefix + id.Name.Text
+					filename string
+					err      error
 				)
-				err := cg.EmitExpr(ctx, mod.Scope, id.Expr, nil, nil, nil, ret)
-				if err != nil {
-					return err
+
+				// Replaced imports are read from the local path instead of
+				// being resolved, regardless of vendored modules, so their
+				// expression isn't evaluated.
+				replace, replaced := replaceFrom(ctx)[path]
+				if !replaced {
+					err = cg.EmitExpr(ctx, mod.Scope, id.Expr, nil, nil, nil, ret)
+					if err != nil {
+						return err
+					}
 				}
 
-				var filename string
-				switch ret.Kind() {
-				case parser.Filesystem:
+				switch {
+				case replaced:
+					res, filename, err = resolveReplace(id, replace)
+					if err != nil {
+						return err
+					}
+
+				case ret.Kind() == parser.Filesystem:
 					fs, err := ret.Filesystem()
 					if err != nil {
 						return err
@@ -404,7 +515,7 @@ func resolveGraph(ctx context.Context, info *resolveGraphInfo, res Resolved, mod
 					}
 					defer res.Close()
 
-				case parser.String:
+				case ret.Kind() == parser.String:
 					filename, err = ret.String()
 					if err != nil {
 						return err
@@ -443,7 +554,7 @@ func resolveGraph(ctx context.Context, info *resolveGraphInfo, res Resolved, mod
 					mu.Unlock()
 				}()
 
-				err = checker.SemanticPass(imod)
+				err = checker.Traced(ctx, "checker.SemanticPass", checker.SemanticPass, imod)
 				if err != nil {
 					return err
 				}
@@ -451,7 +562,7 @@ func resolveGraph(ctx context.Context, info *resolveGraphInfo, res Resolved, mod
 				// Drop errors from linting.
 				_ = linter.Lint(ctx, imod)
 
-				err = checker.Check(imod)
+				err = checker.Traced(ctx, "checker.Check", checker.Check, imod)
 				if err != nil {
 					return err
 				}
@@ -463,13 +574,15 @@ func resolveGraph(ctx context.Context, info *resolveGraphInfo, res Resolved, mod
 						ImportDecl: id,
 						Ret:        ret,
 						Digest:     res.Digest(),
+						Path:       path,
+						Replace:    replace,
 					})
 					if err != nil {
 						return err
 					}
 				}
 
-				return resolveGraph(ctx, info, res, imod)
+				return resolveGraph(ctx, info, res, imod, path+".")
 			})
 		},
 	)
@@ -488,5 +601,5 @@ func resolveGraph(ctx context.Context, info *resolveGraphInfo, res Resolved, mod
 		obj.Data = imp.Scope
 	}
 
-	return checker.CheckReferences(mod)
+	return checker.Traced(ctx, "checker.CheckReferences", checker.CheckReferences, mod)
 }
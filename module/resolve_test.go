@@ -2,10 +2,14 @@ package module
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/lithammer/dedent"
@@ -16,6 +20,7 @@ import (
 	"github.com/openllb/hlb/errdefs"
 	"github.com/openllb/hlb/linter"
 	"github.com/openllb/hlb/parser"
+	"github.com/openllb/hlb/pkg/ociutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -158,3 +163,394 @@ func validateError(t *testing.T, ctx context.Context, expected, actual error, na
 		}
 	}
 }
+
+func TestResolveGraphReplace(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hlb-replace")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for filename, fixture := range map[string]string{
+		"module.hlb": `
+			import simple from "simple.hlb"
+			export build
+			fs build() {
+				simple.build
+			}
+		`,
+		"simple.hlb": `
+			export build
+			fs build() {}
+		`,
+		"other.hlb": `
+			export build
+			fs build() {}
+		`,
+	} {
+		err = ioutil.WriteFile(filepath.Join(dir, filename), []byte(dedent.Dedent(fixture)), 0644)
+		require.NoError(t, err)
+	}
+
+	res := &testResolved{map[string]string{
+		"simple.hlb": `
+			export other
+			fs other() {}
+		`,
+	}}
+
+	for _, tc := range []struct {
+		name     string
+		input    string
+		replace  Replace
+		replaced []string
+		errMsg   string
+	}{{
+		"replace remote import with directory",
+		`
+		import remote from fs { image "openllb/remote.hlb"; }
+		fs default() {
+			remote.build
+		}
+		`,
+		Replace{"remote": dir},
+		[]string{"remote"},
+		"",
+	}, {
+		"replace local import with file",
+		`
+		import simple from "simple.hlb"
+		fs default() {
+			simple.build
+		}
+		`,
+		Replace{"simple": filepath.Join(dir, "simple.hlb")},
+		[]string{"simple"},
+		"",
+	}, {
+		"replaced imports are not evaluated",
+		`
+		import remote from fs { local "./missing"; }
+		fs default() {
+			remote.build
+		}
+		`,
+		Replace{"remote": dir},
+		[]string{"remote"},
+		"",
+	}, {
+		"replace with missing module",
+		`
+		import remote from fs { image "openllb/remote.hlb"; }
+		`,
+		Replace{"remote": filepath.Join(dir, "missing")},
+		nil,
+		"no such file or directory",
+	}, {
+		"replace with directory without module",
+		`
+		import remote from fs { image "openllb/remote.hlb"; }
+		`,
+		Replace{"remote": filepath.Dir(dir)},
+		nil,
+		ModuleFilename + ": no such file or directory",
+	}, {
+		"imports of imported modules are not replaced by their name",
+		`
+		import remote from fs { image "openllb/remote.hlb"; }
+		fs default() {
+			remote.build
+		}
+		`,
+		Replace{"remote": dir, "simple": filepath.Join(dir, "missing")},
+		[]string{"remote"},
+		"",
+	}, {
+		"replace import of imported module by its path",
+		`
+		import remote from fs { image "openllb/remote.hlb"; }
+		fs default() {
+			remote.build
+		}
+		`,
+		Replace{"remote": dir, "remote.simple": filepath.Join(dir, "other.hlb")},
+		[]string{"remote", "remote.simple"},
+		"",
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			in := strings.NewReader(dedent.Dedent(tc.input))
+
+			ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+			mod, err := parser.Parse(ctx, in)
+			require.NoError(t, err)
+
+			err = checker.SemanticPass(mod)
+			require.NoError(t, err)
+
+			err = checker.Check(mod)
+			require.NoError(t, err)
+
+			var (
+				replaced []string
+				mu       sync.Mutex
+			)
+			ctx = WithReplace(ctx, tc.replace)
+			err = ResolveGraph(ctx, nil, nil, res, mod, func(info VisitInfo) error {
+				if info.Replace != "" {
+					mu.Lock()
+					replaced = append(replaced, info.Path)
+					mu.Unlock()
+				}
+				return nil
+			})
+			if tc.errMsg != "" {
+				require.Error(t, err, tc.name)
+				require.Contains(t, err.Error(), tc.errMsg, tc.name)
+				require.NotEmpty(t, diagnostic.Spans(err), tc.name)
+				return
+			}
+			require.NoError(t, err, tc.name)
+			sort.Strings(replaced)
+			require.Equal(t, tc.replaced, replaced, tc.name)
+		})
+	}
+}
+
+func TestVendorResolverVerify(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hlb-vendor")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var (
+		modulePath = filepath.Join(dir, "modules")
+		sumPath    = filepath.Join(dir, "hlb.sum")
+		dgst       = digest.FromString("module")
+		unsummed   = digest.FromString("unsummed")
+	)
+
+	for _, dgst := range []digest.Digest{dgst, unsummed} {
+		vp := VendorPath(modulePath, dgst)
+		err = os.MkdirAll(vp, 0700)
+		require.NoError(t, err)
+
+		err = ioutil.WriteFile(filepath.Join(vp, ModuleFilename), []byte("fs build() {}\n"), 0644)
+		require.NoError(t, err)
+	}
+
+	checksum, err := ChecksumDir(VendorPath(modulePath, dgst))
+	require.NoError(t, err)
+
+	err = WriteSum(sumPath, Sum{dgst: checksum})
+	require.NoError(t, err)
+
+	sum, err := ReadSum(sumPath)
+	require.NoError(t, err)
+	require.Equal(t, Sum{dgst: checksum}, sum)
+
+	ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+	mod, err := parser.Parse(ctx, strings.NewReader(dedent.Dedent(`
+	import remote from fs { image "openllb/remote.hlb"; }
+	`)))
+	require.NoError(t, err)
+
+	var id *parser.ImportDecl
+	parser.Match(mod, parser.MatchOpts{},
+		func(decl *parser.ImportDecl) {
+			id = decl
+		},
+	)
+
+	r := &vendorResolver{modulePath: modulePath, sumPath: sumPath}
+	err = r.verify(id, dgst)
+	require.NoError(t, err)
+
+	err = r.verify(id, unsummed)
+	validateError(t, ctx, errdefs.WithVendorNotSummed(id.Name, VendorPath(modulePath, unsummed)), err, "unsummed")
+
+	err = ioutil.WriteFile(filepath.Join(VendorPath(modulePath, dgst), ModuleFilename), []byte("fs tampered() {}\n"), 0644)
+	require.NoError(t, err)
+
+	tampered, err := ChecksumDir(VendorPath(modulePath, dgst))
+	require.NoError(t, err)
+
+	err = r.verify(id, dgst)
+	validateError(t, ctx, errdefs.WithVendorChecksumMismatch(id.Name, VendorPath(modulePath, dgst), checksum.String(), tampered.String()), err, "tampered")
+}
+
+func TestResolveGraphArtifact(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hlb-artifact")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	moduleDir := filepath.Join(dir, "module")
+	err = os.MkdirAll(filepath.Join(moduleDir, ".hlb"), 0755)
+	require.NoError(t, err)
+
+	for filename, fixture := range map[string]string{
+		"module.hlb": `
+			import simple from "simple.hlb"
+			export build
+			fs build() {
+				simple.build
+			}
+		`,
+		"simple.hlb": `
+			export build
+			fs build() {}
+		`,
+		".hlb/skipped.hlb": `
+			fs skipped() {}
+		`,
+	} {
+		err = ioutil.WriteFile(filepath.Join(moduleDir, filename), []byte(dedent.Dedent(fixture)), 0644)
+		require.NoError(t, err)
+	}
+
+	ref := fmt.Sprintf("oci-layout://%s:v1", filepath.Join(dir, "layout"))
+	_, err = Publish(context.Background(), moduleDir, ref)
+	require.NoError(t, err)
+
+	artifact, err := ociutil.Pull(context.Background(), ref)
+	require.NoError(t, err)
+	require.Len(t, artifact.Files, 2)
+
+	for _, tc := range []struct {
+		name   string
+		input  string
+		errMsg string
+	}{{
+		"import artifact",
+		fmt.Sprintf(`
+		import remote from oci(%q)
+		fs default() {
+			remote.build
+		}
+		`, ref),
+		"",
+	}, {
+		"import artifact from block",
+		fmt.Sprintf(`
+		import remote from fs { oci %q; }
+		fs default() {
+			remote.build
+		}
+		`, ref),
+		"",
+	}, {
+		"import unknown tag",
+		fmt.Sprintf(`
+		import remote from oci(%q)
+		`, strings.TrimSuffix(ref, ":v1")+":v2"),
+		"v2 not found",
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			in := strings.NewReader(dedent.Dedent(tc.input))
+
+			ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+			mod, err := parser.Parse(ctx, in)
+			require.NoError(t, err)
+
+			err = checker.SemanticPass(mod)
+			require.NoError(t, err)
+
+			err = checker.Check(mod)
+			require.NoError(t, err)
+
+			res, err := NewLocalResolved(mod)
+			require.NoError(t, err)
+
+			var imported []string
+			err = ResolveGraph(ctx, nil, &remoteResolver{}, res, mod, func(info VisitInfo) error {
+				imported = append(imported, info.ImportDecl.Name.Text)
+				return nil
+			})
+			if tc.errMsg != "" {
+				require.Error(t, err, tc.name)
+				require.Contains(t, err.Error(), tc.errMsg, tc.name)
+				return
+			}
+			require.NoError(t, err, tc.name)
+			require.ElementsMatch(t, []string{"remote", "simple"}, imported, tc.name)
+		})
+	}
+}
+
+func TestVendorResolverArtifact(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hlb-vendor-artifact")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	moduleDir := filepath.Join(dir, "module")
+	err = os.MkdirAll(moduleDir, 0755)
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(moduleDir, ModuleFilename), []byte(dedent.Dedent(`
+	export build
+	fs build() {}
+	`)), 0644)
+	require.NoError(t, err)
+
+	layout := filepath.Join(dir, "layout")
+	ref := fmt.Sprintf("oci-layout://%s:v1", layout)
+	_, err = Publish(context.Background(), moduleDir, ref)
+	require.NoError(t, err)
+
+	ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+	mod, err := parser.Parse(ctx, strings.NewReader(dedent.Dedent(fmt.Sprintf(`
+	import remote from oci(%q)
+	fs default() {
+		remote.build
+	}
+	`, ref))))
+	require.NoError(t, err)
+
+	err = checker.SemanticPass(mod)
+	require.NoError(t, err)
+
+	err = checker.Check(mod)
+	require.NoError(t, err)
+
+	res, err := NewLocalResolved(mod)
+	require.NoError(t, err)
+	defer res.Close()
+
+	// Vendor the artifact while it can still be pulled.
+	modulePath := filepath.Join(dir, "modules")
+	err = ResolveGraph(ctx, nil, &remoteResolver{nil, modulePath}, res, mod, func(info VisitInfo) error {
+		vp := VendorPath(modulePath, info.Digest)
+		err := os.MkdirAll(vp, 0700)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(vp, ModuleFilename), []byte(info.Import.String()), 0644)
+	})
+	require.NoError(t, err)
+
+	// Without the layout, the artifact can no longer be pulled.
+	err = os.RemoveAll(layout)
+	require.NoError(t, err)
+
+	err = ResolveGraph(ctx, nil, &remoteResolver{nil, modulePath}, res, mod, func(info VisitInfo) error {
+		return nil
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to resolve "+ref)
+
+	var imported []string
+	r := &vendorResolver{modulePath: modulePath, sumPath: filepath.Join(dir, "hlb.sum")}
+	err = ResolveGraph(ctx, nil, r, res, mod, func(info VisitInfo) error {
+		imported = append(imported, info.ImportDecl.Name.Text)
+		require.Equal(t, filepath.Join(VendorPath(modulePath, info.Digest), ModuleFilename), info.Import.Pos.Filename)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"remote"}, imported)
+}
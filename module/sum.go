@@ -0,0 +1,114 @@
+package module
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// SumPath is the path of the file recording the checksums of vendored modules
+// of the module in the current working directory. It is expected to be
+// committed to git repositories alongside DotHLBPath.
+var SumPath = "hlb.sum"
+
+// Sum maps the digests of imported modules to the checksums of their vendored
+// files.
+type Sum map[digest.Digest]digest.Digest
+
+// ReadSum reads a sum file with a module digest and its checksum per line. The
+// error satisfies os.IsNotExist if there is no sum file.
+func ReadSum(path string) (Sum, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sum := make(Sum)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected module digest and checksum", path, n)
+		}
+
+		dgst, err := digest.Parse(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+
+		checksum, err := digest.Parse(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+
+		sum[dgst] = checksum
+	}
+	return sum, scanner.Err()
+}
+
+// WriteSum writes a sum file sorted by module digest.
+func WriteSum(path string, sum Sum) error {
+	var dgsts []string
+	for dgst := range sum {
+		dgsts = append(dgsts, dgst.String())
+	}
+	sort.Strings(dgsts)
+
+	var buf bytes.Buffer
+	for _, dgst := range dgsts {
+		fmt.Fprintf(&buf, "%s %s\n", dgst, sum[digest.Digest(dgst)])
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// Checksum returns the checksum of the files of a module, keyed by their slash
+// separated paths.
+func Checksum(files map[string][]byte) digest.Digest {
+	var filenames []string
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	var summary bytes.Buffer
+	for _, filename := range filenames {
+		fmt.Fprintf(&summary, "%s  %s\n", digest.FromBytes(files[filename]).Encoded(), filename)
+	}
+	return digest.FromBytes(summary.Bytes())
+}
+
+// ChecksumDir returns the checksum of the files in a vendored module
+// directory.
+func ChecksumDir(dir string) (digest.Digest, error) {
+	files := make(map[string][]byte)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(rel)], err = ioutil.ReadFile(path)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return Checksum(files), nil
+}
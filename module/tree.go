@@ -13,7 +13,9 @@ import (
 
 // NewTree resolves the import graph and returns a treeprint.Tree that can be
 // printed to display a visualization of the imports. Imports that transitively
-// import the same module will be duplicated in the tree.
+// import the same module will be duplicated in the tree, and replaced imports
+// are displayed with their local path. Imports of imported modules are
+// displayed with the dotted path they are replaced by.
 func NewTree(ctx context.Context, cln *client.Client, mod *parser.Module, long bool) (treeprint.Tree, error) {
 	resolver, err := NewResolver(cln)
 	if err != nil {
@@ -46,10 +48,12 @@ func NewTree(ctx context.Context, cln *client.Client, mod *parser.Module, long b
 		}
 
 		var value string
-		switch info.Ret.Kind() {
-		case parser.Filesystem:
+		switch {
+		case info.Replace != "":
+			value = fmt.Sprintf("=> %s", info.Replace)
+		case info.Ret.Kind() == parser.Filesystem:
 			value = filepath.Join(prefix, ModuleFilename)
-		case parser.String:
+		case info.Ret.Kind() == parser.String:
 			value, err = info.Ret.String()
 			if err != nil {
 				return err
@@ -61,8 +65,8 @@ func NewTree(ctx context.Context, cln *client.Client, mod *parser.Module, long b
 		}
 
 		mu.Lock()
-		node := nodeByModule[mod]
-		inode := node.AddMetaBranch(info.ImportDecl.Name.Text, value)
+		node := nodeByModule[info.Parent]
+		inode := node.AddMetaBranch(info.Path, value)
 		nodeByModule[info.Import] = inode
 		mu.Unlock()
 
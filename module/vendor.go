@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/moby/buildkit/client"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/openllb/hlb/parser"
 	"golang.org/x/sync/errgroup"
 )
@@ -16,11 +17,15 @@ import (
 //
 // If tidy mode is enabled, vertices with digests that already exist in the
 // modules directory are skipped, and unused modules are pruned.
+//
+// The checksums of vendored modules are recorded in the sum file, so that
+// they can be verified later.
 func Vendor(ctx context.Context, cln *client.Client, mod *parser.Module, targets []string, tidy bool) error {
 	root := ModulesPath
 
 	var mu sync.Mutex
 	markedPaths := make(map[string]struct{})
+	vendored := make(map[digest.Digest]bool)
 
 	var resolver Resolver
 	if tidy {
@@ -85,6 +90,11 @@ func Vendor(ctx context.Context, cln *client.Client, mod *parser.Module, targets
 				_, err := os.Stat(vp)
 				if err == nil {
 					// Skip modules that have already been vendored.
+					mu.Lock()
+					if _, ok := vendored[info.Digest]; !ok {
+						vendored[info.Digest] = false
+					}
+					mu.Unlock()
 					return nil
 				}
 				if !os.IsNotExist(err) {
@@ -97,6 +107,10 @@ func Vendor(ctx context.Context, cln *client.Client, mod *parser.Module, targets
 				return err
 			}
 
+			mu.Lock()
+			vendored[info.Digest] = true
+			mu.Unlock()
+
 			var filename string
 			switch info.Ret.Kind() {
 			case parser.Filesystem:
@@ -145,5 +159,42 @@ func Vendor(ctx context.Context, cln *client.Client, mod *parser.Module, targets
 		}
 	}
 
-	return nil
+	return updateSum(SumPath, root, vendored)
+}
+
+// updateSum records the checksums of modules that were vendored, and of
+// modules that were already vendored but not recorded yet. Modules that are no
+// longer vendored are removed from the sum file.
+func updateSum(sumPath, root string, vendored map[digest.Digest]bool) error {
+	sum, err := ReadSum(sumPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		sum = make(Sum)
+	}
+
+	for dgst, written := range vendored {
+		if _, ok := sum[dgst]; ok && !written {
+			continue
+		}
+
+		sum[dgst], err = ChecksumDir(VendorPath(root, dgst))
+		if err != nil {
+			return err
+		}
+	}
+
+	for dgst := range sum {
+		_, err = os.Stat(VendorPath(root, dgst))
+		if err == nil {
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		delete(sum, dgst)
+	}
+
+	return WriteSum(sumPath, sum)
 }
@@ -0,0 +1,116 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/moby/buildkit/client"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/openllb/hlb/parser"
+)
+
+// Verify checks that the vendored modules match the checksums in the sum file,
+// and that the remote imports of a module still resolve to the content that
+// was vendored. It returns a message for every module that failed
+// verification.
+func Verify(ctx context.Context, cln *client.Client, mod *parser.Module) ([]string, error) {
+	return verify(ctx, cln, mod, ModulesPath, SumPath)
+}
+
+func verify(ctx context.Context, cln *client.Client, mod *parser.Module, root, sumPath string) ([]string, error) {
+	sum, err := ReadSum(sumPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("missing %s, run `hlb module vendor` to create it", sumPath)
+		}
+		return nil, err
+	}
+
+	var failures []string
+	for dgst, expected := range sum {
+		vp := VendorPath(root, dgst)
+		actual, err := ChecksumDir(vp)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+			failures = append(failures, fmt.Sprintf("%s: missing from vendor", vp))
+			continue
+		}
+		if actual != expected {
+			failures = append(failures, fmt.Sprintf("%s: vendored files have checksum %s, expected %s", vp, actual, expected))
+		}
+	}
+
+	matches, err := filepath.Glob(filepath.Join(root, "*/*/*"))
+	if err != nil {
+		return nil, err
+	}
+
+	vendored := make(map[string]struct{})
+	for dgst := range sum {
+		vendored[VendorPath(root, dgst)] = struct{}{}
+	}
+	for _, match := range matches {
+		if _, ok := vendored[match]; !ok {
+			failures = append(failures, fmt.Sprintf("%s: not in %s", match, sumPath))
+		}
+	}
+
+	// Resolve the imports remotely, and compare the files that would be
+	// vendored with the recorded checksums.
+	res, err := NewLocalResolved(mod)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var (
+		files = make(map[digest.Digest]map[string][]byte)
+		mu    sync.Mutex
+	)
+	err = ResolveGraph(ctx, cln, &remoteResolver{cln, root}, res, mod, func(info VisitInfo) error {
+		if info.Digest == "" {
+			return nil
+		}
+
+		filename := ModuleFilename
+		if info.Ret.Kind() == parser.String {
+			var err error
+			filename, err = info.Ret.String()
+			if err != nil {
+				return err
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if files[info.Digest] == nil {
+			files[info.Digest] = make(map[string][]byte)
+		}
+		files[info.Digest][filepath.ToSlash(filename)] = []byte(info.Import.String())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for dgst, files := range files {
+		expected, ok := sum[dgst]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s: remote module is not in %s, run `hlb module vendor` to add it", VendorPath(root, dgst), sumPath))
+			continue
+		}
+		actual := Checksum(files)
+		if actual != expected {
+			failures = append(failures, fmt.Sprintf("%s: remote module resolves to checksum %s, expected %s", VendorPath(root, dgst), actual, expected))
+		}
+	}
+
+	sort.Strings(failures)
+	return failures, nil
+}
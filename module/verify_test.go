@@ -0,0 +1,212 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lithammer/dedent"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/openllb/hlb/builtin"
+	"github.com/openllb/hlb/checker"
+	"github.com/openllb/hlb/diagnostic"
+	"github.com/openllb/hlb/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hlb-verify")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	moduleDir := filepath.Join(dir, "module")
+	err = os.MkdirAll(moduleDir, 0755)
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(moduleDir, ModuleFilename), []byte(dedent.Dedent(`
+	export build
+	fs build() {}
+	`)), 0644)
+	require.NoError(t, err)
+
+	ref := fmt.Sprintf("oci-layout://%s:v1", filepath.Join(dir, "layout"))
+	_, err = Publish(context.Background(), moduleDir, ref)
+	require.NoError(t, err)
+
+	ctx := diagnostic.WithSources(context.Background(), builtin.Sources())
+	mod, err := parser.Parse(ctx, strings.NewReader(dedent.Dedent(fmt.Sprintf(`
+	import remote from oci(%q)
+	fs default() {
+		remote.build
+	}
+	`, ref))))
+	require.NoError(t, err)
+
+	err = checker.SemanticPass(mod)
+	require.NoError(t, err)
+
+	err = checker.Check(mod)
+	require.NoError(t, err)
+
+	var (
+		root    = filepath.Join(dir, "modules")
+		sumPath = filepath.Join(dir, "hlb.sum")
+	)
+
+	_, err = verify(ctx, nil, mod, root, sumPath)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "missing "+sumPath)
+
+	err = WriteSum(sumPath, Sum{})
+	require.NoError(t, err)
+
+	failures, err := verify(ctx, nil, mod, root, sumPath)
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	require.Contains(t, failures[0], "remote module is not in "+sumPath)
+
+	// Vendor the remote import the same way `hlb module vendor` does.
+	res, err := NewLocalResolved(mod)
+	require.NoError(t, err)
+	defer res.Close()
+
+	var (
+		dgst     digest.Digest
+		vendored = make(map[digest.Digest]bool)
+		mu       sync.Mutex
+	)
+	err = ResolveGraph(ctx, nil, &remoteResolver{nil, root}, res, mod, func(info VisitInfo) error {
+		if info.Digest == "" {
+			return nil
+		}
+
+		vp := VendorPath(root, info.Digest)
+		err := os.MkdirAll(vp, 0700)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		dgst = info.Digest
+		vendored[dgst] = true
+		mu.Unlock()
+		return ioutil.WriteFile(filepath.Join(vp, ModuleFilename), []byte(info.Import.String()), 0644)
+	})
+	require.NoError(t, err)
+	require.Len(t, vendored, 1)
+
+	err = updateSum(sumPath, root, vendored)
+	require.NoError(t, err)
+
+	failures, err = verify(ctx, nil, mod, root, sumPath)
+	require.NoError(t, err)
+	require.Empty(t, failures)
+
+	unsummed := VendorPath(root, digest.FromString("unsummed"))
+	err = os.MkdirAll(unsummed, 0700)
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(VendorPath(root, dgst), ModuleFilename), []byte("fs tampered() {}\n"), 0644)
+	require.NoError(t, err)
+
+	checksum, err := ChecksumDir(VendorPath(root, dgst))
+	require.NoError(t, err)
+
+	sum, err := ReadSum(sumPath)
+	require.NoError(t, err)
+
+	failures, err = verify(ctx, nil, mod, root, sumPath)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		fmt.Sprintf("%s: not in %s", unsummed, sumPath),
+		fmt.Sprintf("%s: vendored files have checksum %s, expected %s", VendorPath(root, dgst), checksum, sum[dgst]),
+	}, failures)
+
+	err = os.RemoveAll(root)
+	require.NoError(t, err)
+
+	failures, err = verify(ctx, nil, mod, root, sumPath)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		fmt.Sprintf("%s: missing from vendor", VendorPath(root, dgst)),
+	}, failures)
+}
+
+func TestUpdateSum(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hlb-sum")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var (
+		root    = filepath.Join(dir, "modules")
+		sumPath = filepath.Join(dir, "hlb.sum")
+		written = digest.FromString("written")
+		skipped = digest.FromString("skipped")
+		removed = digest.FromString("removed")
+	)
+
+	writeModule := func(dgst digest.Digest, content string) digest.Digest {
+		vp := VendorPath(root, dgst)
+		err := os.MkdirAll(vp, 0700)
+		require.NoError(t, err)
+
+		err = ioutil.WriteFile(filepath.Join(vp, ModuleFilename), []byte(content), 0644)
+		require.NoError(t, err)
+
+		checksum, err := ChecksumDir(vp)
+		require.NoError(t, err)
+		return checksum
+	}
+
+	// Without a sum file, every vendored module is recorded even if it was
+	// already vendored.
+	writtenSum := writeModule(written, "fs written() {}\n")
+	skippedSum := writeModule(skipped, "fs skipped() {}\n")
+	removedSum := writeModule(removed, "fs removed() {}\n")
+
+	err = updateSum(sumPath, root, map[digest.Digest]bool{
+		written: true,
+		skipped: false,
+		removed: false,
+	})
+	require.NoError(t, err)
+
+	sum, err := ReadSum(sumPath)
+	require.NoError(t, err)
+	require.Equal(t, Sum{
+		written: writtenSum,
+		skipped: skippedSum,
+		removed: removedSum,
+	}, sum)
+
+	// Modules that are rewritten get a new checksum, modules that were
+	// skipped keep their recorded checksum, and modules that are no longer
+	// vendored are dropped.
+	writtenSum = writeModule(written, "fs rewritten() {}\n")
+	writeModule(skipped, "fs tampered() {}\n")
+
+	err = os.RemoveAll(VendorPath(root, removed))
+	require.NoError(t, err)
+
+	err = updateSum(sumPath, root, map[digest.Digest]bool{
+		written: true,
+		skipped: false,
+	})
+	require.NoError(t, err)
+
+	sum, err = ReadSum(sumPath)
+	require.NoError(t, err)
+	require.Equal(t, Sum{
+		written: writtenSum,
+		skipped: skippedSum,
+	}, sum)
+}
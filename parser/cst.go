@@ -15,7 +15,7 @@ var (
 	// Lexer lexes HLB into tokens for the parser.
 	Lexer = lexer.Must(stateful.New(stateful.Rules{
 		"Root": {
-			{"Keyword", `\b(import|export|with|as)\b`, nil},
+			{"Keyword", `\b(import|export|with|as|if|else|for|in)\b`, nil},
 			{"Numeric", `\b(0(b|B|o|O|x|X)[a-fA-F0-9]+)\b`, nil},
 			{"Decimal", `\b(0|[1-9][0-9]*)\b`, nil},
 			{"Bool", `\b(true|false)\b`, nil},
@@ -25,6 +25,8 @@ var (
 			{"RawHeredoc", "<<[-~]?`(\\w+)`", stateful.Push("RawHeredoc")},
 			{"Block", `{`, stateful.Push("Block")},
 			{"Paren", `\(`, stateful.Push("Paren")},
+			{"List", `\[\]`, nil},
+			{"Bracket", `\[`, stateful.Push("Bracket")},
 			{"Ident", `[\w:]+`, stateful.Push("Reference")},
 			{"Operator", `;`, nil},
 			{"Newline", `\n`, nil},
@@ -71,6 +73,11 @@ var (
 			{"Delimit", `,`, nil},
 			stateful.Include("Root"),
 		},
+		"Bracket": {
+			{"BracketEnd", `\]`, stateful.Pop()},
+			{"Delimit", `,`, nil},
+			stateful.Include("Root"),
+		},
 	}))
 
 	// Parser parses HLB into a concrete syntax tree rooted from a Module.
@@ -135,6 +142,21 @@ const (
 	Option     Kind = "option"
 )
 
+// ListOf returns the kind of a list with elements of the given kind.
+func ListOf(kind Kind) Kind {
+	return Kind(fmt.Sprintf("[]%s", kind))
+}
+
+// IsList returns true if the kind is a list.
+func (k Kind) IsList() bool {
+	return strings.HasPrefix(string(k), "[]")
+}
+
+// Elem returns the kind of elements of a list kind.
+func (k Kind) Elem() Kind {
+	return Kind(strings.TrimPrefix(string(k), "[]"))
+}
+
 func (k Kind) Primary() Kind {
 	parts := splitKind(k)
 	return Kind(parts[0])
@@ -284,7 +306,7 @@ func NewFuncDecl(kind Kind, name string, params []*Field, effects []*Field, stmt
 // Type represents an object type.
 type Type struct {
 	Mixin
-	Kind Kind `parser:"@Ident"`
+	Kind Kind `parser:"@List? @Ident"`
 }
 
 func NewType(kind Kind) *Type {
@@ -422,7 +444,7 @@ func (bs *BlockStmt) Stmts() []*Stmt {
 	}
 	var stmts []*Stmt
 	for _, stmt := range bs.List {
-		if stmt.Call != nil || stmt.Expr != nil {
+		if stmt.Call != nil || stmt.If != nil || stmt.For != nil || stmt.Expr != nil {
 			stmts = append(stmts, stmt)
 		}
 	}
@@ -433,6 +455,8 @@ func (bs *BlockStmt) Stmts() []*Stmt {
 type Stmt struct {
 	Mixin
 	Call     *CallStmt     `parser:"( @@"`
+	If       *IfStmt       `parser:"| @@"`
+	For      *ForStmt      `parser:"| @@"`
 	Expr     *ExprStmt     `parser:"| @@"`
 	Newline  *Newline      `parser:"| @@"`
 	Comments *CommentGroup `parser:"| @@ )"`
@@ -578,6 +602,112 @@ type As struct {
 	Text string `parser:"@'as'"`
 }
 
+// IfStmt represents a conditional statement. The body is only evaluated when
+// the condition is true, otherwise the optional ElseClause is evaluated.
+type IfStmt struct {
+	Mixin
+	If        *If         `parser:"@@"`
+	Condition *Condition  `parser:"@@"`
+	Body      *BlockStmt  `parser:"@@"`
+	Else      *ElseClause `parser:"@@?"`
+	Terminate *StmtEnd    `parser:"@@?"`
+}
+
+// Blocks returns the blocks of every branch in the if-else chain.
+func (is *IfStmt) Blocks() []*BlockStmt {
+	blocks := []*BlockStmt{is.Body}
+	if is.Else != nil {
+		switch {
+		case is.Else.If != nil:
+			blocks = append(blocks, is.Else.If.Blocks()...)
+		case is.Else.Body != nil:
+			blocks = append(blocks, is.Else.Body)
+		}
+	}
+	return blocks
+}
+
+// If represents the keyword "if".
+type If struct {
+	Mixin
+	Text string `parser:"@'if'"`
+}
+
+// Condition represents the expression of an IfStmt. Function literals are not
+// allowed so that the following brace always begins the body.
+type Condition struct {
+	Mixin
+	BasicLit *BasicLit `parser:"( @@"`
+	CallExpr *CallExpr `parser:"| @@ )"`
+}
+
+// Expr returns the condition as an expression.
+func (c *Condition) Expr() *Expr {
+	return &Expr{
+		Mixin:    c.Mixin,
+		BasicLit: c.BasicLit,
+		CallExpr: c.CallExpr,
+	}
+}
+
+// ElseClause represents the alternative branch of an IfStmt, which is either
+// another IfStmt or a block.
+type ElseClause struct {
+	Mixin
+	Else *Else      `parser:"@@"`
+	If   *IfStmt    `parser:"( @@"`
+	Body *BlockStmt `parser:"| @@ )"`
+}
+
+// Else represents the keyword "else".
+type Else struct {
+	Mixin
+	Text string `parser:"@'else'"`
+}
+
+// ForStmt represents a loop that evaluates its body once for every element of
+// a list, binding the element to the loop variable.
+type ForStmt struct {
+	Mixin
+	Field     *Field
+	For       *For       `parser:"@@"`
+	Type      *Type      `parser:"@@"`
+	Name      *Ident     `parser:"@@"`
+	In        *In        `parser:"@@"`
+	Iterable  *Iterable  `parser:"@@"`
+	Body      *BlockStmt `parser:"@@"`
+	Terminate *StmtEnd   `parser:"@@?"`
+}
+
+// For represents the keyword "for".
+type For struct {
+	Mixin
+	Text string `parser:"@'for'"`
+}
+
+// In represents the keyword "in".
+type In struct {
+	Mixin
+	Text string `parser:"@'in'"`
+}
+
+// Iterable represents the list expression of a ForStmt. Function literals are
+// not allowed so that the following brace always begins the body.
+type Iterable struct {
+	Mixin
+	ListLit  *ListLit  `parser:"( @@"`
+	CallExpr *CallExpr `parser:"| @@ )"`
+}
+
+// Expr returns the iterable as an expression.
+func (i *Iterable) Expr() *Expr {
+	return &Expr{
+		Mixin:    i.Mixin,
+		ListLit:  i.ListLit,
+		CallExpr: i.CallExpr,
+	}
+}
+
 // ExprStmt represents a statement returning an expression.
 type ExprStmt struct {
 	Mixin
@@ -598,6 +728,7 @@ type Expr struct {
 	Mixin
 	FuncLit  *FuncLit  `parser:"( @@"`
 	BasicLit *BasicLit `parser:"| @@"`
+	ListLit  *ListLit  `parser:"| @@"`
 	CallExpr *CallExpr `parser:"| @@ )"`
 }
 
@@ -607,6 +738,8 @@ func (e *Expr) Kind() Kind {
 		return e.FuncLit.Kind()
 	case e.BasicLit != nil:
 		return e.BasicLit.Kind()
+	case e.ListLit != nil:
+		return e.ListLit.Kind()
 	}
 	return None
 }
@@ -646,7 +779,7 @@ type BasicLit struct {
 	Mixin
 	Decimal    *int          `parser:"( @Decimal"`
 	Numeric    *NumericLit   `parser:"| @Numeric"`
-	Bool       *bool         `parser:"| @Bool"`
+	Bool       *BoolLit      `parser:"| @Bool"`
 	Str        *StringLit    `parser:"| @@"`
 	RawString  *RawStringLit `parser:"| @@"`
 	Heredoc    *Heredoc      `parser:"| @@"`
@@ -666,6 +799,42 @@ func (bl *BasicLit) Kind() Kind {
 	return None
 }
 
+// ListLit represents a list of expressions enclosed in brackets. The elements
+// of the list take the element type of the list it is used as. An empty list
+// without spaces is lexed as the "[]" of a list type.
+type ListLit struct {
+	Mixin
+	Start     *OpenBracket  `parser:"( @@"`
+	Fields    []*ExprField  `parser:"@@*"`
+	Terminate *CloseBracket `parser:"@@"`
+	Empty     *string       `parser:"| @List )"`
+}
+
+// Elems returns the expressions of the elements of the list.
+func (ll *ListLit) Elems() []*Expr {
+	var elems []*Expr
+	for _, field := range ll.Fields {
+		if field.Expr != nil {
+			elems = append(elems, field.Expr)
+		}
+	}
+	return elems
+}
+
+// Kind returns the type of the list literal as far as it is known from its
+// first element, or None otherwise.
+func (ll *ListLit) Kind() Kind {
+	elems := ll.Elems()
+	if len(elems) == 0 {
+		return None
+	}
+	kind := elems[0].Kind()
+	if kind == None || kind.IsList() {
+		return kind
+	}
+	return ListOf(kind)
+}
+
 // NumericLit represents a number literal with a non-decimal base.
 type NumericLit struct {
 	Mixin
@@ -697,6 +866,21 @@ func (nl *NumericLit) Capture(tokens []string) error {
 	return err
 }
 
+// BoolLit represents a boolean literal.
+type BoolLit struct {
+	Mixin
+	Value bool
+}
+
+func (bl *BoolLit) Position() lexer.Position { return bl.Pos }
+func (bl *BoolLit) End() lexer.Position      { return diagnostic.Offset(bl.Pos, len(bl.String()), 0) }
+
+func (bl *BoolLit) Capture(tokens []string) error {
+	var err error
+	bl.Value, err = strconv.ParseBool(tokens[0])
+	return err
+}
+
 // StringLit represents a string literal that can contain escaped characters,
 // interpolated expressions and regular string characters.
 type StringLit struct {
@@ -818,7 +1002,7 @@ func NewNumericExpr(v int64, base int) *Expr {
 func NewBoolExpr(v bool) *Expr {
 	return &Expr{
 		BasicLit: &BasicLit{
-			Bool: &v,
+			Bool: &BoolLit{Value: v},
 		},
 	}
 }
@@ -929,6 +1113,18 @@ type CloseParen struct {
 	Text string `parser:"@ParenEnd"`
 }
 
+// OpenBracket represents the "[" bracket.
+type OpenBracket struct {
+	Mixin
+	Text string `parser:"@Bracket"`
+}
+
+// CloseBracket represents the "]" bracket.
+type CloseBracket struct {
+	Mixin
+	Text string `parser:"@BracketEnd"`
+}
+
 // OpenBrace represents the "{" brace.
 type OpenBrace struct {
 	Mixin
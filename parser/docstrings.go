@@ -1,7 +1,8 @@
 package parser
 
 // AssignDocStrings assigns the comment group immediately before a function
-// declaration as the function's doc string.
+// declaration as the function's doc string. Comments include their newline, so
+// a comment group ends on the line of the node it documents.
 func AssignDocStrings(mod *Module) {
 	var (
 		lastCG *CommentGroup
@@ -14,7 +15,7 @@ func AssignDocStrings(mod *Module) {
 			}
 		},
 		func(fun *FuncDecl) {
-			if lastCG != nil && lastCG.End().Line == fun.Pos.Line-1 {
+			if lastCG != nil && lastCG.End().Line == fun.Pos.Line {
 				fun.Doc = lastCG
 			}
 
@@ -24,7 +25,7 @@ func AssignDocStrings(mod *Module) {
 						lastCG = cg
 					},
 					func(call *CallStmt) {
-						if lastCG != nil && lastCG.End().Line == call.Pos.Line-1 {
+						if lastCG != nil && lastCG.End().Line == call.Pos.Line {
 							call.Doc = lastCG
 						}
 					},
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"io"
 
+	"github.com/alecthomas/participle"
 	"github.com/alecthomas/participle/lexer"
 	"github.com/openllb/hlb/diagnostic"
 	"github.com/openllb/hlb/pkg/filebuffer"
+	"github.com/openllb/hlb/pkg/tracing"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -18,6 +20,9 @@ func Parse(ctx context.Context, r io.Reader) (*Module, error) {
 	}
 	r = &NewlinedReader{Reader: r}
 
+	_, span := tracing.Start(ctx, "parse", tracing.String("hlb.filename", name))
+	defer span.End()
+
 	mod := &Module{}
 	defer AssignDocStrings(mod)
 
@@ -32,7 +37,37 @@ func Parse(ctx context.Context, r io.Reader) (*Module, error) {
 		}()
 	}
 
-	return mod, Parser.Parse(name, r, mod)
+	err := Parser.Parse(name, r, mod)
+	if err != nil {
+		err = withKeywordHint(err)
+	}
+	span.SetError(err)
+	return mod, err
+}
+
+// withKeywordHint annotates a parse error on a keyword added after names
+// could already be declared with it, so that modules declaring them know why
+// they no longer parse.
+func withKeywordHint(err error) error {
+	var uerr participle.UnexpectedTokenError
+	if !errors.As(err, &uerr) {
+		return err
+	}
+
+	keyword := uerr.Unexpected.Value
+	switch keyword {
+	case "if", "else", "for", "in":
+	default:
+		return err
+	}
+
+	start := uerr.Unexpected.Pos
+	end := diagnostic.Offset(start, len(keyword), 0)
+	return diagnostic.WithError(
+		errors.New(uerr.Message()),
+		start,
+		diagnostic.Spanf(diagnostic.Primary, start, end, "`%s` is a keyword and cannot be used as a name", keyword),
+	)
 }
 
 func ParseMultiple(ctx context.Context, rs []io.Reader) ([]*Module, error) {
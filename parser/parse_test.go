@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/openllb/hlb/diagnostic"
 	"github.com/stretchr/testify/require"
 )
 
@@ -113,3 +114,95 @@ func TestParse(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, file)
 }
+
+func TestAssignDocStrings(t *testing.T) {
+	t.Parallel()
+	mod, err := Parse(context.Background(), strings.NewReader(`
+# Documented.
+fs foo() {
+	# Documented call.
+	image "alpine"
+}
+
+# Detached.
+
+fs bar()
+`))
+	require.NoError(t, err)
+
+	docs := make(map[string]string)
+	Match(mod, MatchOpts{},
+		func(fun *FuncDecl) {
+			if fun.Doc != nil {
+				docs[fun.Name.Text] = fun.Doc.String()
+			}
+		},
+		func(call *CallStmt) {
+			if call.Doc != nil {
+				docs[call.Name.String()] = call.Doc.String()
+			}
+		},
+	)
+	require.Equal(t, "# Documented.\n", docs["foo"])
+	require.Equal(t, "# Documented call.\n", docs["image"])
+	require.NotContains(t, docs, "bar")
+}
+
+func TestExportedNames(t *testing.T) {
+	t.Parallel()
+	mod, err := Parse(context.Background(), strings.NewReader(`
+export foo
+export bar
+
+fs foo()
+fs bar()
+fs baz()
+`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo", "bar"}, ExportedNames(mod))
+}
+
+func TestParseEmptyList(t *testing.T) {
+	t.Parallel()
+	mod, err := Parse(context.Background(), strings.NewReader(`
+fs foo([]string flags) {
+	for string flag in [] { scratch; }
+	build []
+}
+`))
+	require.NoError(t, err)
+
+	// "[]" is the prefix of the list type of the parameter, and an empty list
+	// literal when used as an expression.
+	fun := mod.Decls[1].Func
+	require.Equal(t, ListOf(String), fun.Params.Fields()[0].Kind())
+
+	var lists []*ListLit
+	Match(mod, MatchOpts{},
+		func(ll *ListLit) {
+			lists = append(lists, ll)
+		},
+	)
+	require.Len(t, lists, 2)
+	for _, ll := range lists {
+		require.Empty(t, ll.Elems())
+		require.Equal(t, None, ll.Kind())
+		require.Equal(t, "[]", ll.String())
+	}
+}
+
+func TestParseKeywordName(t *testing.T) {
+	t.Parallel()
+	_, err := Parse(context.Background(), strings.NewReader(`
+fs in() { scratch; }
+`))
+	require.Error(t, err)
+
+	spans := diagnostic.Spans(err)
+	require.Len(t, spans, 1)
+	require.Equal(t, "unexpected token \"in\" (expected <ident>)", spans[0].Err.Error())
+	require.Equal(t, "`in` is a keyword and cannot be used as a name", spans[0].Spans[0].Message)
+	require.Equal(t, 2, spans[0].Spans[0].Start.Line)
+	require.Equal(t, 4, spans[0].Spans[0].Start.Column)
+	require.Equal(t, 6, spans[0].Spans[0].End.Column)
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -9,11 +10,17 @@ import (
 )
 
 type UnparseInfo struct {
-	Indent    int
-	NoNewline bool
-	NoStmtEnd bool
+	Indent      int
+	NoNewline   bool
+	NoStmtEnd   bool
+	SortImports bool
+	Width       int
+	Column      int
 }
 
+// TabWidth is the number of columns a tab is counted as when wrapping lists.
+const TabWidth = 4
+
 type UnparseOption func(*UnparseInfo)
 
 func WithIndent(depth int) UnparseOption {
@@ -34,16 +41,55 @@ func WithNoStmtEnd() UnparseOption {
 	}
 }
 
+// WithSortImports sorts consecutive import declarations by name, grouping
+// remote modules before local files.
+func WithSortImports() UnparseOption {
+	return func(info *UnparseInfo) {
+		info.SortImports = true
+	}
+}
+
+// WithWidth wraps parenthesized lists, such as parameters and arguments of
+// call expressions, with an element per line when they would exceed the
+// width. A width of zero never wraps.
+func WithWidth(width int) UnparseOption {
+	return func(info *UnparseInfo) {
+		info.Width = width
+	}
+}
+
+// withColumn sets the column a node starts at, so that lists can be wrapped.
+func withColumn(column int) UnparseOption {
+	return func(info *UnparseInfo) {
+		info.Column = column
+	}
+}
+
 func (m *Module) String() string { return m.Unparse() }
 
 func (m *Module) Unparse(opts ...UnparseOption) string {
+	var info UnparseInfo
+	for _, opt := range opts {
+		opt(&info)
+	}
+
+	var strs []string
+	for i := 0; i < len(m.Decls); i++ {
+		if info.SortImports && (m.Decls[i].Import != nil || isImportDoc(m.Decls, i)) {
+			var str string
+			str, i = unparseImports(m.Decls, i, opts...)
+			strs = append(strs, str)
+			continue
+		}
+		strs = append(strs, m.Decls[i].Unparse(opts...))
+	}
+
 	skipNewlines := true
 
 	var decls []string
 	var prevDecl string
 
-	for _, decl := range m.Decls {
-		str := decl.Unparse(opts...)
+	for _, str := range strs {
 
 		// Skip consecutive new lines.
 		if len(str) == 1 {
@@ -83,6 +129,106 @@ func (m *Module) Unparse(opts ...UnparseOption) string {
 	return fmt.Sprintf("%s\n", strings.TrimSpace(module))
 }
 
+// unparseImports unparses the consecutive import declarations starting at
+// decls[start] sorted by name, with remote modules grouped before local files.
+// Doc comments and trailing comments stay with their import. It returns the
+// index of the last declaration unparsed.
+func unparseImports(decls []*Decl, start int, opts ...UnparseOption) (string, int) {
+	type unit struct {
+		doc     string
+		id      *ImportDecl
+		comment string
+	}
+
+	var (
+		units []*unit
+		doc   string
+		end   = start
+	)
+loop:
+	for i := start; i < len(decls); i++ {
+		decl := decls[i]
+		switch {
+		case decl.Import != nil:
+			units = append(units, &unit{doc: doc, id: decl.Import})
+			doc = ""
+			end = i
+			continue
+		case decl.Newline != nil:
+			continue
+		case decl.Comments != nil:
+			// A comment group may start with the trailing comment of the
+			// previous import and end with the doc comment of the next.
+			var (
+				last     *unit
+				comments = decl.Comments.List
+			)
+			if len(units) > 0 {
+				last = units[len(units)-1]
+			}
+			trailing := last != nil && last.comment == "" && comments[0].Pos.Line == last.id.Pos.Line
+			if trailing {
+				comments = comments[1:]
+			}
+			isDoc := len(comments) > 0 && isImportDoc(decls, i)
+			if len(comments) > 0 && !isDoc {
+				break loop
+			}
+
+			if trailing {
+				last.comment = strings.TrimRight(decl.Comments.List[0].Unparse(opts...), "\n")
+				end = i
+			}
+			if isDoc {
+				doc = strings.TrimRight((&CommentGroup{List: comments}).Unparse(opts...), "\n")
+			}
+			continue
+		}
+		break loop
+	}
+
+	var groups [2][]*unit
+	for _, u := range units {
+		group := 1
+		if u.id.Expr != nil && (u.id.Expr.BasicLit == nil || (u.id.Expr.BasicLit.Str == nil && u.id.Expr.BasicLit.RawString == nil)) {
+			group = 0
+		}
+		groups[group] = append(groups[group], u)
+	}
+
+	var blocks []string
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].id.Name.Text < group[j].id.Name.Text
+		})
+
+		var lines []string
+		for _, u := range group {
+			line := u.id.Unparse(opts...)
+			if u.comment != "" {
+				line = fmt.Sprintf("%s %s", line, u.comment)
+			}
+			if u.doc != "" {
+				line = fmt.Sprintf("%s\n%s", u.doc, line)
+			}
+			lines = append(lines, line)
+		}
+		blocks = append(blocks, strings.Join(lines, "\n"))
+	}
+	return strings.Join(blocks, "\n\n"), end
+}
+
+// isImportDoc returns true if decls[i] is the doc comment of the import
+// declaration that follows it. Comments include their newline, so a doc
+// comment ends on the line of its import.
+func isImportDoc(decls []*Decl, i int) bool {
+	return decls[i].Comments != nil && i+1 < len(decls) && decls[i+1].Import != nil &&
+		decls[i].Comments.End().Line == decls[i+1].Import.Pos.Line
+}
+
 func (d *Decl) String() string { return d.Unparse() }
 
 func (d *Decl) Unparse(opts ...UnparseOption) string {
@@ -143,7 +289,8 @@ func (fd *FuncDecl) String() string { return fd.Unparse() }
 func (fd *FuncDecl) Unparse(opts ...UnparseOption) string {
 	params := ""
 	if fd.Params != nil {
-		params = fd.Params.Unparse(opts...)
+		column := endColumn(0, fmt.Sprintf("%s %s", fd.Type, fd.Name))
+		params = fd.Params.Unparse(append(opts, withColumn(column))...)
 	}
 
 	effects := ""
@@ -300,6 +447,10 @@ func (s *Stmt) Unparse(opts ...UnparseOption) string {
 	switch {
 	case s.Call != nil:
 		return s.Call.Unparse(opts...)
+	case s.If != nil:
+		return s.If.Unparse(opts...)
+	case s.For != nil:
+		return s.For.Unparse(opts...)
 	case s.Expr != nil:
 		return s.Expr.Unparse(opts...)
 	case s.Newline != nil:
@@ -313,11 +464,19 @@ func (s *Stmt) Unparse(opts ...UnparseOption) string {
 func (cs *CallStmt) String() string { return cs.Unparse() }
 
 func (cs *CallStmt) Unparse(opts ...UnparseOption) string {
+	var info UnparseInfo
+	for _, opt := range opts {
+		opt(&info)
+	}
+
 	args := ""
 	if len(cs.Args) > 0 {
 		var exprs []string
+		column := endColumn(info.Indent*TabWidth, cs.Name.String())
 		for _, expr := range cs.Args {
-			exprs = append(exprs, expr.Unparse(opts...))
+			str := expr.Unparse(append(opts, withColumn(column+1))...)
+			exprs = append(exprs, str)
+			column = endColumn(column+1, str)
 		}
 		args = fmt.Sprintf(" %s", strings.Join(exprs, " "))
 	}
@@ -404,10 +563,110 @@ func (a *As) Unparse(opts ...UnparseOption) string {
 	return a.Text
 }
 
+func (is *IfStmt) String() string { return is.Unparse() }
+
+func (is *IfStmt) Unparse(opts ...UnparseOption) string {
+	elseClause := ""
+	if is.Else != nil {
+		elseClause = fmt.Sprintf(" %s", is.Else.Unparse(opts...))
+	}
+
+	end := ""
+	if is.Terminate != nil {
+		end = is.Terminate.Unparse(opts...)
+	}
+
+	return fmt.Sprintf("%s %s %s%s%s", is.If.Unparse(opts...), is.Condition.Unparse(opts...), is.Body.Unparse(opts...), elseClause, end)
+}
+
+func (i *If) String() string { return i.Unparse() }
+
+func (i *If) Unparse(opts ...UnparseOption) string {
+	return i.Text
+}
+
+func (c *Condition) String() string { return c.Unparse() }
+
+func (c *Condition) Unparse(opts ...UnparseOption) string {
+	switch {
+	case c.BasicLit != nil:
+		return c.BasicLit.Unparse(opts...)
+	case c.CallExpr != nil:
+		return c.CallExpr.Unparse(opts...)
+	}
+	return ""
+}
+
+func (ec *ElseClause) String() string { return ec.Unparse() }
+
+func (ec *ElseClause) Unparse(opts ...UnparseOption) string {
+	switch {
+	case ec.If != nil:
+		return fmt.Sprintf("%s %s", ec.Else.Unparse(opts...), ec.If.Unparse(opts...))
+	case ec.Body != nil:
+		return fmt.Sprintf("%s %s", ec.Else.Unparse(opts...), ec.Body.Unparse(opts...))
+	}
+	return ""
+}
+
+func (e *Else) String() string { return e.Unparse() }
+
+func (e *Else) Unparse(opts ...UnparseOption) string {
+	return e.Text
+}
+
+func (fs *ForStmt) String() string { return fs.Unparse() }
+
+func (fs *ForStmt) Unparse(opts ...UnparseOption) string {
+	end := ""
+	if fs.Terminate != nil {
+		end = fs.Terminate.Unparse(opts...)
+	}
+
+	return fmt.Sprintf(
+		"%s %s %s %s %s %s%s",
+		fs.For.Unparse(opts...),
+		fs.Type.Unparse(opts...),
+		fs.Name.Unparse(opts...),
+		fs.In.Unparse(opts...),
+		fs.Iterable.Unparse(opts...),
+		fs.Body.Unparse(opts...),
+		end,
+	)
+}
+
+func (f *For) String() string { return f.Unparse() }
+
+func (f *For) Unparse(opts ...UnparseOption) string {
+	return f.Text
+}
+
+func (i *In) String() string { return i.Unparse() }
+
+func (i *In) Unparse(opts ...UnparseOption) string {
+	return i.Text
+}
+
+func (i *Iterable) String() string { return i.Unparse() }
+
+func (i *Iterable) Unparse(opts ...UnparseOption) string {
+	switch {
+	case i.ListLit != nil:
+		return i.ListLit.Unparse(opts...)
+	case i.CallExpr != nil:
+		return i.CallExpr.Unparse(opts...)
+	}
+	return ""
+}
+
 func (es *ExprStmt) String() string { return es.Unparse() }
 
 func (es *ExprStmt) Unparse(opts ...UnparseOption) string {
-	return fmt.Sprintf("%s%s", es.Expr.Unparse(opts...), es.Terminate.Unparse(opts...))
+	end := ""
+	if es.Terminate != nil {
+		end = es.Terminate.Unparse(opts...)
+	}
+	return fmt.Sprintf("%s%s", es.Expr.Unparse(opts...), end)
 }
 
 func (e *Expr) String() string { return e.Unparse() }
@@ -418,6 +677,8 @@ func (e *Expr) Unparse(opts ...UnparseOption) string {
 		return e.FuncLit.Unparse(opts...)
 	case e.BasicLit != nil:
 		return e.BasicLit.Unparse(opts...)
+	case e.ListLit != nil:
+		return e.ListLit.Unparse(opts...)
 	case e.CallExpr != nil:
 		return e.CallExpr.Unparse(opts...)
 	}
@@ -439,7 +700,7 @@ func (bl *BasicLit) Unparse(opts ...UnparseOption) string {
 	case bl.Numeric != nil:
 		return bl.Numeric.String()
 	case bl.Bool != nil:
-		return strconv.FormatBool(*bl.Bool)
+		return bl.Bool.String()
 	case bl.Str != nil:
 		return bl.Str.Unparse(opts...)
 	case bl.RawString != nil:
@@ -452,6 +713,19 @@ func (bl *BasicLit) Unparse(opts ...UnparseOption) string {
 	return ""
 }
 
+func (ll *ListLit) String() string { return ll.Unparse() }
+
+func (ll *ListLit) Unparse(opts ...UnparseOption) string {
+	var list []Node
+	for _, field := range ll.Fields {
+		list = append(list, field)
+	}
+	if len(list) == 0 {
+		return "[]"
+	}
+	return unparseDelimitedList("[", "]", list, opts...)
+}
+
 func (nl *NumericLit) String() string { return nl.Unparse() }
 
 func (nl *NumericLit) Unparse(opts ...UnparseOption) string {
@@ -466,6 +740,12 @@ func (nl *NumericLit) Unparse(opts ...UnparseOption) string {
 	return ""
 }
 
+func (bl *BoolLit) String() string { return bl.Unparse() }
+
+func (bl *BoolLit) Unparse(opts ...UnparseOption) string {
+	return strconv.FormatBool(bl.Value)
+}
+
 func (sl *StringLit) String() string { return sl.Unparse() }
 
 func (sl *StringLit) Unparse(opts ...UnparseOption) string {
@@ -581,7 +861,13 @@ func (oi *OpenInterpolated) Unparse(opts ...UnparseOption) string {
 func (ce *CallExpr) String() string { return ce.Unparse() }
 
 func (ce *CallExpr) Unparse(opts ...UnparseOption) string {
-	return fmt.Sprintf("%s%s", ce.Name.Unparse(opts...), ce.List.Unparse(opts...))
+	var info UnparseInfo
+	for _, opt := range opts {
+		opt(&info)
+	}
+
+	name := ce.Name.Unparse(opts...)
+	return fmt.Sprintf("%s%s", name, ce.List.Unparse(append(opts, withColumn(endColumn(info.Column, name)))...))
 }
 
 func (el *ExprList) String() string { return el.Unparse() }
@@ -686,14 +972,34 @@ func (se *StmtEnd) Unparse(opts ...UnparseOption) string {
 	return ""
 }
 
+// endColumn returns the column after unparsing a string at a column, counting
+// tabs as TabWidth columns.
+func endColumn(column int, str string) int {
+	if i := strings.LastIndex(str, "\n"); i >= 0 {
+		column, str = 0, str[i+1:]
+	}
+	for _, r := range str {
+		if r == '\t' {
+			column += TabWidth
+		} else {
+			column++
+		}
+	}
+	return column
+}
+
 func unparseList(list []Node, opts ...UnparseOption) string {
+	return unparseDelimitedList("(", ")", list, opts...)
+}
+
+func unparseDelimitedList(open, close string, list []Node, opts ...UnparseOption) string {
 	var info UnparseInfo
 	for _, opt := range opts {
 		opt(&info)
 	}
 
 	if len(list) == 0 {
-		return "()"
+		return open + close
 	}
 
 	hasNewline := false
@@ -714,14 +1020,34 @@ func unparseList(list []Node, opts ...UnparseOption) string {
 
 	var stmts []string
 	if !hasNewline {
+		column := info.Column + 1
 		for _, stmt := range list {
-			str := stmt.Unparse(opts...)
+			str := stmt.Unparse(append(opts, withColumn(column))...)
 			if len(strings.TrimSpace(str)) == 0 {
 				continue
 			}
 			stmts = append(stmts, str)
+			column = endColumn(column, str) + 2
+		}
+
+		str := fmt.Sprintf("%s%s%s", open, strings.Join(stmts, ", "), close)
+		if info.Width == 0 || endColumn(info.Column, strings.SplitN(str, "\n", 2)[0]) <= info.Width {
+			return str
+		}
+
+		// Wrap the list with an element per line.
+		indent := strings.Repeat("\t", info.Indent+1)
+		opts = append(opts, WithIndent(info.Indent+1), withColumn((info.Indent+1)*TabWidth))
+
+		var lines []string
+		for _, stmt := range list {
+			str := stmt.Unparse(opts...)
+			if len(strings.TrimSpace(str)) == 0 {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s%s,\n", indent, str))
 		}
-		return fmt.Sprintf("(%s)", strings.Join(stmts, ", "))
+		return fmt.Sprintf("%s\n%s%s%s", open, strings.Join(lines, ""), strings.Repeat("\t", info.Indent), close)
 	}
 	indent := strings.Repeat("\t", info.Indent+1)
 	opts = append(opts, WithIndent(info.Indent+1))
@@ -765,5 +1091,5 @@ func unparseList(list []Node, opts ...UnparseOption) string {
 	}
 	stmts = stmts[:i+1]
 
-	return fmt.Sprintf("(\n%s\n%s)", strings.Join(stmts, ""), strings.Repeat("\t", info.Indent))
+	return fmt.Sprintf("%s\n%s\n%s%s", open, strings.Join(stmts, ""), strings.Repeat("\t", info.Indent), close)
 }
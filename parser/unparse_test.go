@@ -655,6 +655,80 @@ func TestUnparse(t *testing.T) {
 			}
 			`,
 		},
+		{
+			"if else",
+			`
+			fs foo(bool cond) {
+				if cond {
+					image "alpine"
+				} else if   false { scratch; }  else {
+					image "busybox"
+				}
+			}
+			`,
+			`
+			fs foo(bool cond) {
+				if cond {
+					image "alpine"
+				} else if false { scratch } else {
+					image "busybox"
+				}
+			}
+			`,
+		},
+		{
+			"list and for",
+			`
+			[]string platforms() { "linux/amd64"; "linux/arm64"; }
+			fs foo([]string  extra) {
+				for   string platform   in platforms {
+					run string { format "build %s" platform; }
+				}
+			}
+			`,
+			`
+			[]string platforms() { "linux/amd64"; "linux/arm64" }
+
+			fs foo([]string extra) {
+				for string platform in platforms {
+					run string { format "build %s" platform }
+				}
+			}
+			`,
+		},
+		{
+			"list literals",
+			`
+			[]string platforms() { [  "linux/amd64","linux/arm64", ]; }
+			fs foo() {
+				for string platform in [ platforms,
+					"windows/amd64" # last
+				] { scratch; }
+				for fs input in [fs { scratch; }, image("alpine")] {
+					copy input "/" "/"
+				}
+				for string spaced in [ ] { scratch; }
+				for string empty in [] { scratch; }
+				build []
+			}
+			`,
+			`
+			[]string platforms() { ["linux/amd64", "linux/arm64"] }
+
+			fs foo() {
+				for string platform in [
+					platforms,
+					"windows/amd64", # last
+				] { scratch }
+				for fs input in [fs { scratch }, image("alpine")] {
+					copy input "/" "/"
+				}
+				for string spaced in [] { scratch }
+				for string empty in [] { scratch }
+				build []
+			}
+			`,
+		},
 	} {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
@@ -665,3 +739,142 @@ func TestUnparse(t *testing.T) {
 		})
 	}
 }
+
+func TestUnparseOptions(t *testing.T) {
+	for _, tc := range []struct {
+		testCase
+		opts []UnparseOption
+	}{{
+		testCase{
+			"sort imports",
+			`
+			import foo from "./foo.hlb"
+			import bar from "./bar.hlb" # bar
+			import go from fs { image "openllb/go.hlb"; }
+
+			import baz from image("openllb/baz.hlb")
+
+			# Not sorted past detached comments.
+
+			import alpha from "./alpha.hlb"
+
+			fs default() { scratch; }
+			`,
+			`
+			import baz from image("openllb/baz.hlb")
+			import go from fs { image "openllb/go.hlb" }
+
+			import bar from "./bar.hlb" # bar
+			import foo from "./foo.hlb"
+
+			# Not sorted past detached comments.
+
+			import alpha from "./alpha.hlb"
+
+			fs default() { scratch }
+			`,
+		},
+		[]UnparseOption{WithSortImports()},
+	}, {
+		testCase{
+			"sort imports with doc comments",
+			`
+			# Documents foo
+			# over two lines.
+			import foo from "./foo.hlb"
+			import baz from "./baz.hlb" # baz
+			# Documents bar.
+			import bar from "./bar.hlb"
+
+			fs default() { scratch; }
+			`,
+			`
+			# Documents bar.
+			import bar from "./bar.hlb"
+			import baz from "./baz.hlb" # baz
+			# Documents foo
+			# over two lines.
+			import foo from "./foo.hlb"
+
+			fs default() { scratch }
+			`,
+		},
+		[]UnparseOption{WithSortImports()},
+	}, {
+		testCase{
+			"formatted modules are unchanged by options",
+			`
+			# Documents foo.
+			import foo from "./foo.hlb"
+
+			fs default() {
+				image "alpine"
+				run "echo hello"
+			}
+			`,
+			`
+			# Documents foo.
+			import foo from "./foo.hlb"
+
+			fs default() {
+				image "alpine"
+				run "echo hello"
+			}
+			`,
+		},
+		[]UnparseOption{WithSortImports(), WithWidth(120)},
+	}, {
+		testCase{
+			"wrap long lists",
+			`
+			fs build(string name, string version, variadic string flags) {
+				image "alpine"
+				copy bin(name, version, "linux/amd64") "/" "/usr/bin"
+			}
+			`,
+			`
+			fs build(
+				string name,
+				string version,
+				variadic string flags,
+			) {
+				image "alpine"
+				copy bin(
+					name,
+					version,
+					"linux/amd64",
+				) "/" "/usr/bin"
+			}
+			`,
+		},
+		[]UnparseOption{WithWidth(40)},
+	}, {
+		testCase{
+			"short lists are not wrapped",
+			`
+			fs build(string name) {
+				copy bin(name) "/" "/usr/bin"
+			}
+			`,
+			`
+			fs build(string name) {
+				copy bin(name) "/" "/usr/bin"
+			}
+			`,
+		},
+		[]UnparseOption{WithWidth(40)},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			file, err := Parse(context.Background(), strings.NewReader(cleanup(tc.input)))
+			require.NoError(t, err)
+			require.Equal(t, cleanup(tc.expected), file.Unparse(tc.opts...))
+
+			// Formatting is idempotent.
+			file, err = Parse(context.Background(), strings.NewReader(cleanup(tc.expected)))
+			require.NoError(t, err)
+			require.Equal(t, cleanup(tc.expected), file.Unparse(tc.opts...))
+		})
+	}
+}
@@ -23,3 +23,17 @@ func ResolvePath(root, path string) (string, error) {
 func FormatPos(pos lexer.Position) string {
 	return fmt.Sprintf("%s:%d:%d:", pos.Filename, pos.Line, pos.Column)
 }
+
+// ExportedNames returns the names exported by a module, in the order of their
+// export declarations.
+func ExportedNames(mod *Module) []string {
+	var names []string
+	Match(mod, MatchOpts{},
+		func(ed *ExportDecl) {
+			if ed.Name != nil {
+				names = append(names, ed.Name.Text)
+			}
+		},
+	)
+	return names
+}
@@ -131,6 +131,10 @@ func (w *walker) walk(node Node, v Visitor) {
 		switch {
 		case n.Call != nil:
 			w.walk(n.Call, v)
+		case n.If != nil:
+			w.walk(n.If, v)
+		case n.For != nil:
+			w.walk(n.For, v)
 		case n.Expr != nil:
 			w.walk(n.Expr, v)
 		case n.Comments != nil:
@@ -183,6 +187,68 @@ func (w *walker) walk(node Node, v Visitor) {
 		if n.Target != nil {
 			w.walk(n.Target, v)
 		}
+	case *IfStmt:
+		if n.If != nil {
+			w.walk(n.If, v)
+		}
+		if n.Condition != nil {
+			w.walk(n.Condition, v)
+		}
+		if n.Body != nil {
+			w.walk(n.Body, v)
+		}
+		if n.Else != nil {
+			w.walk(n.Else, v)
+		}
+		if n.Terminate != nil {
+			w.walk(n.Terminate, v)
+		}
+	case *Condition:
+		switch {
+		case n.BasicLit != nil:
+			w.walk(n.BasicLit, v)
+		case n.CallExpr != nil:
+			w.walk(n.CallExpr, v)
+		}
+	case *ElseClause:
+		if n.Else != nil {
+			w.walk(n.Else, v)
+		}
+		switch {
+		case n.If != nil:
+			w.walk(n.If, v)
+		case n.Body != nil:
+			w.walk(n.Body, v)
+		}
+	case *ForStmt:
+		if n.For != nil {
+			w.walk(n.For, v)
+		}
+		if n.Type != nil {
+			w.walk(n.Type, v)
+		}
+		if n.Name != nil {
+			w.walk(n.Name, v)
+		}
+		if n.In != nil {
+			w.walk(n.In, v)
+		}
+		if n.Iterable != nil {
+			w.walk(n.Iterable, v)
+		}
+		if n.Body != nil {
+			w.walk(n.Body, v)
+		}
+		if n.Terminate != nil {
+			w.walk(n.Terminate, v)
+		}
+	case *Iterable:
+		switch {
+		case n.ListLit != nil:
+			w.walk(n.ListLit, v)
+		case n.CallExpr != nil:
+			w.walk(n.CallExpr, v)
+		}
 	case *ExprStmt:
 		if n.Expr != nil {
 			w.walk(n.Expr, v)
@@ -200,6 +266,8 @@ func (w *walker) walk(node Node, v Visitor) {
 			w.walk(n.FuncLit, v)
 		case n.BasicLit != nil:
 			w.walk(n.BasicLit, v)
+		case n.ListLit != nil:
+			w.walk(n.ListLit, v)
 		case n.CallExpr != nil:
 			w.walk(n.CallExpr, v)
 		}
@@ -214,6 +282,8 @@ func (w *walker) walk(node Node, v Visitor) {
 		switch {
 		case n.Numeric != nil:
 			w.walk(n.Numeric, v)
+		case n.Bool != nil:
+			w.walk(n.Bool, v)
 		case n.Str != nil:
 			w.walk(n.Str, v)
 		case n.RawString != nil:
@@ -256,6 +326,8 @@ func (w *walker) walk(node Node, v Visitor) {
 		}
 	case *ExprList:
 		w.walkExprFieldList(n.Fields, v)
+	case *ListLit:
+		w.walkExprFieldList(n.Fields, v)
 	case *ExprField:
 		if n.Expr != nil {
 			w.walk(n.Expr, v)
@@ -5,19 +5,22 @@ import (
 	"io"
 	"os"
 
+	"github.com/containerd/containerd/content"
 	"github.com/moby/buildkit/session"
 	"github.com/moby/buildkit/session/auth/authprovider"
+	sessioncontent "github.com/moby/buildkit/session/content"
 	"github.com/moby/buildkit/session/filesync"
 	"github.com/moby/buildkit/session/secrets/secretsprovider"
 	"github.com/openllb/hlb/pkg/sockproxy"
 )
 
 type SessionInfo struct {
-	SyncTargetDir   *string
-	SyncTarget      func(map[string]string) (io.WriteCloser, error)
-	SyncedDirByID   map[string]filesync.SyncedDir
-	FileSourceByID  map[string]secretsprovider.Source
-	AgentConfigByID map[string]sockproxy.AgentConfig
+	SyncTargetDir    *string
+	SyncTarget       func(map[string]string) (io.WriteCloser, error)
+	SyncedDirByID    map[string]filesync.SyncedDir
+	FileSourceByID   map[string]secretsprovider.Source
+	AgentConfigByID  map[string]sockproxy.AgentConfig
+	ContentStoreByID map[string]content.Store
 }
 
 type SessionOption func(*SessionInfo)
@@ -52,11 +55,18 @@ func WithAgentConfig(id string, cfg sockproxy.AgentConfig) SessionOption {
 	}
 }
 
+func WithContentStore(id string, store content.Store) SessionOption {
+	return func(si *SessionInfo) {
+		si.ContentStoreByID[id] = store
+	}
+}
+
 func NewSession(ctx context.Context, opts ...SessionOption) (*session.Session, error) {
 	si := SessionInfo{
-		SyncedDirByID:   make(map[string]filesync.SyncedDir),
-		FileSourceByID:  make(map[string]secretsprovider.Source),
-		AgentConfigByID: make(map[string]sockproxy.AgentConfig),
+		SyncedDirByID:    make(map[string]filesync.SyncedDir),
+		FileSourceByID:   make(map[string]secretsprovider.Source),
+		AgentConfigByID:  make(map[string]sockproxy.AgentConfig),
+		ContentStoreByID: make(map[string]content.Store),
 	}
 	for _, opt := range opts {
 		opt(&si)
@@ -110,6 +120,11 @@ func NewSession(ctx context.Context, opts ...SessionOption) (*session.Session, e
 		attachables = append(attachables, secretsprovider.NewSecretProvider(fileStore))
 	}
 
+	// Attach content stores for local caches to the session.
+	if len(si.ContentStoreByID) > 0 {
+		attachables = append(attachables, sessioncontent.NewAttachable(si.ContentStoreByID))
+	}
+
 	s, err := session.NewSession(ctx, "hlb", "")
 	if err != nil {
 		return s, err
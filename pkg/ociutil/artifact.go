@@ -0,0 +1,277 @@
+package ociutil
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/containerd/containerd/errdefs"
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MediaTypeModuleConfig is the media type of the config of an HLB module
+	// artifact.
+	MediaTypeModuleConfig = "application/vnd.openllb.hlb.module.config.v1+json"
+
+	// MediaTypeModuleLayer is the media type of the gzipped tarball containing
+	// the files of an HLB module.
+	MediaTypeModuleLayer = "application/vnd.openllb.hlb.module.layer.v1.tar+gzip"
+
+	// LayoutPrefix is the prefix of references to artifacts in an OCI image
+	// layout on disk, for example oci-layout://path/to/layout:tag.
+	LayoutPrefix = "oci-layout://"
+)
+
+// Artifact is an HLB module stored as an OCI artifact.
+type Artifact struct {
+	// Digest is the digest of the artifact's manifest.
+	Digest digest.Digest
+
+	// Files are the contents of the module's files keyed by their slash
+	// separated paths.
+	Files map[string][]byte
+}
+
+// target is where artifacts are pushed to and pulled from.
+type target interface {
+	// Resolve returns the descriptor of the manifest of the reference.
+	Resolve(ctx context.Context) (ocispec.Descriptor, error)
+
+	Fetch(ctx context.Context, desc ocispec.Descriptor) ([]byte, error)
+
+	// Push pushes a blob, and tags the reference when the blob is a manifest.
+	// Manifests are pushed after the blobs they refer to.
+	Push(ctx context.Context, desc ocispec.Descriptor, dt []byte) error
+}
+
+func newTarget(ref string) (target, error) {
+	if strings.HasPrefix(ref, LayoutPrefix) {
+		return newLayoutTarget(strings.TrimPrefix(ref, LayoutPrefix))
+	}
+	return newRegistryTarget(ref)
+}
+
+// Push pushes the files of an HLB module as an artifact, and returns the
+// digest of its manifest. References are either registry references or
+// references to an OCI image layout prefixed with LayoutPrefix.
+func Push(ctx context.Context, ref string, files map[string][]byte) (digest.Digest, error) {
+	t, err := newTarget(ref)
+	if err != nil {
+		return "", err
+	}
+
+	layer, err := tarball(files)
+	if err != nil {
+		return "", err
+	}
+
+	config := []byte("{}")
+
+	layerDesc := ocispec.Descriptor{
+		MediaType: MediaTypeModuleLayer,
+		Digest:    digest.FromBytes(layer),
+		Size:      int64(len(layer)),
+	}
+	configDesc := ocispec.Descriptor{
+		MediaType: MediaTypeModuleConfig,
+		Digest:    digest.FromBytes(config),
+		Size:      int64(len(config)),
+	}
+
+	manifest, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Config:    configDesc,
+		Layers:    []ocispec.Descriptor{layerDesc},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	manifestDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(manifest),
+		Size:      int64(len(manifest)),
+	}
+
+	for _, blob := range []struct {
+		desc ocispec.Descriptor
+		dt   []byte
+	}{
+		{layerDesc, layer},
+		{configDesc, config},
+		{manifestDesc, manifest},
+	} {
+		err = t.Push(ctx, blob.desc, blob.dt)
+		if err != nil && !errdefs.IsAlreadyExists(err) {
+			return "", errors.Wrapf(err, "failed to push %s", blob.desc.Digest)
+		}
+	}
+
+	return manifestDesc.Digest, nil
+}
+
+// Resolve returns the digest of the manifest of an artifact without pulling
+// it.
+func Resolve(ctx context.Context, ref string) (digest.Digest, error) {
+	t, err := newTarget(ref)
+	if err != nil {
+		return "", err
+	}
+
+	desc, err := t.Resolve(ctx)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve %s", ref)
+	}
+	return desc.Digest, nil
+}
+
+// Pull pulls an HLB module artifact.
+func Pull(ctx context.Context, ref string) (*Artifact, error) {
+	t, err := newTarget(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	desc, err := t.Resolve(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve %s", ref)
+	}
+
+	dt, err := t.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest ocispec.Manifest
+	err = json.Unmarshal(dt, &manifest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal manifest of %s", ref)
+	}
+
+	if manifest.Config.MediaType != MediaTypeModuleConfig {
+		return nil, fmt.Errorf("%s is not an HLB module, expected config media type %s but got %s", ref, MediaTypeModuleConfig, manifest.Config.MediaType)
+	}
+
+	artifact := &Artifact{
+		Digest: desc.Digest,
+		Files:  make(map[string][]byte),
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != MediaTypeModuleLayer {
+			continue
+		}
+
+		dt, err := t.Fetch(ctx, layer)
+		if err != nil {
+			return nil, err
+		}
+
+		err = untar(dt, artifact.Files)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to extract layer %s of %s", layer.Digest, ref)
+		}
+	}
+
+	return artifact, nil
+}
+
+// fetchBlob reads a blob and verifies its digest.
+func fetchBlob(rc io.ReadCloser, desc ocispec.Descriptor) ([]byte, error) {
+	defer rc.Close()
+
+	dt, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	if dgst := desc.Digest.Algorithm().FromBytes(dt); dgst != desc.Digest {
+		return nil, fmt.Errorf("blob %s has unexpected digest %s", desc.Digest, dgst)
+	}
+	return dt, nil
+}
+
+// tarball returns a reproducible gzipped tarball of files.
+func tarball(files map[string][]byte) ([]byte, error) {
+	var filenames []string
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, filename := range filenames {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     filename,
+			Mode:     0644,
+			Size:     int64(len(files[filename])),
+			Format:   tar.FormatPAX,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tw.Write(files[filename])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := tw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = gw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func untar(dt []byte, files map[string][]byte) error {
+	gr, err := gzip.NewReader(bytes.NewReader(dt))
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// Files must stay within the module.
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid file %q", hdr.Name)
+		}
+
+		files[name], err = ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+	}
+}
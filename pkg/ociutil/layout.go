@@ -0,0 +1,144 @@
+package ociutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// layoutTarget is an OCI image layout on disk, where manifests are tagged by
+// the org.opencontainers.image.ref.name annotation in its index.
+// See: https://github.com/opencontainers/image-spec/blob/master/image-layout.md
+type layoutTarget struct {
+	root string
+	tag  string
+	dgst digest.Digest
+}
+
+// newLayoutTarget parses a path to an OCI image layout, optionally followed by
+// a tag or a digest, or both where the digest takes precedence. The tag
+// defaults to latest.
+func newLayoutTarget(ref string) (*layoutTarget, error) {
+	t := &layoutTarget{root: ref, tag: "latest"}
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		dgst, err := digest.Parse(ref[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		t.root, t.tag, t.dgst = ref[:i], "", dgst
+	}
+	if i := strings.LastIndex(t.root, ":"); i > strings.LastIndex(t.root, "/") {
+		t.root = t.root[:i]
+		if t.dgst == "" {
+			t.tag = ref[i+1:]
+		}
+	}
+
+	if t.root == "" {
+		return nil, fmt.Errorf("invalid reference %q, expected path to OCI image layout", LayoutPrefix+ref)
+	}
+	return t, nil
+}
+
+func (t *layoutTarget) Resolve(ctx context.Context) (ocispec.Descriptor, error) {
+	index, err := t.readIndex()
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	for _, desc := range index.Manifests {
+		if t.dgst != "" && desc.Digest == t.dgst {
+			return desc, nil
+		}
+		if t.tag != "" && desc.Annotations[ocispec.AnnotationRefName] == t.tag {
+			return desc, nil
+		}
+	}
+
+	ref := t.tag
+	if t.dgst != "" {
+		ref = t.dgst.String()
+	}
+	return ocispec.Descriptor{}, fmt.Errorf("%s not found in %s", ref, t.root)
+}
+
+func (t *layoutTarget) Fetch(ctx context.Context, desc ocispec.Descriptor) ([]byte, error) {
+	f, err := os.Open(t.blobPath(desc.Digest))
+	if err != nil {
+		return nil, err
+	}
+	return fetchBlob(f, desc)
+}
+
+func (t *layoutTarget) Push(ctx context.Context, desc ocispec.Descriptor, dt []byte) error {
+	err := os.MkdirAll(filepath.Dir(t.blobPath(desc.Digest)), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(t.blobPath(desc.Digest), dt, 0644)
+	if err != nil {
+		return err
+	}
+
+	if desc.MediaType != ocispec.MediaTypeImageManifest {
+		return nil
+	}
+
+	err = ioutil.WriteFile(filepath.Join(t.root, ocispec.ImageLayoutFile), []byte(`{"imageLayoutVersion":"`+ocispec.ImageLayoutVersion+`"}`), 0644)
+	if err != nil {
+		return err
+	}
+
+	index, err := t.readIndex()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		index = &ocispec.Index{Versioned: specs.Versioned{SchemaVersion: 2}}
+	}
+
+	// Replace the manifest previously tagged with the same tag.
+	var manifests []ocispec.Descriptor
+	for _, m := range index.Manifests {
+		if t.tag == "" || m.Annotations[ocispec.AnnotationRefName] != t.tag {
+			manifests = append(manifests, m)
+		}
+	}
+	if t.tag != "" {
+		desc.Annotations = map[string]string{ocispec.AnnotationRefName: t.tag}
+	}
+	index.Manifests = append(manifests, desc)
+
+	dt, err = json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(t.root, "index.json"), dt, 0644)
+}
+
+func (t *layoutTarget) readIndex() (*ocispec.Index, error) {
+	dt, err := ioutil.ReadFile(filepath.Join(t.root, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var index ocispec.Index
+	err = json.Unmarshal(dt, &index)
+	if err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
+
+func (t *layoutTarget) blobPath(dgst digest.Digest) string {
+	return filepath.Join(t.root, "blobs", dgst.Algorithm().String(), dgst.Encoded())
+}
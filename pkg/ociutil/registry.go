@@ -0,0 +1,92 @@
+package ociutil
+
+import (
+	"context"
+	"io/ioutil"
+
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+type registryTarget struct {
+	ref      string
+	resolver remotes.Resolver
+}
+
+func newRegistryTarget(ref string) (*registryTarget, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid reference %q", ref)
+	}
+
+	return &registryTarget{
+		ref: reference.TagNameOnly(named).String(),
+		resolver: docker.NewResolver(docker.ResolverOptions{
+			Hosts: docker.ConfigureDefaultRegistries(
+				docker.WithAuthorizer(docker.NewDockerAuthorizer(
+					docker.WithAuthCreds(dockerCredentials),
+				)),
+				// Allow a local registry for development.
+				docker.WithPlainHTTP(docker.MatchLocalhost),
+			),
+		}),
+	}, nil
+}
+
+func (t *registryTarget) Resolve(ctx context.Context) (ocispec.Descriptor, error) {
+	_, desc, err := t.resolver.Resolve(ctx, t.ref)
+	return desc, err
+}
+
+func (t *registryTarget) Fetch(ctx context.Context, desc ocispec.Descriptor) ([]byte, error) {
+	fetcher, err := t.resolver.Fetcher(ctx, t.ref)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	return fetchBlob(rc, desc)
+}
+
+func (t *registryTarget) Push(ctx context.Context, desc ocispec.Descriptor, dt []byte) error {
+	pusher, err := t.resolver.Pusher(ctx, t.ref)
+	if err != nil {
+		return err
+	}
+
+	w, err := pusher.Push(ctx, desc)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	_, err = w.Write(dt)
+	if err != nil {
+		return err
+	}
+	return w.Commit(ctx, desc.Size, desc.Digest)
+}
+
+// dockerCredentials returns the credentials of a registry host from the docker
+// config file.
+func dockerCredentials(host string) (string, string, error) {
+	if host == "registry-1.docker.io" {
+		host = "https://index.docker.io/v1/"
+	}
+
+	auth, err := config.LoadDefaultConfigFile(ioutil.Discard).GetAuthConfig(host)
+	if err != nil {
+		return "", "", err
+	}
+	if auth.IdentityToken != "" {
+		return "", auth.IdentityToken, nil
+	}
+	return auth.Username, auth.Password, nil
+}
@@ -0,0 +1,228 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// InstrumentationName is the name of the instrumentation scope of the spans.
+const InstrumentationName = "github.com/openllb/hlb"
+
+// otlpExporter exports spans to an OTLP/HTTP collector.
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewOTLPExporter returns an exporter that sends spans to an OTLP/HTTP
+// endpoint with JSON encoding, such as an OpenTelemetry collector. An
+// endpoint without a path is sent to the default /v1/traces path.
+func NewOTLPExporter(endpoint string) (Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("otlp endpoint must be an http or https url, got %q", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return &otlpExporter{
+		endpoint: u.String(),
+		client:   http.DefaultClient,
+	}, nil
+}
+
+func (e *otlpExporter) ExportSpans(ctx context.Context, resource []Attribute, spans []*Span) error {
+	dt, err := json.Marshal(newTracesData(resource, spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", e.endpoint, bytes.NewReader(dt))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to export spans to %s: %s: %s", e.endpoint, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// fileExporter writes spans to a local file.
+type fileExporter struct {
+	filename string
+
+	mu      sync.Mutex
+	created bool
+}
+
+// NewFileExporter returns an exporter that writes each batch of spans to a
+// file as a line of OTLP/JSON, which can be read by the OTLP JSON file
+// receiver of an OpenTelemetry collector. The file is truncated by the first
+// batch.
+func NewFileExporter(filename string) Exporter {
+	return &fileExporter{filename: filename}
+}
+
+func (e *fileExporter) ExportSpans(ctx context.Context, resource []Attribute, spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if !e.created {
+		flag |= os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(e.filename, flag, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	e.created = true
+
+	return json.NewEncoder(f).Encode(newTracesData(resource, spans))
+}
+
+// The types below are the JSON encoding of the OTLP ExportTraceServiceRequest
+// message, where IDs are hex encoded and 64-bit integers are strings.
+
+type tracesData struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes,omitempty"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            *status    `json:"status,omitempty"`
+}
+
+const (
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	IntValue    *string     `json:"intValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	ArrayValue  *arrayValue `json:"arrayValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+func newTracesData(attrs []Attribute, spans []*Span) tracesData {
+	ss := scopeSpans{Scope: scope{Name: InstrumentationName}}
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.ID.String(),
+			Name:              s.Name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+			Attributes:        newKeyValues(s.Attributes),
+		}
+		if s.Parent.IsValid() {
+			span.ParentSpanID = s.Parent.String()
+		}
+		if s.Err != nil {
+			span.Status = &status{Code: statusCodeError, Message: s.Err.Error()}
+		}
+		s.mu.Unlock()
+		ss.Spans = append(ss.Spans, span)
+	}
+
+	return tracesData{
+		ResourceSpans: []resourceSpans{{
+			Resource:   resource{Attributes: newKeyValues(attrs)},
+			ScopeSpans: []scopeSpans{ss},
+		}},
+	}
+}
+
+func newKeyValues(attrs []Attribute) []keyValue {
+	var kvs []keyValue
+	for _, attr := range attrs {
+		kvs = append(kvs, keyValue{Key: attr.Key, Value: newAnyValue(attr.Value)})
+	}
+	return kvs
+}
+
+func newAnyValue(v interface{}) anyValue {
+	switch v := v.(type) {
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return anyValue{IntValue: &s}
+	case bool:
+		return anyValue{BoolValue: &v}
+	case []string:
+		values := []anyValue{}
+		for _, elem := range v {
+			values = append(values, newAnyValue(elem))
+		}
+		return anyValue{ArrayValue: &arrayValue{Values: values}}
+	case string:
+		return anyValue{StringValue: &v}
+	default:
+		s := fmt.Sprint(v)
+		return anyValue{StringValue: &s}
+	}
+}
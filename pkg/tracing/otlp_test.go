@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testSpans() []*Span {
+	traceID := TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	root := &Span{
+		TraceID:   traceID,
+		ID:        SpanID{0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8},
+		Name:      "hlb run",
+		StartTime: time.Unix(1, 0),
+		EndTime:   time.Unix(3, 500),
+		Attributes: []Attribute{
+			StringSlice("hlb.targets", []string{"default", "test"}),
+		},
+	}
+	child := &Span{
+		TraceID:   traceID,
+		ID:        SpanID{0xb1, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6, 0xb7, 0xb8},
+		Parent:    root.ID,
+		Name:      "solver.Solve",
+		StartTime: time.Unix(2, 0),
+		EndTime:   time.Unix(3, 0),
+		Attributes: []Attribute{
+			String("hlb.prefix", "default"),
+			Int("llb.ops", 3),
+			Bool("hlb.shared", true),
+		},
+		Err: errors.New("exit code: 1"),
+	}
+	return []*Span{root, child}
+}
+
+// expectedTraces is the OTLP/JSON encoding of the test spans, following the
+// JSON mapping of the ExportTraceServiceRequest message: IDs are hex encoded,
+// 64-bit integers are decimal strings and enums are integers.
+const expectedTraces = `{
+	"resourceSpans": [{
+		"resource": {
+			"attributes": [
+				{"key": "service.name", "value": {"stringValue": "hlb"}}
+			]
+		},
+		"scopeSpans": [{
+			"scope": {"name": "github.com/openllb/hlb"},
+			"spans": [{
+				"traceId": "0102030405060708090a0b0c0d0e0f10",
+				"spanId": "a1a2a3a4a5a6a7a8",
+				"name": "hlb run",
+				"kind": 1,
+				"startTimeUnixNano": "1000000000",
+				"endTimeUnixNano": "3000000500",
+				"attributes": [
+					{"key": "hlb.targets", "value": {"arrayValue": {"values": [
+						{"stringValue": "default"},
+						{"stringValue": "test"}
+					]}}}
+				]
+			}, {
+				"traceId": "0102030405060708090a0b0c0d0e0f10",
+				"spanId": "b1b2b3b4b5b6b7b8",
+				"parentSpanId": "a1a2a3a4a5a6a7a8",
+				"name": "solver.Solve",
+				"kind": 1,
+				"startTimeUnixNano": "2000000000",
+				"endTimeUnixNano": "3000000000",
+				"attributes": [
+					{"key": "hlb.prefix", "value": {"stringValue": "default"}},
+					{"key": "llb.ops", "value": {"intValue": "3"}},
+					{"key": "hlb.shared", "value": {"boolValue": true}}
+				],
+				"status": {"code": 2, "message": "exit code: 1"}
+			}]
+		}]
+	}]
+}`
+
+func TestNewTracesData(t *testing.T) {
+	t.Parallel()
+
+	dt, err := json.Marshal(newTracesData([]Attribute{String("service.name", "hlb")}, testSpans()))
+	require.NoError(t, err)
+	require.JSONEq(t, expectedTraces, string(dt))
+}
+
+func TestOTLPExporter(t *testing.T) {
+	t.Parallel()
+
+	var (
+		path, contentType string
+		body              []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+		if r.URL.Query().Get("fail") != "" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	exporter, err := NewOTLPExporter(srv.URL)
+	require.NoError(t, err)
+
+	err = exporter.ExportSpans(context.Background(), []Attribute{String("service.name", "hlb")}, testSpans())
+	require.NoError(t, err)
+	require.Equal(t, "/v1/traces", path)
+	require.Equal(t, "application/json", contentType)
+	require.JSONEq(t, expectedTraces, string(body))
+
+	exporter, err = NewOTLPExporter(srv.URL + "/v1/traces?fail=1")
+	require.NoError(t, err)
+
+	err = exporter.ExportSpans(context.Background(), nil, testSpans())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "503 Service Unavailable: unavailable")
+
+	_, err = NewOTLPExporter("localhost:4318")
+	require.Error(t, err)
+}
+
+func TestFileExporter(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "tracing")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "trace.json")
+	require.NoError(t, ioutil.WriteFile(filename, []byte("stale\n"), 0644))
+
+	// Each batch is a line, after truncating the file written by a previous
+	// run.
+	exporter := NewFileExporter(filename)
+	spans := testSpans()
+	for _, batch := range [][]*Span{spans[:1], spans[1:]} {
+		err = exporter.ExportSpans(context.Background(), nil, batch)
+		require.NoError(t, err)
+	}
+
+	dt, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(dt)), "\n")
+	require.Len(t, lines, 2)
+	for i, line := range lines {
+		var data tracesData
+		require.NoError(t, json.Unmarshal([]byte(line), &data))
+		require.Len(t, data.ResourceSpans[0].ScopeSpans[0].Spans, 1)
+		require.Equal(t, spans[i].Name, data.ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+	}
+}
@@ -0,0 +1,324 @@
+// Package tracing records spans of the compile and solve phases of hlb, and
+// exports them in the OpenTelemetry protocol (OTLP) so they can be viewed in
+// existing tracing tools.
+//
+// The OpenTelemetry SDK requires a newer gRPC than BuildKit is built with, so
+// this is a minimal tracer that only encodes spans as OTLP/JSON.
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	// MaxExportBatchSize is the number of ended spans that are exported
+	// together without waiting for the export interval.
+	MaxExportBatchSize = 512
+
+	// ExportInterval is the longest time ended spans wait to be exported.
+	ExportInterval = 5 * time.Second
+
+	// ExportTimeout is the timeout of exports before the tracer is shut down.
+	ExportTimeout = 30 * time.Second
+)
+
+// TraceID is the identifier of a trace.
+type TraceID [16]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID is the identifier of a span within a trace.
+type SpanID [8]byte
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid returns true if the span ID is set, as the root span has no parent.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// Exporter exports the spans of a trace.
+type Exporter interface {
+	ExportSpans(ctx context.Context, resource []Attribute, spans []*Span) error
+}
+
+// Tracer records the spans of a single trace, and exports them in batches as
+// they end and once it is shut down.
+type Tracer struct {
+	exporter  Exporter
+	resource  []Attribute
+	traceID   TraceID
+	batchSize int
+
+	// ids generates span IDs, seeded from crypto/rand so that spans don't
+	// each need a read of crypto/rand which may fail.
+	idsMu sync.Mutex
+	ids   *rand.Rand
+
+	mu    sync.Mutex
+	spans []*Span
+	err   error
+
+	flush    chan struct{}
+	shutdown chan struct{}
+	stopped  chan struct{}
+	once     sync.Once
+}
+
+// NewTracer returns a tracer that exports its spans to an exporter. The
+// resource attributes describe the process being traced, such as its
+// service.name.
+func NewTracer(exporter Exporter, resource ...Attribute) (*Tracer, error) {
+	return newTracer(exporter, MaxExportBatchSize, ExportInterval, resource...)
+}
+
+func newTracer(exporter Exporter, batchSize int, interval time.Duration, resource ...Attribute) (*Tracer, error) {
+	t := &Tracer{
+		exporter:  exporter,
+		resource:  resource,
+		batchSize: batchSize,
+		flush:     make(chan struct{}, 1),
+		shutdown:  make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+
+	_, err := crand.Read(t.traceID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var seed [8]byte
+	_, err = crand.Read(seed[:])
+	if err != nil {
+		return nil, err
+	}
+	t.ids = rand.New(rand.NewSource(int64(binary.LittleEndian.Uint64(seed[:]))))
+
+	go t.run(interval)
+	return t, nil
+}
+
+// run exports the ended spans every interval, or once a batch of spans has
+// ended, until the tracer is shut down.
+func (t *Tracer) run(interval time.Duration) {
+	defer close(t.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.shutdown:
+			return
+		case <-ticker.C:
+		case <-t.flush:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), ExportTimeout)
+		t.export(ctx)
+		cancel()
+	}
+}
+
+// export exports the ended spans in batches, keeping the first error so that
+// it is returned when the tracer is shut down.
+func (t *Tracer) export(ctx context.Context) error {
+	for {
+		t.mu.Lock()
+		n := len(t.spans)
+		if n > t.batchSize {
+			n = t.batchSize
+		}
+		spans := t.spans[:n:n]
+		t.spans = t.spans[n:]
+		t.mu.Unlock()
+
+		if len(spans) == 0 {
+			break
+		}
+
+		err := t.exporter.ExportSpans(ctx, t.resource, spans)
+		if err != nil {
+			t.mu.Lock()
+			if t.err == nil {
+				t.err = err
+			}
+			t.mu.Unlock()
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// Shutdown stops exporting spans in the background and exports the spans that
+// have ended since the last batch. It returns the first error of the exports.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.once.Do(func() {
+		close(t.shutdown)
+	})
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.stopped:
+	}
+	return t.export(ctx)
+}
+
+// newSpanID returns a random span ID.
+func (t *Tracer) newSpanID() (id SpanID) {
+	t.idsMu.Lock()
+	defer t.idsMu.Unlock()
+	for !id.IsValid() {
+		t.ids.Read(id[:])
+	}
+	return id
+}
+
+type tracerKey struct{}
+
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+func TracerFrom(ctx context.Context) *Tracer {
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	return t
+}
+
+// Enabled returns true if spans started with the context are recorded, so
+// that callers can skip computing expensive attributes.
+func Enabled(ctx context.Context) bool {
+	return TracerFrom(ctx) != nil
+}
+
+type spanKey struct{}
+
+func SpanFrom(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Span is a timed operation of a trace.
+type Span struct {
+	tracer *Tracer
+
+	TraceID    TraceID
+	ID         SpanID
+	Parent     SpanID
+	Name       string
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes []Attribute
+	Err        error
+
+	mu    sync.Mutex
+	ended bool
+}
+
+// Start starts a span as a child of the span of the context, if any. The span
+// is nil if the context has no tracer, and the methods of a nil span do
+// nothing, so callers don't need to check if tracing is enabled.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	t := TracerFrom(ctx)
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{
+		tracer:     t,
+		TraceID:    t.traceID,
+		ID:         t.newSpanID(),
+		Name:       name,
+		StartTime:  time.Now(),
+		Attributes: attrs,
+	}
+	if parent := SpanFrom(ctx); parent != nil {
+		s.Parent = parent.ID
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attributes = append(s.Attributes, attrs...)
+}
+
+// SetError marks the span as failed if err is not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Err = err
+}
+
+// End ends the span, after which it is exported by the tracer with the next
+// batch. Only the first call to End has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+
+	t := s.tracer
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	full := len(t.spans) >= t.batchSize
+	t.mu.Unlock()
+
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Attribute is a key-value pair describing a span or resource. Its value is a
+// string, int64, bool or []string.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attribute {
+	return Attribute{key, value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{key, int64(value)}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{key, value}
+}
+
+func StringSlice(key string, value []string) Attribute {
+	return Attribute{key, value}
+}
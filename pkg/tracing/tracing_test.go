@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// batchRecorder is an exporter that records the batches of spans exported.
+type batchRecorder struct {
+	mu       sync.Mutex
+	batches  [][]*Span
+	err      error
+	exported chan struct{}
+}
+
+func (r *batchRecorder) ExportSpans(ctx context.Context, resource []Attribute, spans []*Span) error {
+	r.mu.Lock()
+	r.batches = append(r.batches, spans)
+	r.mu.Unlock()
+	if r.exported != nil {
+		r.exported <- struct{}{}
+	}
+	return r.err
+}
+
+func (r *batchRecorder) names() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names [][]string
+	for _, batch := range r.batches {
+		var batchNames []string
+		for _, span := range batch {
+			batchNames = append(batchNames, span.Name)
+		}
+		names = append(names, batchNames)
+	}
+	return names
+}
+
+func TestTracerSpans(t *testing.T) {
+	t.Parallel()
+
+	rec := &batchRecorder{}
+	tracer, err := NewTracer(rec)
+	require.NoError(t, err)
+
+	ctx := WithTracer(context.Background(), tracer)
+	ctx, parent := Start(ctx, "parent")
+	_, child := Start(ctx, "child", String("key", "value"))
+	child.SetError(errors.New("failed"))
+	child.End()
+	child.End()
+	parent.End()
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	require.Equal(t, [][]string{{"child", "parent"}}, rec.names())
+
+	require.Equal(t, parent.TraceID, child.TraceID)
+	require.Equal(t, parent.ID, child.Parent)
+	require.False(t, parent.Parent.IsValid())
+	require.True(t, child.ID.IsValid())
+	require.NotEqual(t, parent.ID, child.ID)
+	require.EqualError(t, child.Err, "failed")
+
+	// Spans started without a tracer are nil and ignored.
+	_, span := Start(context.Background(), "untraced")
+	require.Nil(t, span)
+	span.SetAttributes(String("key", "value"))
+	span.End()
+}
+
+func TestTracerBatches(t *testing.T) {
+	t.Parallel()
+
+	rec := &batchRecorder{exported: make(chan struct{}, 2)}
+	tracer, err := newTracer(rec, 2, time.Hour)
+	require.NoError(t, err)
+
+	ctx := WithTracer(context.Background(), tracer)
+	for _, name := range []string{"a", "b", "c"} {
+		_, span := Start(ctx, name)
+		span.End()
+	}
+
+	// A full batch is exported without waiting for the interval or the
+	// shutdown.
+	select {
+	case <-rec.exported:
+	case <-time.After(10 * time.Second):
+		t.Fatal("batch was not exported")
+	}
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	names := rec.names()
+	require.Equal(t, []string{"a", "b"}, names[0])
+
+	var all []string
+	for _, batch := range names {
+		require.True(t, len(batch) <= 2)
+		all = append(all, batch...)
+	}
+	require.Equal(t, []string{"a", "b", "c"}, all)
+}
+
+func TestTracerInterval(t *testing.T) {
+	t.Parallel()
+
+	rec := &batchRecorder{exported: make(chan struct{}, 1)}
+	tracer, err := newTracer(rec, 512, time.Millisecond)
+	require.NoError(t, err)
+
+	_, span := Start(WithTracer(context.Background(), tracer), "a")
+	span.End()
+
+	select {
+	case <-rec.exported:
+	case <-time.After(10 * time.Second):
+		t.Fatal("span was not exported")
+	}
+	require.Equal(t, [][]string{{"a"}}, rec.names())
+}
+
+func TestTracerExportError(t *testing.T) {
+	t.Parallel()
+
+	rec := &batchRecorder{err: errors.New("unavailable")}
+	tracer, err := NewTracer(rec)
+	require.NoError(t, err)
+
+	_, span := Start(WithTracer(context.Background(), tracer), "a")
+	span.End()
+
+	err = tracer.Shutdown(context.Background())
+	require.EqualError(t, err, "unavailable")
+}
@@ -0,0 +1,264 @@
+package solver
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containerd/containerd/content/local"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/ociindex"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/openllb/hlb/pkg/llbutil"
+)
+
+const (
+	// CacheLocal is a cache stored in a local directory.
+	CacheLocal = "local"
+
+	// CacheInline is a cache embedded in the config of an exported image.
+	CacheInline = "inline"
+
+	// CacheRegistry is a cache stored as a separate image in a registry.
+	CacheRegistry = "registry"
+)
+
+// CacheTypes are the types of cache that can be exported and imported.
+var CacheTypes = []string{CacheLocal, CacheInline, CacheRegistry}
+
+const (
+	// cacheTagLatest is the tag BuildKit gives the manifest of every local
+	// cache it exports, replacing the manifest of the previous export.
+	cacheTagLatest = "latest"
+
+	// exporterCacheManifest is the key of the descriptor of the exported cache
+	// manifest in the exporter response.
+	exporterCacheManifest = "cache.manifest"
+)
+
+// NewCacheExport returns a cache export entry to a local directory or a
+// registry reference. The ref is ignored for inline caches, which are exported
+// with the image.
+func NewCacheExport(typ, ref string) (client.CacheOptionsEntry, error) {
+	entry := client.CacheOptionsEntry{Type: typ, Attrs: map[string]string{}}
+	switch typ {
+	case CacheLocal:
+		entry.Attrs["dest"] = ref
+	case CacheRegistry:
+		entry.Attrs["ref"] = ref
+	case CacheInline:
+	default:
+		return entry, fmt.Errorf("unknown cache type %q", typ)
+	}
+	return entry, nil
+}
+
+// NewCacheImport returns a cache import entry from a local directory or a
+// registry reference. Inline caches are imported from the image they were
+// exported with, so they are imported the same way as registry caches.
+func NewCacheImport(typ, ref string) (client.CacheOptionsEntry, error) {
+	entry := client.CacheOptionsEntry{Type: typ, Attrs: map[string]string{}}
+	switch typ {
+	case CacheLocal:
+		entry.Attrs["src"] = ref
+	case CacheRegistry, CacheInline:
+		entry.Type = CacheRegistry
+		entry.Attrs["ref"] = ref
+	default:
+		return entry, fmt.Errorf("unknown cache type %q", typ)
+	}
+	return entry, nil
+}
+
+// ParseCacheOption parses a cache option in the form of comma-separated
+// key-value pairs, e.g. `type=local,dest=/tmp/cache`. A spec without a type is
+// a registry reference.
+func ParseCacheOption(spec string) (client.CacheOptionsEntry, error) {
+	entry := client.CacheOptionsEntry{Attrs: map[string]string{}}
+	if !strings.Contains(spec, "=") {
+		entry.Type = CacheRegistry
+		entry.Attrs["ref"] = spec
+		return entry, nil
+	}
+
+	fields, err := csv.NewReader(strings.NewReader(spec)).Read()
+	if err != nil {
+		return entry, fmt.Errorf("invalid cache option %q: %s", spec, err)
+	}
+
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return entry, fmt.Errorf("invalid cache option %q: expected key=value, got %q", spec, field)
+		}
+		if kv[0] == "type" {
+			entry.Type = kv[1]
+		} else {
+			entry.Attrs[kv[0]] = kv[1]
+		}
+	}
+
+	switch entry.Type {
+	case CacheLocal, CacheInline, CacheRegistry:
+	case "":
+		return entry, fmt.Errorf("invalid cache option %q: missing type", spec)
+	default:
+		return entry, fmt.Errorf("invalid cache option %q: unknown cache type %q", spec, entry.Type)
+	}
+	return entry, nil
+}
+
+// CacheSessionOptions returns the session options to attach the content stores
+// of local caches to a session. BuildKit only attaches them to the sessions it
+// creates, but requests are always solved with a session created by hlb.
+func CacheSessionOptions(opts ...SolveOption) ([]llbutil.SessionOption, error) {
+	info := &SolveInfo{}
+	for _, opt := range opts {
+		err := opt(info)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var sessionOpts []llbutil.SessionOption
+	for _, entry := range info.CacheExports {
+		if entry.Type != CacheLocal {
+			continue
+		}
+		dir := entry.Attrs["dest"]
+		if dir == "" {
+			return nil, fmt.Errorf("local cache export requires dest")
+		}
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return nil, err
+		}
+		store, err := local.NewStore(dir)
+		if err != nil {
+			return nil, err
+		}
+		sessionOpts = append(sessionOpts, llbutil.WithContentStore("local:"+dir, store))
+	}
+
+	for _, entry := range info.CacheImports {
+		if entry.Type != CacheLocal {
+			continue
+		}
+		dir := entry.Attrs["src"]
+		if dir == "" {
+			return nil, fmt.Errorf("local cache import requires src")
+		}
+		// A missing cache is skipped by BuildKit, so that the first build can
+		// import from the directory it exports to.
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		store, err := local.NewStore(dir)
+		if err != nil {
+			return nil, err
+		}
+		sessionOpts = append(sessionOpts, llbutil.WithContentStore("local:"+dir, store))
+	}
+	return sessionOpts, nil
+}
+
+// cacheTagFor returns the tag of the local cache exported by a build in a
+// request tree with other builds, so that the builds don't replace each
+// other's cache by tagging it as latest.
+func cacheTagFor(i int) string {
+	return fmt.Sprintf("%s-%d", cacheTagLatest, i)
+}
+
+// tagCacheExports tags the manifest of the local caches exported by a build
+// with the cache tag of its context, if any.
+func tagCacheExports(ctx context.Context, entries []client.CacheOptionsEntry, resp *client.SolveResponse) error {
+	tag := cacheTag(ctx)
+	dt := resp.ExporterResponse[exporterCacheManifest]
+	if tag == "" || dt == "" {
+		return nil
+	}
+
+	for _, entry := range entries {
+		if entry.Type != CacheLocal {
+			continue
+		}
+
+		var desc specs.Descriptor
+		err := json.Unmarshal([]byte(dt), &desc)
+		if err != nil {
+			return err
+		}
+
+		err = ociindex.PutDescToIndexJSONFileLocked(filepath.Join(entry.Attrs["dest"], "index.json"), desc, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gatewayCacheImports returns the cache imports for solves in a gateway build,
+// which aren't imported from the solve options of the build. Local caches are
+// imported by the digests tagged in their index, and skipped if there are none
+// yet. Without a tag, the caches of every build exporting to the directory are
+// imported.
+func gatewayCacheImports(entries []client.CacheOptionsEntry) []gateway.CacheOptionsEntry {
+	var imports []gateway.CacheOptionsEntry
+	for _, entry := range entries {
+		entry = copyCacheEntry(entry)
+		if entry.Type == CacheLocal && entry.Attrs["digest"] == "" {
+			idx, err := ociindex.ReadIndexJSONFileLocked(filepath.Join(entry.Attrs["src"], "index.json"))
+			if err != nil {
+				continue
+			}
+
+			for _, dgst := range cacheDigests(idx, entry.Attrs["tag"]) {
+				entry = copyCacheEntry(entry)
+				entry.Attrs["digest"] = dgst.String()
+				imports = append(imports, gateway.CacheOptionsEntry{
+					Type:  entry.Type,
+					Attrs: entry.Attrs,
+				})
+			}
+			continue
+		}
+		imports = append(imports, gateway.CacheOptionsEntry{
+			Type:  entry.Type,
+			Attrs: entry.Attrs,
+		})
+	}
+	return imports
+}
+
+// cacheDigests returns the digests of the cache manifests in an index with a
+// tag, or with the tags of cache exports by hlb if the tag is empty.
+func cacheDigests(idx *specs.Index, tag string) []digest.Digest {
+	var dgsts []digest.Digest
+	for _, m := range idx.Manifests {
+		name := m.Annotations[specs.AnnotationRefName]
+		switch {
+		case tag != "" && name != tag:
+			continue
+		case tag == "" && name != cacheTagLatest && !strings.HasPrefix(name, cacheTagLatest+"-"):
+			continue
+		}
+
+		dup := false
+		for _, dgst := range dgsts {
+			if dgst == m.Digest {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			dgsts = append(dgsts, m.Digest)
+		}
+	}
+	return dgsts
+}
@@ -0,0 +1,109 @@
+package solver
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/ociindex"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayCacheImports(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hlb-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	latest, separate, other := digest.FromString("latest"), digest.FromString("separate"), digest.FromString("other")
+	for _, m := range []struct {
+		dgst digest.Digest
+		tag  string
+	}{
+		{latest, cacheTagLatest},
+		{separate, cacheTagFor(1)},
+		{latest, cacheTagFor(2)},
+		{other, "other"},
+	} {
+		err = ociindex.PutDescToIndexJSONFileLocked(filepath.Join(dir, "index.json"), specs.Descriptor{Digest: m.dgst}, m.tag)
+		require.NoError(t, err)
+	}
+
+	local := func(attrs map[string]string) client.CacheOptionsEntry {
+		return client.CacheOptionsEntry{Type: CacheLocal, Attrs: attrs}
+	}
+	for _, tc := range []struct {
+		name     string
+		entry    client.CacheOptionsEntry
+		expected []gateway.CacheOptionsEntry
+	}{{
+		"every build",
+		local(map[string]string{"src": dir}),
+		[]gateway.CacheOptionsEntry{
+			{Type: CacheLocal, Attrs: map[string]string{"src": dir, "digest": latest.String()}},
+			{Type: CacheLocal, Attrs: map[string]string{"src": dir, "digest": separate.String()}},
+		},
+	}, {
+		"tag",
+		local(map[string]string{"src": dir, "tag": "other"}),
+		[]gateway.CacheOptionsEntry{
+			{Type: CacheLocal, Attrs: map[string]string{"src": dir, "tag": "other", "digest": other.String()}},
+		},
+	}, {
+		"digest",
+		local(map[string]string{"src": dir, "digest": other.String()}),
+		[]gateway.CacheOptionsEntry{
+			{Type: CacheLocal, Attrs: map[string]string{"src": dir, "digest": other.String()}},
+		},
+	}, {
+		"missing cache",
+		local(map[string]string{"src": filepath.Join(dir, "missing")}),
+		nil,
+	}, {
+		"registry",
+		client.CacheOptionsEntry{Type: CacheRegistry, Attrs: map[string]string{"ref": "docker.io/openllb/cache"}},
+		[]gateway.CacheOptionsEntry{
+			{Type: CacheRegistry, Attrs: map[string]string{"ref": "docker.io/openllb/cache"}},
+		},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, gatewayCacheImports([]client.CacheOptionsEntry{tc.entry}))
+		})
+	}
+}
+
+func TestTagCacheExports(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hlb-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dt, err := json.Marshal(specs.Descriptor{Digest: digest.FromString("separate")})
+	require.NoError(t, err)
+	resp := &client.SolveResponse{ExporterResponse: map[string]string{exporterCacheManifest: string(dt)}}
+	entries := []client.CacheOptionsEntry{{Type: CacheLocal, Attrs: map[string]string{"dest": dir}}}
+
+	// Builds without a cache tag are only tagged as latest by BuildKit.
+	err = tagCacheExports(context.Background(), entries, resp)
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "index.json"))
+	require.True(t, os.IsNotExist(err))
+
+	err = tagCacheExports(withCacheTag(context.Background(), cacheTagFor(1)), entries, resp)
+	require.NoError(t, err)
+
+	idx, err := ociindex.ReadIndexJSONFileLocked(filepath.Join(dir, "index.json"))
+	require.NoError(t, err)
+	require.Equal(t, []digest.Digest{digest.FromString("separate")}, cacheDigests(idx, ""))
+	require.Equal(t, cacheTagFor(1), idx.Manifests[0].Annotations[specs.AnnotationRefName])
+}
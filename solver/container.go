@@ -0,0 +1,156 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/containerd/console"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/errdefs"
+)
+
+// RunContainer creates a container with the requested mounts and runs a
+// process in it until it exits. If stdin is a terminal, it is put in raw mode
+// and attached as the TTY of the process.
+//
+// Reads from stdin are forwarded until the process exits. Pass an Input that
+// is shared with the other readers of stdin, so that a read still pending at
+// that point doesn't consume their next input.
+func RunContainer(ctx context.Context, c gateway.Client, req gateway.NewContainerRequest, start gateway.StartRequest, stdin io.Reader, stdout, stderr io.Writer) error {
+	ctr, err := c.NewContainer(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer ctr.Release(context.Background())
+
+	in, ok := stdin.(*Input)
+	if !ok {
+		in = NewInput(stdin)
+	}
+
+	var con console.Console
+	if f, ok := in.File(); ok {
+		con, err = console.ConsoleFromFile(f)
+		if err == nil {
+			err = con.SetRaw()
+			if err != nil {
+				return err
+			}
+			defer con.Reset()
+			start.Tty = true
+		}
+	}
+
+	// Stop forwarding stdin once the process exits.
+	inCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(copyInput(inCtx, pw, in))
+	}()
+
+	start.Stdin = pr
+	start.Stdout = nopWriteCloser{stdout}
+	start.Stderr = nopWriteCloser{stderr}
+
+	proc, err := ctr.Start(ctx, start)
+	if err != nil {
+		return err
+	}
+
+	if con != nil {
+		size, err := con.Size()
+		if err == nil {
+			err = proc.Resize(ctx, gateway.WinSize{
+				Rows: uint32(size.Height),
+				Cols: uint32(size.Width),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return proc.Wait()
+}
+
+// RunContainerOnError runs a process in the container of a failed exec, with
+// the mounts left behind by the failure and the same environment, working
+// directory, user, network and security mode. It returns false if the error
+// didn't come from an exec.
+func RunContainerOnError(ctx context.Context, c gateway.Client, solveErr error, args []string, stdin io.Reader, stdout, stderr io.Writer) (bool, error) {
+	var se *errdefs.SolveError
+	if !errors.As(solveErr, &se) {
+		return false, nil
+	}
+
+	op := se.Solve.Op
+	exec := op.GetExec()
+	if exec == nil {
+		return false, nil
+	}
+
+	var mounts []gateway.Mount
+	for i, m := range exec.Mounts {
+		mount := gateway.Mount{
+			Selector:  m.Selector,
+			Dest:      m.Dest,
+			Readonly:  m.Readonly,
+			MountType: m.MountType,
+			CacheOpt:  m.CacheOpt,
+			SecretOpt: m.SecretOpt,
+			SSHOpt:    m.SSHOpt,
+		}
+		if i < len(se.MountIDs) {
+			mount.ResultID = se.MountIDs[i]
+		}
+		mounts = append(mounts, mount)
+	}
+
+	return true, RunContainer(ctx, c,
+		gateway.NewContainerRequest{
+			Mounts:      mounts,
+			NetMode:     exec.Network,
+			Platform:    op.Platform,
+			Constraints: op.Constraints,
+		},
+		gateway.StartRequest{
+			Args:         args,
+			Env:          exec.Meta.Env,
+			User:         exec.Meta.User,
+			Cwd:          exec.Meta.Cwd,
+			SecurityMode: exec.Security,
+		},
+		stdin, stdout, stderr,
+	)
+}
+
+// copyInput copies from an input until the context is done.
+func copyInput(ctx context.Context, w io.Writer, in *Input) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := in.ReadContext(ctx, buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,92 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/errdefs"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+// containerClient is a gateway client that records the containers it is asked
+// to create, without creating them.
+type containerClient struct {
+	gateway.Client
+	req gateway.NewContainerRequest
+	err error
+}
+
+func (c *containerClient) NewContainer(ctx context.Context, req gateway.NewContainerRequest) (gateway.Container, error) {
+	c.req = req
+	return nil, c.err
+}
+
+func TestRunContainerOnError(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	exec := &pb.ExecOp{
+		Meta: &pb.Meta{
+			Args: []string{"false"},
+			Env:  []string{"PATH=/bin"},
+			Cwd:  "/src",
+		},
+		Mounts: []*pb.Mount{{
+			Dest:   "/",
+			Output: 0,
+		}, {
+			Dest:     "/src",
+			Selector: "/app",
+			Readonly: true,
+			Output:   pb.SkipOutput,
+		}, {
+			Dest:      "/cache",
+			MountType: pb.MountType_CACHE,
+			CacheOpt:  &pb.CacheOpt{ID: "go"},
+			Output:    pb.SkipOutput,
+		}},
+		Network: pb.NetMode_NONE,
+	}
+	solveErr := &errdefs.SolveError{
+		Solve: errdefs.Solve{
+			Op:       &pb.Op{Op: &pb.Op_Exec{Exec: exec}},
+			MountIDs: []string{"root", "src"},
+		},
+		Err: errors.New("exit code: 1"),
+	}
+
+	c := &containerClient{err: errors.New("no containers")}
+	ok, err := RunContainerOnError(ctx, c, solveErr, []string{"sh"}, strings.NewReader(""), nil, nil)
+	require.True(t, ok)
+	require.Equal(t, c.err, err)
+
+	// Each mount of the failed exec is backed by the mount left behind by the
+	// failure, if any.
+	require.Equal(t, pb.NetMode_NONE, c.req.NetMode)
+	require.Equal(t, []gateway.Mount{{
+		Dest:     "/",
+		ResultID: "root",
+	}, {
+		Dest:     "/src",
+		Selector: "/app",
+		Readonly: true,
+		ResultID: "src",
+	}, {
+		Dest:      "/cache",
+		MountType: pb.MountType_CACHE,
+		CacheOpt:  &pb.CacheOpt{ID: "go"},
+	}}, c.req.Mounts)
+}
+
+func TestRunContainerOnErrorNotExec(t *testing.T) {
+	t.Parallel()
+
+	c := &containerClient{}
+	ok, err := RunContainerOnError(context.Background(), c, errors.New("failed to resolve"), nil, nil, nil, nil)
+	require.False(t, ok)
+	require.NoError(t, err)
+}
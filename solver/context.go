@@ -0,0 +1,30 @@
+package solver
+
+import "context"
+
+type solveOptionsKey struct{}
+
+// WithSolveOptions returns a context with solve options that are applied to
+// every request solved with the context.
+func WithSolveOptions(ctx context.Context, opts ...SolveOption) context.Context {
+	opts = append(append([]SolveOption{}, SolveOptions(ctx)...), opts...)
+	return context.WithValue(ctx, solveOptionsKey{}, opts)
+}
+
+func SolveOptions(ctx context.Context) []SolveOption {
+	opts, _ := ctx.Value(solveOptionsKey{}).([]SolveOption)
+	return opts
+}
+
+type cacheTagKey struct{}
+
+// withCacheTag returns a context for a build whose local cache exports are
+// tagged in addition to the latest tag.
+func withCacheTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, cacheTagKey{}, tag)
+}
+
+func cacheTag(ctx context.Context) string {
+	tag, _ := ctx.Value(cacheTagKey{}).(string)
+	return tag
+}
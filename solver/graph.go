@@ -0,0 +1,371 @@
+package solver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// Graph is a request tree with the vertices of the LLB definitions of its
+// leaves. A leaf has vertices and edges, otherwise it has either parallel or
+// sequential children.
+type Graph struct {
+	// Outputs are the exporters of a leaf, like pushing or downloading it.
+	Outputs []string `json:"outputs,omitempty"`
+
+	Vertices   []Vertex `json:"vertices,omitempty"`
+	Edges      []Edge   `json:"edges,omitempty"`
+	Parallel   []*Graph `json:"parallel,omitempty"`
+	Sequential []*Graph `json:"sequential,omitempty"`
+}
+
+// Vertex is a LLB op.
+type Vertex struct {
+	Digest digest.Digest `json:"digest"`
+	Type   string        `json:"type"`
+	Label  string        `json:"label"`
+
+	// Source is the scheme of a source op's identifier, like docker-image, git
+	// or local.
+	Source string `json:"source,omitempty"`
+
+	// Location is the HLB source span that produced the op.
+	Location string `json:"location,omitempty"`
+}
+
+// Edge is an input of a LLB op. The label is the mountpoint of the input if
+// it is mounted by an exec op.
+type Edge struct {
+	From  digest.Digest `json:"from"`
+	To    digest.Digest `json:"to"`
+	Label string        `json:"label,omitempty"`
+}
+
+// NewGraph returns the graph of a request tree.
+func NewGraph(req Request) (*Graph, error) {
+	l, err := req.LLB()
+	if err != nil {
+		return nil, err
+	}
+	return newGraphFromLLB(l), nil
+}
+
+func newGraphFromLLB(l *LLB) *Graph {
+	g := &Graph{}
+	for _, child := range l.Parallel {
+		g.Parallel = append(g.Parallel, newGraphFromLLB(child))
+	}
+	for _, child := range l.Sequential {
+		g.Sequential = append(g.Sequential, newGraphFromLLB(child))
+	}
+
+	if info := l.SolveOptions; info != nil {
+		if info.OutputDockerRef != "" {
+			g.Outputs = append(g.Outputs, fmt.Sprintf("dockerRef %s", info.OutputDockerRef))
+		}
+		if info.OutputPushImage != "" {
+			g.Outputs = append(g.Outputs, fmt.Sprintf("pushImage %s", info.OutputPushImage))
+		}
+		if info.OutputLocal != "" {
+			g.Outputs = append(g.Outputs, fmt.Sprintf("download %s", info.OutputLocal))
+		}
+		if info.OutputLocalTarball {
+			g.Outputs = append(g.Outputs, "downloadTarball")
+		}
+		if info.OutputLocalOCITarball {
+			g.Outputs = append(g.Outputs, "downloadOCITarball")
+		}
+	}
+
+	var source *pb.Source
+	if l.def != nil {
+		source = l.def.Source
+	}
+
+	for _, op := range l.Ops {
+		// The last op of a definition only selects its output.
+		if op.Op.Op == nil {
+			continue
+		}
+
+		typ, label := opLabel(op.Op)
+		v := Vertex{
+			Digest:   op.Digest,
+			Type:     typ,
+			Label:    label,
+			Location: opLocation(source, op.Digest),
+		}
+		if src, ok := op.Op.Op.(*pb.Op_Source); ok {
+			v.Source = strings.SplitN(src.Source.Identifier, "://", 2)[0]
+
+			// Local sources are identified by a digest of their path.
+			if localPath, ok := op.OpMetadata.Description[LocalPathDescriptionKey]; ok {
+				v.Label = localPath
+			}
+		}
+		g.Vertices = append(g.Vertices, v)
+
+		for i, input := range op.Op.Inputs {
+			e := Edge{From: input.Digest, To: op.Digest}
+			if exec, ok := op.Op.Op.(*pb.Op_Exec); ok {
+				for _, mnt := range exec.Exec.Mounts {
+					if int(mnt.Input) == i && mnt.Dest != "/" {
+						e.Label = mnt.Dest
+					}
+				}
+			}
+			g.Edges = append(g.Edges, e)
+		}
+	}
+	return g
+}
+
+func opLabel(op pb.Op) (string, string) {
+	switch op := op.Op.(type) {
+	case *pb.Op_Source:
+		return "source", op.Source.Identifier
+	case *pb.Op_Exec:
+		return "exec", strings.Join(op.Exec.Meta.Args, " ")
+	case *pb.Op_Build:
+		return "build", "build"
+	case *pb.Op_File:
+		var names []string
+		for _, action := range op.File.Actions {
+			switch act := action.Action.(type) {
+			case *pb.FileAction_Copy:
+				names = append(names, fmt.Sprintf("copy{src=%s, dest=%s}", act.Copy.Src, act.Copy.Dest))
+			case *pb.FileAction_Mkfile:
+				names = append(names, fmt.Sprintf("mkfile{path=%s}", act.Mkfile.Path))
+			case *pb.FileAction_Mkdir:
+				names = append(names, fmt.Sprintf("mkdir{path=%s}", act.Mkdir.Path))
+			case *pb.FileAction_Rm:
+				names = append(names, fmt.Sprintf("rm{path=%s}", act.Rm.Path))
+			}
+		}
+		return "file", strings.Join(names, ",")
+	default:
+		return "unknown", ""
+	}
+}
+
+// opLocation returns the start of the innermost source span of an op.
+func opLocation(source *pb.Source, dgst digest.Digest) string {
+	filename, r := opRange(source, dgst)
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%d", filepath.Base(filename), r.Start.Line, r.Start.Character)
+}
+
+// opRange returns the filename and range of the innermost source span of an
+// op, or a nil range if it has none.
+func opRange(source *pb.Source, dgst digest.Digest) (string, *pb.Range) {
+	if source == nil {
+		return "", nil
+	}
+
+	locs, ok := source.Locations[dgst.String()]
+	if !ok {
+		return "", nil
+	}
+
+	for _, loc := range locs.Locations {
+		if int(loc.SourceIndex) >= len(source.Infos) || len(loc.Ranges) == 0 {
+			continue
+		}
+		return source.Infos[loc.SourceIndex].Filename, loc.Ranges[0]
+	}
+	return "", nil
+}
+
+// WriteJSON writes the graph as indented JSON.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// sourceColors are the colors of source ops by scheme.
+var sourceColors = map[string]string{
+	"docker-image": "#a6cee3",
+	"git":          "#fdbf6f",
+	"local":        "#b2df8a",
+	"http":         "#fb9a99",
+	"https":        "#fb9a99",
+}
+
+// WriteDot writes the graph in the DOT language, with each request as a
+// cluster of its vertices.
+func (g *Graph) WriteDot(w io.Writer) error {
+	gw := &graphWriter{w: w}
+	gw.printf("digraph {\n")
+	gw.printf("  node [style=filled fillcolor=white];\n")
+	g.writeDot(gw, "", "  ")
+	gw.printf("}\n")
+	return gw.err
+}
+
+func (g *Graph) writeDot(gw *graphWriter, prefix, indent string) {
+	writeChildren := func(kind string, children []*Graph) {
+		gw.printf("%ssubgraph %s {\n", indent, dotString("cluster_"+prefix+kind))
+		gw.printf("%s  label=%s;\n", indent, dotString(kind))
+		gw.printf("%s  style=dashed;\n", indent)
+		for i, child := range children {
+			child.writeDot(gw, fmt.Sprintf("%s%d_", prefix, i), indent+"  ")
+		}
+		gw.printf("%s}\n", indent)
+	}
+
+	switch {
+	case len(g.Parallel) > 0:
+		writeChildren("parallel", g.Parallel)
+		return
+	case len(g.Sequential) > 0:
+		writeChildren("sequential", g.Sequential)
+		return
+	case len(g.Vertices) == 0:
+		return
+	}
+
+	gw.printf("%ssubgraph %s {\n", indent, dotString("cluster_"+prefix+"request"))
+	gw.printf("%s  label=%s;\n", indent, dotString(g.label()))
+	for _, v := range g.Vertices {
+		label := v.Label
+		if v.Location != "" {
+			label = fmt.Sprintf("%s\n%s", label, v.Location)
+		}
+		attrs := fmt.Sprintf("label=%s shape=%s", dotString(label), dotString(vertexShape(v)))
+		if color, ok := sourceColors[v.Source]; ok {
+			attrs += fmt.Sprintf(" fillcolor=%s", dotString(color))
+		}
+		gw.printf("%s  %s [%s];\n", indent, dotString(prefix+v.Digest.String()), attrs)
+	}
+	for _, e := range g.Edges {
+		gw.printf("%s  %s -> %s [label=%s];\n", indent, dotString(prefix+e.From.String()), dotString(prefix+e.To.String()), dotString(e.Label))
+	}
+	gw.printf("%s}\n", indent)
+}
+
+func (g *Graph) label() string {
+	return strings.Join(append([]string{"request"}, g.Outputs...), "\n")
+}
+
+func vertexShape(v Vertex) string {
+	switch v.Type {
+	case "source":
+		return "ellipse"
+	case "exec":
+		return "box"
+	case "build":
+		return "box3d"
+	case "file":
+		return "note"
+	default:
+		return "plaintext"
+	}
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart, with each request as
+// a subgraph of its vertices.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	gw := &graphWriter{w: w, ids: make(map[string]int)}
+	gw.printf("flowchart BT\n")
+	for _, source := range []string{"docker-image", "git", "local", "http"} {
+		gw.printf("  classDef %s fill:%s\n", mermaidClass(source), sourceColors[source])
+	}
+	g.writeMermaid(gw, "", "  ")
+	return gw.err
+}
+
+func (g *Graph) writeMermaid(gw *graphWriter, prefix, indent string) {
+	writeChildren := func(kind string, children []*Graph) {
+		gw.printf("%ssubgraph %s [%s]\n", indent, gw.id(prefix+kind), kind)
+		for i, child := range children {
+			child.writeMermaid(gw, fmt.Sprintf("%s%d_", prefix, i), indent+"  ")
+		}
+		gw.printf("%send\n", indent)
+	}
+
+	switch {
+	case len(g.Parallel) > 0:
+		writeChildren("parallel", g.Parallel)
+		return
+	case len(g.Sequential) > 0:
+		writeChildren("sequential", g.Sequential)
+		return
+	case len(g.Vertices) == 0:
+		return
+	}
+
+	gw.printf("%ssubgraph %s [%s]\n", indent, gw.id(prefix+"request"), mermaidString(g.label()))
+	for _, v := range g.Vertices {
+		label := v.Label
+		if v.Location != "" {
+			label = fmt.Sprintf("%s\n%s", label, v.Location)
+		}
+		gw.printf("%s  %s[%s]\n", indent, gw.id(prefix+v.Digest.String()), mermaidString(label))
+		if _, ok := sourceColors[v.Source]; ok {
+			gw.printf("%s  class %s %s\n", indent, gw.id(prefix+v.Digest.String()), mermaidClass(v.Source))
+		}
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Label != "" {
+			arrow = fmt.Sprintf("-->|%s|", mermaidString(e.Label))
+		}
+		gw.printf("%s  %s %s %s\n", indent, gw.id(prefix+e.From.String()), arrow, gw.id(prefix+e.To.String()))
+	}
+	gw.printf("%send\n", indent)
+}
+
+// dotString quotes a string as a DOT identifier, where a newline is a centered
+// line break.
+func dotString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// mermaidString quotes a string as Mermaid text, which has no escape sequences
+// so quotes are written as entity codes and newlines as HTML line breaks.
+func mermaidString(s string) string {
+	return `"` + strings.NewReplacer(`"`, "#quot;", "\n", "<br/>").Replace(s) + `"`
+}
+
+func mermaidClass(source string) string {
+	switch source {
+	case "docker-image":
+		return "image"
+	case "https":
+		return "http"
+	default:
+		return source
+	}
+}
+
+// graphWriter keeps the first error writing a graph, and assigns short
+// identifiers to nodes for formats that don't allow quoted identifiers.
+type graphWriter struct {
+	w   io.Writer
+	err error
+	ids map[string]int
+}
+
+func (gw *graphWriter) printf(format string, a ...interface{}) {
+	if gw.err != nil {
+		return
+	}
+	_, gw.err = fmt.Fprintf(gw.w, format, a...)
+}
+
+func (gw *graphWriter) id(key string) string {
+	n, ok := gw.ids[key]
+	if !ok {
+		n = len(gw.ids)
+		gw.ids[key] = n
+	}
+	return fmt.Sprintf("n%d", n)
+}
@@ -0,0 +1,108 @@
+package solver
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testGraph returns a graph of two sequential requests, with labels that need
+// escaping.
+func testGraph() *Graph {
+	return &Graph{
+		Sequential: []*Graph{{
+			Outputs: []string{"download ./out"},
+			Vertices: []Vertex{{
+				Digest:   "sha256:a",
+				Type:     "source",
+				Label:    "docker-image://docker.io/library/alpine:latest",
+				Source:   "docker-image",
+				Location: "build.hlb:2:2",
+			}, {
+				Digest: "sha256:b",
+				Type:   "source",
+				Label:  "./src",
+				Source: "local",
+			}, {
+				Digest: "sha256:c",
+				Type:   "exec",
+				Label:  `sh -c "echo \"hi\" > C:\out"`,
+			}},
+			Edges: []Edge{{
+				From: "sha256:a",
+				To:   "sha256:c",
+			}, {
+				From:  "sha256:b",
+				To:    "sha256:c",
+				Label: `/src "x"`,
+			}},
+		}, {
+			Vertices: []Vertex{{
+				Digest: "sha256:a",
+				Type:   "file",
+				Label:  "mkdir{path=/out}",
+			}},
+		}},
+	}
+}
+
+const expectedDot = `digraph {
+  node [style=filled fillcolor=white];
+  subgraph "cluster_sequential" {
+    label="sequential";
+    style=dashed;
+    subgraph "cluster_0_request" {
+      label="request\ndownload ./out";
+      "0_sha256:a" [label="docker-image://docker.io/library/alpine:latest\nbuild.hlb:2:2" shape="ellipse" fillcolor="#a6cee3"];
+      "0_sha256:b" [label="./src" shape="ellipse" fillcolor="#b2df8a"];
+      "0_sha256:c" [label="sh -c \"echo \\\"hi\\\" > C:\\out\"" shape="box"];
+      "0_sha256:a" -> "0_sha256:c" [label=""];
+      "0_sha256:b" -> "0_sha256:c" [label="/src \"x\""];
+    }
+    subgraph "cluster_1_request" {
+      label="request";
+      "1_sha256:a" [label="mkdir{path=/out}" shape="note"];
+    }
+  }
+}
+`
+
+const expectedMermaid = `flowchart BT
+  classDef image fill:#a6cee3
+  classDef git fill:#fdbf6f
+  classDef local fill:#b2df8a
+  classDef http fill:#fb9a99
+  subgraph n0 [sequential]
+    subgraph n1 ["request<br/>download ./out"]
+      n2["docker-image://docker.io/library/alpine:latest<br/>build.hlb:2:2"]
+      class n2 image
+      n3["./src"]
+      class n3 local
+      n4["sh -c #quot;echo \#quot;hi\#quot; > C:\out#quot;"]
+      n2 --> n4
+      n3 -->|"/src #quot;x#quot;"| n4
+    end
+    subgraph n5 ["request"]
+      n6["mkdir{path=/out}"]
+    end
+  end
+`
+
+func TestGraphWriteDot(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := testGraph().WriteDot(&buf)
+	require.NoError(t, err)
+	require.Equal(t, expectedDot, buf.String())
+}
+
+func TestGraphWriteMermaid(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := testGraph().WriteMermaid(&buf)
+	require.NoError(t, err)
+	require.Equal(t, expectedMermaid, buf.String())
+}
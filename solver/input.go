@@ -0,0 +1,99 @@
+package solver
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+)
+
+// Input is a reader that reads from its source in the background, so that a
+// read can be abandoned, e.g. when the process it forwards input to exits.
+// Input read after a read was abandoned is kept for the next read instead of
+// being lost, so the same Input must be shared by every reader of the source.
+type Input struct {
+	src io.Reader
+
+	mu      sync.Mutex
+	buf     []byte
+	err     error
+	pending chan struct{}
+}
+
+func NewInput(src io.Reader) *Input {
+	return &Input{src: src}
+}
+
+// File returns the source of the input if it is a file, such as a terminal.
+func (in *Input) File() (*os.File, bool) {
+	f, ok := in.src.(*os.File)
+	return f, ok
+}
+
+func (in *Input) Read(p []byte) (int, error) {
+	return in.ReadContext(context.Background(), p)
+}
+
+// ReadString reads until the first occurrence of delim, like
+// bufio.Reader.ReadString. Input after delim stays buffered in the Input, so
+// it is not lost to the next reader.
+func (in *Input) ReadString(delim byte) (string, error) {
+	var (
+		line []byte
+		p    = make([]byte, 1)
+	)
+	for {
+		_, err := in.Read(p)
+		if err != nil {
+			return string(line), err
+		}
+		line = append(line, p[0])
+		if p[0] == delim {
+			return string(line), nil
+		}
+	}
+}
+
+// ReadContext reads like Read, but returns early with the error of the context
+// when it is done. A read from the source is only started while there is a
+// reader waiting for it.
+func (in *Input) ReadContext(ctx context.Context, p []byte) (int, error) {
+	for {
+		in.mu.Lock()
+		if len(in.buf) > 0 {
+			n := copy(p, in.buf)
+			in.buf = in.buf[n:]
+			in.mu.Unlock()
+			return n, nil
+		}
+		if in.err != nil {
+			err := in.err
+			in.mu.Unlock()
+			return 0, err
+		}
+		if in.pending == nil {
+			in.pending = make(chan struct{})
+			go in.fill(in.pending)
+		}
+		pending := in.pending
+		in.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-pending:
+		}
+	}
+}
+
+func (in *Input) fill(pending chan struct{}) {
+	buf := make([]byte, 32*1024)
+	n, err := in.src.Read(buf)
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.buf = append(in.buf, buf[:n]...)
+	in.err = err
+	in.pending = nil
+	close(pending)
+}
@@ -0,0 +1,59 @@
+package solver
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputAbandonedRead(t *testing.T) {
+	t.Parallel()
+
+	pr, pw := io.Pipe()
+	in := NewInput(pr)
+
+	// A read abandoned while waiting on the source, like the stdin of a process
+	// that exited, leaves the read from the source pending.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := in.ReadContext(ctx, make([]byte, 8))
+		done <- err
+	}()
+	cancel()
+	require.Equal(t, context.Canceled, <-done)
+
+	// The input read by the pending read is kept for the next reader.
+	go func() {
+		pw.Write([]byte("next\n"))
+		pw.Close()
+	}()
+
+	dt, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.Equal(t, "next\n", string(dt))
+}
+
+func TestInputReadString(t *testing.T) {
+	t.Parallel()
+
+	pr, pw := io.Pipe()
+	in := NewInput(pr)
+
+	// Input typed ahead of a line must stay buffered for the next reader.
+	go func() {
+		pw.Write([]byte("exec\nls\n"))
+		pw.Close()
+	}()
+
+	line, err := in.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "exec\n", line)
+
+	dt, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.Equal(t, "ls\n", string(dt))
+}
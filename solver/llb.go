@@ -0,0 +1,104 @@
+package solver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// LLB is a request tree of LLB definitions. A leaf has the ops of its
+// definition and the options it is solved with, otherwise it has either
+// parallel or sequential children.
+type LLB struct {
+	Ops          []LLBOp    `json:"ops,omitempty"`
+	SolveOptions *SolveInfo `json:"solveOptions,omitempty"`
+	Parallel     []*LLB     `json:"parallel,omitempty"`
+	Sequential   []*LLB     `json:"sequential,omitempty"`
+
+	def *llb.Definition
+}
+
+// LLBOp is an op of a LLB definition, in the same form that is printed by
+// `buildctl debug dump-llb`.
+type LLBOp struct {
+	Op         pb.Op
+	Digest     digest.Digest
+	OpMetadata pb.OpMetadata
+}
+
+func newLLBFromDefinition(def *llb.Definition, opts []SolveOption) (*LLB, error) {
+	info := &SolveInfo{}
+	for _, opt := range opts {
+		err := opt(info)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l := &LLB{
+		SolveOptions: info,
+		def:          def,
+	}
+	for _, dt := range def.Def {
+		var op pb.Op
+		if err := (&op).Unmarshal(dt); err != nil {
+			return nil, err
+		}
+		dgst := digest.FromBytes(dt)
+		l.Ops = append(l.Ops, LLBOp{
+			Op:         op,
+			Digest:     dgst,
+			OpMetadata: def.Metadata[dgst],
+		})
+	}
+	return l, nil
+}
+
+// Definitions returns the LLB definitions of the leaves of the tree, in the
+// order they are declared.
+func (l *LLB) Definitions() []*llb.Definition {
+	if l.def != nil {
+		return []*llb.Definition{l.def}
+	}
+
+	var defs []*llb.Definition
+	for _, child := range append(append([]*LLB{}, l.Parallel...), l.Sequential...) {
+		defs = append(defs, child.Definitions()...)
+	}
+	return defs
+}
+
+// WriteJSON writes the tree as indented JSON.
+func (l *LLB) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
+
+// WriteProtobuf writes the LLB definition of a tree with a single leaf as
+// protobuf, which can be read by tools like `buildctl build`. Definitions
+// written back to back are read as one, so trees with multiple leaves are
+// rejected.
+func (l *LLB) WriteProtobuf(w io.Writer) error {
+	defs := l.Definitions()
+	if len(defs) > 1 {
+		return fmt.Errorf("cannot write %d LLB definitions as protobuf, only a single definition is supported", len(defs))
+	}
+
+	for _, def := range defs {
+		dt, err := def.ToPB().Marshal()
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(dt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
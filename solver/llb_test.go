@@ -0,0 +1,44 @@
+package solver
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLLBWriteProtobuf(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	def, err := llb.Image("alpine").Marshal(ctx, llb.LinuxAmd64)
+	require.NoError(t, err)
+
+	l, err := Single(&Params{Def: def}).LLB()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = l.WriteProtobuf(&buf)
+	require.NoError(t, err)
+
+	var actual pb.Definition
+	err = actual.Unmarshal(buf.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, def.Def, actual.Def)
+
+	// Definitions written back to back would be read as one, so a tree with
+	// multiple leaves can't be written as protobuf.
+	other, err := llb.Image("busybox").Marshal(ctx, llb.LinuxAmd64)
+	require.NoError(t, err)
+
+	l, err = Parallel(Single(&Params{Def: def}), Single(&Params{Def: other})).LLB()
+	require.NoError(t, err)
+
+	buf.Reset()
+	err = l.WriteProtobuf(&buf)
+	require.EqualError(t, err, "cannot write 2 LLB definitions as protobuf, only a single definition is supported")
+	require.Zero(t, buf.Len())
+}
@@ -18,6 +18,7 @@ type ProgressOption func(*ProgressInfo) error
 
 type ProgressInfo struct {
 	LogOutput LogOutput
+	Recorder  *Recorder
 }
 
 type LogOutput int
@@ -25,6 +26,7 @@ type LogOutput int
 const (
 	LogOutputTTY LogOutput = iota
 	LogOutputPlain
+	LogOutputJSON
 )
 
 func WithLogOutput(logOutput LogOutput) ProgressOption {
@@ -34,6 +36,14 @@ func WithLogOutput(logOutput LogOutput) ProgressOption {
 	}
 }
 
+// WithRecorder records the vertexes of the progress for a timing report.
+func WithRecorder(rec *Recorder) ProgressOption {
+	return func(info *ProgressInfo) error {
+		info.Recorder = rec
+		return nil
+	}
+}
+
 type Progress interface {
 	MultiWriter() *progress.MultiWriter
 
@@ -98,18 +108,37 @@ func NewProgress(ctx context.Context, opts ...ProgressOption) (Progress, error)
 	// graceful exit and report error.
 	pctx, cancel := context.WithCancel(context.Background())
 
-	var pw progress.Writer
+	var (
+		pw   progress.Writer
+		tags *vertexTags
+	)
 
 	switch info.LogOutput {
 	case LogOutputTTY:
 		pw = progress.NewPrinter(pctx, os.Stderr, "tty")
 	case LogOutputPlain:
 		pw = progress.NewPrinter(pctx, os.Stderr, "plain")
+	case LogOutputJSON:
+		tags = newVertexTags()
+		pw = newJSONPrinter(os.Stderr, tags)
 	default:
 		cancel()
 		return nil, errors.Errorf("unknown log output %q", info.LogOutput)
 	}
 
+	// Timing reports map vertexes back to their targets and source locations,
+	// so they need the vertexes tagged regardless of the log output.
+	if info.Recorder != nil {
+		if tags == nil {
+			tags = newVertexTags()
+		}
+		info.Recorder.tags = tags
+		pw = recordWriter(pw, info.Recorder)
+	}
+	if tags != nil {
+		ctx = context.WithValue(ctx, vertexTagsKey{}, tags)
+	}
+
 	g, ctx := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
@@ -137,7 +166,16 @@ func NewProgress(ctx context.Context, opts ...ProgressOption) (Progress, error)
 		return nil
 	})
 
-	return &progressUI{mw, ctx, g, done}, nil
+	return &progressUI{mw, ctx, g, done, tags}, nil
+}
+
+// WithProgress returns a context where solves tag their vertexes for the
+// progress, if its output needs them.
+func WithProgress(ctx context.Context, p Progress) context.Context {
+	if ui, ok := p.(*progressUI); ok && ui.tags != nil {
+		ctx = context.WithValue(ctx, vertexTagsKey{}, ui.tags)
+	}
+	return ctx
 }
 
 type progressUI struct {
@@ -145,6 +183,7 @@ type progressUI struct {
 	ctx  context.Context
 	g    *errgroup.Group
 	done chan struct{}
+	tags *vertexTags
 }
 
 func (p *progressUI) MultiWriter() *progress.MultiWriter {
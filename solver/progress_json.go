@@ -0,0 +1,295 @@
+package solver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/buildx/util/progress"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/openllb/hlb/diagnostic"
+)
+
+// ProgressEvent is an event of the JSON progress stream. Each update of a
+// vertex, a status of a vertex or a log of a vertex is written as an event.
+type ProgressEvent struct {
+	// Type is either vertex, status or log.
+	Type   string        `json:"type"`
+	Vertex digest.Digest `json:"vertex"`
+
+	// Prefixes are the requests that solved the vertex, such as the targets or
+	// imports, and Location is the HLB source that the vertex was compiled
+	// from. A vertex shared by several requests is tagged with each of them.
+	Prefixes []string             `json:"prefixes,omitempty"`
+	Location *diagnostic.Location `json:"location,omitempty"`
+
+	Name      string          `json:"name,omitempty"`
+	Inputs    []digest.Digest `json:"inputs,omitempty"`
+	Timestamp *time.Time      `json:"timestamp,omitempty"`
+	Started   *time.Time      `json:"started,omitempty"`
+	Completed *time.Time      `json:"completed,omitempty"`
+	Cached    bool            `json:"cached,omitempty"`
+	Error     string          `json:"error,omitempty"`
+
+	// ID, Current and Total are the progress of a status, such as the bytes
+	// of a layer being pulled.
+	ID      string `json:"id,omitempty"`
+	Current int64  `json:"current,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+
+	// Stream is 1 for stdout and 2 for stderr of a log.
+	Stream int    `json:"stream,omitempty"`
+	Data   string `json:"data,omitempty"`
+}
+
+type progressPrefixKey struct{}
+
+// WithProgressPrefix returns a context where the progress of solves is tagged
+// with a prefix, such as the target or import being solved.
+func WithProgressPrefix(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, progressPrefixKey{}, prefix)
+}
+
+func ProgressPrefix(ctx context.Context) string {
+	prefix, _ := ctx.Value(progressPrefixKey{}).(string)
+	return prefix
+}
+
+type vertexTagsKey struct{}
+
+// vertexTag is the prefixes and source location a vertex is tagged with.
+type vertexTag struct {
+	prefixes []string
+	location *diagnostic.Location
+
+	// defined is true if the vertex is tagged by a definition, so that the
+	// prefixes of the progress writers don't tag it as well.
+	defined bool
+}
+
+// addPrefix adds a prefix to the sorted prefixes of the tag, so that the tags
+// of a vertex shared by concurrent requests don't depend on their order.
+func (tag *vertexTag) addPrefix(prefix string) {
+	if prefix == "" {
+		return
+	}
+	i := sort.SearchStrings(tag.prefixes, prefix)
+	if i < len(tag.prefixes) && tag.prefixes[i] == prefix {
+		return
+	}
+	tag.prefixes = append(tag.prefixes, "")
+	copy(tag.prefixes[i+1:], tag.prefixes[i:])
+	tag.prefixes[i] = prefix
+}
+
+// vertexTags are the tags of the vertexes solved with a JSON progress output or
+// a timing report, as the vertexes in the status from BuildKit don't have a
+// source map.
+type vertexTags struct {
+	mu   sync.Mutex
+	tags map[digest.Digest]*vertexTag
+}
+
+func newVertexTags() *vertexTags {
+	return &vertexTags{tags: make(map[digest.Digest]*vertexTag)}
+}
+
+// get returns a copy of the tag of a vertex.
+func (vt *vertexTags) get(dgst digest.Digest) vertexTag {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	tag, ok := vt.tags[dgst]
+	if !ok {
+		return vertexTag{}
+	}
+	cp := *tag
+	cp.prefixes = append([]string(nil), tag.prefixes...)
+	return cp
+}
+
+// tag returns the tag of a vertex to be updated. The lock must be held.
+func (vt *vertexTags) tag(dgst digest.Digest) *vertexTag {
+	tag, ok := vt.tags[dgst]
+	if !ok {
+		tag = &vertexTag{}
+		vt.tags[dgst] = tag
+	}
+	return tag
+}
+
+// tagDefinition tags the vertexes of a definition with the prefix of the
+// context and their source locations.
+func tagDefinition(ctx context.Context, def *llb.Definition) {
+	vt, ok := ctx.Value(vertexTagsKey{}).(*vertexTags)
+	if !ok {
+		return
+	}
+
+	prefix := ProgressPrefix(ctx)
+
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	for _, dt := range def.Def {
+		dgst := digest.FromBytes(dt)
+		tag := vt.tag(dgst)
+		tag.defined = true
+		tag.addPrefix(prefix)
+		if tag.location != nil {
+			continue
+		}
+		if filename, r := opRange(def.Source, dgst); r != nil {
+			tag.location = &diagnostic.Location{
+				Filename: filename,
+				Start:    diagnostic.Position{Line: int(r.Start.Line), Column: int(r.Start.Character)},
+				End:      diagnostic.Position{Line: int(r.End.Line), Column: int(r.End.Character)},
+			}
+		}
+	}
+}
+
+// tagWriter returns a progress writer that tags the vertexes which aren't
+// tagged by a definition with the prefix of the context, such as the vertexes
+// of frontends.
+func tagWriter(ctx context.Context, pw progress.Writer) progress.Writer {
+	vt, ok := ctx.Value(vertexTagsKey{}).(*vertexTags)
+	if !ok {
+		return pw
+	}
+
+	prefix := ProgressPrefix(ctx)
+	tw := &tagged{Writer: pw, status: make(chan *client.SolveStatus)}
+	go func() {
+		for {
+			select {
+			case <-pw.Done():
+				return
+			case st, ok := <-tw.status:
+				if !ok {
+					close(pw.Status())
+					return
+				}
+				vt.mu.Lock()
+				for _, v := range st.Vertexes {
+					if tag := vt.tag(v.Digest); !tag.defined {
+						tag.addPrefix(prefix)
+					}
+				}
+				vt.mu.Unlock()
+				pw.Status() <- st
+			}
+		}
+	}()
+	return tw
+}
+
+type tagged struct {
+	progress.Writer
+	status chan *client.SolveStatus
+}
+
+func (t *tagged) Status() chan *client.SolveStatus {
+	return t.status
+}
+
+// jsonPrinter is a progress writer that writes a JSON progress event per line.
+type jsonPrinter struct {
+	status chan *client.SolveStatus
+	done   chan struct{}
+	err    error
+}
+
+func newJSONPrinter(w io.Writer, vt *vertexTags) progress.Writer {
+	p := &jsonPrinter{
+		status: make(chan *client.SolveStatus),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(p.done)
+
+		enc := json.NewEncoder(w)
+		for st := range p.status {
+			// Keep draining the statuses after an error, so that solves aren't
+			// blocked on the progress.
+			if p.err != nil {
+				continue
+			}
+			for _, event := range progressEvents(st, vt) {
+				if err := enc.Encode(event); err != nil {
+					p.err = err
+					break
+				}
+			}
+		}
+	}()
+	return p
+}
+
+func (p *jsonPrinter) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *jsonPrinter) Err() error {
+	return p.err
+}
+
+func (p *jsonPrinter) Status() chan *client.SolveStatus {
+	return p.status
+}
+
+// progressEvents returns the events of a solve status, tagged with the prefix
+// and location of their vertex.
+func progressEvents(st *client.SolveStatus, vt *vertexTags) []ProgressEvent {
+	var events []ProgressEvent
+	for _, v := range st.Vertexes {
+		tag := vt.get(v.Digest)
+		events = append(events, ProgressEvent{
+			Type:      "vertex",
+			Vertex:    v.Digest,
+			Prefixes:  tag.prefixes,
+			Location:  tag.location,
+			Name:      v.Name,
+			Inputs:    v.Inputs,
+			Started:   v.Started,
+			Completed: v.Completed,
+			Cached:    v.Cached,
+			Error:     v.Error,
+		})
+	}
+	for _, s := range st.Statuses {
+		tag := vt.get(s.Vertex)
+		timestamp := s.Timestamp
+		events = append(events, ProgressEvent{
+			Type:      "status",
+			Vertex:    s.Vertex,
+			Prefixes:  tag.prefixes,
+			Location:  tag.location,
+			Name:      s.Name,
+			Timestamp: &timestamp,
+			Started:   s.Started,
+			Completed: s.Completed,
+			ID:        s.ID,
+			Current:   s.Current,
+			Total:     s.Total,
+		})
+	}
+	for _, l := range st.Logs {
+		tag := vt.get(l.Vertex)
+		timestamp := l.Timestamp
+		events = append(events, ProgressEvent{
+			Type:      "log",
+			Vertex:    l.Vertex,
+			Prefixes:  tag.prefixes,
+			Location:  tag.location,
+			Timestamp: &timestamp,
+			Stream:    l.Stream,
+			Data:      string(l.Data),
+		})
+	}
+	return events
+}
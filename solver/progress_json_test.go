@@ -0,0 +1,189 @@
+package solver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/openllb/hlb/diagnostic"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressEvents(t *testing.T) {
+	t.Parallel()
+
+	var (
+		shared   = digest.FromString("shared")
+		frontend = digest.FromString("frontend")
+		untagged = digest.FromString("untagged")
+		now      = time.Unix(0, 0).UTC()
+		location = &diagnostic.Location{
+			Filename: "build.hlb",
+			Start:    diagnostic.Position{Line: 1, Column: 2},
+			End:      diagnostic.Position{Line: 1, Column: 8},
+		}
+	)
+
+	vt := newVertexTags()
+	vt.tags[shared] = &vertexTag{prefixes: []string{"a", "b"}, location: location, defined: true}
+	vt.tags[frontend] = &vertexTag{prefixes: []string{"a"}}
+
+	events := progressEvents(&client.SolveStatus{
+		Vertexes: []*client.Vertex{{
+			Digest:    shared,
+			Name:      "image alpine",
+			Started:   &now,
+			Completed: &now,
+			Cached:    true,
+		}, {
+			Digest:  untagged,
+			Inputs:  []digest.Digest{shared},
+			Started: &now,
+			Error:   "exit code: 1",
+		}},
+		Statuses: []*client.VertexStatus{{
+			ID:        "layer",
+			Vertex:    frontend,
+			Current:   1,
+			Total:     2,
+			Timestamp: now,
+		}},
+		Logs: []*client.VertexLog{{
+			Vertex:    shared,
+			Stream:    2,
+			Data:      []byte("hello"),
+			Timestamp: now,
+		}},
+	}, vt)
+
+	require.Equal(t, []ProgressEvent{{
+		Type:      "vertex",
+		Vertex:    shared,
+		Prefixes:  []string{"a", "b"},
+		Location:  location,
+		Name:      "image alpine",
+		Started:   &now,
+		Completed: &now,
+		Cached:    true,
+	}, {
+		Type:    "vertex",
+		Vertex:  untagged,
+		Inputs:  []digest.Digest{shared},
+		Started: &now,
+		Error:   "exit code: 1",
+	}, {
+		Type:      "status",
+		Vertex:    frontend,
+		Prefixes:  []string{"a"},
+		Timestamp: &now,
+		ID:        "layer",
+		Current:   1,
+		Total:     2,
+	}, {
+		Type:      "log",
+		Vertex:    shared,
+		Prefixes:  []string{"a", "b"},
+		Location:  location,
+		Timestamp: &now,
+		Stream:    2,
+		Data:      "hello",
+	}}, events)
+}
+
+func TestTagDefinitionShared(t *testing.T) {
+	t.Parallel()
+
+	vt := newVertexTags()
+	ctx := context.WithValue(context.Background(), vertexTagsKey{}, vt)
+
+	// The same definition solved by two targets is tagged with both, in the
+	// same order whichever target is solved first.
+	def := newRequest(t, "alpine").params.Def
+	tagDefinition(WithProgressPrefix(ctx, "b"), def)
+	tagDefinition(WithProgressPrefix(ctx, "a"), def)
+	tagDefinition(WithProgressPrefix(ctx, "b"), def)
+
+	for _, dt := range def.Def {
+		tag := vt.get(digest.FromBytes(dt))
+		require.Equal(t, []string{"a", "b"}, tag.prefixes)
+		require.True(t, tag.defined)
+	}
+}
+
+// writeStatus writes a status through a tag writer with a prefix into a JSON
+// printer and returns the events printed.
+func writeStatus(t *testing.T, vt *vertexTags, prefix string, st *client.SolveStatus) []ProgressEvent {
+	ctx := context.WithValue(context.Background(), vertexTagsKey{}, vt)
+	ctx = WithProgressPrefix(ctx, prefix)
+
+	var buf bytes.Buffer
+	pw := tagWriter(ctx, newJSONPrinter(&buf, vt))
+	pw.Status() <- st
+	close(pw.Status())
+	<-pw.Done()
+	require.NoError(t, pw.Err())
+
+	var events []ProgressEvent
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var event ProgressEvent
+		require.NoError(t, dec.Decode(&event))
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestTagWriter(t *testing.T) {
+	t.Parallel()
+
+	vt := newVertexTags()
+	def := newRequest(t, "alpine").params.Def
+	tagDefinition(context.WithValue(WithProgressPrefix(context.Background(), "a"), vertexTagsKey{}, vt), def)
+
+	defined := digest.FromBytes(def.Def[0])
+	frontend := digest.FromString("frontend")
+	st := &client.SolveStatus{
+		Vertexes: []*client.Vertex{{Digest: defined}, {Digest: frontend}},
+	}
+
+	// Vertexes tagged by a definition keep the prefixes of their definitions,
+	// other vertexes are tagged with the prefix of each writer they are
+	// written to.
+	events := writeStatus(t, vt, "b", st)
+	require.Len(t, events, 2)
+	require.Equal(t, []string{"a"}, events[0].Prefixes)
+	require.Equal(t, []string{"b"}, events[1].Prefixes)
+
+	events = writeStatus(t, vt, "c", st)
+	require.Len(t, events, 2)
+	require.Equal(t, []string{"a"}, events[0].Prefixes)
+	require.Equal(t, []string{"b", "c"}, events[1].Prefixes)
+}
+
+func TestPrefixedSharedVertex(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, NilRequest(), Prefixed("x", NilRequest()))
+
+	// Two targets solving the same vertex concurrently in a shared build both
+	// tag it.
+	a, b := newRequest(t, "alpine"), newRequest(t, "alpine")
+	req := Parallel(Prefixed("a", a), Prefixed("b", b))
+
+	vt := newVertexTags()
+	ctx := context.WithValue(context.Background(), vertexTagsKey{}, vt)
+	sb, err := newSharedBuild(ctx, nil, nil, req)
+	require.NoError(t, err)
+	sb.c = &recordClient{release: make(chan struct{})}
+
+	err = req.solve(ctx, sb)
+	require.NoError(t, err)
+
+	for _, dt := range a.params.Def.Def {
+		require.Equal(t, []string{"a", "b"}, vt.get(digest.FromBytes(dt)).prefixes)
+	}
+}
@@ -0,0 +1,324 @@
+package solver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/buildx/util/progress"
+	"github.com/moby/buildkit/client"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/openllb/hlb/diagnostic"
+)
+
+// NumSlowestOps is the number of slowest ops printed in a timing report.
+var NumSlowestOps = 10
+
+// Recorder records the vertexes of the solves of a progress for a timing
+// report.
+type Recorder struct {
+	mu       sync.Mutex
+	vertexes map[digest.Digest]*client.Vertex
+	order    []digest.Digest
+	tags     *vertexTags
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{vertexes: make(map[digest.Digest]*client.Vertex)}
+}
+
+// record merges the updates of vertexes in a status.
+func (r *Recorder) record(st *client.SolveStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range st.Vertexes {
+		rv, ok := r.vertexes[v.Digest]
+		if !ok {
+			rv = &client.Vertex{Digest: v.Digest}
+			r.vertexes[v.Digest] = rv
+			r.order = append(r.order, v.Digest)
+		}
+		if v.Name != "" {
+			rv.Name = v.Name
+		}
+		if len(v.Inputs) > 0 {
+			rv.Inputs = v.Inputs
+		}
+		if rv.Started == nil || (v.Started != nil && v.Started.Before(*rv.Started)) {
+			rv.Started = v.Started
+		}
+		if v.Completed != nil {
+			rv.Completed = v.Completed
+		}
+		rv.Cached = rv.Cached || v.Cached
+		if v.Error != "" {
+			rv.Error = v.Error
+		}
+	}
+}
+
+// recordWriter returns a progress writer that records statuses before they
+// are written to the progress writer.
+func recordWriter(pw progress.Writer, r *Recorder) progress.Writer {
+	rw := &recorded{Writer: pw, status: make(chan *client.SolveStatus)}
+	go func() {
+		for {
+			select {
+			case <-pw.Done():
+				return
+			case st, ok := <-rw.status:
+				if !ok {
+					close(pw.Status())
+					return
+				}
+				r.record(st)
+				pw.Status() <- st
+			}
+		}
+	}()
+	return rw
+}
+
+type recorded struct {
+	progress.Writer
+	status chan *client.SolveStatus
+}
+
+func (r *recorded) Status() chan *client.SolveStatus {
+	return r.status
+}
+
+// Report is a timing report of the ops solved by a build.
+type Report struct {
+	// Duration is the time from the first op started to the last op
+	// completed.
+	Duration time.Duration `json:"duration"`
+
+	// Ops are the ops that were started, from slowest to fastest.
+	Ops []ReportOp `json:"ops"`
+
+	// Targets are the cache hits of the ops of each target or import.
+	Targets []ReportTarget `json:"targets"`
+
+	// CriticalPath is the chain of ops through the graph with the longest
+	// total duration, from the first op to the last.
+	CriticalPath []ReportOp `json:"criticalPath"`
+
+	// CriticalPathDuration is the total duration of the critical path.
+	CriticalPathDuration time.Duration `json:"criticalPathDuration"`
+}
+
+// ReportOp is the timing of an op.
+type ReportOp struct {
+	Vertex   digest.Digest        `json:"vertex"`
+	Name     string               `json:"name"`
+	Prefixes []string             `json:"prefixes,omitempty"`
+	Location *diagnostic.Location `json:"location,omitempty"`
+	Duration time.Duration        `json:"duration"`
+	Cached   bool                 `json:"cached,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// ReportTarget is the cache hits of the ops of a target or import. An op shared
+// by several targets counts for each of them.
+type ReportTarget struct {
+	Prefix   string  `json:"prefix"`
+	Ops      int     `json:"ops"`
+	Cached   int     `json:"cached"`
+	HitRatio float64 `json:"hitRatio"`
+}
+
+// Report returns the timing report of the vertexes recorded so far.
+func (r *Recorder) Report() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		report   = &Report{Ops: []ReportOp{}, Targets: []ReportTarget{}, CriticalPath: []ReportOp{}}
+		ops      = make(map[digest.Digest]ReportOp)
+		targets  = make(map[string]*ReportTarget)
+		prefixes []string
+		first    *time.Time
+		last     *time.Time
+	)
+
+	for _, dgst := range r.order {
+		v := r.vertexes[dgst]
+		if v.Started == nil {
+			continue
+		}
+
+		op := ReportOp{
+			Vertex: v.Digest,
+			Name:   v.Name,
+			Cached: v.Cached,
+			Error:  v.Error,
+		}
+		if r.tags != nil {
+			tag := r.tags.get(v.Digest)
+			op.Prefixes, op.Location = tag.prefixes, tag.location
+		}
+		if v.Completed != nil {
+			op.Duration = v.Completed.Sub(*v.Started)
+			if last == nil || v.Completed.After(*last) {
+				last = v.Completed
+			}
+		}
+		if first == nil || v.Started.Before(*first) {
+			first = v.Started
+		}
+		ops[dgst] = op
+		report.Ops = append(report.Ops, op)
+
+		for _, prefix := range op.Prefixes {
+			target, ok := targets[prefix]
+			if !ok {
+				target = &ReportTarget{Prefix: prefix}
+				targets[prefix] = target
+				prefixes = append(prefixes, prefix)
+			}
+			target.Ops++
+			if op.Cached {
+				target.Cached++
+			}
+		}
+	}
+
+	if first != nil && last != nil {
+		report.Duration = last.Sub(*first)
+	}
+
+	sort.SliceStable(report.Ops, func(i, j int) bool {
+		return report.Ops[i].Duration > report.Ops[j].Duration
+	})
+
+	for _, prefix := range prefixes {
+		target := targets[prefix]
+		target.HitRatio = float64(target.Cached) / float64(target.Ops)
+		report.Targets = append(report.Targets, *target)
+	}
+
+	report.CriticalPath, report.CriticalPathDuration = r.criticalPath(ops)
+	return report
+}
+
+// criticalPath returns the chain of ops with the longest total duration,
+// following the inputs of each vertex.
+func (r *Recorder) criticalPath(ops map[digest.Digest]ReportOp) ([]ReportOp, time.Duration) {
+	var (
+		costs = make(map[digest.Digest]time.Duration)
+		next  = make(map[digest.Digest]digest.Digest)
+		cost  func(dgst digest.Digest) time.Duration
+	)
+
+	// The cost of an op is its duration plus the cost of its most expensive
+	// input, where next is the input on the critical path of the op.
+	cost = func(dgst digest.Digest) time.Duration {
+		if c, ok := costs[dgst]; ok {
+			return c
+		}
+		// Guard against cycles while the cost is being computed.
+		costs[dgst] = 0
+
+		var max time.Duration
+		for _, input := range r.vertexes[dgst].Inputs {
+			if _, ok := ops[input]; !ok {
+				continue
+			}
+			if c := cost(input); c > max || next[dgst] == "" {
+				max = c
+				next[dgst] = input
+			}
+		}
+
+		c := ops[dgst].Duration + max
+		costs[dgst] = c
+		return c
+	}
+
+	var (
+		end   digest.Digest
+		total time.Duration
+	)
+	// Ties end at the op recorded last, so that ops which are cached or never
+	// completed still end the path to the ops depending on them.
+	for _, dgst := range r.order {
+		if _, ok := ops[dgst]; !ok {
+			continue
+		}
+		if c := cost(dgst); end == "" || c >= total {
+			end, total = dgst, c
+		}
+	}
+
+	path := []ReportOp{}
+	for dgst := end; dgst != ""; dgst = next[dgst] {
+		path = append([]ReportOp{ops[dgst]}, path...)
+	}
+	return path, total
+}
+
+// WriteJSON writes the report as indented JSON.
+func (report *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// WriteText writes a summary of the report with the slowest ops, the cache
+// hits of each target and the critical path.
+func (report *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Build report (%s)\n", formatDuration(report.Duration))
+
+	if len(report.Ops) > 0 {
+		fmt.Fprintf(tw, "\nSlowest ops:\n")
+		for i, op := range report.Ops {
+			if i == NumSlowestOps {
+				break
+			}
+			writeReportOp(tw, op)
+		}
+	}
+
+	if len(report.Targets) > 0 {
+		fmt.Fprintf(tw, "\nCache hits:\n")
+		for _, target := range report.Targets {
+			fmt.Fprintf(tw, "  %s\t%d/%d\t(%.0f%%)\n", target.Prefix, target.Cached, target.Ops, target.HitRatio*100)
+		}
+	}
+
+	if len(report.CriticalPath) > 0 {
+		fmt.Fprintf(tw, "\nCritical path (%s):\n", formatDuration(report.CriticalPathDuration))
+		for _, op := range report.CriticalPath {
+			writeReportOp(tw, op)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func writeReportOp(w io.Writer, op ReportOp) {
+	var location string
+	if op.Location != nil {
+		location = fmt.Sprintf("%s:%d:%d", filepath.Base(op.Location.Filename), op.Location.Start.Line, op.Location.Start.Column)
+	}
+
+	duration := formatDuration(op.Duration)
+	if op.Cached {
+		duration = "CACHED"
+	}
+	fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", duration, strings.Join(op.Prefixes, ","), location, op.Name)
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
@@ -0,0 +1,169 @@
+package solver
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+var reportEpoch = time.Unix(0, 0).UTC()
+
+// reportVertex returns a vertex started and completed at seconds from the
+// epoch, which isn't completed if completed is negative.
+func reportVertex(name string, started, completed int, inputs ...string) *client.Vertex {
+	v := &client.Vertex{Digest: digest.FromString(name), Name: name}
+	for _, input := range inputs {
+		v.Inputs = append(v.Inputs, digest.FromString(input))
+	}
+	start := reportEpoch.Add(time.Duration(started) * time.Second)
+	v.Started = &start
+	if completed >= 0 {
+		end := reportEpoch.Add(time.Duration(completed) * time.Second)
+		v.Completed = &end
+	}
+	return v
+}
+
+func cachedVertex(v *client.Vertex) *client.Vertex {
+	v.Cached = true
+	return v
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name         string
+		vertexes     []*client.Vertex
+		prefixes     map[string][]string
+		duration     time.Duration
+		criticalPath []string
+		criticalCost time.Duration
+		targets      []ReportTarget
+	}{{
+		"diamond",
+		[]*client.Vertex{
+			reportVertex("a", 0, 1),
+			reportVertex("b", 1, 4, "a"),
+			reportVertex("c", 1, 2, "a"),
+			reportVertex("d", 4, 5, "b", "c"),
+		},
+		nil,
+		5 * time.Second,
+		[]string{"a", "b", "d"},
+		5 * time.Second,
+		[]ReportTarget{},
+	}, {
+		"cached ops",
+		[]*client.Vertex{
+			cachedVertex(reportVertex("a", 0, 0)),
+			reportVertex("b", 0, 2, "a"),
+			cachedVertex(reportVertex("c", 2, 2, "b")),
+		},
+		map[string][]string{"a": {"x"}, "b": {"x"}, "c": {"x"}},
+		2 * time.Second,
+		[]string{"a", "b", "c"},
+		2 * time.Second,
+		[]ReportTarget{{Prefix: "x", Ops: 3, Cached: 2, HitRatio: 2.0 / 3}},
+	}, {
+		"missing completed",
+		[]*client.Vertex{
+			reportVertex("a", 0, 3),
+			reportVertex("b", 3, -1, "a"),
+			{Digest: digest.FromString("c"), Name: "c"},
+		},
+		nil,
+		3 * time.Second,
+		[]string{"a", "b"},
+		3 * time.Second,
+		[]ReportTarget{},
+	}, {
+		"shared vertex across targets",
+		[]*client.Vertex{
+			cachedVertex(reportVertex("base", 0, 0)),
+			reportVertex("x", 0, 2, "base"),
+			reportVertex("y", 0, 1, "base"),
+		},
+		map[string][]string{"base": {"x", "y"}, "x": {"x"}, "y": {"y"}},
+		2 * time.Second,
+		[]string{"base", "x"},
+		2 * time.Second,
+		[]ReportTarget{
+			{Prefix: "x", Ops: 2, Cached: 1, HitRatio: 0.5},
+			{Prefix: "y", Ops: 2, Cached: 1, HitRatio: 0.5},
+		},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRecorder()
+			r.tags = newVertexTags()
+			for name, prefixes := range tc.prefixes {
+				r.tags.tags[digest.FromString(name)] = &vertexTag{prefixes: prefixes}
+			}
+			r.record(&client.SolveStatus{Vertexes: tc.vertexes})
+
+			report := r.Report()
+			require.Equal(t, tc.duration, report.Duration)
+			require.Equal(t, tc.targets, report.Targets)
+			require.Equal(t, tc.criticalCost, report.CriticalPathDuration)
+
+			var path []string
+			for _, op := range report.CriticalPath {
+				path = append(path, op.Name)
+			}
+			require.Equal(t, tc.criticalPath, path)
+
+			// Ops that never started are left out, the rest are sorted from
+			// slowest to fastest.
+			var started int
+			for _, v := range tc.vertexes {
+				if v.Started != nil {
+					started++
+				}
+			}
+			require.Len(t, report.Ops, started)
+			for i, op := range report.Ops {
+				if i > 0 {
+					require.True(t, report.Ops[i-1].Duration >= op.Duration)
+				}
+			}
+
+			var buf bytes.Buffer
+			require.NoError(t, report.WriteText(&buf))
+		})
+	}
+}
+
+func TestRecorderMerge(t *testing.T) {
+	t.Parallel()
+
+	r := NewRecorder()
+	first := reportVertex("a", 2, -1)
+	r.record(&client.SolveStatus{Vertexes: []*client.Vertex{first}})
+
+	// Updates of a vertex keep its earliest start, its latest completion and
+	// whether it was ever cached or failed, and fields left empty in an update
+	// keep their previous value.
+	update := reportVertex("a", 1, 4, "b")
+	update.Name = ""
+	update.Cached = true
+	r.record(&client.SolveStatus{Vertexes: []*client.Vertex{update}})
+	r.record(&client.SolveStatus{Vertexes: []*client.Vertex{{
+		Digest: first.Digest,
+		Error:  "canceled",
+	}}})
+
+	require.Equal(t, []digest.Digest{first.Digest}, r.order)
+
+	v := r.vertexes[first.Digest]
+	require.Equal(t, "a", v.Name)
+	require.Equal(t, []digest.Digest{digest.FromString("b")}, v.Inputs)
+	require.Equal(t, reportEpoch.Add(time.Second), *v.Started)
+	require.Equal(t, reportEpoch.Add(4*time.Second), *v.Completed)
+	require.True(t, v.Cached)
+	require.Equal(t, "canceled", v.Error)
+}
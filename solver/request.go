@@ -14,6 +14,7 @@ import (
 	"github.com/moby/buildkit/solver/pb"
 	digest "github.com/opencontainers/go-digest"
 	"github.com/openllb/hlb/pkg/llbutil"
+	"github.com/openllb/hlb/pkg/tracing"
 	"github.com/xlab/treeprint"
 	"golang.org/x/sync/errgroup"
 )
@@ -31,9 +32,28 @@ type Request interface {
 	// Solve sends the request and its children to BuildKit. The request passes
 	// down the progress.MultiWriter for them to spawn their own progress writers
 	// for each independent solve.
+	//
+	// Requests with children are solved in a single session and gateway build
+	// shared by the tree, except for the requests whose exports conflict with
+	// the build.
 	Solve(ctx context.Context, cln *client.Client, mw *progress.MultiWriter) error
 
 	Tree(tree treeprint.Tree) error
+
+	// LLB returns the LLB definitions of the request and its children in the
+	// same shape as the request tree.
+	LLB() (*LLB, error)
+
+	// solve sends the request and its children to BuildKit within a shared
+	// build.
+	solve(ctx context.Context, b *sharedBuild) error
+
+	// leaves returns the single requests of the request tree.
+	leaves() []*singleRequest
+
+	// last returns the single requests of the request tree that no other
+	// request is sequenced after.
+	last() []*singleRequest
 }
 
 type nilRequest struct{}
@@ -46,10 +66,26 @@ func (r *nilRequest) Solve(ctx context.Context, cln *client.Client, mw *progress
 	return nil
 }
 
+func (r *nilRequest) solve(ctx context.Context, b *sharedBuild) error {
+	return nil
+}
+
+func (r *nilRequest) leaves() []*singleRequest {
+	return nil
+}
+
+func (r *nilRequest) last() []*singleRequest {
+	return nil
+}
+
 func (r *nilRequest) Tree(tree treeprint.Tree) error {
 	return nil
 }
 
+func (r *nilRequest) LLB() (*LLB, error) {
+	return &LLB{}, nil
+}
+
 type Params struct {
 	Def         *llb.Definition
 	SolveOpts   []SolveOption
@@ -65,13 +101,26 @@ func Single(params *Params) Request {
 	return &singleRequest{params: params}
 }
 
-func (r *singleRequest) Solve(ctx context.Context, cln *client.Client, mw *progress.MultiWriter) error {
+func (r *singleRequest) Solve(ctx context.Context, cln *client.Client, mw *progress.MultiWriter) (err error) {
+	ctx, span := startSpan(ctx, "solver.Solve", tracing.Int("llb.ops", len(r.params.Def.Def)))
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	var pw progress.Writer
 	if mw != nil {
 		pw = mw.WithPrefix("", false)
 	}
 
-	s, err := llbutil.NewSession(ctx, r.params.SessionOpts...)
+	opts := append(append([]SolveOption{}, SolveOptions(ctx)...), r.params.SolveOpts...)
+
+	cacheOpts, err := CacheSessionOptions(opts...)
+	if err != nil {
+		return err
+	}
+
+	s, err := llbutil.NewSession(ctx, append(append([]llbutil.SessionOption{}, r.params.SessionOpts...), cacheOpts...)...)
 	if err != nil {
 		return err
 	}
@@ -83,16 +132,35 @@ func (r *singleRequest) Solve(ctx context.Context, cln *client.Client, mw *progr
 	})
 
 	g.Go(func() error {
-		return Solve(ctx, cln, s, pw, r.params.Def, r.params.SolveOpts...)
+		return Solve(ctx, cln, s, pw, r.params.Def, opts...)
 	})
 
 	return g.Wait()
 }
 
+func (r *singleRequest) solve(ctx context.Context, b *sharedBuild) error {
+	if b.shared(r) {
+		return b.solve(ctx, r)
+	}
+	return r.Solve(withCacheTag(ctx, b.cacheTags[r]), b.cln, b.mw)
+}
+
+func (r *singleRequest) leaves() []*singleRequest {
+	return []*singleRequest{r}
+}
+
+func (r *singleRequest) last() []*singleRequest {
+	return []*singleRequest{r}
+}
+
 func (r *singleRequest) Tree(tree treeprint.Tree) error {
 	return treeFromDefinition(tree, r.params.Def, r.params.SolveOpts)
 }
 
+func (r *singleRequest) LLB() (*LLB, error) {
+	return newLLBFromDefinition(r.params.Def, r.params.SolveOpts)
+}
+
 func treeFromDefinition(tree treeprint.Tree, def *llb.Definition, opts []SolveOption) error {
 	var info SolveInfo
 	for _, opt := range opts {
@@ -268,6 +336,44 @@ func (o op) Tree(tree treeprint.Tree) error {
 	return nil
 }
 
+type prefixRequest struct {
+	prefix string
+	req    Request
+}
+
+// Prefixed returns a request that tags the progress of the request and its
+// children with a prefix, such as the target being solved.
+func Prefixed(prefix string, req Request) Request {
+	if _, ok := req.(*nilRequest); ok {
+		return req
+	}
+	return &prefixRequest{prefix: prefix, req: req}
+}
+
+func (r *prefixRequest) Solve(ctx context.Context, cln *client.Client, mw *progress.MultiWriter) error {
+	return r.req.Solve(WithProgressPrefix(ctx, r.prefix), cln, mw)
+}
+
+func (r *prefixRequest) solve(ctx context.Context, b *sharedBuild) error {
+	return r.req.solve(WithProgressPrefix(ctx, r.prefix), b)
+}
+
+func (r *prefixRequest) leaves() []*singleRequest {
+	return r.req.leaves()
+}
+
+func (r *prefixRequest) last() []*singleRequest {
+	return r.req.last()
+}
+
+func (r *prefixRequest) Tree(tree treeprint.Tree) error {
+	return r.req.Tree(tree)
+}
+
+func (r *prefixRequest) LLB() (*LLB, error) {
+	return r.req.LLB()
+}
+
 type parallelRequest struct {
 	reqs []Request
 }
@@ -293,16 +399,42 @@ func Parallel(candidates ...Request) Request {
 }
 
 func (r *parallelRequest) Solve(ctx context.Context, cln *client.Client, mw *progress.MultiWriter) error {
+	return solveShared(ctx, cln, mw, r)
+}
+
+func (r *parallelRequest) solve(ctx context.Context, b *sharedBuild) (err error) {
+	ctx, span := startSpan(ctx, "solver.Parallel")
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	g, ctx := errgroup.WithContext(ctx)
 	for _, req := range r.reqs {
 		req := req
 		g.Go(func() error {
-			return req.Solve(ctx, cln, mw)
+			return req.solve(ctx, b)
 		})
 	}
 	return g.Wait()
 }
 
+func (r *parallelRequest) leaves() []*singleRequest {
+	var leaves []*singleRequest
+	for _, req := range r.reqs {
+		leaves = append(leaves, req.leaves()...)
+	}
+	return leaves
+}
+
+func (r *parallelRequest) last() []*singleRequest {
+	var last []*singleRequest
+	for _, req := range r.reqs {
+		last = append(last, req.last()...)
+	}
+	return last
+}
+
 func (r *parallelRequest) Tree(tree treeprint.Tree) error {
 	branch := tree.AddBranch("parallel")
 	for _, req := range r.reqs {
@@ -314,6 +446,18 @@ func (r *parallelRequest) Tree(tree treeprint.Tree) error {
 	return nil
 }
 
+func (r *parallelRequest) LLB() (*LLB, error) {
+	l := &LLB{Parallel: []*LLB{}}
+	for _, req := range r.reqs {
+		child, err := req.LLB()
+		if err != nil {
+			return nil, err
+		}
+		l.Parallel = append(l.Parallel, child)
+	}
+	return l, nil
+}
+
 type sequentialRequest struct {
 	reqs []Request
 }
@@ -339,8 +483,18 @@ func Sequential(candidates ...Request) Request {
 }
 
 func (r *sequentialRequest) Solve(ctx context.Context, cln *client.Client, mw *progress.MultiWriter) error {
+	return solveShared(ctx, cln, mw, r)
+}
+
+func (r *sequentialRequest) solve(ctx context.Context, b *sharedBuild) (err error) {
+	ctx, span := startSpan(ctx, "solver.Sequential")
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	for _, req := range r.reqs {
-		err := req.Solve(ctx, cln, mw)
+		err := req.solve(ctx, b)
 		if err != nil {
 			return err
 		}
@@ -348,6 +502,18 @@ func (r *sequentialRequest) Solve(ctx context.Context, cln *client.Client, mw *p
 	return nil
 }
 
+func (r *sequentialRequest) leaves() []*singleRequest {
+	var leaves []*singleRequest
+	for _, req := range r.reqs {
+		leaves = append(leaves, req.leaves()...)
+	}
+	return leaves
+}
+
+func (r *sequentialRequest) last() []*singleRequest {
+	return r.reqs[len(r.reqs)-1].last()
+}
+
 func (r *sequentialRequest) Tree(tree treeprint.Tree) error {
 	branch := tree.AddBranch("sequential")
 	for _, req := range r.reqs {
@@ -358,3 +524,24 @@ func (r *sequentialRequest) Tree(tree treeprint.Tree) error {
 	}
 	return nil
 }
+
+func (r *sequentialRequest) LLB() (*LLB, error) {
+	l := &LLB{Sequential: []*LLB{}}
+	for _, req := range r.reqs {
+		child, err := req.LLB()
+		if err != nil {
+			return nil, err
+		}
+		l.Sequential = append(l.Sequential, child)
+	}
+	return l, nil
+}
+
+// startSpan starts a span for solving a request, tagged with the prefix of the
+// progress such as the target or import being solved.
+func startSpan(ctx context.Context, name string, attrs ...tracing.Attribute) (context.Context, *tracing.Span) {
+	if prefix := ProgressPrefix(ctx); prefix != "" {
+		attrs = append(attrs, tracing.String("hlb.prefix", prefix))
+	}
+	return tracing.Start(ctx, name, attrs...)
+}
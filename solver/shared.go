@@ -0,0 +1,221 @@
+package solver
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/docker/buildx/util/progress"
+	"github.com/moby/buildkit/client"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/openllb/hlb/pkg/llbutil"
+	"github.com/openllb/hlb/pkg/tracing"
+	"golang.org/x/sync/errgroup"
+)
+
+// sharedBuild is a session and gateway build shared by the requests of a
+// request tree, so that local files are only synced once and the requests
+// share the results of the gateway.
+//
+// A build only exports the result it returns once it is done, so only one of
+// the requests with exports can share a build, and only if no other request
+// is sequenced after it. The exports of the other requests conflict with the
+// build and they fall back to separate builds.
+type sharedBuild struct {
+	cln *client.Client
+	mw  *progress.MultiWriter
+
+	// opts and sessionOpts are the options of the requests sharing the build.
+	opts        []SolveOption
+	sessionOpts []llbutil.SessionOption
+
+	// c is the gateway client of the build, which is nil if none of the
+	// requests can share the build.
+	c      gateway.Client
+	shares map[*singleRequest]bool
+
+	// export is the request whose exports are the exports of the build, which
+	// is nil if none of the requests sharing the build have exports.
+	export *singleRequest
+
+	// cacheTags are the tags of the local caches exported by the separate
+	// builds of the requests that don't share the build.
+	cacheTags map[*singleRequest]string
+
+	mu       sync.Mutex
+	res      *gateway.Result
+	exported *gateway.Result
+}
+
+// newSharedBuild returns a build for the requests of a request tree that can
+// share it.
+func newSharedBuild(ctx context.Context, cln *client.Client, mw *progress.MultiWriter, r Request) (*sharedBuild, error) {
+	b := &sharedBuild{
+		cln:       cln,
+		mw:        mw,
+		opts:      append([]SolveOption{}, SolveOptions(ctx)...),
+		shares:    make(map[*singleRequest]bool),
+		cacheTags: make(map[*singleRequest]string),
+		res:       gateway.NewResult(),
+	}
+
+	global := &SolveInfo{}
+	for _, opt := range b.opts {
+		err := opt(global)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	last := make(map[*singleRequest]bool)
+	for _, leaf := range r.last() {
+		last[leaf] = true
+	}
+
+	for i, leaf := range r.leaves() {
+		b.cacheTags[leaf] = cacheTagFor(i)
+
+		info := &SolveInfo{}
+		for _, opt := range leaf.params.SolveOpts {
+			err := opt(info)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if info.hasExports() {
+			// Only the result of the exporting request is returned by the build,
+			// so the caches exported for the build would miss the other requests.
+			if b.export != nil || !last[leaf] || len(global.CacheExports) > 0 {
+				continue
+			}
+			b.export = leaf
+		}
+
+		b.shares[leaf] = true
+		b.opts = append(b.opts, leaf.params.SolveOpts...)
+		b.sessionOpts = append(b.sessionOpts, leaf.params.SessionOpts...)
+	}
+
+	return b, nil
+}
+
+// solveShared solves a request tree in a shared build.
+func solveShared(ctx context.Context, cln *client.Client, mw *progress.MultiWriter, r Request) (err error) {
+	ctx, span := startSpan(ctx, "solver.Build")
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	b, err := newSharedBuild(ctx, cln, mw, r)
+	if err != nil {
+		return err
+	}
+
+	if len(b.shares) == 0 {
+		return r.solve(ctx, b)
+	}
+
+	cacheOpts, err := CacheSessionOptions(b.opts...)
+	if err != nil {
+		return err
+	}
+
+	s, err := llbutil.NewSession(ctx, append(b.sessionOpts, cacheOpts...)...)
+	if err != nil {
+		return err
+	}
+
+	var pw progress.Writer
+	if mw != nil {
+		pw = mw.WithPrefix("", false)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		return s.Run(ctx, cln.Dialer())
+	})
+
+	g.Go(func() error {
+		// Callbacks of the requests are called once the shared build is done.
+		return Build(ctx, cln, s, pw, func(ctx context.Context, c gateway.Client) (*gateway.Result, error) {
+			b.c = c
+			err := r.solve(ctx, b)
+			if err != nil {
+				return nil, err
+			}
+			return b.result(), nil
+		}, b.opts...)
+	})
+
+	return g.Wait()
+}
+
+// shared returns true if the request is solved in the shared build.
+func (b *sharedBuild) shared(r *singleRequest) bool {
+	return b.c != nil && b.shares[r]
+}
+
+// result returns the result of the build. It is the result of the exporting
+// request if there is one, otherwise it has the results of every request so
+// that caches are exported for every request.
+func (b *sharedBuild) result() *gateway.Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.exported != nil {
+		return b.exported
+	}
+	return b.res
+}
+
+// solve evaluates a request with the gateway of the shared build.
+func (b *sharedBuild) solve(ctx context.Context, r *singleRequest) (err error) {
+	ctx, span := startSpan(ctx, "solver.Solve",
+		tracing.Int("llb.ops", len(r.params.Def.Def)),
+		tracing.Bool("hlb.shared", true),
+	)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	info := &SolveInfo{}
+	for _, opt := range append(append([]SolveOption{}, SolveOptions(ctx)...), r.params.SolveOpts...) {
+		err := opt(info)
+		if err != nil {
+			return err
+		}
+	}
+
+	tagDefinition(ctx, r.params.Def)
+
+	res, err := b.c.Solve(ctx, gateway.SolveRequest{
+		Evaluate:     true,
+		Definition:   r.params.Def.ToPB(),
+		CacheImports: gatewayCacheImports(info.CacheImports),
+	})
+	if err != nil {
+		if info.ErrorHandler != nil {
+			info.ErrorHandler(ctx, b.c, err)
+		}
+		return err
+	}
+
+	ref, err := res.SingleRef()
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ref != nil {
+		b.res.AddRef(strconv.Itoa(len(b.res.Refs)), ref)
+	}
+	if r == b.export {
+		b.exported = res
+		return addImageConfig(res, info)
+	}
+	return nil
+}
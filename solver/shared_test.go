@@ -0,0 +1,214 @@
+package solver
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func newRequest(t *testing.T, ref string, opts ...SolveOption) *singleRequest {
+	def, err := llb.Image(ref).Marshal(context.Background(), llb.LinuxAmd64)
+	require.NoError(t, err)
+	return &singleRequest{params: &Params{Def: def, SolveOpts: opts}}
+}
+
+func TestRequestLeaves(t *testing.T) {
+	t.Parallel()
+
+	a, b, c := newRequest(t, "a"), newRequest(t, "b"), newRequest(t, "c")
+	for _, tc := range []struct {
+		name   string
+		req    Request
+		leaves []*singleRequest
+		last   []*singleRequest
+	}{{
+		"nil",
+		NilRequest(),
+		nil,
+		nil,
+	}, {
+		"single",
+		a,
+		[]*singleRequest{a},
+		[]*singleRequest{a},
+	}, {
+		"parallel",
+		Parallel(a, b),
+		[]*singleRequest{a, b},
+		[]*singleRequest{a, b},
+	}, {
+		"sequential",
+		Sequential(a, b),
+		[]*singleRequest{a, b},
+		[]*singleRequest{b},
+	}, {
+		"sequential in parallel",
+		Parallel(a, Sequential(b, c)),
+		[]*singleRequest{a, b, c},
+		[]*singleRequest{a, c},
+	}, {
+		"parallel in sequential",
+		Sequential(Parallel(a, b), c),
+		[]*singleRequest{a, b, c},
+		[]*singleRequest{c},
+	}, {
+		"prefixed",
+		Sequential(Prefixed("x", Parallel(a, b)), Prefixed("y", c)),
+		[]*singleRequest{a, b, c},
+		[]*singleRequest{c},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.leaves, tc.req.leaves())
+			require.Equal(t, tc.last, tc.req.last())
+		})
+	}
+}
+
+func TestSharedBuildExports(t *testing.T) {
+	t.Parallel()
+
+	a, b := newRequest(t, "a"), newRequest(t, "b")
+	exportA := newRequest(t, "a", WithDownload("a"))
+	exportB := newRequest(t, "b", WithPushImage("b"))
+	cacheTo := WithCacheExport(client.CacheOptionsEntry{
+		Type:  "local",
+		Attrs: map[string]string{"dest": "cache"},
+	})
+	cacheA := newRequest(t, "a", cacheTo)
+
+	for _, tc := range []struct {
+		name   string
+		opts   []SolveOption
+		req    Request
+		shares []*singleRequest
+		export *singleRequest
+	}{{
+		"without exports",
+		nil,
+		Sequential(a, b),
+		[]*singleRequest{a, b},
+		nil,
+	}, {
+		"export in parallel",
+		nil,
+		Parallel(exportA, b),
+		[]*singleRequest{exportA, b},
+		exportA,
+	}, {
+		"export followed by a request",
+		nil,
+		Sequential(exportA, b),
+		[]*singleRequest{b},
+		nil,
+	}, {
+		"export following a request",
+		nil,
+		Sequential(a, exportB),
+		[]*singleRequest{a, exportB},
+		exportB,
+	}, {
+		"conflicting exports",
+		nil,
+		Parallel(exportA, exportB),
+		[]*singleRequest{exportA},
+		exportA,
+	}, {
+		"export with cache exports",
+		[]SolveOption{cacheTo},
+		Parallel(exportA, b),
+		[]*singleRequest{b},
+		nil,
+	}, {
+		"request with cache exports",
+		nil,
+		Parallel(cacheA, exportB),
+		[]*singleRequest{cacheA},
+		cacheA,
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := WithSolveOptions(context.Background(), tc.opts...)
+			sb, err := newSharedBuild(ctx, nil, nil, tc.req)
+			require.NoError(t, err)
+
+			var shares []*singleRequest
+			for _, leaf := range tc.req.leaves() {
+				if sb.shares[leaf] {
+					shares = append(shares, leaf)
+				}
+			}
+			require.ElementsMatch(t, tc.shares, shares)
+			require.Equal(t, tc.export, sb.export)
+		})
+	}
+}
+
+// recordClient is a gateway client that records the order of the solves. The
+// solve of a definition is held until another definition is solved.
+type recordClient struct {
+	gateway.Client
+	hold, until digest.Digest
+	release     chan struct{}
+
+	mu     sync.Mutex
+	solves []digest.Digest
+}
+
+type fakeRef struct {
+	gateway.Reference
+	dgst digest.Digest
+}
+
+func (c *recordClient) Solve(ctx context.Context, req gateway.SolveRequest) (*gateway.Result, error) {
+	dgst := digest.FromBytes(req.Definition.Def[len(req.Definition.Def)-1])
+	if dgst == c.hold {
+		<-c.release
+	}
+
+	c.mu.Lock()
+	c.solves = append(c.solves, dgst)
+	c.mu.Unlock()
+
+	if dgst == c.until {
+		close(c.release)
+	}
+
+	res := gateway.NewResult()
+	res.SetRef(&fakeRef{dgst: dgst})
+	return res, nil
+}
+
+func TestSharedBuildSolve(t *testing.T) {
+	t.Parallel()
+
+	a, b, c := newRequest(t, "a"), newRequest(t, "b"), newRequest(t, "c", WithDownload("c"))
+	id := func(r *singleRequest) digest.Digest {
+		return digest.FromBytes(r.params.Def.Def[len(r.params.Def.Def)-1])
+	}
+
+	req := Sequential(Parallel(a, Prefixed("b", b)), c)
+	sb, err := newSharedBuild(context.Background(), nil, nil, req)
+	require.NoError(t, err)
+
+	// Holding a until b is solved only completes if the requests in parallel
+	// are solved concurrently.
+	cln := &recordClient{hold: id(a), until: id(b), release: make(chan struct{})}
+	sb.c = cln
+
+	err = req.solve(context.Background(), sb)
+	require.NoError(t, err)
+	require.Equal(t, []digest.Digest{id(b), id(a), id(c)}, cln.solves)
+
+	// The build returns the result of the exporting request.
+	require.Len(t, sb.res.Refs, 3)
+	ref, err := sb.result().SingleRef()
+	require.NoError(t, err)
+	require.Equal(t, id(c), ref.(*fakeRef).dgst)
+}
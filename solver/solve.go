@@ -19,6 +19,10 @@ type SolveOption func(*SolveInfo) error
 
 type SolveCallback func(ctx context.Context, resp *client.SolveResponse) error
 
+// ErrorHandler is called when a solve fails, before the results of the failed
+// solve are released by the gateway.
+type ErrorHandler func(ctx context.Context, c gateway.Client, err error)
+
 type SolveInfo struct {
 	OutputDockerRef       string
 	OutputPushImage       string
@@ -26,8 +30,21 @@ type SolveInfo struct {
 	OutputLocalTarball    bool
 	OutputLocalOCITarball bool
 	Callbacks             []SolveCallback `json:"-"`
+	ErrorHandler          ErrorHandler    `json:"-"`
 	ImageSpec             *specs.Image
 	Entitlements          []entitlements.Entitlement
+	CacheExports          []client.CacheOptionsEntry
+	CacheImports          []client.CacheOptionsEntry
+}
+
+// hasExports returns true if the solve exports its result.
+func (info *SolveInfo) hasExports() bool {
+	return info.OutputDockerRef != "" ||
+		info.OutputPushImage != "" ||
+		info.OutputLocal != "" ||
+		info.OutputLocalTarball ||
+		info.OutputLocalOCITarball ||
+		len(info.CacheExports) > 0
 }
 
 func WithDownloadDockerTarball(ref string) SolveOption {
@@ -72,6 +89,13 @@ func WithCallback(fn SolveCallback) SolveOption {
 	}
 }
 
+func WithErrorHandler(fn ErrorHandler) SolveOption {
+	return func(info *SolveInfo) error {
+		info.ErrorHandler = fn
+		return nil
+	}
+}
+
 func WithImageSpec(cfg *specs.Image) SolveOption {
 	return func(info *SolveInfo) error {
 		info.ImageSpec = cfg
@@ -86,6 +110,30 @@ func WithEntitlement(e entitlements.Entitlement) SolveOption {
 	}
 }
 
+func WithCacheExport(entry client.CacheOptionsEntry) SolveOption {
+	return func(info *SolveInfo) error {
+		info.CacheExports = append(info.CacheExports, copyCacheEntry(entry))
+		return nil
+	}
+}
+
+func WithCacheImport(entry client.CacheOptionsEntry) SolveOption {
+	return func(info *SolveInfo) error {
+		info.CacheImports = append(info.CacheImports, copyCacheEntry(entry))
+		return nil
+	}
+}
+
+// copyCacheEntry copies the attributes of a cache entry, as BuildKit writes to
+// them and options may be applied to more than one solve.
+func copyCacheEntry(entry client.CacheOptionsEntry) client.CacheOptionsEntry {
+	attrs := make(map[string]string, len(entry.Attrs))
+	for k, v := range entry.Attrs {
+		attrs[k] = v
+	}
+	return client.CacheOptionsEntry{Type: entry.Type, Attrs: attrs}
+}
+
 func Solve(ctx context.Context, c *client.Client, s *session.Session, pw progress.Writer, def *llb.Definition, opts ...SolveOption) error {
 	info := &SolveInfo{}
 	for _, opt := range opts {
@@ -95,24 +143,47 @@ func Solve(ctx context.Context, c *client.Client, s *session.Session, pw progres
 		}
 	}
 
-	return Build(ctx, c, s, pw, func(ctx context.Context, c gateway.Client) (*gateway.Result, error) {
+	tagDefinition(ctx, def)
+
+	return Build(ctx, c, s, pw, solveFunc(def, info), opts...)
+}
+
+// solveFunc returns a build function solving a definition. Gateway solves are
+// lazy, so with an error handler the definition is evaluated for errors to
+// surface inside the build where the handler can still use the gateway client.
+// Otherwise, the solve is left lazy so that exporters only solve what they use.
+func solveFunc(def *llb.Definition, info *SolveInfo) gateway.BuildFunc {
+	return func(ctx context.Context, c gateway.Client) (*gateway.Result, error) {
 		res, err := c.Solve(ctx, gateway.SolveRequest{
-			Definition: def.ToPB(),
+			Evaluate:     info.ErrorHandler != nil,
+			Definition:   def.ToPB(),
+			CacheImports: gatewayCacheImports(info.CacheImports),
 		})
 		if err != nil {
+			if info.ErrorHandler != nil {
+				info.ErrorHandler(ctx, c, err)
+			}
 			return nil, err
 		}
 
-		if _, ok := res.Metadata[exptypes.ExporterImageConfigKey]; !ok && info.ImageSpec != nil {
-			config, err := json.Marshal(info.ImageSpec)
-			if err != nil {
-				return nil, err
-			}
+		return res, addImageConfig(res, info)
+	}
+}
 
-			res.AddMeta(exptypes.ExporterImageConfigKey, config)
-		}
-		return res, nil
-	}, opts...)
+// addImageConfig adds the image config of the solve to the metadata of its
+// result for exporters, unless the result already has one.
+func addImageConfig(res *gateway.Result, info *SolveInfo) error {
+	if _, ok := res.Metadata[exptypes.ExporterImageConfigKey]; ok || info.ImageSpec == nil {
+		return nil
+	}
+
+	config, err := json.Marshal(info.ImageSpec)
+	if err != nil {
+		return err
+	}
+
+	res.AddMeta(exptypes.ExporterImageConfigKey, config)
+	return nil
 }
 
 func Build(ctx context.Context, c *client.Client, s *session.Session, pw progress.Writer, f gateway.BuildFunc, opts ...SolveOption) error {
@@ -128,6 +199,8 @@ func Build(ctx context.Context, c *client.Client, s *session.Session, pw progres
 		SharedSession:         s,
 		SessionPreInitialized: s != nil,
 		AllowedEntitlements:   info.Entitlements,
+		CacheExports:          info.CacheExports,
+		CacheImports:          info.CacheImports,
 	}
 
 	if info.OutputDockerRef != "" {
@@ -173,7 +246,7 @@ func Build(ctx context.Context, c *client.Client, s *session.Session, pw progres
 		resp     *client.SolveResponse
 	)
 	if pw != nil {
-		pw = progress.ResetTime(pw)
+		pw = tagWriter(ctx, progress.ResetTime(pw))
 		statusCh = pw.Status()
 	}
 
@@ -182,6 +255,11 @@ func Build(ctx context.Context, c *client.Client, s *session.Session, pw progres
 		return err
 	}
 
+	err = tagCacheExports(ctx, info.CacheExports, resp)
+	if err != nil {
+		return err
+	}
+
 	g, ctx := errgroup.WithContext(ctx)
 
 	for _, fn := range info.Callbacks {
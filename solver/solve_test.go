@@ -0,0 +1,59 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/stretchr/testify/require"
+)
+
+// lazyClient is a gateway client that only fails solves that are evaluated,
+// like the gateway of BuildKit which defers solving until a result is used.
+type lazyClient struct {
+	gateway.Client
+	err error
+}
+
+func (c *lazyClient) Solve(ctx context.Context, req gateway.SolveRequest) (*gateway.Result, error) {
+	if req.Evaluate {
+		return nil, c.err
+	}
+	return gateway.NewResult(), nil
+}
+
+func TestSolveErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	def, err := llb.Image("alpine").Run(llb.Shlex("false")).Root().Marshal(ctx, llb.LinuxAmd64)
+	require.NoError(t, err)
+
+	var handled error
+	info := &SolveInfo{}
+	err = WithErrorHandler(func(ctx context.Context, c gateway.Client, err error) {
+		handled = err
+	})(info)
+	require.NoError(t, err)
+
+	c := &lazyClient{err: errors.New("exit code: 1")}
+	_, err = solveFunc(def, info)(ctx, c)
+	require.Equal(t, c.err, err)
+	require.Equal(t, c.err, handled)
+}
+
+func TestSolveWithoutErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	def, err := llb.Image("alpine").Run(llb.Shlex("false")).Root().Marshal(ctx, llb.LinuxAmd64)
+	require.NoError(t, err)
+
+	// Without an error handler, the solve is left for the exporter to evaluate.
+	c := &lazyClient{err: errors.New("exit code: 1")}
+	res, err := solveFunc(def, &SolveInfo{})(ctx, c)
+	require.NoError(t, err)
+	require.NotNil(t, res)
+}